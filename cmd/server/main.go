@@ -37,9 +37,25 @@ func main() {
 		log.Printf("Admin bootstrap warning: %v", err)
 	}
 
+	if posted, err := service.BackfillOrderLedger(repository.NewOrderRepository(db)); err != nil {
+		log.Printf("Ledger backfill warning: %v", err)
+	} else if posted > 0 {
+		log.Printf("Ledger backfill posted %d orders", posted)
+	}
+
 	// Setup routes
 	router := api.SetupRoutes()
 
+	// Background jobs stop when the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	harvestSweeper := service.NewHarvestRequestSweeper(repository.NewOrderRepository(db), service.NewEmailService())
+	go harvestSweeper.Run(jobsCtx, 15*time.Minute)
+	creditSweeper := service.NewCreditReminderSweeper(repository.NewOrderRepository(db), service.NewEmailService())
+	go creditSweeper.Run(jobsCtx, time.Hour)
+	expirySweeper := service.NewListingExpirySweeper(repository.NewProductRepository(db), service.NewEmailService())
+	go expirySweeper.Run(jobsCtx, time.Hour)
+
 	// Create HTTP server
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -64,6 +80,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopJobs()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
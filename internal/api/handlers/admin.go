@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/f2b-portal/backend/internal/service"
 	"github.com/gin-gonic/gin"
@@ -64,7 +67,12 @@ func (h *AdminHandler) GetHarvestRequests(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch harvest requests"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": items})
+	metrics, err := h.adminService.GetHarvestResponseMetrics()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch harvest response metrics"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "response_metrics": metrics})
 }
 
 func (h *AdminHandler) UpdateUserStatus(c *gin.Context) {
@@ -219,3 +227,558 @@ func (h *AdminHandler) GetTransactionInvoice(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"invoice": invoice})
 }
+
+func (h *AdminHandler) DownloadTransactionInvoicePDF(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	content, filename, err := h.adminService.RenderTransactionInvoicePDF(uint(orderID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	c.Data(http.StatusOK, "application/pdf", content)
+}
+
+func (h *AdminHandler) GetEscalatedDisputes(c *gin.Context) {
+	orders, err := h.adminService.GetEscalatedDisputes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load disputes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"disputes": orders})
+}
+
+func (h *AdminHandler) DecideDispute(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req service.DisputeDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.adminService.DecideDispute(uint(orderID), adminID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Dispute decision recorded", "order": order})
+}
+
+func (h *AdminHandler) GetLedgerBalances(c *gin.Context) {
+	balances, err := h.adminService.GetLedgerBalances()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load ledger balances"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"balances": balances})
+}
+
+func (h *AdminHandler) RecordFarmerPayout(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req service.FarmerPayoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	statement, err := h.adminService.RecordFarmerPayout(adminID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Payout recorded", "ledger": statement})
+}
+
+func (h *AdminHandler) GetPayoutAccounts(c *gin.Context) {
+	accounts, err := h.adminService.GetPayoutAccounts(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load payout accounts"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+func (h *AdminHandler) ReviewPayoutAccount(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	accountID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payout account ID"})
+		return
+	}
+
+	var req service.ReviewPayoutAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := h.adminService.ReviewPayoutAccount(adminID.(uint), uint(accountID), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Payout account reviewed", "account": account})
+}
+
+func (h *AdminHandler) GetSettlementBatches(c *gin.Context) {
+	batches, err := h.adminService.GetSettlementBatches()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load settlement batches"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"batches": batches})
+}
+
+func (h *AdminHandler) CreateSettlementBatch(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	// The body is optional; without it the default hold period applies.
+	var req service.CreateSettlementBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	batch, err := h.adminService.CreateSettlementBatch(adminID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Settlement batch created", "batch": batch})
+}
+
+func (h *AdminHandler) GetSettlementBatch(c *gin.Context) {
+	batchID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settlement batch ID"})
+		return
+	}
+
+	batch, err := h.adminService.GetSettlementBatch(uint(batchID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"batch": batch})
+}
+
+func (h *AdminHandler) ExportSettlementBatch(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	batchID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settlement batch ID"})
+		return
+	}
+
+	payload, filename, err := h.adminService.ExportSettlementBatch(adminID.(uint), uint(batchID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	c.String(http.StatusOK, payload)
+}
+
+func (h *AdminHandler) UpdateFarmerPayout(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	payoutID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payout ID"})
+		return
+	}
+
+	var req service.UpdateFarmerPayoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payout, err := h.adminService.UpdateFarmerPayout(adminID.(uint), uint(payoutID), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Payout updated", "payout": payout})
+}
+
+func (h *AdminHandler) GetCODReconciliation(c *gin.Context) {
+	report, err := h.adminService.GetCODReconciliation()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load COD reconciliation"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+func (h *AdminHandler) GetCODDiscrepancies(c *gin.Context) {
+	collections, err := h.adminService.GetCODDiscrepancies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load COD discrepancies"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"discrepancies": collections})
+}
+
+func (h *AdminHandler) ResolveCODDiscrepancy(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	collectionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	var req service.ResolveCODDiscrepancyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, err := h.adminService.ResolveCODDiscrepancy(adminID.(uint), uint(collectionID), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Discrepancy resolved", "collection": collection})
+}
+
+func (h *AdminHandler) RecordCODRemittance(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req service.CODRemittanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	row, err := h.adminService.RecordCODRemittance(adminID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Remittance recorded", "farmer": row})
+}
+
+func (h *AdminHandler) GetBuyerWallet(c *gin.Context) {
+	buyerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	wallet, err := h.adminService.GetBuyerWallet(uint(buyerID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"wallet": wallet})
+}
+
+func (h *AdminHandler) IssueWalletCredit(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req service.WalletCreditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	wallet, err := h.adminService.IssueWalletCredit(adminID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Wallet credited", "wallet": wallet})
+}
+
+func (h *AdminHandler) GetCreditAccounts(c *gin.Context) {
+	accounts, err := h.adminService.GetCreditAccounts(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load credit accounts"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+func (h *AdminHandler) ReviewCreditAccount(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	accountID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credit account ID"})
+		return
+	}
+
+	var req service.ReviewCreditAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := h.adminService.ReviewCreditAccount(adminID.(uint), uint(accountID), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Credit account reviewed", "account": account})
+}
+
+func (h *AdminHandler) RecordCreditRepayment(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req service.CreditRepaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credit, err := h.adminService.RecordCreditRepayment(adminID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Repayment recorded", "credit": credit})
+}
+
+func (h *AdminHandler) GetCreditAging(c *gin.Context) {
+	report, err := h.adminService.GetCreditAging(time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build credit aging report"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+func (h *AdminHandler) ExportCreditAgingCSV(c *gin.Context) {
+	payload, err := h.adminService.ExportCreditAgingCSV(time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export credit aging report"})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment; filename=credit_aging.csv")
+	c.String(http.StatusOK, payload)
+}
+
+func (h *AdminHandler) GetFeeRules(c *gin.Context) {
+	rules, err := h.adminService.GetFeeRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load fee rules"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+func (h *AdminHandler) CreateFeeRule(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req service.FeeRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.adminService.CreateFeeRule(adminID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Fee rule created", "rule": rule})
+}
+
+func (h *AdminHandler) UpdateFeeRule(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+	ruleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fee rule ID"})
+		return
+	}
+
+	var req service.FeeRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.adminService.UpdateFeeRule(adminID.(uint), uint(ruleID), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Fee rule updated", "rule": rule})
+}
+
+func (h *AdminHandler) GetInventoryReconciliation(c *gin.Context) {
+	mismatches, err := h.adminService.GetInventoryReconciliation()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile inventory"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"balanced": len(mismatches) == 0, "mismatches": mismatches})
+}
+
+func (h *AdminHandler) GetTaxonomy(c *gin.Context) {
+	taxonomy, err := h.adminService.GetTaxonomy()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load taxonomy"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"taxonomy": taxonomy})
+}
+
+func (h *AdminHandler) CreateTaxonomyNode(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req service.TaxonomyNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	node, err := h.adminService.CreateTaxonomyNode(adminID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Taxonomy node created", "node": node})
+}
+
+func (h *AdminHandler) UpdateTaxonomyNode(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+	nodeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid taxonomy node ID"})
+		return
+	}
+
+	var req service.TaxonomyNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	node, err := h.adminService.UpdateTaxonomyNode(adminID.(uint), uint(nodeID), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Taxonomy node updated", "node": node})
+}
+
+func (h *AdminHandler) GetPromotions(c *gin.Context) {
+	promotions, err := h.adminService.GetPromotions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load promotions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"promotions": promotions})
+}
+
+func (h *AdminHandler) CreatePromotion(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req service.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := h.adminService.CreatePromotion(adminID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Promotion created", "promotion": promotion})
+}
+
+func (h *AdminHandler) UpdatePromotion(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+	promotionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion ID"})
+		return
+	}
+
+	var req service.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := h.adminService.UpdatePromotion(adminID.(uint), uint(promotionID), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Promotion updated", "promotion": promotion})
+}
+
+func (h *AdminHandler) GetTaxRates(c *gin.Context) {
+	rates, err := h.adminService.GetTaxRates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tax rates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tax_rates": rates})
+}
+
+func (h *AdminHandler) UpdateTaxRate(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req service.UpdateTaxRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rate, err := h.adminService.UpdateTaxRate(adminID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Tax rate updated", "tax_rate": rate})
+}
+
+func (h *AdminHandler) GetCancellationPolicies(c *gin.Context) {
+	policies, err := h.adminService.GetCancellationPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load cancellation policies"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+func (h *AdminHandler) UpdateCancellationPolicy(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req service.UpdateCancellationPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.adminService.UpdateCancellationPolicy(adminID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policy": policy})
+}
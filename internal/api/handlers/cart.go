@@ -81,10 +81,12 @@ func (h *CartHandler) Checkout(c *gin.Context) {
 		DeliveryAddress string `json:"delivery_address"`
 		PaymentMethod   string `json:"payment_method"`
 		PaymentReference string `json:"payment_reference"`
+		WalletAmount     float64 `json:"wallet_amount"`
+		CouponCode       string  `json:"coupon_code"`
 	}
 	_ = c.ShouldBindJSON(&req)
 
-	orders, err := h.cartService.CheckoutWithPayment(userID.(uint), req.DeliveryAddress, req.PaymentMethod, req.PaymentReference)
+	orders, err := h.cartService.CheckoutWithPayment(userID.(uint), req.DeliveryAddress, req.PaymentMethod, req.PaymentReference, req.WalletAmount, req.CouponCode)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
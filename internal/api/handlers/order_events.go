@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/service"
+	"github.com/f2b-portal/backend/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const orderEventHeartbeat = 25 * time.Second
+
+// CreateOrderEventTicket issues the short-lived ticket a browser passes to
+// StreamOrderEvents in place of its session token.
+func (h *OrderHandler) CreateOrderEventTicket(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	email, _ := c.Get("email")
+	userType, _ := c.Get("user_type")
+	ticket, err := utils.GenerateStreamTicket(userID.(uint), email.(string), userType.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue stream ticket"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"ticket":     ticket,
+		"expires_in": int(utils.StreamTicketTTL.Seconds()),
+	})
+}
+
+// StreamOrderEvents pushes new messages, status changes and dispute updates
+// for the caller's orders as server-sent events. Clients resume after a
+// disconnect by sending the Last-Event-ID header (or last_event_id query);
+// event IDs come from the database, so this works across restarts.
+func (h *OrderHandler) StreamOrderEvents(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var orderID uint
+	if raw := c.Query("order_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+		orderID = uint(id)
+	}
+
+	lastEventRaw := c.GetHeader("Last-Event-ID")
+	if lastEventRaw == "" {
+		lastEventRaw = c.Query("last_event_id")
+	}
+	var resume *service.OrderEventCursor
+	if lastEventRaw != "" {
+		cursor, err := service.ParseOrderEventCursor(lastEventRaw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last event ID"})
+			return
+		}
+		resume = &cursor
+	}
+
+	stream, err := h.orderService.SubscribeOrderEvents(userID.(uint), orderID, resume)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer stream.Close()
+	cursor := stream.Cursor
+
+	// The server-wide write timeout would otherwise cut the stream off.
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	// An event with only an ID sets the client's Last-Event-ID, so even a
+	// stream that drops before its first event resumes from here.
+	fmt.Fprintf(c.Writer, "retry: 3000\nid: %s\n\n", cursor)
+
+	for _, event := range stream.Replay {
+		cursor.Include(event)
+		if writeOrderEvent(c, cursor, event) != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(orderEventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-stream.Events:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and replays.
+				return
+			}
+			if (orderID != 0 && event.OrderID != orderID) || stream.Replayed(event) {
+				continue
+			}
+			cursor.Include(event)
+			if writeOrderEvent(c, cursor, event) != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// writeOrderEvent sends event with the cursor the client resumes from if the
+// stream drops after it.
+func writeOrderEvent(c *gin.Context, cursor service.OrderEventCursor, event service.OrderEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", cursor, event.Type, payload)
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/f2b-portal/backend/internal/service"
+	"github.com/f2b-portal/backend/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -38,6 +39,10 @@ type orderMessagePayload struct {
 	Message string `json:"message"`
 }
 
+type markMessagesReadPayload struct {
+	MessageID uint `json:"message_id"`
+}
+
 type disputeEvidencePayload struct {
 	Note        string `json:"note"`
 	EvidenceURL string `json:"evidence_url"`
@@ -182,6 +187,50 @@ func (h *OrderHandler) UpdateHarvestRequest(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Harvest request updated successfully", "request": item})
 }
 
+func (h *OrderHandler) CounterHarvestRequest(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	var req service.CounterHarvestRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := h.orderService.CounterHarvestRequest(uint(id), userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Counter-proposal sent successfully", "request": item})
+}
+
+func (h *OrderHandler) RespondToHarvestCounter(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	var req service.RespondHarvestCounterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := h.orderService.RespondToHarvestCounter(uint(id), userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Counter-proposal response recorded", "request": item})
+}
+
 func (h *OrderHandler) ConvertHarvestRequestToOrder(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	userIDUint := userID.(uint)
@@ -232,12 +281,16 @@ func (h *OrderHandler) GetOrderMessages(c *gin.Context) {
 		return
 	}
 
-	items, err := h.orderService.GetOrderMessages(uint(id), userID.(uint))
+	before, _ := strconv.ParseUint(c.Query("before"), 10, 32)
+	after, _ := strconv.ParseUint(c.Query("after"), 10, 32)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	page, err := h.orderService.GetOrderMessages(uint(id), userID.(uint), uint(before), uint(after), limit)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": items})
+	c.JSON(http.StatusOK, page)
 }
 
 func (h *OrderHandler) SendOrderMessage(c *gin.Context) {
@@ -248,20 +301,150 @@ func (h *OrderHandler) SendOrderMessage(c *gin.Context) {
 		return
 	}
 
-	var req orderMessagePayload
-	if err := c.ShouldBindJSON(&req); err != nil {
+	req := service.SendOrderMessageRequest{}
+	if c.ContentType() == "multipart/form-data" {
+		form, err := c.MultipartForm()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse form"})
+			return
+		}
+		files := form.File["attachments"]
+		if len(files) > utils.MaxAttachments {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "maximum 3 attachments allowed"})
+			return
+		}
+		req.Message = c.PostForm("message")
+		for _, file := range files {
+			url, contentType, err := utils.SaveAttachment(file)
+			if err != nil {
+				deleteMessageAttachments(req.Attachments)
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			req.Attachments = append(req.Attachments, service.OrderMessageAttachment{
+				URL:         url,
+				FileName:    file.Filename,
+				ContentType: contentType,
+				Size:        file.Size,
+			})
+		}
+	} else {
+		var payload orderMessagePayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.Message = payload.Message
+	}
+
+	page, err := h.orderService.SendOrderMessage(uint(id), userID.(uint), req)
+	if err != nil {
+		deleteMessageAttachments(req.Attachments)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	items, err := h.orderService.SendOrderMessage(uint(id), userID.(uint), service.SendOrderMessageRequest{
-		Message: req.Message,
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Message sent successfully",
+		"items":        page.Items,
+		"next_cursor":  page.NextCursor,
+		"has_more":     page.HasMore,
+		"read_markers": page.ReadMarkers,
 	})
+}
+
+// GetOrderMessageAttachment serves a file from an order thread to the buyer
+// or farmer on that order. Attachments are never served from /uploads.
+func (h *OrderHandler) GetOrderMessageAttachment(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	attachmentID, err := strconv.ParseUint(c.Param("attachment_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	attachment, err := h.orderService.GetOrderMessageAttachment(uint(id), uint(attachmentID), userID.(uint))
+	if err != nil {
+		status := http.StatusNotFound
+		if err.Error() == "unauthorized access to order" {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	filePath, ok := utils.UploadFilePath(attachment.URL)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return
+	}
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	if attachment.ContentType != "" {
+		c.Header("Content-Type", attachment.ContentType)
+	}
+	c.File(filePath)
+}
+
+// deleteMessageAttachments removes files saved for a message that was never
+// created so failed sends do not leave orphan uploads.
+func deleteMessageAttachments(items []service.OrderMessageAttachment) {
+	for _, item := range items {
+		_ = utils.DeleteImage(item.URL)
+	}
+}
+
+func (h *OrderHandler) MarkOrderMessagesRead(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req markMessagesReadPayload
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	marker, err := h.orderService.MarkOrderMessagesRead(uint(id), userID.(uint), req.MessageID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusCreated, gin.H{"message": "Message sent successfully", "items": items})
+	c.JSON(http.StatusOK, gin.H{"read_marker": marker})
+}
+
+func (h *OrderHandler) GetOrderUnreadCount(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	unread, err := h.orderService.GetOrderUnreadCount(uint(id), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"order_id": uint(id), "unread": unread})
+}
+
+func (h *OrderHandler) GetUnreadMessageSummary(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	summary, err := h.orderService.GetUnreadMessageSummary(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
 }
 
 func (h *OrderHandler) GetDisputeEvidences(c *gin.Context) {
@@ -367,12 +550,37 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 		return
 	}
 
-	if err := h.orderService.CancelOrder(uint(id), userIDUint); err != nil {
+	var req service.CancelOrderRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	order, err := h.orderService.CancelOrder(uint(id), userIDUint, req)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Order cancelled successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Order cancelled successfully", "order": order})
+}
+
+func (h *OrderHandler) GetCancellationQuote(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	quote, err := h.orderService.GetCancellationQuote(uint(id), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, quote)
 }
 
 func (h *OrderHandler) GetFarmerPayoutSummary(c *gin.Context) {
@@ -388,6 +596,223 @@ func (h *OrderHandler) GetFarmerPayoutSummary(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"summary": summary})
 }
 
+func (h *OrderHandler) GetTaxInvoice(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	invoice, err := h.orderService.GetTaxInvoice(uint(id), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invoice": invoice})
+}
+
+func (h *OrderHandler) GetFarmerLedger(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	statement, err := h.orderService.GetFarmerLedger(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ledger": statement})
+}
+
+func (h *OrderHandler) GetPayoutAccounts(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	accounts, err := h.orderService.GetPayoutAccounts(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+func (h *OrderHandler) AddPayoutAccount(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req service.PayoutAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := h.orderService.AddPayoutAccount(userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Payout account submitted for verification", "account": account})
+}
+
+func (h *OrderHandler) GetFarmerPayouts(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	overview, err := h.orderService.GetFarmerPayouts(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payouts": overview})
+}
+
+func (h *OrderHandler) RequestFarmerPayout(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	payout, err := h.orderService.RequestFarmerPayout(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Payout requested", "payout": payout})
+}
+
+func (h *OrderHandler) RecordCODCollection(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req service.RecordCODCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, err := h.orderService.RecordCODCollection(uint(id), userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Cash collection recorded", "collection": collection})
+}
+
+func (h *OrderHandler) GetFarmerCODCollections(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	collections, err := h.orderService.GetFarmerCODCollections(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"collections": collections})
+}
+
+func (h *OrderHandler) GetBuyerWallet(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	wallet, err := h.orderService.GetBuyerWallet(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"wallet": wallet})
+}
+
+func (h *OrderHandler) GetBuyerCredit(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	credit, err := h.orderService.GetBuyerCredit(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"credit": credit})
+}
+
+func (h *OrderHandler) ApplyForCredit(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req service.CreditAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := h.orderService.ApplyForCredit(userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Credit application submitted for review", "account": account})
+}
+
+func (h *OrderHandler) QuotePromotion(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req service.PromotionQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quote, err := h.orderService.QuotePromotion(userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"quote": quote})
+}
+
+func (h *OrderHandler) GetFarmerPromotions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	promotions, err := h.orderService.GetFarmerPromotions(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"promotions": promotions})
+}
+
+func (h *OrderHandler) CreateFarmerPromotion(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req service.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := h.orderService.SaveFarmerPromotion(userID.(uint), 0, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Promotion created", "promotion": promotion})
+}
+
+func (h *OrderHandler) UpdateFarmerPromotion(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	promotionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion ID"})
+		return
+	}
+
+	var req service.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := h.orderService.SaveFarmerPromotion(userID.(uint), uint(promotionID), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Promotion updated", "promotion": promotion})
+}
+
 func (h *OrderHandler) GetFarmerInvoice(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	userIDUint := userID.(uint)
@@ -407,6 +832,27 @@ func (h *OrderHandler) GetFarmerInvoice(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"invoice": invoice})
 }
 
+// DownloadInvoicePDF serves the farmer's invoice or the buyer's receipt.
+func (h *OrderHandler) DownloadInvoicePDF(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	userIDUint := userID.(uint)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	content, filename, err := h.orderService.RenderOrderInvoicePDF(uint(id), userIDUint)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	c.Data(http.StatusOK, "application/pdf", content)
+}
+
 func (h *OrderHandler) GetFarmerAnalytics(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	userIDUint := userID.(uint)
@@ -504,13 +950,13 @@ func (h *OrderHandler) OpenDispute(c *gin.Context) {
 		return
 	}
 
-	var req disputeActionRequest
+	var req service.OpenDisputeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	order, err := h.orderService.OpenDispute(uint(id), userIDUint, req.Note)
+	order, err := h.orderService.OpenDispute(uint(id), userIDUint, req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -564,6 +1010,30 @@ func (h *OrderHandler) RejectDispute(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Dispute rejected successfully", "order": order})
 }
 
+func (h *OrderHandler) EscalateDispute(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req disputeActionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	order, err := h.orderService.EscalateDispute(uint(id), userID.(uint), req.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Dispute escalated to admin", "order": order})
+}
+
 func (h *OrderHandler) SubmitBuyerReview(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	userIDUint := userID.(uint)
@@ -664,3 +1134,68 @@ func (h *OrderHandler) ExportFarmerReport(c *gin.Context) {
 	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
 	c.String(http.StatusOK, csvContent)
 }
+
+func (h *OrderHandler) GetOrderAmendments(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	items, err := h.orderService.GetOrderAmendments(uint(id), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *OrderHandler) RequestOrderAmendment(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req service.RequestOrderAmendmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := h.orderService.RequestOrderAmendment(uint(id), userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Amendment requested successfully", "amendment": item})
+}
+
+func (h *OrderHandler) RespondToOrderAmendment(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	amendmentID, err := strconv.ParseUint(c.Param("amendment_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amendment ID"})
+		return
+	}
+
+	var req service.RespondOrderAmendmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := h.orderService.RespondToOrderAmendment(uint(id), uint(amendmentID), userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Amendment " + item.Status + " successfully", "amendment": item})
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/f2b-portal/backend/internal/models"
 	"github.com/f2b-portal/backend/internal/service"
+	"github.com/f2b-portal/backend/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -130,6 +131,7 @@ func buildTrustAwareProduct(product models.Product, rank *rankingMeta) gin.H {
 			(freshnessWeightStorage * storageComponent),
 	)
 	freshnessLabel := freshnessBandFromScore(freshnessScore)
+	baseUnit, unitFactor := models.BaseUnitOf(product.Unit)
 
 	item := gin.H{
 		"id":                product.ID,
@@ -137,8 +139,13 @@ func buildTrustAwareProduct(product models.Product, rank *rankingMeta) gin.H {
 		"farmer":            product.Farmer,
 		"crop_name":         product.CropName,
 		"category":          product.Category,
+		"category_id":       product.CategoryID,
+		"crop_id":           product.CropID,
 		"quantity":          product.Quantity,
 		"unit":              product.Unit,
+		"base_unit":         baseUnit,
+		"base_price_per_unit": roundToTwo(basePrice / unitFactor),
+		"base_quantity":     product.Quantity * unitFactor,
 		"price_per_unit":    basePrice,
 		"base_price":        roundToTwo(basePrice),
 		"trust_score":       roundToTwo(trustScore),
@@ -159,11 +166,19 @@ func buildTrustAwareProduct(product models.Product, rank *rankingMeta) gin.H {
 		"city":              product.City,
 		"state":             product.State,
 		"image_url":         product.ImageURL,
+		"thumbnail_url":     productThumbnail(product),
+		"images":            product.Images,
+		"variants":          activeVariants(product.Variants),
 		"status":            product.Status,
 		"is_bulk_available": product.IsBulkAvailable,
 		"minimum_bulk_quantity": product.MinimumBulkQuantity,
+		"minimum_bulk_base_quantity": product.MinimumBulkQuantity * unitFactor,
+		"price_tiers":       product.PriceTiers,
 		"supports_harvest_request": product.SupportsHarvestRequest,
 		"harvest_lead_days": product.HarvestLeadDays,
+		"harvested_at":      product.HarvestedAt,
+		"best_before":       product.BestBefore,
+		"expired_at":        product.ExpiredAt,
 		"created_at":        product.CreatedAt,
 		"updated_at":        product.UpdatedAt,
 	}
@@ -179,6 +194,31 @@ func buildTrustAwareProduct(product models.Product, rank *rankingMeta) gin.H {
 	return item
 }
 
+// activeVariants lists the variants buyers can order.
+func activeVariants(variants []models.ProductVariant) []models.ProductVariant {
+	active := make([]models.ProductVariant, 0, len(variants))
+	for _, variant := range variants {
+		if variant.IsActive {
+			active = append(active, variant)
+		}
+	}
+	return active
+}
+
+// productThumbnail is the listing thumbnail: the primary gallery image's,
+// falling back to the one SaveImage wrote next to a legacy image_url.
+func productThumbnail(product models.Product) string {
+	for _, image := range product.Images {
+		if image.IsPrimary {
+			return image.ThumbnailURL
+		}
+	}
+	if utils.IsUploadURL(product.ImageURL) {
+		return utils.ThumbnailURL(product.ImageURL)
+	}
+	return product.ImageURL
+}
+
 func relevanceScore(product models.Product, query, category string) float64 {
 	q := strings.ToLower(strings.TrimSpace(query))
 	cat := strings.ToLower(strings.TrimSpace(category))
@@ -256,16 +296,18 @@ func fairnessRankProducts(products []models.Product, query, category string) ([]
 		return products, map[uint]rankingMeta{}
 	}
 
-	minPrice := products[0].PricePerUnit
-	maxPrice := products[0].PricePerUnit
+	// Compare prices per base unit so per-quintal and per-kg listings rank fairly.
+	minPrice := models.ToBasePrice(products[0].PricePerUnit, products[0].Unit)
+	maxPrice := minPrice
 	stateCounts := make(map[string]int)
 	maxStateCount := 0
 	for _, p := range products {
-		if p.PricePerUnit < minPrice {
-			minPrice = p.PricePerUnit
+		price := models.ToBasePrice(p.PricePerUnit, p.Unit)
+		if price < minPrice {
+			minPrice = price
 		}
-		if p.PricePerUnit > maxPrice {
-			maxPrice = p.PricePerUnit
+		if price > maxPrice {
+			maxPrice = price
 		}
 
 		state := strings.ToLower(strings.TrimSpace(p.State))
@@ -289,7 +331,7 @@ func fairnessRankProducts(products []models.Product, query, category string) ([]
 
 		priceFactor := 0.7
 		if maxPrice > minPrice {
-			priceFactor = 1.0 - ((p.PricePerUnit - minPrice) / (maxPrice - minPrice))
+			priceFactor = 1.0 - ((models.ToBasePrice(p.PricePerUnit, p.Unit) - minPrice) / (maxPrice - minPrice))
 		}
 		priceFactor = clamp(priceFactor, 0, 1)
 
@@ -341,24 +383,7 @@ func fairnessRankProducts(products []models.Product, query, category string) ([]
 }
 
 func calculateHarvestFreshness(product models.Product) (float64, float64) {
-	referenceTime := product.CreatedAt
-	ageHours := time.Since(referenceTime).Hours()
-	ageDays := ageHours / 24.0
-
-	switch {
-	case ageDays <= 1:
-		return 1.0, ageHours
-	case ageDays <= 2:
-		return 0.9, ageHours
-	case ageDays <= 4:
-		return 0.75, ageHours
-	case ageDays <= 7:
-		return 0.6, ageHours
-	case ageDays <= 10:
-		return 0.45, ageHours
-	default:
-		return 0.3, ageHours
-	}
+	return service.HarvestFreshness(product, time.Now().UTC())
 }
 
 func calculateDistanceFreshness(product models.Product) float64 {
@@ -444,6 +469,19 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"product": buildTrustAwareProduct(*product, nil)})
 }
 
+func (h *ProductHandler) GetUnits(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"units": h.productService.GetUnits()})
+}
+
+func (h *ProductHandler) GetTaxonomy(c *gin.Context) {
+	taxonomy, err := h.productService.GetTaxonomy()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load categories"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"taxonomy": taxonomy})
+}
+
 func (h *ProductHandler) GetAllProducts(c *gin.Context) {
 	// Parse query parameters
 	filters := make(map[string]interface{})
@@ -460,6 +498,21 @@ func (h *ProductHandler) GetAllProducts(c *gin.Context) {
 	if category := c.Query("category"); category != "" {
 		filters["category"] = category
 	}
+	if categoryIDStr := c.Query("category_id"); categoryIDStr != "" {
+		if categoryID, err := strconv.ParseUint(categoryIDStr, 10, 32); err == nil && categoryID > 0 {
+			filters["category_id"] = uint(categoryID)
+		}
+	}
+	if priceUnit := c.Query("price_unit"); priceUnit != "" {
+		if _, ok := models.LookupUnit(priceUnit); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown price unit"})
+			return
+		}
+		filters["price_unit"] = priceUnit
+	}
+	if baseUnit := c.Query("base_unit"); baseUnit != "" {
+		filters["base_unit"] = strings.ToLower(baseUnit)
+	}
 	if minPriceStr := c.Query("min_price"); minPriceStr != "" {
 		if minPrice, err := strconv.ParseFloat(minPriceStr, 64); err == nil {
 			filters["min_price"] = minPrice
@@ -701,6 +754,33 @@ func (h *ProductHandler) UpdateProductPrice(c *gin.Context) {
 	})
 }
 
+func (h *ProductHandler) UpdatePriceTiers(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	userIDUint := userID.(uint)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var req service.UpdatePriceTiersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := h.productService.UpdatePriceTiers(uint(id), userIDUint, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Price tiers updated successfully",
+		"product": product,
+	})
+}
+
 func (h *ProductHandler) DuplicateProduct(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	userIDUint := userID.(uint)
@@ -739,3 +819,279 @@ func (h *ProductHandler) GetProductPriceHistory(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"history": history})
 }
+
+func (h *ProductHandler) GetStockHistory(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	history, err := h.productService.GetStockHistory(uint(id), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stock": history})
+}
+
+// AdjustStock records a manual stock correction or a spoilage write-off.
+func (h *ProductHandler) AdjustStock(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var req service.StockAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := h.productService.AdjustStock(uint(id), userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Stock adjusted successfully",
+		"product": product,
+	})
+}
+
+// AddProductImages accepts either a multipart upload of "images" (with an
+// optional "alt_text" per file and a "primary" file index) or JSON with the
+// URLs returned by the upload endpoints.
+func (h *ProductHandler) AddProductImages(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var req service.AddProductImagesRequest
+	uploaded := []string{}
+	if c.ContentType() == "multipart/form-data" {
+		form, err := c.MultipartForm()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse form"})
+			return
+		}
+		files := form.File["images"]
+		if len(files) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No image files provided"})
+			return
+		}
+		uploaded, err = utils.SaveMultipleImages(files)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := h.productService.RecordImageUploads(userID.(uint), uploaded); err != nil {
+			deleteUploadedImages(uploaded)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save images"})
+			return
+		}
+		altTexts := form.Value["alt_text"]
+		primary, primaryErr := strconv.Atoi(c.PostForm("primary"))
+		for i, url := range uploaded {
+			input := service.ProductImageInput{URL: url, IsPrimary: primaryErr == nil && primary == i}
+			if i < len(altTexts) {
+				input.AltText = altTexts[i]
+			}
+			req.Images = append(req.Images, input)
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := h.productService.AddProductImages(uint(id), userID.(uint), req)
+	if err != nil {
+		deleteUploadedImages(uploaded)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Product images added successfully",
+		"product": product,
+	})
+}
+
+// deleteUploadedImages removes files saved for a gallery change that failed.
+func deleteUploadedImages(urls []string) {
+	for _, url := range urls {
+		_ = utils.DeleteImage(url)
+	}
+}
+
+func (h *ProductHandler) ReorderProductImages(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var req service.ReorderProductImagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := h.productService.ReorderProductImages(uint(id), userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Product images reordered successfully",
+		"product": product,
+	})
+}
+
+func (h *ProductHandler) UpdateProductImage(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	imageID, err := strconv.ParseUint(c.Param("image_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return
+	}
+
+	var req service.UpdateProductImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := h.productService.UpdateProductImage(uint(id), uint(imageID), userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Product image updated successfully",
+		"product": product,
+	})
+}
+
+func (h *ProductHandler) DeleteProductImage(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	imageID, err := strconv.ParseUint(c.Param("image_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return
+	}
+
+	product, err := h.productService.RemoveProductImage(uint(id), uint(imageID), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Product image removed successfully",
+		"product": product,
+	})
+}
+
+func (h *ProductHandler) CreateProductVariant(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var req service.ProductVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := h.productService.CreateProductVariant(uint(id), userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Product variant created successfully",
+		"product": product,
+	})
+}
+
+func (h *ProductHandler) UpdateProductVariant(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	variantID, err := strconv.ParseUint(c.Param("variant_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variant ID"})
+		return
+	}
+
+	var req service.ProductVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := h.productService.UpdateProductVariant(uint(id), uint(variantID), userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Product variant updated successfully",
+		"product": product,
+	})
+}
+
+func (h *ProductHandler) DeactivateProductVariant(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	variantID, err := strconv.ParseUint(c.Param("variant_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variant ID"})
+		return
+	}
+
+	product, err := h.productService.DeactivateProductVariant(uint(id), uint(variantID), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Product variant removed from sale",
+		"product": product,
+	})
+}
@@ -3,28 +3,37 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/f2b-portal/backend/internal/service"
 	"github.com/f2b-portal/backend/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
-type UploadHandler struct{}
+type UploadHandler struct {
+	productService *service.ProductService
+}
 
-func NewUploadHandler() *UploadHandler {
-	return &UploadHandler{}
+func NewUploadHandler(productService *service.ProductService) *UploadHandler {
+	return &UploadHandler{productService: productService}
 }
 
 func (h *UploadHandler) UploadImage(c *gin.Context) {
+	userID, _ := c.Get("user_id")
 	file, err := c.FormFile("image")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No image file provided"})
 		return
 	}
 
-	url, err := utils.SaveImage(file)
+	url, err := utils.SaveProductImage(file)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := h.productService.RecordImageUploads(userID.(uint), []string{url}); err != nil {
+		_ = utils.DeleteImage(url)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Image uploaded successfully",
@@ -33,6 +42,7 @@ func (h *UploadHandler) UploadImage(c *gin.Context) {
 }
 
 func (h *UploadHandler) UploadMultipleImages(c *gin.Context) {
+	userID, _ := c.Get("user_id")
 	form, err := c.MultipartForm()
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse form"})
@@ -50,6 +60,11 @@ func (h *UploadHandler) UploadMultipleImages(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := h.productService.RecordImageUploads(userID.(uint), urls); err != nil {
+		deleteUploadedImages(urls)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save images"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Images uploaded successfully",
@@ -81,6 +81,21 @@ func (h *UserHandler) SaveAddress(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"address": item})
 }
 
+func (h *UserHandler) UpdateTaxProfile(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var req service.UpdateTaxProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := h.userPortalService.UpdateTaxProfile(userID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
 func (h *UserHandler) DeleteAddress(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -29,31 +29,67 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		token := parts[1]
 		claims, err := utils.ValidateToken(token)
-		if err != nil {
+		// Stream tickets only open event streams.
+		if err != nil || claims.Scope != "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
-
-		// Set user info in context
-		if db := config.GetDB(); db != nil {
-			userRepo := repository.NewUserRepository(db)
-			user, userErr := userRepo.GetByID(claims.UserID)
-			if userErr != nil || !user.IsActive {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is inactive"})
-				c.Abort()
-				return
-			}
-			c.Set("verification_status", user.VerificationStatus)
+		if !setAuthenticatedUser(c, claims) {
+			return
 		}
-		c.Set("user_id", claims.UserID)
-		c.Set("email", claims.Email)
-		c.Set("user_type", claims.UserType)
+		c.Next()
+	}
+}
 
+// StreamAuthMiddleware authenticates long-lived event streams. Browser
+// EventSource clients cannot set headers, so they pass a short-lived stream
+// ticket as the ticket query parameter instead of their session token.
+func StreamAuthMiddleware() gin.HandlerFunc {
+	auth := AuthMiddleware()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			auth(c)
+			return
+		}
+		ticket := strings.TrimSpace(c.Query("ticket"))
+		if ticket == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Stream ticket required"})
+			c.Abort()
+			return
+		}
+		claims, err := utils.ValidateToken(ticket)
+		if err != nil || claims.Scope != utils.StreamTicketScope {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired stream ticket"})
+			c.Abort()
+			return
+		}
+		if !setAuthenticatedUser(c, claims) {
+			return
+		}
 		c.Next()
 	}
 }
 
+// setAuthenticatedUser puts the token's user in the request context, refusing
+// accounts that have been deactivated since the token was issued.
+func setAuthenticatedUser(c *gin.Context, claims *utils.Claims) bool {
+	if db := config.GetDB(); db != nil {
+		userRepo := repository.NewUserRepository(db)
+		user, err := userRepo.GetByID(claims.UserID)
+		if err != nil || !user.IsActive {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is inactive"})
+			c.Abort()
+			return false
+		}
+		c.Set("verification_status", user.VerificationStatus)
+	}
+	c.Set("user_id", claims.UserID)
+	c.Set("email", claims.Email)
+	c.Set("user_type", claims.UserType)
+	return true
+}
+
 func FarmerOnly() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userType, exists := c.Get("user_type")
@@ -10,6 +10,7 @@ import (
 	"github.com/f2b-portal/backend/internal/api/middleware"
 	"github.com/f2b-portal/backend/internal/repository"
 	"github.com/f2b-portal/backend/internal/service"
+	"github.com/f2b-portal/backend/internal/utils"
 	"github.com/f2b-portal/backend/pkg/config"
 	"github.com/gin-gonic/gin"
 )
@@ -21,8 +22,9 @@ func SetupRoutes() *gin.Engine {
 	router.Use(middleware.CORSMiddleware())
 	// Note: gin.Default() already includes Logger + Recovery.
 
-	// Serve static files (uploads)
-	router.Static("/uploads", "./uploads")
+	// Serve static files (uploads). Message attachments are left out and
+	// served to the parties of their order under /api/v1/orders.
+	router.StaticFS("/uploads", utils.PublicUploads())
 
 	// Serve built frontend if present (optional).
 	// This makes the project "single-process" in production: `go run ...` serves both API and UI.
@@ -58,6 +60,7 @@ func SetupRoutes() *gin.Engine {
 	productRepo := repository.NewProductRepository(config.GetDB())
 	orderRepo := repository.NewOrderRepository(config.GetDB())
 	cartRepo := repository.NewCartRepository(config.GetDB())
+	shipmentRepo := repository.NewShipmentRepository(config.GetDB())
 
 	// Initialize services
 	authService := service.NewAuthService(userRepo)
@@ -67,15 +70,30 @@ func SetupRoutes() *gin.Engine {
 	cartService := service.NewCartService(cartRepo, productRepo, orderRepo)
 	adminService := service.NewAdminService(userRepo, productRepo, orderRepo)
 	userPortalService := service.NewUserPortalService(userRepo, productRepo, orderRepo)
+	orderEvents := service.NewOrderEventBroker()
+	orderService.SetEventBroker(orderEvents)
+	cartService.SetEventBroker(orderEvents)
+	adminService.SetEventBroker(orderEvents)
+	paymentService := service.NewPaymentService(orderRepo,
+		service.NewMockPaymentProvider(config.AppConfig.PaymentWebhookSecret),
+	)
+	paymentService.SetEventBroker(orderEvents)
+	orderService.SetPaymentService(paymentService)
+	adminService.SetPaymentService(paymentService)
+	shipmentService := service.NewShipmentService(shipmentRepo, orderRepo,
+		service.NewMockCourier(config.AppConfig.CourierWebhookSecret),
+	)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
 	productHandler := handlers.NewProductHandler(productService)
 	orderHandler := handlers.NewOrderHandler(orderService)
 	userHandler := handlers.NewUserHandler(trustScoreService, userPortalService)
-	uploadHandler := handlers.NewUploadHandler()
+	uploadHandler := handlers.NewUploadHandler(productService)
 	cartHandler := handlers.NewCartHandler(cartService)
 	adminHandler := handlers.NewAdminHandler(adminService)
+	shipmentHandler := handlers.NewShipmentHandler(shipmentService)
+	paymentHandler := handlers.NewPaymentHandler(paymentService)
 
 	// API routes
 	api := router.Group("/api/v1")
@@ -93,6 +111,8 @@ func SetupRoutes() *gin.Engine {
 		{
 			products.GET("", productHandler.GetAllProducts)
 			products.GET("/search", productHandler.SearchProducts)
+			products.GET("/taxonomy", productHandler.GetTaxonomy)
+			products.GET("/units", productHandler.GetUnits)
 			products.GET("/:id", productHandler.GetProduct)
 			products.POST("", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.CreateProduct)
 			products.PUT("/:id", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.UpdateProduct)
@@ -101,10 +121,25 @@ func SetupRoutes() *gin.Engine {
 			products.PATCH("/:id/price", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.UpdateProductPrice)
 			products.POST("/:id/duplicate", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.DuplicateProduct)
 			products.GET("/:id/price-history", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.GetProductPriceHistory)
+			products.GET("/:id/stock", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.GetStockHistory)
+			products.POST("/:id/stock/adjustments", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.AdjustStock)
+			products.PUT("/:id/price-tiers", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.UpdatePriceTiers)
+			products.POST("/:id/images", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.AddProductImages)
+			products.PUT("/:id/images/order", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.ReorderProductImages)
+			products.PATCH("/:id/images/:image_id", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.UpdateProductImage)
+			products.DELETE("/:id/images/:image_id", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.DeleteProductImage)
+			products.POST("/:id/variants", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.CreateProductVariant)
+			products.PUT("/:id/variants/:variant_id", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.UpdateProductVariant)
+			products.DELETE("/:id/variants/:variant_id", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.DeactivateProductVariant)
 			products.DELETE("/:id", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.DeleteProduct)
 			products.GET("/my/listings", middleware.AuthMiddleware(), middleware.FarmerOnly(), productHandler.GetMyProducts)
 		}
 
+		// Real-time order updates. Browsers first fetch a short-lived ticket
+		// and pass it as ?ticket=, since EventSource cannot send headers.
+		api.POST("/orders/events/ticket", middleware.AuthMiddleware(), orderHandler.CreateOrderEventTicket)
+		api.GET("/orders/events", middleware.StreamAuthMiddleware(), orderHandler.StreamOrderEvents)
+
 		// Orders (protected)
 		orders := api.Group("/orders")
 		orders.Use(middleware.AuthMiddleware())
@@ -115,18 +150,37 @@ func SetupRoutes() *gin.Engine {
 			orders.GET("/:id", orderHandler.GetOrder)
 			orders.GET("/:id/messages", orderHandler.GetOrderMessages)
 			orders.POST("/:id/messages", orderHandler.SendOrderMessage)
+			orders.POST("/:id/messages/read", orderHandler.MarkOrderMessagesRead)
+			orders.GET("/:id/messages/attachments/:attachment_id", orderHandler.GetOrderMessageAttachment)
+			orders.GET("/:id/messages/unread-count", orderHandler.GetOrderUnreadCount)
+			orders.GET("/messages/unread", orderHandler.GetUnreadMessageSummary)
 			orders.GET("/:id/dispute/evidence", orderHandler.GetDisputeEvidences)
 			orders.POST("/:id/dispute/evidence", orderHandler.AddDisputeEvidence)
 			orders.GET("/my/orders", middleware.BuyerOnly(), orderHandler.GetMyOrders)
 			orders.GET("/my/harvest-requests", middleware.BuyerOnly(), orderHandler.GetBuyerHarvestRequests)
 			orders.GET("/my/reviews", middleware.BuyerOnly(), orderHandler.GetBuyerReviews)
 			orders.GET("/my/notifications", middleware.BuyerOnly(), orderHandler.GetBuyerNotifications)
+			orders.GET("/my/wallet", middleware.BuyerOnly(), orderHandler.GetBuyerWallet)
+			orders.GET("/my/credit", middleware.BuyerOnly(), orderHandler.GetBuyerCredit)
+			orders.POST("/my/credit", middleware.BuyerOnly(), orderHandler.ApplyForCredit)
+			orders.POST("/promotions/quote", middleware.BuyerOnly(), orderHandler.QuotePromotion)
 			orders.POST("/harvest-requests/:id/convert", middleware.BuyerOnly(), orderHandler.ConvertHarvestRequestToOrder)
+			orders.POST("/harvest-requests/:id/counter/respond", middleware.BuyerOnly(), orderHandler.RespondToHarvestCounter)
 			orders.PATCH("/harvest-requests/:id", orderHandler.UpdateHarvestRequest)
 			orders.POST("/:id/review", middleware.BuyerOnly(), orderHandler.SubmitBuyerReview)
 			orders.GET("/farmer/orders", middleware.FarmerOnly(), orderHandler.GetFarmerOrders)
 			orders.GET("/farmer/harvest-requests", middleware.FarmerOnly(), orderHandler.GetFarmerHarvestRequests)
+			orders.POST("/harvest-requests/:id/counter", middleware.FarmerOnly(), orderHandler.CounterHarvestRequest)
 			orders.GET("/farmer/payout-summary", middleware.FarmerOnly(), orderHandler.GetFarmerPayoutSummary)
+			orders.GET("/farmer/ledger", middleware.FarmerOnly(), orderHandler.GetFarmerLedger)
+			orders.GET("/farmer/payout-accounts", middleware.FarmerOnly(), orderHandler.GetPayoutAccounts)
+			orders.POST("/farmer/payout-accounts", middleware.FarmerOnly(), orderHandler.AddPayoutAccount)
+			orders.GET("/farmer/payouts", middleware.FarmerOnly(), orderHandler.GetFarmerPayouts)
+			orders.POST("/farmer/payouts", middleware.FarmerOnly(), orderHandler.RequestFarmerPayout)
+			orders.GET("/farmer/cod-collections", middleware.FarmerOnly(), orderHandler.GetFarmerCODCollections)
+			orders.GET("/farmer/promotions", middleware.FarmerOnly(), orderHandler.GetFarmerPromotions)
+			orders.POST("/farmer/promotions", middleware.FarmerOnly(), orderHandler.CreateFarmerPromotion)
+			orders.PUT("/farmer/promotions/:id", middleware.FarmerOnly(), orderHandler.UpdateFarmerPromotion)
 			orders.GET("/farmer/analytics", middleware.FarmerOnly(), orderHandler.GetFarmerAnalytics)
 			orders.GET("/farmer/notifications", middleware.FarmerOnly(), orderHandler.GetFarmerNotifications)
 			orders.GET("/farmer/summary/weekly", middleware.FarmerOnly(), orderHandler.GetFarmerWeeklySummary)
@@ -134,13 +188,26 @@ func SetupRoutes() *gin.Engine {
 			orders.GET("/farmer/reports/export", middleware.FarmerOnly(), orderHandler.ExportFarmerReport)
 			orders.GET("/farmer/reviews", middleware.FarmerOnly(), orderHandler.GetFarmerReviews)
 			orders.GET("/farmer/disputes", middleware.FarmerOnly(), orderHandler.GetFarmerDisputes)
-			orders.POST("/:id/dispute/open", middleware.FarmerOnly(), orderHandler.OpenDispute)
+			orders.POST("/:id/dispute/open", middleware.BuyerOnly(), orderHandler.OpenDispute)
 			orders.POST("/:id/dispute/resolve", middleware.FarmerOnly(), orderHandler.ResolveDispute)
 			orders.POST("/:id/dispute/reject", middleware.FarmerOnly(), orderHandler.RejectDispute)
+			orders.POST("/:id/dispute/escalate", orderHandler.EscalateDispute)
 			orders.GET("/:id/invoice", middleware.FarmerOnly(), orderHandler.GetFarmerInvoice)
+			orders.GET("/:id/invoice.pdf", orderHandler.DownloadInvoicePDF)
 			orders.GET("/:id/history", orderHandler.GetOrderStatusHistory)
+			orders.GET("/:id/timeline", shipmentHandler.GetOrderTimeline)
+			orders.GET("/:id/amendments", orderHandler.GetOrderAmendments)
+			orders.POST("/:id/amendments", middleware.BuyerOnly(), orderHandler.RequestOrderAmendment)
+			orders.POST("/:id/amendments/:amendment_id/respond", middleware.FarmerOnly(), orderHandler.RespondToOrderAmendment)
+			orders.GET("/:id/shipment", shipmentHandler.GetShipment)
+			orders.POST("/:id/shipment", middleware.FarmerOnly(), shipmentHandler.CreateShipment)
 			orders.PUT("/:id/status", orderHandler.UpdateOrderStatus)
 			orders.DELETE("/:id", orderHandler.CancelOrder)
+			orders.GET("/:id/cancellation-quote", orderHandler.GetCancellationQuote)
+			orders.GET("/:id/tax-invoice", orderHandler.GetTaxInvoice)
+			orders.POST("/:id/cod-collection", middleware.FarmerOnly(), orderHandler.RecordCODCollection)
+			orders.POST("/:id/payment/intent", middleware.BuyerOnly(), paymentHandler.CreatePaymentIntent)
+			orders.GET("/:id/payment/events", paymentHandler.GetPaymentEvents)
 		}
 
 		// Users
@@ -151,6 +218,7 @@ func SetupRoutes() *gin.Engine {
 			users.GET("/me/addresses", middleware.AuthMiddleware(), userHandler.GetMyAddresses)
 			users.POST("/me/addresses", middleware.AuthMiddleware(), userHandler.SaveAddress)
 			users.DELETE("/me/addresses/:id", middleware.AuthMiddleware(), userHandler.DeleteAddress)
+			users.PUT("/me/tax-profile", middleware.AuthMiddleware(), userHandler.UpdateTaxProfile)
 			users.GET("/me/favorites", middleware.AuthMiddleware(), middleware.BuyerOnly(), userHandler.GetFavorites)
 			users.POST("/me/favorites/:product_id", middleware.AuthMiddleware(), middleware.BuyerOnly(), userHandler.ToggleFavorite)
 			users.GET("/me/documents", middleware.AuthMiddleware(), userHandler.GetMyVerificationDocuments)
@@ -176,6 +244,13 @@ func SetupRoutes() *gin.Engine {
 			upload.POST("/images", uploadHandler.UploadMultipleImages)
 		}
 
+		// Inbound partner webhooks (authenticated by signature, not JWT)
+		webhooks := api.Group("/webhooks")
+		{
+			webhooks.POST("/couriers/:carrier", shipmentHandler.CourierWebhook)
+			webhooks.POST("/payments/:provider", paymentHandler.PaymentWebhook)
+		}
+
 		// Admin data endpoints
 		admin := api.Group("/admin")
 		admin.Use(middleware.AuthMiddleware(), middleware.AdminOnly())
@@ -189,7 +264,44 @@ func SetupRoutes() *gin.Engine {
 			admin.GET("/transactions", adminHandler.GetTransactions)
 			admin.GET("/transactions/export", adminHandler.ExportTransactionsCSV)
 			admin.GET("/transactions/:id/invoice", adminHandler.GetTransactionInvoice)
+			admin.GET("/transactions/:id/invoice.pdf", adminHandler.DownloadTransactionInvoicePDF)
 			admin.GET("/harvest-requests", adminHandler.GetHarvestRequests)
+			admin.GET("/cancellation-policies", adminHandler.GetCancellationPolicies)
+			admin.PUT("/cancellation-policies", adminHandler.UpdateCancellationPolicy)
+			admin.GET("/fee-rules", adminHandler.GetFeeRules)
+			admin.POST("/fee-rules", adminHandler.CreateFeeRule)
+			admin.PUT("/fee-rules/:id", adminHandler.UpdateFeeRule)
+			admin.GET("/taxonomy", adminHandler.GetTaxonomy)
+			admin.POST("/taxonomy", adminHandler.CreateTaxonomyNode)
+			admin.PUT("/taxonomy/:id", adminHandler.UpdateTaxonomyNode)
+			admin.GET("/inventory/reconciliation", adminHandler.GetInventoryReconciliation)
+			admin.GET("/promotions", adminHandler.GetPromotions)
+			admin.POST("/promotions", adminHandler.CreatePromotion)
+			admin.PUT("/promotions/:id", adminHandler.UpdatePromotion)
+			admin.GET("/tax-rates", adminHandler.GetTaxRates)
+			admin.PUT("/tax-rates", adminHandler.UpdateTaxRate)
+			admin.GET("/disputes", adminHandler.GetEscalatedDisputes)
+			admin.POST("/disputes/:id/decision", adminHandler.DecideDispute)
+			admin.GET("/ledger/balances", adminHandler.GetLedgerBalances)
+			admin.POST("/payouts", adminHandler.RecordFarmerPayout)
+			admin.PATCH("/payouts/:id", adminHandler.UpdateFarmerPayout)
+			admin.GET("/payout-accounts", adminHandler.GetPayoutAccounts)
+			admin.PATCH("/payout-accounts/:id", adminHandler.ReviewPayoutAccount)
+			admin.GET("/settlement-batches", adminHandler.GetSettlementBatches)
+			admin.POST("/settlement-batches", adminHandler.CreateSettlementBatch)
+			admin.GET("/settlement-batches/:id", adminHandler.GetSettlementBatch)
+			admin.GET("/settlement-batches/:id/export", adminHandler.ExportSettlementBatch)
+			admin.GET("/cod/reconciliation", adminHandler.GetCODReconciliation)
+			admin.GET("/cod/discrepancies", adminHandler.GetCODDiscrepancies)
+			admin.POST("/cod/discrepancies/:id/resolve", adminHandler.ResolveCODDiscrepancy)
+			admin.POST("/cod/remittances", adminHandler.RecordCODRemittance)
+			admin.GET("/users/:id/wallet", adminHandler.GetBuyerWallet)
+			admin.POST("/wallet-credits", adminHandler.IssueWalletCredit)
+			admin.GET("/credit-accounts", adminHandler.GetCreditAccounts)
+			admin.PATCH("/credit-accounts/:id", adminHandler.ReviewCreditAccount)
+			admin.POST("/credit-repayments", adminHandler.RecordCreditRepayment)
+			admin.GET("/credit/aging", adminHandler.GetCreditAging)
+			admin.GET("/credit/aging/export", adminHandler.ExportCreditAgingCSV)
 			admin.GET("/reports", adminHandler.GetReports)
 			admin.POST("/reports/action", adminHandler.ResolveReportAction)
 			admin.PATCH("/reports/:id/resolve", adminHandler.ResolveReport)
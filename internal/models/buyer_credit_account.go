@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// BuyerCreditAccount lets a verified business buyer order on credit up to an
+// admin-approved limit and settle each order within the payment terms.
+type BuyerCreditAccount struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	BuyerID        uint       `gorm:"uniqueIndex;not null" json:"buyer_id"`
+	Buyer          User       `gorm:"foreignKey:BuyerID" json:"buyer,omitempty"`
+	RequestedLimit float64    `json:"requested_limit"`
+	CreditLimit    float64    `gorm:"default:0" json:"credit_limit"`
+	TermDays       int        `gorm:"default:30" json:"term_days"`           // net-15/net-30
+	Status         string     `gorm:"default:'pending';index" json:"status"` // pending/active/suspended/rejected
+	RequestNote    string     `json:"request_note"`
+	ReviewNote     string     `json:"review_note"`
+	ReviewedBy     *uint      `json:"reviewed_by"`
+	ReviewedAt     *time.Time `json:"reviewed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
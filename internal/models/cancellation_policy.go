@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// CancellationPolicy says whether a buyer may cancel an order of a given type
+// at a given stage, and what share of the order value is charged if so.
+type CancellationPolicy struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	OrderType  string    `gorm:"not null;uniqueIndex:idx_cancellation_policies_type_stage" json:"order_type"` // standard/bulk/harvest_request
+	Stage      string    `gorm:"not null;uniqueIndex:idx_cancellation_policies_type_stage" json:"stage"`      // pending/confirmed/packed/out_for_delivery
+	Allowed    bool      `gorm:"not null" json:"allowed"`
+	FeePercent float64   `gorm:"default:0" json:"fee_percent"`
+	UpdatedBy  *uint     `json:"updated_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// CODCollection records the cash taken from the buyer when a cash-on-delivery
+// order is handed over. A collection that does not match the order total is
+// held as a discrepancy until an admin resolves it.
+type CODCollection struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	OrderID         uint       `gorm:"uniqueIndex;not null" json:"order_id"`
+	FarmerID        uint       `gorm:"not null;index" json:"farmer_id"`
+	BuyerID         uint       `gorm:"not null;index" json:"buyer_id"`
+	ExpectedAmount  float64    `gorm:"not null" json:"expected_amount"`
+	CollectedAmount float64    `gorm:"not null" json:"collected_amount"`
+	Discrepancy     float64    `json:"discrepancy"` // collected - expected
+	CollectorName   string     `json:"collector_name"`
+	RecordedBy      uint       `json:"recorded_by"`
+	Note            string     `json:"note"`
+	Status          string     `gorm:"default:'collected';index" json:"status"` // collected/discrepancy/resolved
+	Resolution      string     `json:"resolution"`                              // farmer_liable/buyer_owes/write_off/refund_buyer
+	ResolutionNote  string     `json:"resolution_note"`
+	ResolvedBy      *uint      `json:"resolved_by"`
+	ResolvedAt      *time.Time `json:"resolved_at"`
+	CollectedAt     time.Time  `json:"collected_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	Order           *Order     `gorm:"foreignKey:OrderID" json:"order,omitempty"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// FeeRule sets the platform fee for orders it matches. Empty criteria match
+// any order; when several active rules match, the highest priority wins and
+// then the most specific one.
+type FeeRule struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Name          string     `gorm:"not null" json:"name"`
+	CategoryID    *uint      `gorm:"index" json:"category_id"` // taxonomy category or crop
+	Category      string     `gorm:"index" json:"category"`    // slug of CategoryID, for display
+	OrderType     string     `gorm:"index" json:"order_type"`  // standard/bulk/harvest_request
+	FarmerBadge   string     `json:"farmer_badge"`             // GOLD/SILVER/BRONZE
+	NewFarmerDays int        `json:"new_farmer_days"`          // only farmers who joined within this many days
+	RatePercent   float64    `gorm:"not null" json:"rate_percent"`
+	MinFee        float64    `json:"min_fee"`
+	MaxFee        float64    `json:"max_fee"` // 0 means uncapped
+	StartsAt      *time.Time `json:"starts_at"`
+	EndsAt        *time.Time `json:"ends_at"`
+	Priority      int        `json:"priority"`
+	IsActive      bool       `gorm:"not null" json:"is_active"`
+	UpdatedBy     *uint      `json:"updated_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -18,10 +18,24 @@ type HarvestRequest struct {
 	PreferredHarvestDate  time.Time      `gorm:"not null" json:"preferred_harvest_date"`
 	DeliveryAddress       string         `json:"delivery_address"`
 	BuyerNote             string         `json:"buyer_note"`
-	Status                string         `gorm:"default:'pending';index" json:"status"`
+	Status                string         `gorm:"default:'pending';index" json:"status"` // pending/countered/accepted/declined/rejected/ready/completed/cancelled/expired
 	FarmerResponseNote    string         `json:"farmer_response_note"`
 	RespondedAt           *time.Time     `json:"responded_at"`
+	ResponseDeadline      *time.Time     `gorm:"index" json:"response_deadline"`
+	ReminderSentAt        *time.Time     `json:"reminder_sent_at"`
+	ExpiredAt             *time.Time     `json:"expired_at"`
+	CounterQuantity       *float64       `json:"counter_quantity"`
+	CounterHarvestDate    *time.Time     `json:"counter_harvest_date"`
+	CounterPricePerUnit   *float64       `json:"counter_price_per_unit"`
+	CounterNote           string         `json:"counter_note"`
+	CounteredAt           *time.Time     `json:"countered_at"`
+	CounterExpiresAt      *time.Time     `gorm:"index" json:"counter_expires_at"`
+	BuyerResponseNote     string         `json:"buyer_response_note"`
+	AgreedQuantity        *float64       `json:"agreed_quantity"`
+	AgreedHarvestDate     *time.Time     `json:"agreed_harvest_date"`
+	AgreedPricePerUnit    *float64       `json:"agreed_price_per_unit"`
 	ConvertedOrderID      *uint          `json:"converted_order_id"`
+	ConvertedAt           *time.Time     `json:"converted_at"`
 	ConvertedOrder        *Order         `gorm:"foreignKey:ConvertedOrderID" json:"converted_order,omitempty"`
 	CreatedAt             time.Time      `json:"created_at"`
 	UpdatedAt             time.Time      `json:"updated_at"`
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// LedgerEntry is one posting in the append-only double-entry ledger. Entries
+// sharing a JournalID form a single balanced transaction whose debits equal
+// its credits. Entries are never updated or deleted; corrections are posted
+// as new journals.
+type LedgerEntry struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	JournalID string    `gorm:"not null;index" json:"journal_id"`
+	Kind      string    `gorm:"not null;index" json:"kind"` // buyer_payment/settlement/refund/cancellation/farmer_payout/cod_adjustment/cod_remittance/wallet_payment/wallet_credit/credit_repayment/promotion
+	OrderID   *uint     `gorm:"index" json:"order_id,omitempty"`
+	Account   string    `gorm:"not null;index" json:"account"` // cash/escrow/platform_fee/farmer_payable/buyer_receivable
+	UserID    *uint     `gorm:"index" json:"user_id,omitempty"`
+	Debit     float64   `gorm:"not null;default:0" json:"debit"`
+	Credit    float64   `gorm:"not null;default:0" json:"credit"`
+	Memo      string    `json:"memo"`
+	CreatedBy *uint     `json:"created_by,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
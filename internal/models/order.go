@@ -10,17 +10,37 @@ type Order struct {
 	ID                   uint            `gorm:"primaryKey" json:"id"`
 	ProductID            uint            `gorm:"not null" json:"product_id"`
 	Product              Product         `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	VariantID            *uint           `gorm:"index" json:"variant_id"`
+	VariantSKU           string          `json:"variant_sku"`
+	VariantLabel         string          `json:"variant_label"` // grade/size/packaging at order time
 	BuyerID              uint            `gorm:"not null" json:"buyer_id"`
 	Buyer                User            `gorm:"foreignKey:BuyerID" json:"buyer,omitempty"`
 	FarmerID             uint            `gorm:"not null" json:"farmer_id"`
 	Farmer               User            `gorm:"foreignKey:FarmerID" json:"farmer,omitempty"`
 	Quantity             float64         `gorm:"not null" json:"quantity"`
+	Unit                 string          `json:"unit"` // the listing's unit when the order was placed
 	TotalPrice           float64         `gorm:"not null" json:"total_price"`
 	OrderType            string          `gorm:"default:'standard';index" json:"order_type"`
 	BuyerNote            string          `json:"buyer_note"`
 	PaymentMethod        string          `gorm:"default:'cod';index" json:"payment_method"`
 	PaymentReference     string          `json:"payment_reference"`
-	PaymentStatus        string          `gorm:"default:'pending';index" json:"payment_status"`
+	PaymentStatus        string          `gorm:"default:'pending';index" json:"payment_status"` // pending/initiated/paid/partially_paid/failed/refunded/partially_refunded
+	PaymentProvider      string          `json:"payment_provider"`
+	PaymentIntentID      string          `gorm:"index" json:"payment_intent_id"`
+	PaidAt               *time.Time      `json:"paid_at"`
+	WalletAmount         float64         `gorm:"default:0" json:"wallet_amount"` // paid from the buyer's wallet
+	CreditDueAt          *time.Time      `gorm:"index" json:"credit_due_at"`     // when a credit order must be settled
+	CreditReminderAt     *time.Time      `json:"credit_reminder_at"`             // last overdue reminder
+	PromotionID          *uint           `gorm:"index" json:"promotion_id"`
+	PromotionCode        string          `json:"promotion_code"`
+	DiscountAmount       float64         `gorm:"default:0" json:"discount_amount"` // taken off the list price; TotalPrice is after it
+	DiscountFundedBy     string          `json:"discount_funded_by"`               // platform/farmer
+	FeeRuleID            *uint           `json:"fee_rule_id"`
+	PlatformFeePercent   float64         `json:"platform_fee_percent"`
+	PlatformFeeMin       float64         `json:"platform_fee_min"`
+	PlatformFeeMax       float64         `json:"platform_fee_max"`
+	PlatformFee          float64         `json:"platform_fee"`
+	FarmerPayoutID       *uint           `gorm:"index" json:"farmer_payout_id"` // payout that settled this order to the farmer
 	ExpiresAt            *time.Time      `json:"expires_at"`
 	PreferredDate        *time.Time      `json:"preferred_date"`
 	SourceRequestID      *uint           `json:"source_request_id"`
@@ -32,8 +52,24 @@ type Order struct {
 	CancellationReason   string          `json:"cancellation_reason"`
 	CancellationType     string          `json:"cancellation_type"`
 	CancellationNote     string          `json:"cancellation_note"`
-	DisputeStatus        string          `gorm:"default:'none';index" json:"dispute_status"` // none/open/resolved/rejected
+	CancelledBy          *uint           `json:"cancelled_by"`
+	CancelledByRole      string          `gorm:"index" json:"cancelled_by_role"` // buyer/farmer
+	CancellationFee      float64         `gorm:"default:0" json:"cancellation_fee"`
+	DisputeStatus        string          `gorm:"default:'none';index" json:"dispute_status"` // none/open/resolved/rejected/escalated/decided
 	DisputeNote          string          `json:"dispute_note"`
+	DisputeCategory      string          `json:"dispute_category"`
+	DisputeRemedy        string          `json:"dispute_remedy"` // refund/partial_refund/replacement/other
+	DisputeClaimAmount   float64         `gorm:"default:0" json:"dispute_claim_amount"`
+	DisputeOpenedBy      *uint           `json:"dispute_opened_by"`
+	DisputeOpenedAt      *time.Time      `json:"dispute_opened_at"`
+	DisputeResponseDueAt *time.Time      `json:"dispute_response_due_at"`
+	DisputeEscalatedBy   *uint           `json:"dispute_escalated_by"`
+	DisputeEscalatedAt   *time.Time      `json:"dispute_escalated_at"`
+	DisputeDecision      string          `json:"dispute_decision"` // refund/partial_refund/no_action
+	DisputeDecidedBy     *uint           `json:"dispute_decided_by"`
+	DisputeDecidedAt     *time.Time      `json:"dispute_decided_at"`
+	RefundAmount         float64         `gorm:"default:0" json:"refund_amount"`
+	WaivedAmount         float64         `gorm:"default:0" json:"waived_amount"` // refunded before the buyer paid, so no longer owed
 	AdminReviewStatus    string          `gorm:"default:'open';index" json:"admin_review_status"`
 	AdminReviewNote      string          `json:"admin_review_note"`
 	AdminReviewedBy      *uint           `json:"admin_reviewed_by"`
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type OrderAmendment struct {
+	ID                 uint       `gorm:"primaryKey" json:"id"`
+	OrderID            uint       `gorm:"not null;index" json:"order_id"`
+	RequestedBy        uint       `gorm:"not null;index" json:"requested_by"`
+	Status             string     `gorm:"default:'pending';index" json:"status"` // pending/approved/declined
+	NewQuantity        *float64   `json:"new_quantity"`
+	NewDeliveryAddress string     `json:"new_delivery_address"`
+	NewDeliveryDate    *time.Time `json:"new_delivery_date"`
+	NewDeliverySlot    string     `json:"new_delivery_slot"`
+	Reason             string     `json:"reason"`
+	FarmerNote         string     `json:"farmer_note"`
+	PriceDelta         float64    `gorm:"default:0" json:"price_delta"`
+	AppliedDiff        string     `gorm:"type:text" json:"applied_diff"`
+	RespondedBy        *uint      `json:"responded_by"`
+	RespondedAt        *time.Time `json:"responded_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
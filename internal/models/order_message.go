@@ -10,4 +10,6 @@ type OrderMessage struct {
 	SenderRole string    `gorm:"not null" json:"sender_role"`
 	Message    string    `gorm:"type:text;not null" json:"message"`
 	CreatedAt  time.Time `json:"created_at"`
+
+	Attachments []OrderMessageAttachment `gorm:"foreignKey:MessageID" json:"attachments"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// OrderMessageAttachment is a file sent in an order thread. URL is where the
+// file is stored; it is not served publicly, so clients fetch it from
+// DownloadURL, which checks the caller is a party to the order.
+type OrderMessageAttachment struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	MessageID   uint      `gorm:"not null;index" json:"message_id"`
+	URL         string    `gorm:"not null" json:"-"`
+	DownloadURL string    `gorm:"-" json:"download_url"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// OrderMessageRead is a participant's read marker for an order thread: every
+// message up to and including LastReadMessageID has been seen.
+type OrderMessageRead struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	OrderID           uint      `gorm:"not null;uniqueIndex:idx_order_message_reads_order_user" json:"order_id"`
+	UserID            uint      `gorm:"not null;uniqueIndex:idx_order_message_reads_order_user;index" json:"user_id"`
+	LastReadMessageID uint      `gorm:"not null;default:0" json:"last_read_message_id"`
+	ReadAt            time.Time `json:"read_at"`
+}
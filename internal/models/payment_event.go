@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PaymentEvent records every interaction with a payment provider for an
+// order: intents created, refunds requested and webhook status changes.
+type PaymentEvent struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	OrderID         uint      `gorm:"not null;index" json:"order_id"`
+	Provider        string    `gorm:"not null;index" json:"provider"`
+	Type            string    `gorm:"not null" json:"type"` // intent/webhook/refund_request
+	Status          string    `json:"status"`
+	Amount          float64   `json:"amount"`
+	ProviderRef     string    `gorm:"index" json:"provider_ref"`
+	ExternalEventID string    `gorm:"index" json:"external_event_id"`
+	OccurredAt      time.Time `gorm:"index" json:"occurred_at"`
+	CreatedAt       time.Time `json:"created_at"`
+}
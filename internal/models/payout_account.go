@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// PayoutAccount is where a farmer is paid: a bank account with IFSC or a UPI
+// ID. Only accounts verified by an admin receive settlements, and a farmer
+// has at most one default account.
+type PayoutAccount struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	FarmerID          uint       `gorm:"not null;index" json:"farmer_id"`
+	Method            string     `gorm:"not null" json:"method"` // bank/upi
+	AccountHolderName string     `json:"account_holder_name"`
+	AccountNumber     string     `json:"-"`
+	AccountLast4      string     `json:"account_last4"`
+	IFSC              string     `json:"ifsc"`
+	BankName          string     `json:"bank_name"`
+	UPIID             string     `json:"upi_id"`
+	Status            string     `gorm:"default:'pending';index" json:"status"` // pending/verified/rejected
+	IsDefault         bool       `gorm:"not null;default:false" json:"is_default"`
+	ReviewNote        string     `json:"review_note"`
+	ReviewedBy        *uint      `json:"reviewed_by"`
+	ReviewedAt        *time.Time `json:"reviewed_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
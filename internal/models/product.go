@@ -11,19 +11,25 @@ type Product struct {
 	FarmerID               uint           `gorm:"not null" json:"farmer_id"`
 	Farmer                 User           `gorm:"foreignKey:FarmerID" json:"farmer,omitempty"`
 	CropName               string         `gorm:"not null;index" json:"crop_name"`
-	Category               string         `gorm:"index" json:"category"`
+	Category               string         `gorm:"index" json:"category"` // slug of the top-level taxonomy category
+	CategoryID             *uint          `gorm:"index" json:"category_id"`
+	CropID                 *uint          `gorm:"index" json:"crop_id"`
+	HSNCode                string         `json:"hsn_code"`
 	Quantity               float64        `gorm:"not null" json:"quantity"`
-	Unit                   string         `gorm:"not null" json:"unit"` // kg, quintal, ton
+	Unit                   string         `gorm:"not null" json:"unit"` // unit registry code: kg, quintal, ton, litre, ...
 	PricePerUnit           float64        `gorm:"not null" json:"price_per_unit"`
 	Description            string         `json:"description"`
 	City                   string         `gorm:"index" json:"city"`
 	State                  string         `gorm:"index" json:"state"`
 	ImageURL               string         `json:"image_url"`
-	Status                 string         `gorm:"default:'active'" json:"status"` // active/sold/expired
+	Status                 string         `gorm:"default:'active'" json:"status"` // active/sold/expired/draft/pending_review/rejected
 	IsBulkAvailable        bool           `gorm:"default:false;index" json:"is_bulk_available"`
-	MinimumBulkQuantity    float64        `gorm:"default:0" json:"minimum_bulk_quantity"`
+	MinimumBulkQuantity    float64        `gorm:"default:0" json:"minimum_bulk_quantity"` // in Unit
 	SupportsHarvestRequest bool           `gorm:"default:true;index" json:"supports_harvest_request"`
 	HarvestLeadDays        int            `gorm:"default:0" json:"harvest_lead_days"`
+	HarvestedAt            *time.Time     `json:"harvested_at"`
+	BestBefore             *time.Time     `gorm:"index" json:"best_before"`
+	ExpiredAt              *time.Time     `json:"expired_at"`
 	ModerationNote         string         `json:"moderation_note"`
 	ReviewedBy             *uint          `json:"reviewed_by"`
 	ReviewedAt             *time.Time     `json:"reviewed_at"`
@@ -32,5 +38,8 @@ type Product struct {
 	DeletedAt              gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Relationships
-	Orders []Order `gorm:"foreignKey:ProductID" json:"orders,omitempty"`
+	Orders     []Order            `gorm:"foreignKey:ProductID" json:"orders,omitempty"`
+	PriceTiers []ProductPriceTier `gorm:"foreignKey:ProductID" json:"price_tiers,omitempty"`
+	Images     []ProductImage     `gorm:"foreignKey:ProductID" json:"images,omitempty"`
+	Variants   []ProductVariant   `gorm:"foreignKey:ProductID" json:"variants,omitempty"`
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ProductImage is one picture in a product's gallery. Position orders the
+// gallery from 0 and exactly one image is primary; the product's ImageURL
+// mirrors the primary image so single-image clients keep working.
+type ProductImage struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ProductID    uint      `gorm:"not null;index" json:"product_id"`
+	URL          string    `gorm:"not null" json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	AltText      string    `json:"alt_text"`
+	Position     int       `gorm:"not null;default:0" json:"position"`
+	IsPrimary    bool      `gorm:"default:false" json:"is_primary"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
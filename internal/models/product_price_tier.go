@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ProductPriceTier is a quantity break: orders of at least MinQuantity pay
+// PricePerUnit instead of the product's base price.
+type ProductPriceTier struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ProductID    uint      `gorm:"not null;index" json:"product_id"`
+	MinQuantity  float64   `gorm:"not null" json:"min_quantity"`
+	PricePerUnit float64   `gorm:"not null" json:"price_per_unit"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ProductVariant is one sellable version of a listing: a grade, size or
+// packaging with its own price, stock and minimum order. When a listing has
+// active variants its Quantity is the sum of their stock and orders must
+// name one.
+type ProductVariant struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	ProductID        uint      `gorm:"not null;index" json:"product_id"`
+	SKU              string    `gorm:"index" json:"sku"`
+	Grade            string    `json:"grade"`     // A/B/export
+	Size             string    `json:"size"`      // small/medium/large
+	Packaging        string    `json:"packaging"` // 5 kg crate/25 kg sack
+	PricePerUnit     float64   `gorm:"not null" json:"price_per_unit"`
+	Quantity         float64   `gorm:"not null;default:0" json:"quantity"`
+	MinOrderQuantity float64   `gorm:"default:0" json:"min_order_quantity"`
+	IsActive         bool      `gorm:"default:true;index" json:"is_active"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
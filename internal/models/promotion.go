@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Promotion discounts orders that meet its eligibility rules. A promotion with
+// a code applies only when the buyer enters it; one without a code applies
+// automatically. Whoever funds it bears the discount: a farmer-funded
+// promotion lowers the farmer's sale, a platform-funded one is paid to the
+// farmer by the platform on delivery.
+type Promotion struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Name           string     `gorm:"not null" json:"name"`
+	Code           string     `gorm:"index" json:"code"`             // empty for automatic promotions
+	DiscountType   string     `gorm:"not null" json:"discount_type"` // percent/flat
+	DiscountValue  float64    `gorm:"not null" json:"discount_value"`
+	MaxDiscount    float64    `json:"max_discount"`                                 // 0 means uncapped
+	FundedBy       string     `gorm:"not null;default:'platform'" json:"funded_by"` // platform/farmer
+	FarmerID       *uint      `gorm:"index" json:"farmer_id"`
+	CategoryID     *uint      `gorm:"index" json:"category_id"` // taxonomy category or crop
+	Category       string     `json:"category"`                 // slug of CategoryID, for display
+	ProductID      *uint      `json:"product_id"`
+	FirstOrderOnly bool       `gorm:"not null;default:false" json:"first_order_only"`
+	MinOrderValue  float64    `json:"min_order_value"`
+	UsageLimit     int        `json:"usage_limit"`     // 0 means unlimited
+	PerBuyerLimit  int        `json:"per_buyer_limit"` // 0 means unlimited
+	StartsAt       *time.Time `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	IsActive       bool       `gorm:"not null" json:"is_active"`
+	CreatedBy      uint       `json:"created_by"`
+	UpdatedBy      *uint      `json:"updated_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// SettlementBatch groups farmer payouts sent to the bank together. A batch
+// covers completed orders older than its hold period.
+type SettlementBatch struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Reference   string         `gorm:"uniqueIndex;not null" json:"reference"`
+	Status      string         `gorm:"default:'pending';index" json:"status"` // pending/exported/completed
+	HoldDays    int            `json:"hold_days"`
+	CutoffAt    time.Time      `json:"cutoff_at"` // orders completed before this are eligible
+	TotalAmount float64        `json:"total_amount"`
+	PayoutCount int            `json:"payout_count"`
+	CreatedBy   uint           `json:"created_by"`
+	ExportedAt  *time.Time     `json:"exported_at"`
+	CompletedAt *time.Time     `json:"completed_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	Payouts     []FarmerPayout `gorm:"foreignKey:BatchID" json:"payouts,omitempty"`
+}
+
+// FarmerPayout is one transfer to a farmer. A farmer's withdrawal request
+// starts without a batch; settlement batches pick it up along with every
+// other eligible farmer.
+type FarmerPayout struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	BatchID         *uint          `gorm:"index" json:"batch_id"`
+	FarmerID        uint           `gorm:"not null;index" json:"farmer_id"`
+	Farmer          User           `gorm:"foreignKey:FarmerID" json:"farmer,omitempty"`
+	PayoutAccountID uint           `gorm:"not null" json:"payout_account_id"`
+	PayoutAccount   *PayoutAccount `gorm:"foreignKey:PayoutAccountID" json:"payout_account,omitempty"`
+	Amount          float64        `gorm:"not null" json:"amount"`
+	OrderCount      int            `json:"order_count"`
+	Status          string         `gorm:"default:'requested';index" json:"status"` // requested/pending/paid/failed
+	UTR             string         `gorm:"index" json:"utr"`
+	FailureReason   string         `json:"failure_reason"`
+	RequestedAt     *time.Time     `json:"requested_at"`
+	PaidAt          *time.Time     `json:"paid_at"`
+	UpdatedBy       *uint          `json:"updated_by"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type Shipment struct {
+	ID                uint            `gorm:"primaryKey" json:"id"`
+	OrderID           uint            `gorm:"not null;index" json:"order_id"`
+	Carrier           string          `gorm:"not null;index" json:"carrier"`
+	TrackingNumber    string          `gorm:"not null;index" json:"tracking_number"` // AWB / consignment number
+	Status            string          `gorm:"default:'created';index" json:"status"` // created/picked_up/in_transit/out_for_delivery/delivered/exception/returned
+	LastLocation      string          `json:"last_location"`
+	EstimatedDelivery *time.Time      `json:"estimated_delivery"`
+	LastEventAt       *time.Time      `json:"last_event_at"`
+	CreatedBy         uint            `gorm:"not null" json:"created_by"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+	Events            []ShipmentEvent `gorm:"foreignKey:ShipmentID" json:"events,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+type ShipmentEvent struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	ShipmentID      uint      `gorm:"not null;index" json:"shipment_id"`
+	OrderID         uint      `gorm:"not null;index" json:"order_id"`
+	Status          string    `gorm:"not null" json:"status"`
+	Location        string    `json:"location"`
+	Description     string    `json:"description"`
+	ExternalEventID string    `gorm:"index" json:"external_event_id"`
+	Source          string    `json:"source"` // farmer/webhook
+	OccurredAt      time.Time `gorm:"index" json:"occurred_at"`
+	CreatedAt       time.Time `json:"created_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// StockMovement is one change to a listing's stock in the append-only
+// inventory ledger. Delta is the signed change to the listing's quantity, so
+// a product's movements sum to its current quantity. A sale moves no stock of
+// its own: it records reserved stock leaving with the buyer, with Quantity
+// set and a zero Delta. Adjustments to an inactive variant are recorded the
+// same way, since that stock is not part of the listing's quantity.
+// Movements are never updated or deleted.
+type StockMovement struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ProductID    uint      `gorm:"not null;index" json:"product_id"`
+	VariantID    *uint     `gorm:"index" json:"variant_id,omitempty"`
+	Kind         string    `gorm:"not null;index" json:"kind"` // opening/reserve/release/sale/adjustment/spoilage
+	Quantity     float64   `gorm:"not null" json:"quantity"`   // amount moved, always positive
+	Delta        float64   `gorm:"not null" json:"delta"`
+	BalanceAfter float64   `gorm:"not null" json:"balance_after"`
+	ActorID      *uint     `gorm:"index" json:"actor_id,omitempty"` // nil for system changes
+	OrderID      *uint     `gorm:"index" json:"order_id,omitempty"`
+	Reference    string    `json:"reference"` // e.g. order, cart_checkout, amendment, listing_edit
+	Note         string    `json:"note"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
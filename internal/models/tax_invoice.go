@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// TaxInvoice is the GST invoice issued by the farmer when an order is
+// delivered. It is a snapshot: later changes to tax rates, GSTINs or
+// addresses never alter an issued invoice.
+type TaxInvoice struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrderID        uint      `gorm:"not null;uniqueIndex" json:"order_id"`
+	SellerID       uint      `gorm:"not null;index" json:"seller_id"`
+	BuyerID        uint      `gorm:"not null;index" json:"buyer_id"`
+	InvoiceNumber  string    `gorm:"not null;uniqueIndex" json:"invoice_number"`
+	FinancialYear  string    `gorm:"not null" json:"financial_year"`
+	Sequence       int       `gorm:"not null" json:"sequence"`
+	IssuedAt       time.Time `json:"issued_at"`
+	SellerName     string    `json:"seller_name"`
+	SellerGSTIN    string    `json:"seller_gstin"`
+	SellerState    string    `json:"seller_state"`
+	BuyerName      string    `json:"buyer_name"`
+	BuyerGSTIN     string    `json:"buyer_gstin"`
+	PlaceOfSupply  string    `json:"place_of_supply"`
+	SupplyType     string    `json:"supply_type"` // intra_state/inter_state
+	HSNCode        string    `json:"hsn_code"`
+	Description    string    `json:"description"`
+	Quantity       float64   `json:"quantity"`
+	Unit           string    `json:"unit"`
+	TaxExempt      bool      `json:"tax_exempt"`
+	TaxRatePercent float64   `json:"tax_rate_percent"`
+	Discount       float64   `json:"discount"` // already taken off TotalAmount
+	TaxableValue   float64   `json:"taxable_value"`
+	CGSTAmount     float64   `json:"cgst_amount"`
+	SGSTAmount     float64   `json:"sgst_amount"`
+	IGSTAmount     float64   `json:"igst_amount"`
+	TotalTax       float64   `json:"total_tax"`
+	TotalAmount    float64   `json:"total_amount"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// InvoiceSequence hands out invoice numbers per seller and financial year.
+type InvoiceSequence struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	SellerID      uint      `gorm:"not null;uniqueIndex:idx_invoice_sequences_seller_year" json:"seller_id"`
+	FinancialYear string    `gorm:"not null;uniqueIndex:idx_invoice_sequences_seller_year" json:"financial_year"`
+	LastNumber    int       `gorm:"not null;default:0" json:"last_number"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
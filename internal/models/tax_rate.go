@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// TaxRate is the GST configuration for an HSN code or, with an empty HSN
+// code, the default for a taxonomy category or crop. Category holds the
+// node's slug, which is unique, so it also keys the rate.
+type TaxRate struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	HSNCode     string    `gorm:"uniqueIndex:idx_tax_rates_hsn_category" json:"hsn_code"`
+	CategoryID  *uint     `gorm:"index" json:"category_id"`
+	Category    string    `gorm:"uniqueIndex:idx_tax_rates_hsn_category" json:"category"`
+	Description string    `json:"description"`
+	RatePercent float64   `gorm:"not null" json:"rate_percent"`
+	IsExempt    bool      `gorm:"not null" json:"is_exempt"`
+	UpdatedBy   *uint     `json:"updated_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// TaxonomyNode is one entry in the admin-managed crop taxonomy: a category
+// (Vegetables, Leafy) or a crop (Spinach). Categories nest; crops are leaves.
+// Path holds the ids from the root, e.g. "/1/4/9/", so a subtree is every
+// node whose path starts with its root's.
+type TaxonomyNode struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	ParentID      *uint          `gorm:"index" json:"parent_id"`
+	Kind          string         `gorm:"not null;default:'category';index" json:"kind"` // category/crop
+	Slug          string         `gorm:"uniqueIndex;not null" json:"slug"`
+	Name          string         `gorm:"not null" json:"name"`
+	Path          string         `gorm:"index" json:"path"`
+	Depth         int            `gorm:"default:0" json:"depth"`
+	SortOrder     int            `gorm:"default:0" json:"sort_order"`
+	ShelfLifeDays int            `gorm:"default:0" json:"shelf_life_days"` // days produce keeps after harvest; 0 inherits the parent's
+	IsActive      bool           `gorm:"default:true" json:"is_active"`
+	CreatedBy     uint           `json:"created_by"`
+	UpdatedBy     uint           `json:"updated_by"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Names         []TaxonomyName `gorm:"foreignKey:NodeID" json:"names,omitempty"`
+	Children      []TaxonomyNode `gorm:"-" json:"children,omitempty"`
+}
+
+// TaxonomyName is another name a taxonomy node is known by: its name in a
+// local language (Kind "local" with a Language such as "hi") or an alias
+// buyers and farmers search for (Kind "alias").
+type TaxonomyName struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	NodeID    uint      `gorm:"not null;index" json:"node_id"`
+	Kind      string    `gorm:"not null;default:'alias'" json:"kind"` // local/alias
+	Language  string    `json:"language"`
+	Name      string    `gorm:"not null;index" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
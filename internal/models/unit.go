@@ -0,0 +1,67 @@
+package models
+
+import "strings"
+
+// UnitOfMeasure is a unit listings can be sold in. Factor is how many of the
+// base unit of its dimension one of it holds, so 1 quintal = 100 kg.
+type UnitOfMeasure struct {
+	Code      string   `json:"code"`
+	Name      string   `json:"name"`
+	Dimension string   `json:"dimension"` // mass, volume, count
+	BaseUnit  string   `json:"base_unit"`
+	Factor    float64  `json:"factor"`
+	Aliases   []string `json:"aliases,omitempty"`
+}
+
+var units = []UnitOfMeasure{
+	{Code: "g", Name: "Gram", Dimension: "mass", BaseUnit: "kg", Factor: 0.001, Aliases: []string{"gm", "gms", "gram", "grams"}},
+	{Code: "kg", Name: "Kilogram", Dimension: "mass", BaseUnit: "kg", Factor: 1, Aliases: []string{"kgs", "kilo", "kilos", "kilogram", "kilograms"}},
+	{Code: "quintal", Name: "Quintal", Dimension: "mass", BaseUnit: "kg", Factor: 100, Aliases: []string{"q", "qtl", "qtls", "quintals"}},
+	{Code: "ton", Name: "Tonne", Dimension: "mass", BaseUnit: "kg", Factor: 1000, Aliases: []string{"t", "mt", "tons", "tonne", "tonnes"}},
+	{Code: "ml", Name: "Millilitre", Dimension: "volume", BaseUnit: "litre", Factor: 0.001, Aliases: []string{"millilitre", "milliliter", "millilitres", "milliliters"}},
+	{Code: "litre", Name: "Litre", Dimension: "volume", BaseUnit: "litre", Factor: 1, Aliases: []string{"l", "ltr", "ltrs", "liter", "liters", "litres"}},
+	{Code: "piece", Name: "Piece", Dimension: "count", BaseUnit: "piece", Factor: 1, Aliases: []string{"pc", "pcs", "pieces", "nos", "unit", "units"}},
+	{Code: "dozen", Name: "Dozen", Dimension: "count", BaseUnit: "piece", Factor: 12, Aliases: []string{"dz", "doz", "dozens"}},
+}
+
+// Units returns the unit registry.
+func Units() []UnitOfMeasure {
+	return append([]UnitOfMeasure(nil), units...)
+}
+
+// LookupUnit finds a unit by its code or one of its aliases.
+func LookupUnit(text string) (UnitOfMeasure, bool) {
+	clean := strings.ToLower(strings.TrimSpace(text))
+	for _, unit := range units {
+		if unit.Code == clean {
+			return unit, true
+		}
+		for _, alias := range unit.Aliases {
+			if alias == clean {
+				return unit, true
+			}
+		}
+	}
+	return UnitOfMeasure{}, false
+}
+
+// BaseUnitOf returns the base unit and factor for a listing's unit. Units
+// outside the registry are treated as their own base unit.
+func BaseUnitOf(text string) (string, float64) {
+	if unit, ok := LookupUnit(text); ok {
+		return unit.BaseUnit, unit.Factor
+	}
+	return strings.ToLower(strings.TrimSpace(text)), 1
+}
+
+// ToBaseQuantity converts a quantity in the given unit to its base unit.
+func ToBaseQuantity(quantity float64, unit string) float64 {
+	_, factor := BaseUnitOf(unit)
+	return quantity * factor
+}
+
+// ToBasePrice converts a price per the given unit to a price per base unit.
+func ToBasePrice(price float64, unit string) float64 {
+	_, factor := BaseUnitOf(unit)
+	return price / factor
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Upload records who saved a file through the upload endpoints, so a file can
+// only be attached to, and deleted from, its uploader's listings.
+type Upload struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	URL        string    `gorm:"not null;uniqueIndex" json:"url"`
+	UploaderID uint      `gorm:"not null;index" json:"uploader_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -20,6 +20,7 @@ type User struct {
 	VerifiedAt         *time.Time `json:"verified_at"`
 	City      string         `json:"city"`
 	State     string         `json:"state"`
+	GSTIN     string         `gorm:"index" json:"gstin"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
@@ -40,6 +41,8 @@ type FarmerProfile struct {
 	RatingAverage   float64 `gorm:"default:0" json:"rating_average"`
 	TotalOrders     int     `gorm:"default:0" json:"total_orders"`
 	CompletedOrders int     `gorm:"default:0" json:"completed_orders"`
+	DisputesLost    int     `gorm:"default:0" json:"disputes_lost"`
+	CancelledOrders int     `gorm:"default:0" json:"cancelled_orders"` // cancelled by the farmer
 	TrustScore      float64 `gorm:"default:0" json:"trust_score"`
 	Badge           string  `gorm:"default:'BRONZE'" json:"badge"` // GOLD, SILVER, BRONZE
 	CreatedAt       time.Time `json:"created_at"`
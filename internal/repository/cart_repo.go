@@ -15,16 +15,24 @@ func NewCartRepository(db *gorm.DB) *CartRepository {
 
 func (r *CartRepository) GetItemsByBuyerID(buyerID uint) ([]models.CartItem, error) {
 	var items []models.CartItem
-	err := r.db.Preload("Product").Preload("Product.Farmer").Preload("Product.Farmer.FarmerProfile").
+	err := r.db.Preload("Product").Preload("Product.Farmer").Preload("Product.Farmer.FarmerProfile").Preload("Variant").
 		Where("buyer_id = ?", buyerID).
 		Order("updated_at DESC").
 		Find(&items).Error
 	return items, err
 }
 
-func (r *CartRepository) GetByBuyerAndProduct(buyerID, productID uint) (*models.CartItem, error) {
+// GetByBuyerAndProduct finds the cart line for a product, or for one of its
+// variants when variantID is set.
+func (r *CartRepository) GetByBuyerAndProduct(buyerID, productID uint, variantID *uint) (*models.CartItem, error) {
 	var item models.CartItem
-	err := r.db.Where("buyer_id = ? AND product_id = ?", buyerID, productID).First(&item).Error
+	query := r.db.Where("buyer_id = ? AND product_id = ?", buyerID, productID)
+	if variantID != nil {
+		query = query.Where("variant_id = ?", *variantID)
+	} else {
+		query = query.Where("variant_id IS NULL")
+	}
+	err := query.First(&item).Error
 	if err != nil {
 		return nil, err
 	}
@@ -1,8 +1,12 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"github.com/f2b-portal/backend/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderRepository struct {
@@ -74,6 +78,112 @@ func (r *OrderRepository) GetTotalOrdersByFarmer(farmerID uint) (int64, error) {
 	return count, err
 }
 
+// CountDisputesLostByFarmer counts disputes settled with a refund to the buyer.
+func (r *OrderRepository) CountDisputesLostByFarmer(farmerID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Order{}).
+		Where("farmer_id = ? AND dispute_status IN ? AND refund_amount > 0", farmerID, []string{"resolved", "decided"}).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *OrderRepository) GetByPaymentIntent(provider, intentID string) (*models.Order, error) {
+	var order models.Order
+	err := r.db.Where("payment_provider = ? AND payment_intent_id = ?", provider, intentID).First(&order).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *OrderRepository) CreatePaymentEvent(item *models.PaymentEvent) error {
+	return r.db.Create(item).Error
+}
+
+func (r *OrderRepository) HasPaymentEvent(provider, externalEventID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.PaymentEvent{}).
+		Where("provider = ? AND external_event_id = ?", provider, externalEventID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *OrderRepository) GetPaymentEvents(orderID uint) ([]models.PaymentEvent, error) {
+	var items []models.PaymentEvent
+	err := r.db.Where("order_id = ?", orderID).Order("occurred_at ASC, id ASC").Find(&items).Error
+	return items, err
+}
+
+type LedgerOrderSum struct {
+	OrderID uint
+	Kind    string
+	Account string
+	Debit   float64
+	Credit  float64
+}
+
+// SumLedgerByOrder totals ledger postings per order, kind and account.
+func (r *OrderRepository) SumLedgerByOrder(orderIDs []uint) ([]LedgerOrderSum, error) {
+	var items []LedgerOrderSum
+	err := r.db.Model(&models.LedgerEntry{}).
+		Select("order_id, kind, account, SUM(debit) AS debit, SUM(credit) AS credit").
+		Where("order_id IN ?", orderIDs).
+		Group("order_id, kind, account").
+		Scan(&items).Error
+	return items, err
+}
+
+type LedgerAccountSum struct {
+	Account string
+	Debit   float64
+	Credit  float64
+}
+
+func (r *OrderRepository) SumLedgerByAccount() ([]LedgerAccountSum, error) {
+	var items []LedgerAccountSum
+	err := r.db.Model(&models.LedgerEntry{}).
+		Select("account, SUM(debit) AS debit, SUM(credit) AS credit").
+		Group("account").
+		Order("account ASC").
+		Scan(&items).Error
+	return items, err
+}
+
+// ListOrdersWithoutLedger returns orders whose money has moved but which have
+// no ledger postings yet, oldest first.
+func (r *OrderRepository) ListOrdersWithoutLedger() ([]models.Order, error) {
+	var orders []models.Order
+	err := r.db.
+		Where("status = ? OR payment_status IN ? OR (status = ? AND cancellation_fee > 0)", "completed", []string{"paid", "partially_refunded", "refunded"}, "cancelled").
+		Where("NOT EXISTS (SELECT 1 FROM ledger_entries WHERE ledger_entries.order_id = orders.id)").
+		Order("id ASC").
+		Find(&orders).Error
+	return orders, err
+}
+
+func (r *OrderRepository) GetLedgerEntriesByUser(account string, userID uint) ([]models.LedgerEntry, error) {
+	var items []models.LedgerEntry
+	err := r.db.Where("account = ? AND user_id = ?", account, userID).Order("created_at ASC, id ASC").Find(&items).Error
+	return items, err
+}
+
+func (r *OrderRepository) CountFarmerCancellations(farmerID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Order{}).
+		Where("farmer_id = ? AND status = ? AND cancelled_by_role = ?", farmerID, "cancelled", "farmer").
+		Count(&count).Error
+	return count, err
+}
+
+func (r *OrderRepository) ListEscalatedDisputes() ([]models.Order, error) {
+	var orders []models.Order
+	err := r.db.Preload("Product").Preload("Buyer").Preload("Farmer").
+		Where("dispute_status = ?", "escalated").
+		Order("dispute_escalated_at ASC").
+		Find(&orders).Error
+	return orders, err
+}
+
 func (r *OrderRepository) ListAll() ([]models.Order, error) {
 	var orders []models.Order
 	err := r.db.Preload("Product").Preload("Buyer").Preload("Farmer").
@@ -86,15 +196,176 @@ func (r *OrderRepository) CreateOrderMessage(item *models.OrderMessage) error {
 	return r.db.Create(item).Error
 }
 
+// GetOrderMessageAttachment returns an attachment only if it was sent on the
+// given order.
+func (r *OrderRepository) GetOrderMessageAttachment(orderID, attachmentID uint) (*models.OrderMessageAttachment, error) {
+	var item models.OrderMessageAttachment
+	err := r.db.Joins("JOIN order_messages ON order_messages.id = order_message_attachments.message_id").
+		Where("order_message_attachments.id = ? AND order_messages.order_id = ?", attachmentID, orderID).
+		First(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetLatestOrderEventIDs returns the newest status log and message IDs, where
+// a new event stream starts reading from.
+func (r *OrderRepository) GetLatestOrderEventIDs() (uint, uint, error) {
+	var statusLogID, messageID uint
+	if err := r.db.Model(&models.OrderStatusLog{}).Select("COALESCE(MAX(id), 0)").Scan(&statusLogID).Error; err != nil {
+		return 0, 0, err
+	}
+	if err := r.db.Model(&models.OrderMessage{}).Select("COALESCE(MAX(id), 0)").Scan(&messageID).Error; err != nil {
+		return 0, 0, err
+	}
+	return statusLogID, messageID, nil
+}
+
+// ListStatusLogsForUserSince returns status logs after afterID on orders the
+// user buys or sells, optionally only for one order, oldest first.
+func (r *OrderRepository) ListStatusLogsForUserSince(userID, orderID, afterID uint, limit int) ([]models.OrderStatusLog, error) {
+	var items []models.OrderStatusLog
+	query := r.db.Where("id > ? AND order_id IN (?)", afterID,
+		r.db.Model(&models.Order{}).Select("id").Where("buyer_id = ? OR farmer_id = ?", userID, userID))
+	if orderID > 0 {
+		query = query.Where("order_id = ?", orderID)
+	}
+	err := query.Order("id ASC").Limit(limit).Find(&items).Error
+	return items, err
+}
+
+// ListOrderMessagesForUserSince returns messages after afterID on orders the
+// user buys or sells, optionally only for one order, oldest first.
+func (r *OrderRepository) ListOrderMessagesForUserSince(userID, orderID, afterID uint, limit int) ([]models.OrderMessage, error) {
+	var items []models.OrderMessage
+	query := r.db.Preload("Sender").Preload("Attachments").Where("id > ? AND order_id IN (?)", afterID,
+		r.db.Model(&models.Order{}).Select("id").Where("buyer_id = ? OR farmer_id = ?", userID, userID))
+	if orderID > 0 {
+		query = query.Where("order_id = ?", orderID)
+	}
+	err := query.Order("id ASC").Limit(limit).Find(&items).Error
+	return items, err
+}
+
 func (r *OrderRepository) GetOrderMessages(orderID uint) ([]models.OrderMessage, error) {
 	var items []models.OrderMessage
-	err := r.db.Preload("Sender").
+	err := r.db.Preload("Sender").Preload("Attachments").
 		Where("order_id = ?", orderID).
 		Order("created_at ASC, id ASC").
 		Find(&items).Error
 	return items, err
 }
 
+// GetOrderMessagesPage returns up to limit messages in ascending id order.
+// With afterID set it returns messages newer than that id; otherwise it
+// returns the newest messages older than beforeID (or the latest when 0).
+func (r *OrderRepository) GetOrderMessagesPage(orderID, beforeID, afterID uint, limit int) ([]models.OrderMessage, error) {
+	var items []models.OrderMessage
+	query := r.db.Preload("Sender").Preload("Attachments").Where("order_id = ?", orderID)
+	if afterID > 0 {
+		err := query.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&items).Error
+		return items, err
+	}
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	if err := query.Order("id DESC").Limit(limit).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	return items, nil
+}
+
+func (r *OrderRepository) GetLatestOrderMessageID(orderID uint) (uint, error) {
+	var id uint
+	err := r.db.Model(&models.OrderMessage{}).
+		Where("order_id = ?", orderID).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&id).Error
+	return id, err
+}
+
+func (r *OrderRepository) GetMessageReads(orderID uint) ([]models.OrderMessageRead, error) {
+	var items []models.OrderMessageRead
+	err := r.db.Where("order_id = ?", orderID).Find(&items).Error
+	return items, err
+}
+
+// MarkMessagesRead moves the user's read marker forward to messageID. The
+// marker never moves backwards.
+func (r *OrderRepository) MarkMessagesRead(orderID, userID, messageID uint, at time.Time) (*models.OrderMessageRead, error) {
+	var marker models.OrderMessageRead
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("order_id = ? AND user_id = ?", orderID, userID).
+			First(&marker).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			marker = models.OrderMessageRead{OrderID: orderID, UserID: userID, LastReadMessageID: messageID, ReadAt: at}
+			return tx.Create(&marker).Error
+		}
+		if err != nil {
+			return err
+		}
+		if messageID <= marker.LastReadMessageID {
+			return nil
+		}
+		marker.LastReadMessageID = messageID
+		marker.ReadAt = at
+		return tx.Save(&marker).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &marker, nil
+}
+
+type OrderUnreadCount struct {
+	OrderID uint  `json:"order_id"`
+	Unread  int64 `json:"unread"`
+}
+
+// CountUnreadMessages returns, per order the user takes part in, how many
+// messages from the other participant are newer than the user's read marker.
+// Pass orderID 0 to cover all of the user's orders.
+func (r *OrderRepository) CountUnreadMessages(userID, orderID uint) ([]OrderUnreadCount, error) {
+	var items []OrderUnreadCount
+	query := r.db.Table("order_messages AS m").
+		Select("m.order_id AS order_id, COUNT(*) AS unread").
+		Joins("JOIN orders AS o ON o.id = m.order_id AND o.deleted_at IS NULL").
+		Joins("LEFT JOIN order_message_reads AS r ON r.order_id = m.order_id AND r.user_id = ?", userID).
+		Where("(o.buyer_id = ? OR o.farmer_id = ?)", userID, userID).
+		Where("m.sender_id <> ?", userID).
+		Where("m.id > COALESCE(r.last_read_message_id, 0)")
+	if orderID > 0 {
+		query = query.Where("m.order_id = ?", orderID)
+	}
+	err := query.Group("m.order_id").Order("m.order_id ASC").Scan(&items).Error
+	return items, err
+}
+
+func (r *OrderRepository) CreateAmendment(item *models.OrderAmendment) error {
+	return r.db.Create(item).Error
+}
+
+func (r *OrderRepository) GetAmendmentsByOrder(orderID uint) ([]models.OrderAmendment, error) {
+	var items []models.OrderAmendment
+	err := r.db.Where("order_id = ?", orderID).
+		Order("created_at DESC, id DESC").
+		Find(&items).Error
+	return items, err
+}
+
+func (r *OrderRepository) HasPendingAmendment(orderID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.OrderAmendment{}).
+		Where("order_id = ? AND status = ?", orderID, "pending").
+		Count(&count).Error
+	return count > 0, err
+}
+
 func (r *OrderRepository) CreateDisputeEvidence(item *models.DisputeEvidence) error {
 	return r.db.Create(item).Error
 }
@@ -144,7 +415,7 @@ func (r *OrderRepository) GetDisputesByFarmer(farmerID uint, page, limit int) ([
 	var orders []models.Order
 	var total int64
 	query := r.db.Model(&models.Order{}).
-		Where("farmer_id = ? AND dispute_status IN ?", farmerID, []string{"open", "resolved", "rejected"})
+		Where("farmer_id = ? AND dispute_status IN ?", farmerID, []string{"open", "resolved", "rejected", "escalated", "decided"})
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -222,3 +493,66 @@ func (r *OrderRepository) ListAllHarvestRequests() ([]models.HarvestRequest, err
 		Find(&items).Error
 	return items, err
 }
+
+// ListPendingHarvestRequestsDueBy returns pending requests whose response
+// deadline falls on or before cutoff.
+func (r *OrderRepository) ListPendingHarvestRequestsDueBy(cutoff time.Time) ([]models.HarvestRequest, error) {
+	var items []models.HarvestRequest
+	err := r.db.Preload("Product").Preload("Buyer").Preload("Farmer").
+		Where("status = ? AND response_deadline IS NOT NULL AND response_deadline <= ?", "pending", cutoff).
+		Order("response_deadline ASC").
+		Find(&items).Error
+	return items, err
+}
+
+func (r *OrderRepository) MarkHarvestReminderSent(id uint, at time.Time) error {
+	return r.db.Model(&models.HarvestRequest{}).
+		Where("id = ? AND reminder_sent_at IS NULL", id).
+		Update("reminder_sent_at", at).Error
+}
+
+// ListCounteredHarvestRequestsExpiredBy returns counter-proposals the buyer
+// has left unanswered past their expiry.
+func (r *OrderRepository) ListCounteredHarvestRequestsExpiredBy(cutoff time.Time) ([]models.HarvestRequest, error) {
+	var items []models.HarvestRequest
+	err := r.db.Preload("Product").Preload("Buyer").Preload("Farmer").
+		Where("status = ? AND counter_expires_at IS NOT NULL AND counter_expires_at <= ?", "countered", cutoff).
+		Order("counter_expires_at ASC").
+		Find(&items).Error
+	return items, err
+}
+
+// ExpireHarvestCounter moves a request to expired only if its counter is still
+// open, so a buyer answer that lands first always wins.
+func (r *OrderRepository) ExpireHarvestCounter(id uint, at time.Time) (bool, error) {
+	result := r.db.Model(&models.HarvestRequest{}).
+		Where("id = ? AND status = ?", id, "countered").
+		Updates(map[string]interface{}{"status": "expired", "expired_at": at})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ExpireHarvestRequest moves a request to expired only if it is still pending,
+// so a farmer response that lands first always wins.
+func (r *OrderRepository) ExpireHarvestRequest(id uint, at time.Time) (bool, error) {
+	result := r.db.Model(&models.HarvestRequest{}).
+		Where("id = ? AND status = ?", id, "pending").
+		Updates(map[string]interface{}{"status": "expired", "expired_at": at})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListOverdueCreditOrders returns unpaid credit orders past their due date
+// whose buyer has not been reminded since remindBefore.
+func (r *OrderRepository) ListOverdueCreditOrders(now, remindBefore time.Time) ([]models.Order, error) {
+	var orders []models.Order
+	err := r.db.Preload("Buyer").Preload("Product").
+		Where("payment_method = ? AND payment_status = ? AND status <> ?", "credit", "pending", "cancelled").
+		Where("credit_due_at IS NOT NULL AND credit_due_at < ?", now).
+		Where("credit_reminder_at IS NULL OR credit_reminder_at < ?", remindBefore).
+		Order("credit_due_at ASC").
+		Find(&orders).Error
+	return orders, err
+}
+
+func (r *OrderRepository) MarkCreditReminderSent(id uint, at time.Time) error {
+	return r.db.Model(&models.Order{}).Where("id = ?", id).Update("credit_reminder_at", at).Error
+}
@@ -1,29 +1,70 @@
 package repository
 
 import (
+	"strconv"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/f2b-portal/backend/internal/models"
 	"gorm.io/gorm"
 )
 
+// expirableStatuses are the listing statuses the best-before sweep expires.
+var expirableStatuses = []string{"active", "pending_review", "draft"}
+
 type ProductRepository struct {
 	db *gorm.DB
-
-	categoryColumnOnce sync.Once
-	hasCategoryColumn  bool
 }
 
 func NewProductRepository(db *gorm.DB) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
-func (r *ProductRepository) supportsCategoryColumn() bool {
-	r.categoryColumnOnce.Do(func() {
-		r.hasCategoryColumn = r.db.Migrator().HasColumn(&models.Product{}, "Category")
-	})
-	return r.hasCategoryColumn
+// orderedPriceTiers preloads tiers from the smallest quantity break upwards.
+func orderedPriceTiers(db *gorm.DB) *gorm.DB {
+	return db.Order("min_quantity ASC")
+}
+
+// orderedVariants preloads variants in the order the farmer added them.
+func orderedVariants(db *gorm.DB) *gorm.DB {
+	return db.Order("id ASC")
+}
+
+// orderedProductImages preloads the gallery in display order.
+func orderedProductImages(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC, id ASC")
+}
+
+// basePriceSQL is the listing price per base unit (kg, litre, piece), so
+// listings sold per quintal and per kg filter and sort together.
+func basePriceSQL() string {
+	var expr strings.Builder
+	expr.WriteString("(price_per_unit / CASE LOWER(TRIM(unit))")
+	for _, unit := range models.Units() {
+		factor := strconv.FormatFloat(unit.Factor, 'f', -1, 64)
+		for _, code := range append([]string{unit.Code}, unit.Aliases...) {
+			expr.WriteString(" WHEN '" + code + "' THEN " + factor)
+		}
+	}
+	expr.WriteString(" ELSE 1 END)")
+	return expr.String()
+}
+
+// unitCodesFor lists the codes and aliases of every unit measured in a base
+// unit.
+func unitCodesFor(baseUnit string) []string {
+	codes := []string{baseUnit}
+	for _, unit := range models.Units() {
+		if unit.BaseUnit == baseUnit {
+			codes = append(codes, unit.Code)
+			codes = append(codes, unit.Aliases...)
+		}
+	}
+	return codes
+}
+
+func (r *ProductRepository) GetDB() *gorm.DB {
+	return r.db
 }
 
 func (r *ProductRepository) Create(product *models.Product) error {
@@ -32,7 +73,7 @@ func (r *ProductRepository) Create(product *models.Product) error {
 
 func (r *ProductRepository) GetByID(id uint) (*models.Product, error) {
 	var product models.Product
-	err := r.db.Preload("Farmer").Preload("Farmer.FarmerProfile").Where("id = ?", id).First(&product).Error
+	err := r.db.Preload("Farmer").Preload("Farmer.FarmerProfile").Preload("PriceTiers", orderedPriceTiers).Preload("Images", orderedProductImages).Preload("Variants", orderedVariants).Where("id = ?", id).First(&product).Error
 	if err != nil {
 		return nil, err
 	}
@@ -43,7 +84,7 @@ func (r *ProductRepository) GetAll(filters map[string]interface{}, page, limit i
 	var products []models.Product
 	var total int64
 
-	query := r.db.Model(&models.Product{}).Preload("Farmer").Preload("Farmer.FarmerProfile")
+	query := r.db.Model(&models.Product{}).Preload("Farmer").Preload("Farmer.FarmerProfile").Preload("PriceTiers", orderedPriceTiers).Preload("Images", orderedProductImages).Preload("Variants", orderedVariants)
 
 	// Apply filters
 	if cropName, ok := filters["crop_name"].(string); ok && cropName != "" {
@@ -55,19 +96,22 @@ func (r *ProductRepository) GetAll(filters map[string]interface{}, page, limit i
 	if state, ok := filters["state"].(string); ok && state != "" {
 		query = query.Where("LOWER(state) = ?", state)
 	}
-	if category, ok := filters["category"].(string); ok && strings.TrimSpace(category) != "" {
-		cat := strings.ToLower(strings.TrimSpace(category))
-		if r.supportsCategoryColumn() {
-			query = query.Where("(LOWER(category) = ? OR LOWER(description) LIKE ?)", cat, "%category: "+cat+"%")
-		} else {
-			query = query.Where("LOWER(description) LIKE ?", "%category: "+cat+"%")
+	if categoryID, ok := filters["category_id"].(uint); ok && categoryID > 0 {
+		var node models.TaxonomyNode
+		if err := r.db.Select("path").Where("id = ?", categoryID).First(&node).Error; err != nil {
+			return []models.Product{}, 0, nil
 		}
+		query = query.Where("(category_id IN (?) OR crop_id IN (?))", r.taxonomySubtree(node.Path), r.taxonomySubtree(node.Path))
+	}
+	if baseUnit, ok := filters["base_unit"].(string); ok && baseUnit != "" {
+		query = query.Where("LOWER(TRIM(unit)) IN ?", unitCodesFor(baseUnit))
 	}
+	// Price filters are per base unit.
 	if minPrice, ok := filters["min_price"].(float64); ok {
-		query = query.Where("price_per_unit >= ?", minPrice)
+		query = query.Where(basePriceSQL()+" >= ?", minPrice)
 	}
 	if maxPrice, ok := filters["max_price"].(float64); ok {
-		query = query.Where("price_per_unit <= ?", maxPrice)
+		query = query.Where(basePriceSQL()+" <= ?", maxPrice)
 	}
 	if status, ok := filters["status"].(string); ok && status != "" {
 		query = query.Where("status = ?", status)
@@ -87,9 +131,9 @@ func (r *ProductRepository) GetAll(filters map[string]interface{}, page, limit i
 	if sortBy, ok := filters["sort_by"].(string); ok {
 		switch sortBy {
 		case "price_asc":
-			query = query.Order("price_per_unit ASC")
+			query = query.Order(basePriceSQL() + " ASC")
 		case "price_desc":
-			query = query.Order("price_per_unit DESC")
+			query = query.Order(basePriceSQL() + " DESC")
 		case "date_asc":
 			query = query.Order("created_at ASC")
 		case "date_desc":
@@ -113,12 +157,33 @@ func (r *ProductRepository) GetAll(filters map[string]interface{}, page, limit i
 
 func (r *ProductRepository) GetByFarmerID(farmerID uint) ([]models.Product, error) {
 	var products []models.Product
-	err := r.db.Where("farmer_id = ?", farmerID).Order("created_at DESC").Find(&products).Error
+	err := r.db.Preload("PriceTiers", orderedPriceTiers).Preload("Images", orderedProductImages).Preload("Variants", orderedVariants).Where("farmer_id = ?", farmerID).Order("created_at DESC").Find(&products).Error
 	return products, err
 }
 
 func (r *ProductRepository) Update(product *models.Product) error {
-	return r.db.Save(product).Error
+	// Tiers, images and variants have their own write paths.
+	return r.db.Omit("PriceTiers", "Images", "Variants").Save(product).Error
+}
+
+// UpdatePrice writes only the base price, so stock that orders move in the
+// meantime is left alone.
+func (r *ProductRepository) UpdatePrice(productID uint, pricePerUnit float64) error {
+	return r.db.Model(&models.Product{}).
+		Where("id = ?", productID).
+		Update("price_per_unit", pricePerUnit).Error
+}
+
+// UpdateModeration writes only a listing's review outcome.
+func (r *ProductRepository) UpdateModeration(productID uint, status, note string, reviewedBy uint, reviewedAt time.Time) error {
+	return r.db.Model(&models.Product{}).
+		Where("id = ?", productID).
+		Updates(map[string]interface{}{
+			"status":          status,
+			"moderation_note": note,
+			"reviewed_by":     reviewedBy,
+			"reviewed_at":     reviewedAt,
+		}).Error
 }
 
 func (r *ProductRepository) UpdateStatus(productID, farmerID uint, status string) error {
@@ -134,13 +199,19 @@ func (r *ProductRepository) BulkUpdateStatus(productIDs []uint, farmerID uint, s
 }
 
 func (r *ProductRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Product{}, id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", id).Delete(&models.ProductImage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Product{}, id).Error
+	})
 }
 
 func (r *ProductRepository) Search(query string, limit int) ([]models.Product, error) {
 	var products []models.Product
-	err := r.db.Preload("Farmer").Preload("Farmer.FarmerProfile").
-		Where("LOWER(crop_name) LIKE ? AND status = ?", "%"+query+"%", "active").
+	err := r.db.Preload("Farmer").Preload("Farmer.FarmerProfile").Preload("PriceTiers", orderedPriceTiers).Preload("Images", orderedProductImages).Preload("Variants", orderedVariants).
+		Where("(LOWER(crop_name) LIKE ? OR crop_id IN (?) OR category_id IN (?)) AND status = ?",
+			"%"+query+"%", r.taxonomyMatching(query), r.taxonomyMatching(query), "active").
 		Order("created_at DESC").
 		Limit(limit).
 		Find(&products).Error
@@ -164,3 +235,208 @@ func (r *ProductRepository) GetPriceHistoryByProduct(productID uint) ([]models.P
 	err := r.db.Where("product_id = ?", productID).Order("changed_at DESC").Find(&history).Error
 	return history, err
 }
+
+func (r *ProductRepository) ListStockMovements(productID uint) ([]models.StockMovement, error) {
+	var movements []models.StockMovement
+	err := r.db.Where("product_id = ?", productID).Order("created_at DESC, id DESC").Find(&movements).Error
+	return movements, err
+}
+
+// StockLedgerTotals sums the stock movements of every product.
+func (r *ProductRepository) StockLedgerTotals() (map[uint]float64, error) {
+	var rows []struct {
+		ProductID uint
+		Total     float64
+	}
+	if err := r.db.Model(&models.StockMovement{}).
+		Select("product_id, SUM(delta) AS total").
+		Group("product_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	totals := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		totals[row.ProductID] = row.Total
+	}
+	return totals, nil
+}
+
+func (r *ProductRepository) GetPriceTiers(productID uint) ([]models.ProductPriceTier, error) {
+	var tiers []models.ProductPriceTier
+	err := orderedPriceTiers(r.db).Where("product_id = ?", productID).Find(&tiers).Error
+	return tiers, err
+}
+
+// ReplacePriceTiers swaps the full tier set for a product and records every
+// added, changed or removed break in the price history, atomically.
+func (r *ProductRepository) ReplacePriceTiers(productID uint, tiers []models.ProductPriceTier, history []models.ProductPriceHistory) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", productID).Delete(&models.ProductPriceTier{}).Error; err != nil {
+			return err
+		}
+		if len(tiers) > 0 {
+			if err := tx.Create(&tiers).Error; err != nil {
+				return err
+			}
+		}
+		if len(history) > 0 {
+			if err := tx.Create(&history).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveProductImages writes a product's gallery after an add, reorder or
+// removal: removed images are deleted, the rest saved with their new
+// positions, and the product's ImageURL is pointed at the primary image.
+func (r *ProductRepository) SaveProductImages(productID uint, images []models.ProductImage, removedIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(removedIDs) > 0 {
+			if err := tx.Where("product_id = ? AND id IN ?", productID, removedIDs).Delete(&models.ProductImage{}).Error; err != nil {
+				return err
+			}
+		}
+		primaryURL := ""
+		for i := range images {
+			images[i].ProductID = productID
+			if err := tx.Save(&images[i]).Error; err != nil {
+				return err
+			}
+			if images[i].IsPrimary {
+				primaryURL = images[i].URL
+			}
+		}
+		return tx.Model(&models.Product{}).Where("id = ?", productID).Update("image_url", primaryURL).Error
+	})
+}
+
+// CountImageReferences counts the gallery images and live products still
+// pointing at an uploaded file, so shared files are only deleted once unused.
+func (r *ProductRepository) CountImageReferences(url string) (int64, error) {
+	var images, products int64
+	if err := r.db.Model(&models.ProductImage{}).Where("url = ?", url).Count(&images).Error; err != nil {
+		return 0, err
+	}
+	if err := r.db.Model(&models.Product{}).Where("image_url = ?", url).Count(&products).Error; err != nil {
+		return 0, err
+	}
+	return images + products, nil
+}
+
+// RecordUploads remembers uploaderID as the owner of each saved file.
+func (r *ProductRepository) RecordUploads(uploaderID uint, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	uploads := make([]models.Upload, 0, len(urls))
+	for _, url := range urls {
+		uploads = append(uploads, models.Upload{URL: url, UploaderID: uploaderID})
+	}
+	return r.db.Create(&uploads).Error
+}
+
+func (r *ProductRepository) GetUpload(url string) (*models.Upload, error) {
+	var upload models.Upload
+	if err := r.db.Where("url = ?", url).First(&upload).Error; err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+func (r *ProductRepository) DeleteUpload(id uint) error {
+	return r.db.Delete(&models.Upload{}, id).Error
+}
+
+// taxonomySubtree selects the ids of a taxonomy node and everything under it.
+func (r *ProductRepository) taxonomySubtree(path string) *gorm.DB {
+	return r.db.Model(&models.TaxonomyNode{}).Select("id").Where("path LIKE ?", path+"%")
+}
+
+// taxonomyMatching selects the ids of taxonomy nodes whose name, local name
+// or alias contains the text.
+func (r *ProductRepository) taxonomyMatching(text string) *gorm.DB {
+	pattern := "%" + strings.ToLower(strings.TrimSpace(text)) + "%"
+	return r.db.Model(&models.TaxonomyNode{}).Select("id").
+		Where("LOWER(name) LIKE ? OR id IN (?)", pattern,
+			r.db.Model(&models.TaxonomyName{}).Select("node_id").Where("LOWER(name) LIKE ?", pattern))
+}
+
+// ListTaxonomy returns the taxonomy ordered so parents come before their
+// children.
+func (r *ProductRepository) ListTaxonomy(activeOnly bool) ([]models.TaxonomyNode, error) {
+	var nodes []models.TaxonomyNode
+	query := r.db.Preload("Names", func(db *gorm.DB) *gorm.DB { return db.Order("kind ASC, language ASC, name ASC") })
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+	err := query.Order("depth ASC, sort_order ASC, name ASC").Find(&nodes).Error
+	return nodes, err
+}
+
+func (r *ProductRepository) GetTaxonomyNode(id uint) (*models.TaxonomyNode, error) {
+	var node models.TaxonomyNode
+	if err := r.db.Preload("Names").Where("id = ?", id).First(&node).Error; err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// ShelfLifeDays returns the shelf life set on a taxonomy node or, failing
+// that, on its nearest ancestor; 0 when none is set.
+func (r *ProductRepository) ShelfLifeDays(nodeID uint) (int, error) {
+	var node models.TaxonomyNode
+	if err := r.db.Select("path").Where("id = ?", nodeID).First(&node).Error; err != nil {
+		return 0, err
+	}
+	ids := make([]uint, 0, 4)
+	for _, part := range strings.Split(strings.Trim(node.Path, "/"), "/") {
+		if id, err := strconv.ParseUint(part, 10, 32); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	var nearest models.TaxonomyNode
+	err := r.db.Where("id IN ? AND shelf_life_days > 0", ids).Order("depth DESC").First(&nearest).Error
+	if err == gorm.ErrRecordNotFound {
+		return 0, nil
+	}
+	return nearest.ShelfLifeDays, err
+}
+
+// ListListingsPastBestBefore returns listings still on offer, or waiting to
+// be, whose best-before date has passed.
+func (r *ProductRepository) ListListingsPastBestBefore(now time.Time) ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.Preload("Farmer").
+		Where("best_before IS NOT NULL AND best_before <= ?", now).
+		Where("status IN ?", expirableStatuses).
+		Order("best_before ASC").
+		Find(&products).Error
+	return products, err
+}
+
+// ExpireListing moves a listing to expired only if it is still in an
+// expirable status, so a farmer's own change that lands first wins.
+func (r *ProductRepository) ExpireListing(id uint, at time.Time) (bool, error) {
+	result := r.db.Model(&models.Product{}).
+		Where("id = ? AND status IN ?", id, expirableStatuses).
+		Updates(map[string]interface{}{"status": "expired", "expired_at": at})
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindTaxonomyNode resolves free text to an active node by slug, name, local
+// name or alias, preferring the shallowest match.
+func (r *ProductRepository) FindTaxonomyNode(text string) (*models.TaxonomyNode, error) {
+	clean := strings.ToLower(strings.TrimSpace(text))
+	var node models.TaxonomyNode
+	err := r.db.Where("is_active = ?", true).
+		Where("slug = ? OR LOWER(name) = ? OR id IN (?)", clean, clean,
+			r.db.Model(&models.TaxonomyName{}).Select("node_id").Where("LOWER(name) = ?", clean)).
+		Order("depth ASC, id ASC").
+		First(&node).Error
+	if err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
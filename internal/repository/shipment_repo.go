@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"github.com/f2b-portal/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type ShipmentRepository struct {
+	db *gorm.DB
+}
+
+func NewShipmentRepository(db *gorm.DB) *ShipmentRepository {
+	return &ShipmentRepository{db: db}
+}
+
+func (r *ShipmentRepository) GetDB() *gorm.DB {
+	return r.db
+}
+
+func (r *ShipmentRepository) Create(item *models.Shipment) error {
+	return r.db.Create(item).Error
+}
+
+func (r *ShipmentRepository) Update(item *models.Shipment) error {
+	return r.db.Save(item).Error
+}
+
+func (r *ShipmentRepository) GetByOrderID(orderID uint) (*models.Shipment, error) {
+	var item models.Shipment
+	err := r.db.Preload("Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("occurred_at ASC, id ASC")
+	}).
+		Where("order_id = ?", orderID).
+		Order("created_at DESC").
+		First(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *ShipmentRepository) GetByCarrierAndTracking(carrier, trackingNumber string) (*models.Shipment, error) {
+	var item models.Shipment
+	err := r.db.Where("carrier = ? AND tracking_number = ?", carrier, trackingNumber).
+		Order("created_at DESC").
+		First(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *ShipmentRepository) CreateEvent(item *models.ShipmentEvent) error {
+	return r.db.Create(item).Error
+}
+
+func (r *ShipmentRepository) HasEvent(shipmentID uint, externalEventID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.ShipmentEvent{}).
+		Where("shipment_id = ? AND external_event_id = ?", shipmentID, externalEventID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *ShipmentRepository) GetEventsByOrder(orderID uint) ([]models.ShipmentEvent, error) {
+	var items []models.ShipmentEvent
+	err := r.db.Where("order_id = ?", orderID).
+		Order("occurred_at ASC, id ASC").
+		Find(&items).Error
+	return items, err
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"math"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -13,12 +14,16 @@ import (
 	"github.com/f2b-portal/backend/internal/models"
 	"github.com/f2b-portal/backend/internal/repository"
 	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AdminService struct {
 	userRepo    *repository.UserRepository
 	productRepo *repository.ProductRepository
 	orderRepo   *repository.OrderRepository
+	events      *OrderEventBroker
+	payments    *PaymentService
 }
 
 func NewAdminService(userRepo *repository.UserRepository, productRepo *repository.ProductRepository, orderRepo *repository.OrderRepository) *AdminService {
@@ -133,22 +138,29 @@ type ResolveReportRequest struct {
 }
 
 type AdminTransactionInvoice struct {
-	OrderID            uint    `json:"order_id"`
-	OrderType          string  `json:"order_type"`
-	Status             string  `json:"status"`
-	BuyerName          string  `json:"buyer_name"`
-	FarmerName         string  `json:"farmer_name"`
-	ProductName        string  `json:"product_name"`
-	Quantity           float64 `json:"quantity"`
-	Unit               string  `json:"unit"`
-	UnitPrice          float64 `json:"unit_price"`
-	GrossAmount        float64 `json:"gross_amount"`
-	PlatformFee        float64 `json:"platform_fee"`
-	NetPayout          float64 `json:"net_payout"`
-	DisputeStatus      string  `json:"dispute_status"`
-	AdminReviewStatus  string  `json:"admin_review_status"`
-	CancellationReason string  `json:"cancellation_reason"`
-	CreatedAt          string  `json:"created_at"`
+	OrderID            uint               `json:"order_id"`
+	OrderType          string             `json:"order_type"`
+	Status             string             `json:"status"`
+	BuyerName          string             `json:"buyer_name"`
+	FarmerName         string             `json:"farmer_name"`
+	ProductName        string             `json:"product_name"`
+	Quantity           float64            `json:"quantity"`
+	Unit               string             `json:"unit"`
+	UnitPrice          float64            `json:"unit_price"`
+	GrossAmount        float64            `json:"gross_amount"`
+	PaidAmount         float64            `json:"paid_amount"`
+	RefundAmount       float64            `json:"refund_amount"`
+	CancellationFee    float64            `json:"cancellation_fee"`
+	DiscountAmount     float64            `json:"discount_amount"`
+	DiscountFundedBy   string             `json:"discount_funded_by,omitempty"`
+	PromotionSubsidy   float64            `json:"promotion_subsidy"`
+	PlatformFee        float64            `json:"platform_fee"`
+	NetPayout          float64            `json:"net_payout"`
+	DisputeStatus      string             `json:"dispute_status"`
+	AdminReviewStatus  string             `json:"admin_review_status"`
+	CancellationReason string             `json:"cancellation_reason"`
+	CreatedAt          string             `json:"created_at"`
+	TaxInvoice         *models.TaxInvoice `json:"tax_invoice,omitempty"`
 }
 
 type AdminHarvestRequestSummary struct {
@@ -163,9 +175,22 @@ type AdminHarvestRequestSummary struct {
 	ConvertedOrderID     *uint   `json:"converted_order_id"`
 	BuyerNote            string  `json:"buyer_note"`
 	FarmerResponseNote   string  `json:"farmer_response_note"`
+	ResponseDeadline     string  `json:"response_deadline"`
+	ResponseHours        float64 `json:"response_hours"`
 	CreatedAt            string  `json:"created_at"`
 }
 
+type AdminFarmerResponseMetric struct {
+	FarmerID         uint    `json:"farmer_id"`
+	FarmerName       string  `json:"farmer_name"`
+	TotalRequests    int     `json:"total_requests"`
+	RespondedCount   int     `json:"responded_count"`
+	PendingCount     int     `json:"pending_count"`
+	ExpiredCount     int     `json:"expired_count"`
+	AvgResponseHours float64 `json:"avg_response_hours"`
+	OnTimeRate       float64 `json:"on_time_rate"`
+}
+
 func isAllowedVerificationStatus(value string) bool {
 	switch value {
 	case "pending", "verified", "rejected":
@@ -204,7 +229,7 @@ func buildAdminReportItem(order models.Order, now time.Time) (AdminReportItem, b
 		reportType = "Dispute"
 		priority = "High"
 		slaHours = 24
-		if order.DisputeStatus == "resolved" || order.DisputeStatus == "rejected" {
+		if order.DisputeStatus == "resolved" || order.DisputeStatus == "rejected" || order.DisputeStatus == "decided" {
 			resolutionState = "Closed"
 		}
 	} else if order.Status == "cancelled" {
@@ -293,21 +318,7 @@ func getNoveltyConfig() NoveltyConfig {
 }
 
 func calculateFreshnessScoreForAdmin(product models.Product, cfg NoveltyConfig) (float64, string) {
-	ageHours := time.Since(product.CreatedAt).Hours()
-	ageDays := ageHours / 24.0
-	harvest := 0.3
-	switch {
-	case ageDays <= 1:
-		harvest = 1.0
-	case ageDays <= 2:
-		harvest = 0.9
-	case ageDays <= 4:
-		harvest = 0.75
-	case ageDays <= 7:
-		harvest = 0.6
-	case ageDays <= 10:
-		harvest = 0.45
-	}
+	harvest, _ := HarvestFreshness(product, time.Now().UTC())
 
 	distance := 0.45
 	productCity := strings.ToLower(strings.TrimSpace(product.City))
@@ -508,9 +519,16 @@ func (s *AdminService) GetOverview() (*OverviewStats, error) {
 			pendingFarmerVerifications++
 		}
 	}
+	ledger, err := loadOrderLedgerTotals(s.orderRepo, orders)
+	if err != nil {
+		return nil, err
+	}
+	var platformFees float64
 	for _, o := range orders {
+		totals := ledger[o.ID]
+		platformFees += totals.PlatformFee
 		if o.Status == "completed" {
-			totalRevenue += o.TotalPrice
+			totalRevenue += totals.Paid - totals.Refunded
 			completedOrders++
 		}
 		if o.OrderType == "bulk" {
@@ -520,7 +538,7 @@ func (s *AdminService) GetOverview() (*OverviewStats, error) {
 		if o.CreatedAt.Year() == now.Year() && o.CreatedAt.YearDay() == now.YearDay() {
 			todayRevenue += o.TotalPrice
 		}
-		if o.Status == "pending" || strings.TrimSpace(o.AdminReviewStatus) == "open" || (o.DisputeStatus != "" && o.DisputeStatus != "none" && o.DisputeStatus != "resolved" && o.DisputeStatus != "rejected") {
+		if o.Status == "pending" || strings.TrimSpace(o.AdminReviewStatus) == "open" || (o.DisputeStatus != "" && o.DisputeStatus != "none" && o.DisputeStatus != "resolved" && o.DisputeStatus != "rejected" && o.DisputeStatus != "decided") {
 			pendingReviews++
 		}
 		if o.Status == "pending" || o.Status == "confirmed" {
@@ -559,14 +577,14 @@ func (s *AdminService) GetOverview() (*OverviewStats, error) {
 	return &OverviewStats{
 		TotalUsers:          len(users),
 		ActiveProducts:      activeProducts,
-		TotalRevenue:        totalRevenue,
+		TotalRevenue:        roundMoney(totalRevenue),
 		BulkOrderCount:      bulkOrderCount,
 		BulkOrderRevenue:    bulkOrderRevenue,
 		HarvestOpenCount:    harvestOpenCount,
 		HarvestReadyCount:   harvestReadyCount,
 		PendingReviews:      pendingReviews,
 		TodayRevenue:        todayRevenue,
-		PlatformFees:        totalRevenue * 0.05,
+		PlatformFees:        roundMoney(platformFees),
 		ActiveSettlements:   activeSettlements,
 		ServerUptime:        serverUptime,
 		DatabasePerformance: databasePerformance,
@@ -594,6 +612,14 @@ func (s *AdminService) GetHarvestRequests() ([]AdminHarvestRequestSummary, error
 	}
 	result := make([]AdminHarvestRequestSummary, 0, len(items))
 	for _, item := range items {
+		deadline := ""
+		if item.ResponseDeadline != nil {
+			deadline = item.ResponseDeadline.Format(time.RFC3339)
+		}
+		responseHours := 0.0
+		if item.RespondedAt != nil {
+			responseHours = math.Round(item.RespondedAt.Sub(item.CreatedAt).Hours()*100) / 100
+		}
 		result = append(result, AdminHarvestRequestSummary{
 			ID:                   item.ID,
 			Status:               item.Status,
@@ -606,12 +632,64 @@ func (s *AdminService) GetHarvestRequests() ([]AdminHarvestRequestSummary, error
 			ConvertedOrderID:     item.ConvertedOrderID,
 			BuyerNote:            item.BuyerNote,
 			FarmerResponseNote:   item.FarmerResponseNote,
+			ResponseDeadline:     deadline,
+			ResponseHours:        responseHours,
 			CreatedAt:            item.CreatedAt.Format(time.RFC3339),
 		})
 	}
 	return result, nil
 }
 
+// GetHarvestResponseMetrics summarises how quickly each farmer answers harvest
+// requests. A response counts as on time when it lands before the deadline;
+// expired requests count against the farmer.
+func (s *AdminService) GetHarvestResponseMetrics() ([]AdminFarmerResponseMetric, error) {
+	items, err := s.orderRepo.ListAllHarvestRequests()
+	if err != nil {
+		return nil, err
+	}
+
+	byFarmer := make(map[uint]*AdminFarmerResponseMetric)
+	totalHours := make(map[uint]float64)
+	onTime := make(map[uint]int)
+	order := make([]uint, 0)
+	for _, item := range items {
+		metric, ok := byFarmer[item.FarmerID]
+		if !ok {
+			metric = &AdminFarmerResponseMetric{FarmerID: item.FarmerID, FarmerName: item.Farmer.Name}
+			byFarmer[item.FarmerID] = metric
+			order = append(order, item.FarmerID)
+		}
+		metric.TotalRequests++
+		switch {
+		case item.RespondedAt != nil:
+			metric.RespondedCount++
+			totalHours[item.FarmerID] += item.RespondedAt.Sub(item.CreatedAt).Hours()
+			if item.ResponseDeadline == nil || item.RespondedAt.Before(*item.ResponseDeadline) {
+				onTime[item.FarmerID]++
+			}
+		case item.Status == "expired":
+			metric.ExpiredCount++
+		case item.Status == "pending":
+			metric.PendingCount++
+		}
+	}
+
+	result := make([]AdminFarmerResponseMetric, 0, len(order))
+	for _, farmerID := range order {
+		metric := byFarmer[farmerID]
+		if metric.RespondedCount > 0 {
+			metric.AvgResponseHours = math.Round((totalHours[farmerID]/float64(metric.RespondedCount))*100) / 100
+		}
+		if decided := metric.RespondedCount + metric.ExpiredCount; decided > 0 {
+			metric.OnTimeRate = math.Round((float64(onTime[farmerID])/float64(decided))*100) / 100
+		}
+		result = append(result, *metric)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].AvgResponseHours > result[j].AvgResponseHours })
+	return result, nil
+}
+
 func (s *AdminService) UpdateUserStatus(userID, adminID uint, req UpdateUserStatusRequest) (*models.User, error) {
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
@@ -679,13 +757,12 @@ func (s *AdminService) UpdateProductModeration(productID, adminID uint, req Upda
 	if product.Quantity <= 0 && nextStatus == "active" {
 		return nil, errors.New("cannot approve an out-of-stock product")
 	}
-
 	now := time.Now().UTC()
-	product.Status = nextStatus
-	product.ModerationNote = utils.SanitizeString(req.Note)
-	product.ReviewedBy = &adminID
-	product.ReviewedAt = &now
-	if err := s.productRepo.Update(product); err != nil {
+	if nextStatus == "active" && pastBestBefore(product, now) {
+		return nil, errors.New("cannot approve a product past its best-before date")
+	}
+
+	if err := s.productRepo.UpdateModeration(product.ID, nextStatus, utils.SanitizeString(req.Note), adminID, now); err != nil {
 		return nil, errors.New("failed to update product moderation")
 	}
 	return s.productRepo.GetByID(productID)
@@ -702,14 +779,18 @@ func (s *AdminService) ExportTransactionsCSV() (string, error) {
 	if err := writer.Write([]string{
 		"order_id", "created_at", "buyer", "farmer", "product", "status",
 		"order_type",
-		"quantity", "unit", "gross_inr", "platform_fee_inr", "net_payout_inr",
+		"quantity", "unit", "base_quantity", "base_unit", "gross_inr", "paid_inr", "refund_inr", "cancellation_fee_inr",
+		"platform_fee_inr", "net_payout_inr",
 		"dispute_status", "admin_review_status",
 	}); err != nil {
 		return "", err
 	}
+	ledger, err := loadOrderLedgerTotals(s.orderRepo, orders)
+	if err != nil {
+		return "", err
+	}
 	for _, o := range orders {
-		fee := o.TotalPrice * 0.05
-		net := o.TotalPrice - fee
+		totals := ledger[o.ID]
 		row := []string{
 			strconv.FormatUint(uint64(o.ID), 10),
 			o.CreatedAt.Format(time.RFC3339),
@@ -719,10 +800,15 @@ func (s *AdminService) ExportTransactionsCSV() (string, error) {
 			o.Status,
 			o.OrderType,
 			strconv.FormatFloat(o.Quantity, 'f', 2, 64),
-			o.Product.Unit,
+			orderUnit(&o),
+			strconv.FormatFloat(models.ToBaseQuantity(o.Quantity, orderUnit(&o)), 'f', 3, 64),
+			baseUnitName(orderUnit(&o)),
 			strconv.FormatFloat(o.TotalPrice, 'f', 2, 64),
-			strconv.FormatFloat(fee, 'f', 2, 64),
-			strconv.FormatFloat(net, 'f', 2, 64),
+			strconv.FormatFloat(totals.Paid, 'f', 2, 64),
+			strconv.FormatFloat(totals.Refunded, 'f', 2, 64),
+			strconv.FormatFloat(totals.CancellationFee, 'f', 2, 64),
+			strconv.FormatFloat(totals.PlatformFee, 'f', 2, 64),
+			strconv.FormatFloat(totals.FarmerShare, 'f', 2, 64),
 			o.DisputeStatus,
 			o.AdminReviewStatus,
 		}
@@ -747,7 +833,15 @@ func (s *AdminService) GetTransactionInvoice(orderID uint) (*AdminTransactionInv
 	if order.Quantity > 0 {
 		unitPrice = order.TotalPrice / order.Quantity
 	}
-	fee := order.TotalPrice * 0.05
+	ledger, err := loadOrderLedgerTotals(s.orderRepo, []models.Order{*order})
+	if err != nil {
+		return nil, err
+	}
+	totals := ledger[order.ID]
+	taxInvoice, err := ensureTaxInvoice(s.orderRepo.GetDB(), order.ID)
+	if err != nil {
+		return nil, err
+	}
 
 	return &AdminTransactionInvoice{
 		OrderID:            order.ID,
@@ -760,12 +854,19 @@ func (s *AdminService) GetTransactionInvoice(orderID uint) (*AdminTransactionInv
 		Unit:               order.Product.Unit,
 		UnitPrice:          unitPrice,
 		GrossAmount:        order.TotalPrice,
-		PlatformFee:        fee,
-		NetPayout:          order.TotalPrice - fee,
+		PaidAmount:         totals.Paid,
+		RefundAmount:       totals.Refunded,
+		CancellationFee:    totals.CancellationFee,
+		DiscountAmount:     order.DiscountAmount,
+		DiscountFundedBy:   order.DiscountFundedBy,
+		PromotionSubsidy:   totals.Subsidy,
+		PlatformFee:        totals.PlatformFee,
+		NetPayout:          totals.FarmerShare,
 		DisputeStatus:      order.DisputeStatus,
 		AdminReviewStatus:  order.AdminReviewStatus,
 		CancellationReason: order.CancellationReason,
 		CreatedAt:          order.CreatedAt.Format(time.RFC3339),
+		TaxInvoice:         taxInvoice,
 	}, nil
 }
 
@@ -798,6 +899,9 @@ func (s *AdminService) ResolveReport(orderID, adminID uint, req ResolveReportReq
 	if err != nil {
 		return nil, errors.New("report not found")
 	}
+	if isDisputeLocked(order.DisputeStatus) {
+		return nil, errors.New("escalated disputes are settled through a dispute decision")
+	}
 
 	now := time.Now().UTC()
 	order.AdminReviewNote = utils.SanitizeString(req.Note)
@@ -831,3 +935,114 @@ func (s *AdminService) ResolveReport(orderID, adminID uint, req ResolveReportReq
 	item, _ := buildAdminReportItem(*updatedOrder, time.Now().UTC())
 	return &item, nil
 }
+
+// SetEventBroker enables real-time events for admin dispute decisions.
+func (s *AdminService) SetEventBroker(broker *OrderEventBroker) {
+	s.events = broker
+}
+
+func (s *AdminService) GetEscalatedDisputes() ([]models.Order, error) {
+	return s.orderRepo.ListEscalatedDisputes()
+}
+
+// DecideDispute records a binding admin decision on an escalated dispute. The
+// refund is applied to the order's payment and payout figures, the decision is
+// audited, and the farmer's trust score is recalculated.
+func (s *AdminService) DecideDispute(orderID, adminID uint, req DisputeDecisionRequest) (*models.Order, error) {
+	decision := strings.ToLower(strings.TrimSpace(req.Decision))
+	if !isAllowedDisputeDecision(decision) {
+		return nil, errors.New("invalid dispute decision")
+	}
+	if strings.TrimSpace(req.Note) == "" {
+		return nil, errors.New("decision note is required")
+	}
+	goodwill := roundMoney(req.GoodwillCredit)
+	if goodwill < 0 {
+		return nil, errors.New("goodwill credit cannot be negative")
+	}
+
+	var farmerID uint
+	var statusLog *models.OrderStatusLog
+	var recipients []uint
+	var refundDue float64
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error; err != nil {
+			return errors.New("order not found")
+		}
+		if order.DisputeStatus != "escalated" {
+			return errors.New("only escalated disputes can be decided")
+		}
+		if err := applyDisputeSettlement(&order, decision, req.RefundAmount); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		note := utils.SanitizeString(req.Note)
+		order.DisputeStatus = "decided"
+		order.DisputeDecision = decision
+		order.DisputeDecidedBy = &adminID
+		order.DisputeDecidedAt = &now
+		order.AdminReviewStatus = "closed"
+		order.AdminReviewNote = note
+		order.AdminReviewedBy = &adminID
+		order.AdminReviewedAt = &now
+		if err := tx.Save(&order).Error; err != nil {
+			return errors.New("failed to record dispute decision")
+		}
+		split, err := postOrderRefund(tx, &order, adminID, order.RefundAmount, "Refund decided by admin")
+		if err != nil {
+			return err
+		}
+		if err := applyRefundPaymentStatus(tx, &order, split); err != nil {
+			return err
+		}
+		if goodwill > 0 {
+			if err := postGoodwillCredit(tx, order.BuyerID, &order.ID, adminID, goodwill, "Goodwill credit on dispute: "+note); err != nil {
+				return err
+			}
+			note = strings.TrimSpace(note + " (goodwill credit " + formatQuantity(goodwill) + ")")
+		}
+
+		statusLog = &models.OrderStatusLog{
+			OrderID:    order.ID,
+			ActorID:    adminID,
+			FromStatus: order.Status,
+			ToStatus:   order.Status,
+			Reason:     "dispute_decision",
+			Category:   decision,
+			Note:       note,
+			CreatedAt:  now,
+		}
+		if err := tx.Create(statusLog).Error; err != nil {
+			return errors.New("failed to log dispute decision")
+		}
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "order",
+			TargetID:   order.ID,
+			Action:     "dispute_" + decision,
+			Note:       note,
+			CreatedAt:  now,
+		}).Error; err != nil {
+			return errors.New("failed to audit dispute decision")
+		}
+
+		farmerID = order.FarmerID
+		recipients = []uint{order.BuyerID, order.FarmerID}
+		refundDue = split.provider
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(orderID, recipients, "dispute", statusLog.ID, statusLog)
+	if err := s.payments.RequestRefund(orderID, refundDue); err != nil {
+		return nil, errors.New("decision recorded but refund request failed: " + err.Error())
+	}
+	// The decision stands even if the profile refresh fails; the score is
+	// recalculated again whenever the farmer's profile is next read.
+	_ = NewTrustScoreService(s.userRepo, s.orderRepo).UpdateTrustScore(farmerID)
+	return s.orderRepo.GetByID(orderID)
+}
@@ -164,12 +164,20 @@ func newAdminServiceTestDB(t *testing.T) *gorm.DB {
 		&models.User{},
 		&models.FarmerProfile{},
 		&models.Product{},
+		&models.ProductPriceTier{},
+		&models.ProductImage{},
+		&models.Upload{},
+		&models.ProductVariant{},
+		&models.StockMovement{},
+		&models.TaxonomyNode{},
+		&models.TaxonomyName{},
 		&models.Order{},
 		&models.HarvestRequest{},
 		&models.Review{},
 		&models.OrderStatusLog{},
 		&models.OrderMessage{},
 		&models.DisputeEvidence{},
+		&models.LedgerEntry{},
 	); err != nil {
 		t.Fatalf("failed to migrate db: %v", err)
 	}
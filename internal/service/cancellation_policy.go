@@ -0,0 +1,218 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var cancellationOrderTypes = []string{"standard", "bulk", "harvest_request"}
+
+var cancellationStages = []string{"pending", "confirmed", "packed", "out_for_delivery"}
+
+// defaultCancellationPolicies apply until an admin overrides a row. Produce
+// harvested or packed for a buyer costs the farmer more the later the buyer
+// walks away, and nothing can be cancelled once it is on the road. Fees are
+// kept from what the buyer has paid, so unpaid orders cancel free.
+var defaultCancellationPolicies = map[string]map[string]models.CancellationPolicy{
+	"standard": {
+		"pending":          {Allowed: true},
+		"confirmed":        {Allowed: true},
+		"packed":           {Allowed: true, FeePercent: 10},
+		"out_for_delivery": {Allowed: false},
+	},
+	"bulk": {
+		"pending":          {Allowed: true},
+		"confirmed":        {Allowed: true, FeePercent: 5},
+		"packed":           {Allowed: true, FeePercent: 15},
+		"out_for_delivery": {Allowed: false},
+	},
+	"harvest_request": {
+		"pending":          {Allowed: true},
+		"confirmed":        {Allowed: true, FeePercent: 10},
+		"packed":           {Allowed: true, FeePercent: 20},
+		"out_for_delivery": {Allowed: false},
+	},
+}
+
+type UpdateCancellationPolicyRequest struct {
+	OrderType  string  `json:"order_type"`
+	Stage      string  `json:"stage"`
+	Allowed    bool    `json:"allowed"`
+	FeePercent float64 `json:"fee_percent"`
+}
+
+type CancellationQuote struct {
+	OrderID    uint    `json:"order_id"`
+	Stage      string  `json:"stage"`
+	Allowed    bool    `json:"allowed"`
+	FeePercent float64 `json:"fee_percent"`
+	Fee        float64 `json:"fee"`
+	Message    string  `json:"message"`
+}
+
+func isCancellationOrderType(value string) bool {
+	for _, item := range cancellationOrderTypes {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func isCancellationStage(value string) bool {
+	for _, item := range cancellationStages {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// effectiveCancellationPolicy returns the stored policy for an order type and
+// stage, falling back to the built-in default.
+func effectiveCancellationPolicy(db *gorm.DB, orderType, stage string) models.CancellationPolicy {
+	if !isCancellationOrderType(orderType) {
+		orderType = "standard"
+	}
+	var policy models.CancellationPolicy
+	if err := db.Where("order_type = ? AND stage = ?", orderType, stage).First(&policy).Error; err == nil {
+		return policy
+	}
+	policy = defaultCancellationPolicies[orderType][stage]
+	policy.OrderType = orderType
+	policy.Stage = stage
+	return policy
+}
+
+func cancellationFee(total, feePercent float64) float64 {
+	return math.Round(total*feePercent) / 100
+}
+
+// chargeableCancellationFee is the policy fee on the order, limited to what
+// the buyer has paid into escrow. The fee is kept back from the refund, so an
+// order the buyer has not paid for yet is cancelled without one.
+func chargeableCancellationFee(tx *gorm.DB, order *models.Order, feePercent float64) (float64, error) {
+	fee := cancellationFee(order.TotalPrice, feePercent)
+	if fee <= 0 {
+		return 0, nil
+	}
+	escrow, err := ledgerBalance(tx, order.ID, ledgerEscrow)
+	if err != nil {
+		return 0, errors.New("failed to read ledger")
+	}
+	return roundMoney(math.Min(fee, math.Max(0, -escrow))), nil
+}
+
+func describeCancellationPolicy(policy models.CancellationPolicy, fee float64) string {
+	stage := strings.ReplaceAll(policy.Stage, "_", " ")
+	if !policy.Allowed {
+		return "orders cannot be cancelled once " + stage
+	}
+	if fee > 0 {
+		return "cancelling now incurs a fee of " + formatQuantity(fee) + " (" + formatQuantity(policy.FeePercent) + "%)"
+	}
+	return "cancellation is free at this stage"
+}
+
+// GetCancellationQuote tells the buyer whether they can cancel right now and
+// what it would cost.
+func (s *OrderService) GetCancellationQuote(orderID, userID uint) (*CancellationQuote, error) {
+	order, err := s.getAccessibleOrder(orderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isCancellationStage(order.Status) {
+		return &CancellationQuote{OrderID: order.ID, Stage: order.Status, Message: "order can no longer be cancelled"}, nil
+	}
+	quote := &CancellationQuote{OrderID: order.ID, Stage: order.Status, Allowed: true}
+	if order.BuyerID == userID {
+		policy := effectiveCancellationPolicy(s.orderRepo.GetDB(), order.OrderType, order.Status)
+		quote.Allowed = policy.Allowed
+		if policy.Allowed {
+			if quote.Fee, err = chargeableCancellationFee(s.orderRepo.GetDB(), order, policy.FeePercent); err != nil {
+				return nil, err
+			}
+			if quote.Fee > 0 {
+				quote.FeePercent = policy.FeePercent
+			}
+		}
+		quote.Message = describeCancellationPolicy(policy, quote.Fee)
+	} else {
+		quote.Message = "farmer cancellations are free but count against your trust score"
+	}
+	return quote, nil
+}
+
+// GetCancellationPolicies lists the effective policy for every order type and
+// stage, including defaults that have not been overridden.
+func (s *AdminService) GetCancellationPolicies() ([]models.CancellationPolicy, error) {
+	db := s.orderRepo.GetDB()
+	items := make([]models.CancellationPolicy, 0, len(cancellationOrderTypes)*len(cancellationStages))
+	for _, orderType := range cancellationOrderTypes {
+		for _, stage := range cancellationStages {
+			items = append(items, effectiveCancellationPolicy(db, orderType, stage))
+		}
+	}
+	return items, nil
+}
+
+func (s *AdminService) UpdateCancellationPolicy(adminID uint, req UpdateCancellationPolicyRequest) (*models.CancellationPolicy, error) {
+	orderType := strings.ToLower(strings.TrimSpace(req.OrderType))
+	stage := strings.ToLower(strings.TrimSpace(req.Stage))
+	if !isCancellationOrderType(orderType) {
+		return nil, errors.New("invalid order type")
+	}
+	if !isCancellationStage(stage) {
+		return nil, errors.New("invalid cancellation stage")
+	}
+	if req.FeePercent < 0 || req.FeePercent > 100 {
+		return nil, errors.New("fee percent must be between 0 and 100")
+	}
+	feePercent := req.FeePercent
+	if !req.Allowed {
+		feePercent = 0
+	}
+
+	policy := models.CancellationPolicy{
+		OrderType:  orderType,
+		Stage:      stage,
+		Allowed:    req.Allowed,
+		FeePercent: feePercent,
+		UpdatedBy:  &adminID,
+	}
+	note := orderType + "/" + stage + ": not allowed"
+	if req.Allowed {
+		note = orderType + "/" + stage + ": fee " + formatQuantity(feePercent) + "%"
+	}
+	now := time.Now().UTC()
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "order_type"}, {Name: "stage"}},
+			DoUpdates: clause.AssignmentColumns([]string{"allowed", "fee_percent", "updated_by", "updated_at"}),
+		}).Create(&policy).Error; err != nil {
+			return errors.New("failed to save cancellation policy")
+		}
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "cancellation_policy",
+			TargetID:   policy.ID,
+			Action:     "update_cancellation_policy",
+			Note:       note,
+			CreatedAt:  now,
+		}).Error; err != nil {
+			return errors.New("failed to audit cancellation policy")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	updated := effectiveCancellationPolicy(s.orderRepo.GetDB(), orderType, stage)
+	return &updated, nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestCancellationPolicyFeesAndFarmerPenalties(t *testing.T) {
+	ctx := setupTestCtx(t)
+	if err := ctx.db.Create(&models.FarmerProfile{UserID: ctx.farmerID, RatingAverage: 5}).Error; err != nil {
+		t.Fatalf("failed to create farmer profile: %v", err)
+	}
+	adminSvc := newAdminServiceForTest(ctx)
+	advance := func(orderID uint, steps ...string) {
+		t.Helper()
+		for _, step := range steps {
+			if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(orderID, ctx.farmerID, UpdateOrderStatusRequest{Status: step}); err != nil {
+				t.Fatalf("failed to move order to %s: %v", step, err)
+			}
+		}
+	}
+
+	// Fees come out of what the buyer paid, so these orders are paid from the
+	// wallet up front.
+	if _, err := adminSvc.IssueWalletCredit(99, WalletCreditRequest{BuyerID: ctx.buyerID, Amount: 400, Note: "test funds"}); err != nil {
+		t.Fatalf("failed to credit wallet: %v", err)
+	}
+	placeWalletOrder := func() *models.Order {
+		t.Helper()
+		order, err := ctx.orderSvc.CreateOrder(ctx.buyerID, CreateOrderRequest{ProductID: ctx.productID, Quantity: 2, DeliveryAddress: "Some address", PaymentMethod: "wallet"})
+		if err != nil {
+			t.Fatalf("failed to create order: %v", err)
+		}
+		return order
+	}
+
+	// An unpaid order has nothing to keep a fee from, so it cancels free and
+	// leaves the buyer owing nothing.
+	unpaid := createOrderForTest(t, ctx)
+	advance(unpaid.ID, "confirmed", "packed")
+	quote, err := ctx.orderSvc.GetCancellationQuote(unpaid.ID, ctx.buyerID)
+	if err != nil || !quote.Allowed || quote.Fee != 0 {
+		t.Fatalf("unexpected quote for unpaid packed order: %+v err=%v", quote, err)
+	}
+	cancelled, err := ctx.orderSvc.CancelOrder(unpaid.ID, ctx.buyerID, CancelOrderRequest{})
+	if err != nil || cancelled.CancellationFee != 0 {
+		t.Fatalf("unexpected unpaid cancellation: %+v err=%v", cancelled, err)
+	}
+	if owed, _ := ledgerBalance(ctx.db, unpaid.ID, ledgerBuyerReceivable); owed != 0 {
+		t.Fatalf("expected no fee to be billed to the buyer, got %v", owed)
+	}
+	if earned, _ := ledgerBalance(ctx.db, unpaid.ID, ledgerFarmerPayable); earned != 0 {
+		t.Fatalf("expected no uncollected fee to be paid to the farmer, got %v", -earned)
+	}
+
+	packed := placeWalletOrder()
+	advance(packed.ID, "confirmed", "packed")
+	quote, err = ctx.orderSvc.GetCancellationQuote(packed.ID, ctx.buyerID)
+	if err != nil || !quote.Allowed || quote.Fee != 20 {
+		t.Fatalf("unexpected quote for packed order: %+v err=%v", quote, err)
+	}
+	cancelled, err = ctx.orderSvc.CancelOrder(packed.ID, ctx.buyerID, CancelOrderRequest{})
+	if err != nil {
+		t.Fatalf("failed to cancel packed order: %v", err)
+	}
+	if cancelled.CancellationFee != 20 || cancelled.CancelledByRole != "buyer" || cancelled.CancellationType != "buyer_request" {
+		t.Fatalf("unexpected cancelled order: fee=%v role=%s type=%s", cancelled.CancellationFee, cancelled.CancelledByRole, cancelled.CancellationType)
+	}
+
+	shipped := placeWalletOrder()
+	advance(shipped.ID, "confirmed", "packed", "out_for_delivery")
+	if _, err := ctx.orderSvc.CancelOrder(shipped.ID, ctx.buyerID, CancelOrderRequest{}); err == nil {
+		t.Fatalf("expected cancellation once out for delivery to be refused")
+	}
+	if _, err := adminSvc.UpdateCancellationPolicy(99, UpdateCancellationPolicyRequest{OrderType: "standard", Stage: "out_for_delivery", Allowed: true, FeePercent: 50}); err != nil {
+		t.Fatalf("failed to update policy: %v", err)
+	}
+	if cancelled, err = ctx.orderSvc.CancelOrder(shipped.ID, ctx.buyerID, CancelOrderRequest{Reason: "moved house"}); err != nil || cancelled.CancellationFee != 100 {
+		t.Fatalf("expected admin policy to apply, got %+v err=%v", cancelled, err)
+	}
+
+	invoice, err := ctx.orderSvc.GetFarmerInvoice(packed.ID, ctx.farmerID)
+	if err != nil || invoice.CancellationFee != 20 || invoice.NetPayout != 19 {
+		t.Fatalf("unexpected invoice for cancelled order: %+v err=%v", invoice, err)
+	}
+	payout, err := ctx.orderSvc.GetFarmerPayoutSummary(ctx.farmerID)
+	if err != nil || payout.CancellationFees != 120 || payout.NetPayout != 114 {
+		t.Fatalf("unexpected payout: %+v err=%v", payout, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		order := createOrderForTest(t, ctx)
+		if _, err := ctx.orderSvc.CancelOrder(order.ID, ctx.farmerID, CancelOrderRequest{CancellationType: "stock_issue"}); err != nil {
+			t.Fatalf("failed to cancel as farmer: %v", err)
+		}
+	}
+	var profile models.FarmerProfile
+	if err := ctx.db.Where("user_id = ?", ctx.farmerID).First(&profile).Error; err != nil {
+		t.Fatalf("failed to load farmer profile: %v", err)
+	}
+	// 5/5 rating, no completions: 0.6 less 0.3 × (1 repeat cancellation / 5 orders).
+	if profile.CancelledOrders != 2 || profile.TrustScore != 0.6-0.3*0.2 {
+		t.Fatalf("expected repeated farmer cancellations to lower trust, got %+v", profile)
+	}
+}
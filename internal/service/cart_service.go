@@ -15,6 +15,7 @@ type CartService struct {
 	cartRepo    *repository.CartRepository
 	productRepo *repository.ProductRepository
 	orderRepo   *repository.OrderRepository
+	events      *OrderEventBroker
 }
 
 func NewCartService(cartRepo *repository.CartRepository, productRepo *repository.ProductRepository, orderRepo *repository.OrderRepository) *CartService {
@@ -27,6 +28,7 @@ func NewCartService(cartRepo *repository.CartRepository, productRepo *repository
 
 type AddToCartRequest struct {
 	ProductID uint    `json:"product_id"`
+	VariantID uint    `json:"variant_id"`
 	Quantity  float64 `json:"quantity"`
 }
 
@@ -60,19 +62,35 @@ func (s *CartService) AddToCart(buyerID uint, req AddToCartRequest) error {
 	if err != nil {
 		return errors.New("product not found")
 	}
-	if product.Status != "active" {
+	if product.Status != "active" || pastBestBefore(product, time.Now().UTC()) {
 		return errors.New("product is not available")
 	}
 	if product.FarmerID == buyerID {
 		return errors.New("you cannot add your own product")
 	}
-	if product.Quantity < req.Quantity {
+	available := product.Quantity
+	var variantID *uint
+	if req.VariantID != 0 {
+		variant := findVariant(product, req.VariantID)
+		if variant == nil || !variant.IsActive {
+			return errors.New("product variant is not available")
+		}
+		available = variant.Quantity
+		variantID = &variant.ID
+	} else {
+		for _, variant := range product.Variants {
+			if variant.IsActive {
+				return errors.New("please choose a variant of this product")
+			}
+		}
+	}
+	if available < req.Quantity {
 		return errors.New("requested quantity exceeds available stock")
 	}
 
-	existing, err := s.cartRepo.GetByBuyerAndProduct(buyerID, req.ProductID)
+	existing, err := s.cartRepo.GetByBuyerAndProduct(buyerID, req.ProductID, variantID)
 	if err == nil {
-		if product.Quantity < (existing.Quantity + req.Quantity) {
+		if available < (existing.Quantity + req.Quantity) {
 			return errors.New("requested quantity exceeds available stock")
 		}
 		existing.Quantity += req.Quantity
@@ -85,6 +103,7 @@ func (s *CartService) AddToCart(buyerID uint, req AddToCartRequest) error {
 	return s.cartRepo.Create(&models.CartItem{
 		BuyerID:   buyerID,
 		ProductID: req.ProductID,
+		VariantID: variantID,
 		Quantity:  req.Quantity,
 	})
 }
@@ -112,7 +131,14 @@ func (s *CartService) UpdateQuantity(buyerID, cartItemID uint, quantity float64)
 	if target.Product.Status != "active" {
 		return errors.New("product is not available")
 	}
-	if quantity > target.Product.Quantity {
+	available := target.Product.Quantity
+	if target.Variant != nil {
+		if !target.Variant.IsActive {
+			return errors.New("product variant is not available")
+		}
+		available = target.Variant.Quantity
+	}
+	if quantity > available {
 		return errors.New("requested quantity exceeds available stock")
 	}
 
@@ -139,17 +165,35 @@ func (s *CartService) RemoveItem(buyerID, cartItemID uint) error {
 	return s.cartRepo.DeleteByID(cartItemID)
 }
 
+// SetEventBroker enables real-time events for orders created at checkout.
+func (s *CartService) SetEventBroker(broker *OrderEventBroker) {
+	s.events = broker
+}
+
 func (s *CartService) Checkout(buyerID uint, deliveryAddress string) ([]models.Order, error) {
-	return s.CheckoutWithPayment(buyerID, deliveryAddress, "cod", "")
+	return s.CheckoutWithPayment(buyerID, deliveryAddress, "cod", "", 0, "")
 }
 
-func (s *CartService) CheckoutWithPayment(buyerID uint, deliveryAddress, paymentMethod, paymentReference string) ([]models.Order, error) {
+// CheckoutWithPayment places one order per cart item. walletAmount is spread
+// across the orders in cart order; the "wallet" method pays everything from it.
+// A coupon applies to every order it is valid for, and the others get any
+// automatic promotion.
+func (s *CartService) CheckoutWithPayment(buyerID uint, deliveryAddress, paymentMethod, paymentReference string, walletAmount float64, couponCode string) ([]models.Order, error) {
 	cleanAddress := strings.TrimSpace(deliveryAddress)
 	createdOrderIDs := make([]uint, 0)
 	if err := validatePayment(paymentMethod, paymentReference); err != nil {
 		return nil, err
 	}
+	walletRemaining := roundMoney(walletAmount)
+	if walletRemaining < 0 {
+		return nil, errors.New("wallet amount cannot be negative")
+	}
+
+	couponCode = normalizeCouponCode(couponCode)
+	couponUsed := false
+	var couponErr error
 
+	statusLogs := make([]*models.OrderStatusLog, 0)
 	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
 		var items []models.CartItem
 		if err := tx.Preload("Product").
@@ -161,6 +205,9 @@ func (s *CartService) CheckoutWithPayment(buyerID uint, deliveryAddress, payment
 		if len(items) == 0 {
 			return errors.New("cart is empty")
 		}
+		if err := ensureBuyerNotOverdue(tx, buyerID, time.Now().UTC()); err != nil {
+			return err
+		}
 
 		for _, item := range items {
 			var product models.Product
@@ -169,19 +216,35 @@ func (s *CartService) CheckoutWithPayment(buyerID uint, deliveryAddress, payment
 				First(&product).Error; err != nil {
 				return errors.New("product not found during checkout")
 			}
-			if product.Status != "active" {
+			if product.Status != "active" || pastBestBefore(&product, time.Now().UTC()) {
 				return errors.New("one or more products are no longer available")
 			}
 			if product.Quantity < item.Quantity {
 				return errors.New("insufficient quantity for one or more products")
 			}
+			variantID := uint(0)
+			if item.VariantID != nil {
+				variantID = *item.VariantID
+			}
+			variant, err := lockOrderVariant(tx, product.ID, variantID)
+			if err != nil {
+				return err
+			}
+			if err := checkVariantQuantity(variant, item.Quantity); err != nil {
+				return err
+			}
+			var tiers []models.ProductPriceTier
+			if err := tx.Where("product_id = ?", product.ID).Order("min_quantity ASC").Find(&tiers).Error; err != nil {
+				return errors.New("failed to load price tiers")
+			}
 
 			order := &models.Order{
 				ProductID:       product.ID,
 				BuyerID:         buyerID,
 				FarmerID:        product.FarmerID,
 				Quantity:        item.Quantity,
-				TotalPrice:      item.Quantity * product.PricePerUnit,
+				Unit:            product.Unit,
+				TotalPrice:      item.Quantity * tierUnitPrice(product.PricePerUnit, tiers, item.Quantity),
 				OrderType:       deriveCartOrderType(product, item.Quantity),
 				PaymentMethod:   normalizePaymentMethod(paymentMethod),
 				PaymentReference: strings.TrimSpace(paymentReference),
@@ -191,10 +254,42 @@ func (s *CartService) CheckoutWithPayment(buyerID uint, deliveryAddress, payment
 				CreatedAt:       time.Now().UTC(),
 				UpdatedAt:       time.Now().UTC(),
 			}
+			applyOrderVariant(order, variant)
+			if err := assessPlatformFee(tx, order, product); err != nil {
+				return err
+			}
+			if couponCode != "" {
+				if err := applyPromotion(tx, order, product, couponCode, time.Now().UTC()); err != nil {
+					couponErr = err
+				} else {
+					couponUsed = true
+				}
+			}
+			if order.PromotionID == nil {
+				if err := applyPromotion(tx, order, product, "", time.Now().UTC()); err != nil {
+					return err
+				}
+			}
+			walletShare := walletRemaining
+			if walletShare > order.TotalPrice {
+				walletShare = roundMoney(order.TotalPrice)
+			}
+			if err := reserveWalletPayment(tx, order, walletShare); err != nil {
+				return err
+			}
+			if walletRemaining > 0 {
+				walletRemaining = roundMoney(walletRemaining - order.WalletAmount)
+			}
+			if err := reserveCreditPayment(tx, order); err != nil {
+				return err
+			}
 			if err := tx.Create(order).Error; err != nil {
 				return errors.New("failed to create order")
 			}
 			createdOrderIDs = append(createdOrderIDs, order.ID)
+			if err := postWalletPayment(tx, order); err != nil {
+				return err
+			}
 
 			// Initialize timeline with first lifecycle event.
 			logNote := "Order placed through cart checkout"
@@ -203,7 +298,7 @@ func (s *CartService) CheckoutWithPayment(buyerID uint, deliveryAddress, payment
 				logNote = "Bulk order placed through cart checkout"
 				logCategory = "bulk"
 			}
-			if err := tx.Create(&models.OrderStatusLog{
+			statusLog := &models.OrderStatusLog{
 				OrderID:    order.ID,
 				ActorID:    buyerID,
 				FromStatus: "new",
@@ -212,10 +307,16 @@ func (s *CartService) CheckoutWithPayment(buyerID uint, deliveryAddress, payment
 				Category:   logCategory,
 				Note:       logNote,
 				CreatedAt:  time.Now().UTC(),
-			}).Error; err != nil {
+			}
+			if err := tx.Create(statusLog).Error; err != nil {
 				return errors.New("failed to initialize order timeline")
 			}
+			statusLogs = append(statusLogs, statusLog)
 
+			if err := takeVariantStock(tx, variant, item.Quantity); err != nil {
+				return err
+			}
+			before := product.Quantity
 			product.Quantity -= item.Quantity
 			if product.Quantity <= 0 {
 				product.Quantity = 0
@@ -224,6 +325,22 @@ func (s *CartService) CheckoutWithPayment(buyerID uint, deliveryAddress, payment
 			if err := tx.Save(&product).Error; err != nil {
 				return errors.New("failed to update inventory")
 			}
+			if err := recordStockMovement(tx, &product, product.Quantity-before, models.StockMovement{
+				Kind:      "reserve",
+				VariantID: order.VariantID,
+				ActorID:   &buyerID,
+				OrderID:   &order.ID,
+				Reference: "cart_checkout",
+			}); err != nil {
+				return err
+			}
+		}
+
+		if walletRemaining > 0 {
+			return errors.New("wallet amount exceeds the cart total")
+		}
+		if couponCode != "" && !couponUsed {
+			return couponErr
 		}
 
 		if err := tx.Where("buyer_id = ?", buyerID).Delete(&models.CartItem{}).Error; err != nil {
@@ -236,12 +353,13 @@ func (s *CartService) CheckoutWithPayment(buyerID uint, deliveryAddress, payment
 	}
 
 	createdOrders := make([]models.Order, 0, len(createdOrderIDs))
-	for _, orderID := range createdOrderIDs {
+	for i, orderID := range createdOrderIDs {
 		order, getErr := s.orderRepo.GetByID(orderID)
 		if getErr != nil {
 			return nil, errors.New("failed to load created orders")
 		}
 		createdOrders = append(createdOrders, *order)
+		s.events.Publish(order.ID, []uint{order.BuyerID, order.FarmerID}, "status", statusLogs[i].ID, statusLogs[i])
 	}
 	return createdOrders, nil
 }
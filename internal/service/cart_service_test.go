@@ -0,0 +1,25 @@
+package service
+
+import "testing"
+
+func TestCartCheckoutMarksBulkEligibleItems(t *testing.T) {
+	ctx := setupTestCtx(t)
+
+	if err := ctx.cartSvc.AddToCart(ctx.buyerID, AddToCartRequest{
+		ProductID: ctx.productID,
+		Quantity:  5,
+	}); err != nil {
+		t.Fatalf("failed to add bulk-eligible item to cart: %v", err)
+	}
+
+	orders, err := ctx.cartSvc.Checkout(ctx.buyerID, "Cart address")
+	if err != nil {
+		t.Fatalf("failed to checkout cart: %v", err)
+	}
+	if len(orders) != 1 {
+		t.Fatalf("expected 1 order from checkout, got %d", len(orders))
+	}
+	if orders[0].OrderType != "bulk" {
+		t.Fatalf("expected cart checkout order type bulk, got %s", orders[0].OrderType)
+	}
+}
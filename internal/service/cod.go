@@ -0,0 +1,391 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RecordCODCollectionRequest struct {
+	Amount        float64 `json:"amount"`
+	CollectorName string  `json:"collector_name"`
+	Note          string  `json:"note"`
+}
+
+type ResolveCODDiscrepancyRequest struct {
+	Resolution string `json:"resolution"`
+	Note       string `json:"note"`
+}
+
+type CODRemittanceRequest struct {
+	FarmerID  uint    `json:"farmer_id"`
+	Amount    float64 `json:"amount"`
+	Reference string  `json:"reference"`
+}
+
+// CODReconciliationRow is one farmer's cash-on-delivery position. Outstanding
+// is cash the farmer holds that belongs to the platform, after everything they
+// have earned and remitted.
+type CODReconciliationRow struct {
+	FarmerID           uint    `json:"farmer_id"`
+	FarmerName         string  `json:"farmer_name"`
+	AwaitingCollection int     `json:"awaiting_collection"` // delivered with no cash recorded
+	AwaitingAmount     float64 `json:"awaiting_amount"`
+	Collections        int     `json:"collections"`
+	Expected           float64 `json:"expected"`
+	Collected          float64 `json:"collected"`
+	OpenDiscrepancies  int     `json:"open_discrepancies"`
+	DiscrepancyAmount  float64 `json:"discrepancy_amount"` // collected - expected on open discrepancies
+	Remitted           float64 `json:"remitted"`
+	LedgerBalance      float64 `json:"ledger_balance"` // owed to the farmer when positive
+	Outstanding        float64 `json:"outstanding"`
+}
+
+type CODReconciliationReport struct {
+	Rows              []CODReconciliationRow `json:"rows"`
+	TotalOutstanding  float64                `json:"total_outstanding"`
+	OpenDiscrepancies int                    `json:"open_discrepancies"`
+	Currency          string                 `json:"currency"`
+}
+
+// codResolutions lists how a discrepancy may be settled and whether it applies
+// to a shortfall (true) or to cash collected over the total (false).
+var codResolutions = map[string]bool{
+	"farmer_liable": true,  // the farmer answers for the missing cash
+	"buyer_owes":    true,  // the shortfall is charged to the buyer
+	"write_off":     true,  // the platform absorbs the shortfall
+	"refund_buyer":  false, // the extra cash goes back to the buyer's wallet
+}
+
+// RecordCODCollection is the farmer's record of the cash handed over at
+// delivery. The buyer's payment is posted to the ledger as held by the farmer
+// and the order's payment status follows what was collected.
+func (s *OrderService) RecordCODCollection(orderID, farmerID uint, req RecordCODCollectionRequest) (*models.CODCollection, error) {
+	amount := roundMoney(req.Amount)
+	if amount < 0 {
+		return nil, errors.New("collected amount cannot be negative")
+	}
+	collector := utils.SanitizeString(strings.TrimSpace(req.CollectorName))
+	if collector == "" {
+		return nil, errors.New("collector name is required")
+	}
+	note := utils.SanitizeString(strings.TrimSpace(req.Note))
+
+	var collection models.CODCollection
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error; err != nil {
+			return errors.New("order not found")
+		}
+		if order.FarmerID != farmerID {
+			return errors.New("unauthorized: you can only record collections for your own orders")
+		}
+		if isPrepaidMethod(order.PaymentMethod) || isCreditMethod(order.PaymentMethod) {
+			return errors.New("order is not cash on delivery")
+		}
+		if order.Status != "out_for_delivery" && order.Status != "completed" {
+			return errors.New("cash can only be collected once the order is out for delivery")
+		}
+		var existing int64
+		if err := tx.Model(&models.CODCollection{}).Where("order_id = ?", order.ID).Count(&existing).Error; err != nil {
+			return errors.New("failed to load collection")
+		}
+		if existing > 0 {
+			return errors.New("cash collection already recorded for this order")
+		}
+
+		now := time.Now().UTC()
+		expected := amountDue(&order)
+		discrepancy := roundMoney(amount - expected)
+		collection = models.CODCollection{
+			OrderID:         order.ID,
+			FarmerID:        order.FarmerID,
+			BuyerID:         order.BuyerID,
+			ExpectedAmount:  expected,
+			CollectedAmount: amount,
+			Discrepancy:     discrepancy,
+			CollectorName:   collector,
+			RecordedBy:      farmerID,
+			Note:            note,
+			Status:          "collected",
+			CollectedAt:     now,
+		}
+		if discrepancy != 0 {
+			if note == "" {
+				return errors.New("a note explaining the difference is required")
+			}
+			collection.Status = "discrepancy"
+		}
+		if err := tx.Create(&collection).Error; err != nil {
+			return errors.New("failed to record collection")
+		}
+
+		order.PaymentStatus = "paid"
+		if discrepancy < 0 {
+			order.PaymentStatus = "partially_paid"
+		}
+		order.PaidAt = &now
+		if err := tx.Save(&order).Error; err != nil {
+			return errors.New("failed to update order")
+		}
+		return postBuyerPayment(tx, &order, "Cash on delivery collected by "+collector)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+func (s *OrderService) GetFarmerCODCollections(farmerID uint) ([]models.CODCollection, error) {
+	var collections []models.CODCollection
+	if err := s.orderRepo.GetDB().Where("farmer_id = ?", farmerID).Order("collected_at DESC").Find(&collections).Error; err != nil {
+		return nil, errors.New("failed to load collections")
+	}
+	return collections, nil
+}
+
+func (s *AdminService) GetCODDiscrepancies() ([]models.CODCollection, error) {
+	var collections []models.CODCollection
+	if err := s.orderRepo.GetDB().Preload("Order").
+		Where("status = ?", "discrepancy").
+		Order("collected_at ASC").
+		Find(&collections).Error; err != nil {
+		return nil, errors.New("failed to load discrepancies")
+	}
+	return collections, nil
+}
+
+// ResolveCODDiscrepancy settles the difference between what was collected and
+// the order total, moving it in the ledger to whoever now owes it.
+func (s *AdminService) ResolveCODDiscrepancy(adminID, collectionID uint, req ResolveCODDiscrepancyRequest) (*models.CODCollection, error) {
+	resolution := strings.ToLower(strings.TrimSpace(req.Resolution))
+	forShortfall, ok := codResolutions[resolution]
+	if !ok {
+		return nil, errors.New("invalid discrepancy resolution")
+	}
+
+	var collection models.CODCollection
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", collectionID).First(&collection).Error; err != nil {
+			return errors.New("collection not found")
+		}
+		if collection.Status != "discrepancy" {
+			return errors.New("collection has no open discrepancy")
+		}
+		shortfall := collection.Discrepancy < 0
+		if forShortfall != shortfall {
+			return errors.New("resolution does not apply to this discrepancy")
+		}
+
+		amount := math.Abs(collection.Discrepancy)
+		memo := "COD discrepancy: " + strings.ReplaceAll(resolution, "_", " ")
+		note := formatQuantity(collection.Discrepancy)
+		var lines []ledgerLine
+		switch resolution {
+		case "buyer_owes":
+			lines = []ledgerLine{
+				{account: ledgerBuyerReceivable, userID: &collection.BuyerID, amount: amount},
+				{account: ledgerFarmerPayable, userID: &collection.FarmerID, amount: -amount},
+			}
+		case "write_off":
+			lines = []ledgerLine{
+				{account: ledgerPlatformFee, amount: amount},
+				{account: ledgerFarmerPayable, userID: &collection.FarmerID, amount: -amount},
+			}
+		case "refund_buyer":
+			// The farmer holds the extra cash; the buyer gets it back as
+			// wallet credit they can spend or have paid out.
+			memo = "COD overpayment credited to buyer wallet"
+			note += " credited to buyer wallet"
+			lines = []ledgerLine{
+				{account: ledgerFarmerPayable, userID: &collection.FarmerID, amount: amount},
+				{account: ledgerBuyerWallet, userID: &collection.BuyerID, amount: -amount},
+			}
+		}
+		if err := postLedgerJournal(tx, "cod_adjustment", &collection.OrderID, &adminID, memo, lines...); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		collection.Status = "resolved"
+		collection.Resolution = resolution
+		collection.ResolutionNote = utils.SanitizeString(req.Note)
+		collection.ResolvedBy = &adminID
+		collection.ResolvedAt = &now
+		if err := tx.Save(&collection).Error; err != nil {
+			return errors.New("failed to resolve discrepancy")
+		}
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "order",
+			TargetID:   collection.OrderID,
+			Action:     "cod_" + resolution,
+			Note:       strings.TrimSpace(note + " " + collection.ResolutionNote),
+			CreatedAt:  now,
+		}).Error; err != nil {
+			return errors.New("failed to audit discrepancy")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// RecordCODRemittance posts cash a farmer has handed back to the platform.
+func (s *AdminService) RecordCODRemittance(adminID uint, req CODRemittanceRequest) (*CODReconciliationRow, error) {
+	amount := roundMoney(req.Amount)
+	if req.FarmerID == 0 {
+		return nil, errors.New("farmer is required")
+	}
+	if amount <= 0 {
+		return nil, errors.New("remittance amount must be greater than 0")
+	}
+	farmer, err := s.userRepo.GetByID(req.FarmerID)
+	if err != nil || farmer.UserType != "farmer" {
+		return nil, errors.New("farmer not found")
+	}
+
+	reference := utils.SanitizeString(req.Reference)
+	err = s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var balance float64
+		if err := tx.Model(&models.LedgerEntry{}).
+			Select("COALESCE(SUM(credit - debit), 0)").
+			Where("account = ? AND user_id = ?", ledgerFarmerPayable, req.FarmerID).
+			Scan(&balance).Error; err != nil {
+			return errors.New("failed to read ledger")
+		}
+		if amount > roundMoney(-balance) {
+			return errors.New("remittance exceeds the farmer's outstanding cash")
+		}
+		memo := "Cash on delivery remitted by farmer"
+		if reference != "" {
+			memo += " (" + reference + ")"
+		}
+		if err := postLedgerJournal(tx, "cod_remittance", nil, &adminID, memo,
+			ledgerLine{account: ledgerCash, amount: amount},
+			ledgerLine{account: ledgerFarmerPayable, userID: &req.FarmerID, amount: -amount},
+		); err != nil {
+			return err
+		}
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "user",
+			TargetID:   req.FarmerID,
+			Action:     "cod_remittance",
+			Note:       memo + ": " + formatQuantity(amount),
+			CreatedAt:  time.Now().UTC(),
+		}).Error; err != nil {
+			return errors.New("failed to audit remittance")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.GetCODReconciliation()
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range report.Rows {
+		if row.FarmerID == req.FarmerID {
+			return &row, nil
+		}
+	}
+	return &CODReconciliationRow{FarmerID: req.FarmerID, FarmerName: farmer.Name}, nil
+}
+
+// GetCODReconciliation lists every farmer with cash-on-delivery activity and
+// how much of the cash they hold is still to be remitted.
+func (s *AdminService) GetCODReconciliation() (*CODReconciliationReport, error) {
+	db := s.orderRepo.GetDB()
+	rows := map[uint]*CODReconciliationRow{}
+	row := func(farmerID uint) *CODReconciliationRow {
+		if rows[farmerID] == nil {
+			rows[farmerID] = &CODReconciliationRow{FarmerID: farmerID}
+		}
+		return rows[farmerID]
+	}
+
+	var awaiting []models.Order
+	if err := db.Where("status = ? AND (payment_method = ? OR payment_method = '' OR payment_method IS NULL)", "completed", "cod").
+		Where("id NOT IN (?)", db.Model(&models.CODCollection{}).Select("order_id")).
+		Find(&awaiting).Error; err != nil {
+		return nil, errors.New("failed to load orders")
+	}
+	for _, order := range awaiting {
+		item := row(order.FarmerID)
+		item.AwaitingCollection++
+		item.AwaitingAmount += amountDue(&order)
+	}
+
+	var collections []models.CODCollection
+	if err := db.Find(&collections).Error; err != nil {
+		return nil, errors.New("failed to load collections")
+	}
+	for _, collection := range collections {
+		item := row(collection.FarmerID)
+		item.Collections++
+		item.Expected += collection.ExpectedAmount
+		item.Collected += collection.CollectedAmount
+		if collection.Status == "discrepancy" {
+			item.OpenDiscrepancies++
+			item.DiscrepancyAmount += collection.Discrepancy
+		}
+	}
+
+	var remittances []struct {
+		UserID uint
+		Amount float64
+	}
+	if err := db.Model(&models.LedgerEntry{}).
+		Select("user_id, SUM(credit - debit) AS amount").
+		Where("kind = ? AND account = ?", "cod_remittance", ledgerFarmerPayable).
+		Group("user_id").
+		Scan(&remittances).Error; err != nil {
+		return nil, errors.New("failed to read ledger")
+	}
+	for _, remittance := range remittances {
+		row(remittance.UserID).Remitted = remittance.Amount
+	}
+
+	report := &CODReconciliationReport{Rows: make([]CODReconciliationRow, 0, len(rows)), Currency: "INR"}
+	for farmerID, item := range rows {
+		statement, err := buildFarmerLedgerStatement(s.orderRepo, farmerID)
+		if err != nil {
+			return nil, err
+		}
+		if farmer, err := s.userRepo.GetByID(farmerID); err == nil {
+			item.FarmerName = farmer.Name
+		}
+		item.AwaitingAmount = roundMoney(item.AwaitingAmount)
+		item.Expected = roundMoney(item.Expected)
+		item.Collected = roundMoney(item.Collected)
+		item.DiscrepancyAmount = roundMoney(item.DiscrepancyAmount)
+		item.Remitted = roundMoney(item.Remitted)
+		item.LedgerBalance = statement.Balance
+		if statement.Balance < 0 {
+			item.Outstanding = -statement.Balance
+		}
+		report.TotalOutstanding += item.Outstanding
+		report.OpenDiscrepancies += item.OpenDiscrepancies
+		report.Rows = append(report.Rows, *item)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		if report.Rows[i].Outstanding != report.Rows[j].Outstanding {
+			return report.Rows[i].Outstanding > report.Rows[j].Outstanding
+		}
+		return report.Rows[i].FarmerID < report.Rows[j].FarmerID
+	})
+	report.TotalOutstanding = roundMoney(report.TotalOutstanding)
+	return report, nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+)
+
+func TestCODCollectionDiscrepanciesAndReconciliation(t *testing.T) {
+	ctx := setupTestCtx(t)
+	orderRepo := repository.NewOrderRepository(ctx.db)
+	adminSvc := newAdminServiceForTest(ctx)
+	outForDelivery := func() *models.Order {
+		t.Helper()
+		order := createOrderForTest(t, ctx)
+		for _, step := range []string{"confirmed", "packed", "out_for_delivery"} {
+			if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{Status: step}); err != nil {
+				t.Fatalf("failed to move order to %s: %v", step, err)
+			}
+		}
+		return order
+	}
+	markReceived := func(orderID uint) {
+		t.Helper()
+		if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(orderID, ctx.buyerID, UpdateOrderStatusRequest{Status: "completed"}); err != nil {
+			t.Fatalf("failed to mark order received: %v", err)
+		}
+	}
+
+	early := createOrderForTest(t, ctx)
+	if _, err := ctx.orderSvc.RecordCODCollection(early.ID, ctx.farmerID, RecordCODCollectionRequest{Amount: 200, CollectorName: "Ravi"}); err == nil {
+		t.Fatalf("expected collection before dispatch to be rejected")
+	}
+
+	// Full amount collected at the door.
+	exact := outForDelivery()
+	collection, err := ctx.orderSvc.RecordCODCollection(exact.ID, ctx.farmerID, RecordCODCollectionRequest{Amount: 200, CollectorName: "Ravi"})
+	if err != nil || collection.Status != "collected" || collection.Discrepancy != 0 {
+		t.Fatalf("unexpected collection: %+v err=%v", collection, err)
+	}
+	if _, err := ctx.orderSvc.RecordCODCollection(exact.ID, ctx.farmerID, RecordCODCollectionRequest{Amount: 200, CollectorName: "Ravi"}); err == nil {
+		t.Fatalf("expected a second collection to be rejected")
+	}
+	if _, err := ctx.orderSvc.CancelOrder(exact.ID, ctx.buyerID, CancelOrderRequest{}); err == nil {
+		t.Fatalf("expected cancellation after collection to be rejected")
+	}
+	markReceived(exact.ID)
+	reloaded, _ := orderRepo.GetByID(exact.ID)
+	if reloaded.PaymentStatus != "paid" || reloaded.PaidAt == nil {
+		t.Fatalf("expected collected order to be paid, got %s", reloaded.PaymentStatus)
+	}
+
+	// Delivered with no cash recorded.
+	completeOrderForTest(t, ctx, createOrderForTest(t, ctx).ID)
+
+	// Short by 50.
+	short := outForDelivery()
+	if _, err := ctx.orderSvc.RecordCODCollection(short.ID, ctx.farmerID, RecordCODCollectionRequest{Amount: 150, CollectorName: "Ravi"}); err == nil {
+		t.Fatalf("expected a short collection without a note to be rejected")
+	}
+	collection, err = ctx.orderSvc.RecordCODCollection(short.ID, ctx.farmerID, RecordCODCollectionRequest{Amount: 150, CollectorName: "Ravi", Note: "buyer paid the rest later"})
+	if err != nil || collection.Status != "discrepancy" || collection.Discrepancy != -50 {
+		t.Fatalf("unexpected short collection: %+v err=%v", collection, err)
+	}
+	markReceived(short.ID)
+	reloaded, _ = orderRepo.GetByID(short.ID)
+	if reloaded.PaymentStatus != "partially_paid" {
+		t.Fatalf("expected short collection to be partially paid, got %s", reloaded.PaymentStatus)
+	}
+
+	report, err := adminSvc.GetCODReconciliation()
+	if err != nil || len(report.Rows) != 1 {
+		t.Fatalf("unexpected reconciliation: %+v err=%v", report, err)
+	}
+	row := report.Rows[0]
+	if row.AwaitingCollection != 1 || row.AwaitingAmount != 200 || row.Collections != 2 || row.Expected != 400 || row.Collected != 350 ||
+		row.OpenDiscrepancies != 1 || row.DiscrepancyAmount != -50 || row.Outstanding != 30 {
+		t.Fatalf("unexpected reconciliation row: %+v", row)
+	}
+
+	// The farmer holds 30 in fees on cash already collected.
+	if _, err := adminSvc.RecordCODRemittance(99, CODRemittanceRequest{FarmerID: ctx.farmerID, Amount: 40}); err == nil {
+		t.Fatalf("expected remittance above the outstanding cash to be rejected")
+	}
+	remitted, err := adminSvc.RecordCODRemittance(99, CODRemittanceRequest{FarmerID: ctx.farmerID, Amount: 30, Reference: "deposit slip 42"})
+	if err != nil || remitted.Remitted != 30 || remitted.Outstanding != 0 || remitted.LedgerBalance != 0 {
+		t.Fatalf("unexpected remittance: %+v err=%v", remitted, err)
+	}
+
+	// Charging the shortfall to the buyer clears the farmer of the missing 50.
+	if _, err := adminSvc.ResolveCODDiscrepancy(99, collection.ID, ResolveCODDiscrepancyRequest{Resolution: "refund_buyer"}); err == nil {
+		t.Fatalf("expected refund resolution on a shortfall to be rejected")
+	}
+	if _, err := adminSvc.ResolveCODDiscrepancy(99, collection.ID, ResolveCODDiscrepancyRequest{Resolution: "buyer_owes", Note: "buyer to pay balance"}); err != nil {
+		t.Fatalf("failed to resolve discrepancy: %v", err)
+	}
+	discrepancies, err := adminSvc.GetCODDiscrepancies()
+	if err != nil || len(discrepancies) != 0 {
+		t.Fatalf("expected no open discrepancies, got %d err=%v", len(discrepancies), err)
+	}
+	statement, err := ctx.orderSvc.GetFarmerLedger(ctx.farmerID)
+	if err != nil || statement.Balance != 50 {
+		t.Fatalf("expected the shortfall to be owed back to the farmer: %+v err=%v", statement, err)
+	}
+	summary, err := ctx.orderSvc.GetFarmerPayoutSummary(ctx.farmerID)
+	if err != nil || summary.PlatformFee != 30 || summary.NetPayout != 570 {
+		t.Fatalf("expected the adjustment to leave fees and earnings alone: %+v err=%v", summary, err)
+	}
+
+	// Over by 30: the extra cash goes back to the buyer's wallet.
+	over := outForDelivery()
+	collection, err = ctx.orderSvc.RecordCODCollection(over.ID, ctx.farmerID, RecordCODCollectionRequest{Amount: 230, CollectorName: "Ravi", Note: "no change at the door"})
+	if err != nil || collection.Discrepancy != 30 {
+		t.Fatalf("unexpected over collection: %+v err=%v", collection, err)
+	}
+	markReceived(over.ID)
+	if _, err := adminSvc.ResolveCODDiscrepancy(99, collection.ID, ResolveCODDiscrepancyRequest{Resolution: "write_off"}); err == nil {
+		t.Fatalf("expected a shortfall resolution on an overpayment to be rejected")
+	}
+	if _, err := adminSvc.ResolveCODDiscrepancy(99, collection.ID, ResolveCODDiscrepancyRequest{Resolution: "refund_buyer"}); err != nil {
+		t.Fatalf("failed to refund overpayment: %v", err)
+	}
+	if balance, _ := walletBalance(ctx.db, ctx.buyerID); balance != 30 {
+		t.Fatalf("expected the overpayment in the buyer's wallet, got %v", balance)
+	}
+	if owed, _ := ledgerBalance(ctx.db, over.ID, ledgerBuyerReceivable); owed != 0 {
+		t.Fatalf("expected nothing left on the buyer's receivable, got %v", owed)
+	}
+	var audit models.AdminAuditLog
+	if err := ctx.db.Where("target_type = ? AND target_id = ? AND action = ?", "order", over.ID, "cod_refund_buyer").First(&audit).Error; err != nil ||
+		!strings.Contains(audit.Note, "credited to buyer wallet") {
+		t.Fatalf("expected the refund in the audit trail: %+v err=%v", audit, err)
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// CourierTrackingUpdate is the carrier-neutral form of a single tracking event
+// received from a courier webhook.
+type CourierTrackingUpdate struct {
+	TrackingNumber  string
+	Status          string
+	Location        string
+	Description     string
+	ExternalEventID string
+	OccurredAt      time.Time
+}
+
+// CourierAdapter translates a courier's signed webhook into tracking updates.
+// Each carrier we integrate with gets its own adapter registered on the
+// ShipmentService under its code.
+type CourierAdapter interface {
+	Code() string
+	VerifyWebhook(payload []byte, signature string) error
+	ParseWebhook(payload []byte) ([]CourierTrackingUpdate, error)
+}
+
+func isAllowedShipmentStatus(value string) bool {
+	switch value {
+	case "created", "picked_up", "in_transit", "out_for_delivery", "delivered", "exception", "returned":
+		return true
+	default:
+		return false
+	}
+}
+
+func signHMACSHA256(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifyHMACSHA256(secret string, payload []byte, signature string) error {
+	if strings.TrimSpace(secret) == "" {
+		return errors.New("webhook secret is not configured")
+	}
+	expected := signHMACSHA256(secret, payload)
+	provided := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(signature, "sha256=")))
+	if !hmac.Equal([]byte(expected), []byte(provided)) {
+		return errors.New("invalid webhook signature")
+	}
+	return nil
+}
+
+// MockCourier is a local carrier used for development and tests. Payloads are
+// JSON and signed with a hex HMAC-SHA256 of the raw body.
+type MockCourier struct {
+	secret string
+}
+
+func NewMockCourier(secret string) *MockCourier {
+	return &MockCourier{secret: secret}
+}
+
+type mockCourierPayload struct {
+	Events []struct {
+		AWB         string `json:"awb"`
+		Status      string `json:"status"`
+		Location    string `json:"location"`
+		Description string `json:"description"`
+		EventID     string `json:"event_id"`
+		OccurredAt  string `json:"occurred_at"`
+	} `json:"events"`
+}
+
+func (m *MockCourier) Code() string {
+	return "mock"
+}
+
+// Sign returns the signature the mock carrier would send for payload.
+func (m *MockCourier) Sign(payload []byte) string {
+	return signHMACSHA256(m.secret, payload)
+}
+
+func (m *MockCourier) VerifyWebhook(payload []byte, signature string) error {
+	return verifyHMACSHA256(m.secret, payload, signature)
+}
+
+func (m *MockCourier) ParseWebhook(payload []byte) ([]CourierTrackingUpdate, error) {
+	var body mockCourierPayload
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, errors.New("invalid courier payload")
+	}
+	updates := make([]CourierTrackingUpdate, 0, len(body.Events))
+	for _, event := range body.Events {
+		occurredAt := time.Now().UTC()
+		if strings.TrimSpace(event.OccurredAt) != "" {
+			parsed, err := time.Parse(time.RFC3339, event.OccurredAt)
+			if err != nil {
+				return nil, errors.New("invalid courier event time")
+			}
+			occurredAt = parsed.UTC()
+		}
+		updates = append(updates, CourierTrackingUpdate{
+			TrackingNumber:  strings.TrimSpace(event.AWB),
+			Status:          strings.ToLower(strings.TrimSpace(event.Status)),
+			Location:        strings.TrimSpace(event.Location),
+			Description:     strings.TrimSpace(event.Description),
+			ExternalEventID: strings.TrimSpace(event.EventID),
+			OccurredAt:      occurredAt,
+		})
+	}
+	return updates, nil
+}
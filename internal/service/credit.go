@@ -0,0 +1,557 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// creditReminderInterval is how often a buyer is reminded about an order that
+// stays overdue.
+const creditReminderInterval = 7 * 24 * time.Hour
+
+var creditTermDays = map[int]bool{15: true, 30: true}
+
+type CreditAccountRequest struct {
+	RequestedLimit float64 `json:"requested_limit"`
+	TermDays       int     `json:"term_days"`
+	Note           string  `json:"note"`
+}
+
+type ReviewCreditAccountRequest struct {
+	Status      string   `json:"status"` // active/suspended/rejected
+	CreditLimit *float64 `json:"credit_limit"`
+	TermDays    *int     `json:"term_days"`
+	Note        string   `json:"note"`
+}
+
+type CreditRepaymentRequest struct {
+	BuyerID   uint   `json:"buyer_id"`
+	OrderIDs  []uint `json:"order_ids"`
+	Reference string `json:"reference"`
+	Note      string `json:"note"`
+}
+
+// BuyerCreditSummary is a buyer's credit position: the account, what is
+// owed, how much of the limit is left and the unpaid orders behind it.
+type BuyerCreditSummary struct {
+	Account       *models.BuyerCreditAccount `json:"account"`
+	Outstanding   float64                    `json:"outstanding"`
+	Available     float64                    `json:"available"`
+	OverdueOrders int                        `json:"overdue_orders"`
+	OverdueAmount float64                    `json:"overdue_amount"`
+	Orders        []models.Order             `json:"orders"`
+	Currency      string                     `json:"currency"`
+}
+
+// CreditAgingRow buckets a buyer's unpaid credit orders by days past due.
+type CreditAgingRow struct {
+	BuyerID     uint       `json:"buyer_id"`
+	BuyerName   string     `json:"buyer_name"`
+	CreditLimit float64    `json:"credit_limit"`
+	TermDays    int        `json:"term_days"`
+	OpenOrders  int        `json:"open_orders"`
+	Current     float64    `json:"current"`
+	Days1To30   float64    `json:"days_1_30"`
+	Days31To60  float64    `json:"days_31_60"`
+	Days61To90  float64    `json:"days_61_90"`
+	Over90      float64    `json:"over_90"`
+	Total       float64    `json:"total"`
+	OldestDueAt *time.Time `json:"oldest_due_at"`
+}
+
+type CreditAgingReport struct {
+	AsOf     time.Time        `json:"as_of"`
+	Rows     []CreditAgingRow `json:"rows"`
+	Totals   CreditAgingRow   `json:"totals"`
+	Currency string           `json:"currency"`
+}
+
+func (row *CreditAgingRow) add(order *models.Order, now time.Time) {
+	due := amountDue(order)
+	row.OpenOrders++
+	row.Total += due
+	if order.CreditDueAt == nil || !now.After(*order.CreditDueAt) {
+		row.Current += due
+	} else {
+		switch days := int(now.Sub(*order.CreditDueAt).Hours() / 24); {
+		case days <= 30:
+			row.Days1To30 += due
+		case days <= 60:
+			row.Days31To60 += due
+		case days <= 90:
+			row.Days61To90 += due
+		default:
+			row.Over90 += due
+		}
+	}
+	if order.CreditDueAt != nil && (row.OldestDueAt == nil || order.CreditDueAt.Before(*row.OldestDueAt)) {
+		row.OldestDueAt = order.CreditDueAt
+	}
+}
+
+func (row *CreditAgingRow) round() {
+	row.Current = roundMoney(row.Current)
+	row.Days1To30 = roundMoney(row.Days1To30)
+	row.Days31To60 = roundMoney(row.Days31To60)
+	row.Days61To90 = roundMoney(row.Days61To90)
+	row.Over90 = roundMoney(row.Over90)
+	row.Total = roundMoney(row.Total)
+}
+
+func openCreditOrders(tx *gorm.DB, buyerID uint) *gorm.DB {
+	query := tx.Model(&models.Order{}).
+		Where("payment_method = ? AND payment_status = ? AND status <> ?", "credit", "pending", "cancelled")
+	if buyerID > 0 {
+		query = query.Where("buyer_id = ?", buyerID)
+	}
+	return query
+}
+
+func creditOutstanding(tx *gorm.DB, buyerID uint) (float64, error) {
+	var outstanding float64
+	if err := openCreditOrders(tx, buyerID).
+		Select("COALESCE(SUM(total_price - wallet_amount - waived_amount), 0)").
+		Scan(&outstanding).Error; err != nil {
+		return 0, errors.New("failed to read credit balance")
+	}
+	return roundMoney(outstanding), nil
+}
+
+// ensureBuyerNotOverdue blocks new orders of any kind while the buyer has a
+// credit order past its due date.
+func ensureBuyerNotOverdue(tx *gorm.DB, buyerID uint, now time.Time) error {
+	var overdue int64
+	if err := openCreditOrders(tx, buyerID).
+		Where("credit_due_at IS NOT NULL AND credit_due_at < ?", now).
+		Count(&overdue).Error; err != nil {
+		return errors.New("failed to read credit balance")
+	}
+	if overdue > 0 {
+		return errors.New("ordering is on hold until overdue credit payments are settled")
+	}
+	return nil
+}
+
+// reserveCreditPayment checks a new credit order against the buyer's
+// available limit. The total is only known once the order is priced, so this
+// runs in the order transaction rather than in validatePayment. The payment
+// term starts when the order is delivered, see startCreditTerm.
+func reserveCreditPayment(tx *gorm.DB, order *models.Order) error {
+	if !isCreditMethod(order.PaymentMethod) {
+		return nil
+	}
+	var account models.BuyerCreditAccount
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("buyer_id = ?", order.BuyerID).First(&account).Error; err != nil {
+		return errors.New("no approved credit account for this buyer")
+	}
+	if account.Status != "active" {
+		return errors.New("credit account is not active")
+	}
+	outstanding, err := creditOutstanding(tx, order.BuyerID)
+	if err != nil {
+		return err
+	}
+	if roundMoney(outstanding+amountDue(order)) > account.CreditLimit {
+		return errors.New("order exceeds the available credit limit")
+	}
+	return nil
+}
+
+// startCreditTerm sets when a credit order falls due. The buyer is billed on
+// delivery, so the term runs from then rather than from when the order was
+// placed; an order that waits weeks for harvest should not arrive overdue.
+func startCreditTerm(tx *gorm.DB, order *models.Order, billedAt time.Time) error {
+	if !isCreditMethod(order.PaymentMethod) || order.CreditDueAt != nil {
+		return nil
+	}
+	var account models.BuyerCreditAccount
+	if err := tx.Where("buyer_id = ?", order.BuyerID).First(&account).Error; err != nil {
+		return errors.New("no credit account for this buyer")
+	}
+	dueAt := billedAt.AddDate(0, 0, account.TermDays)
+	if err := tx.Model(order).Update("credit_due_at", dueAt).Error; err != nil {
+		return errors.New("failed to set credit due date")
+	}
+	order.CreditDueAt = &dueAt
+	return nil
+}
+
+func buildBuyerCreditSummary(db *gorm.DB, buyerID uint, now time.Time) (*BuyerCreditSummary, error) {
+	summary := &BuyerCreditSummary{Currency: "INR"}
+	var account models.BuyerCreditAccount
+	if err := db.Where("buyer_id = ?", buyerID).First(&account).Error; err == nil {
+		summary.Account = &account
+	}
+	if err := openCreditOrders(db, buyerID).Preload("Product").Order("credit_due_at ASC").Find(&summary.Orders).Error; err != nil {
+		return nil, errors.New("failed to load credit orders")
+	}
+	for i := range summary.Orders {
+		order := &summary.Orders[i]
+		due := amountDue(order)
+		summary.Outstanding += due
+		if order.CreditDueAt != nil && now.After(*order.CreditDueAt) {
+			summary.OverdueOrders++
+			summary.OverdueAmount += due
+		}
+	}
+	summary.Outstanding = roundMoney(summary.Outstanding)
+	summary.OverdueAmount = roundMoney(summary.OverdueAmount)
+	if summary.Account != nil && summary.Account.Status == "active" && summary.Account.CreditLimit > summary.Outstanding {
+		summary.Available = roundMoney(summary.Account.CreditLimit - summary.Outstanding)
+	}
+	return summary, nil
+}
+
+func (s *OrderService) GetBuyerCredit(buyerID uint) (*BuyerCreditSummary, error) {
+	return buildBuyerCreditSummary(s.orderRepo.GetDB(), buyerID, time.Now().UTC())
+}
+
+// ApplyForCredit asks for a credit account, or resubmits a pending or
+// rejected one. Only verified business buyers with a GSTIN can apply.
+func (s *OrderService) ApplyForCredit(buyerID uint, req CreditAccountRequest) (*models.BuyerCreditAccount, error) {
+	buyer, err := s.userRepo.GetByID(buyerID)
+	if err != nil || buyer.UserType != "buyer" {
+		return nil, errors.New("buyer not found")
+	}
+	if buyer.VerificationStatus != "verified" || strings.TrimSpace(buyer.GSTIN) == "" {
+		return nil, errors.New("credit is available to verified business buyers with a GSTIN")
+	}
+	limit := roundMoney(req.RequestedLimit)
+	if limit <= 0 {
+		return nil, errors.New("requested limit must be greater than 0")
+	}
+	if req.TermDays == 0 {
+		req.TermDays = 30
+	}
+	if !creditTermDays[req.TermDays] {
+		return nil, errors.New("payment terms must be 15 or 30 days")
+	}
+
+	var account models.BuyerCreditAccount
+	err = s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("buyer_id = ?", buyerID).First(&account).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("failed to load credit account")
+		}
+		switch account.Status {
+		case "active":
+			return errors.New("credit account is already active")
+		case "suspended":
+			return errors.New("credit account is suspended")
+		}
+		account.BuyerID = buyerID
+		account.RequestedLimit = limit
+		account.TermDays = req.TermDays
+		account.RequestNote = utils.SanitizeString(strings.TrimSpace(req.Note))
+		account.Status = "pending"
+		account.ReviewNote = ""
+		account.ReviewedBy = nil
+		account.ReviewedAt = nil
+		if err := tx.Save(&account).Error; err != nil {
+			return errors.New("failed to save credit application")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (s *AdminService) GetCreditAccounts(status string) ([]models.BuyerCreditAccount, error) {
+	query := s.orderRepo.GetDB().Preload("Buyer").Order("created_at DESC")
+	if status = strings.ToLower(strings.TrimSpace(status)); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var accounts []models.BuyerCreditAccount
+	if err := query.Find(&accounts).Error; err != nil {
+		return nil, errors.New("failed to load credit accounts")
+	}
+	return accounts, nil
+}
+
+// ReviewCreditAccount approves, suspends or rejects a buyer's credit. An
+// approval defaults to the limit and terms the buyer asked for.
+func (s *AdminService) ReviewCreditAccount(adminID, accountID uint, req ReviewCreditAccountRequest) (*models.BuyerCreditAccount, error) {
+	status := strings.ToLower(strings.TrimSpace(req.Status))
+	if status != "active" && status != "suspended" && status != "rejected" {
+		return nil, errors.New("invalid credit account status")
+	}
+	var account models.BuyerCreditAccount
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", accountID).First(&account).Error; err != nil {
+			return errors.New("credit account not found")
+		}
+		if status == "active" {
+			var buyer models.User
+			if err := tx.Where("id = ?", account.BuyerID).First(&buyer).Error; err != nil {
+				return errors.New("buyer not found")
+			}
+			if buyer.VerificationStatus != "verified" || strings.TrimSpace(buyer.GSTIN) == "" {
+				return errors.New("credit is available to verified business buyers with a GSTIN")
+			}
+			limit := account.RequestedLimit
+			if req.CreditLimit != nil {
+				limit = roundMoney(*req.CreditLimit)
+			}
+			if limit <= 0 {
+				return errors.New("credit limit must be greater than 0")
+			}
+			if req.TermDays != nil {
+				account.TermDays = *req.TermDays
+			}
+			if !creditTermDays[account.TermDays] {
+				return errors.New("payment terms must be 15 or 30 days")
+			}
+			account.CreditLimit = limit
+		}
+		now := time.Now().UTC()
+		account.Status = status
+		account.ReviewNote = utils.SanitizeString(strings.TrimSpace(req.Note))
+		account.ReviewedBy = &adminID
+		account.ReviewedAt = &now
+		if err := tx.Save(&account).Error; err != nil {
+			return errors.New("failed to review credit account")
+		}
+		note := account.ReviewNote
+		if status == "active" {
+			note = strings.TrimSpace("limit " + formatQuantity(account.CreditLimit) + ", net-" + strconv.Itoa(account.TermDays) + " " + note)
+		}
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "credit_account",
+			TargetID:   account.ID,
+			Action:     "credit_account_" + status,
+			Note:       note,
+			CreatedAt:  now,
+		}).Error; err != nil {
+			return errors.New("failed to audit credit account")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// RecordCreditRepayment marks credit orders paid once finance receives the
+// buyer's transfer. Each order's receivable is billed if delivery has not
+// done so yet and then cleared against cash.
+func (s *AdminService) RecordCreditRepayment(adminID uint, req CreditRepaymentRequest) (*BuyerCreditSummary, error) {
+	reference := utils.SanitizeString(strings.TrimSpace(req.Reference))
+	if reference == "" {
+		return nil, errors.New("a payment reference is required")
+	}
+	if len(req.OrderIDs) == 0 {
+		return nil, errors.New("select at least one order")
+	}
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		var orders []models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", req.OrderIDs).Find(&orders).Error; err != nil {
+			return errors.New("failed to load orders")
+		}
+		if len(orders) != len(req.OrderIDs) {
+			return errors.New("order not found")
+		}
+		total := 0.0
+		for i := range orders {
+			order := &orders[i]
+			if order.BuyerID != req.BuyerID || !isCreditMethod(order.PaymentMethod) {
+				return errors.New("order is not a credit order for this buyer")
+			}
+			if order.Status == "cancelled" || order.PaymentStatus != "pending" {
+				return errors.New("order has no credit balance to settle")
+			}
+			if err := postBuyerPayment(tx, order, "Billed to buyer credit account"); err != nil {
+				return err
+			}
+			due := amountDue(order)
+			if err := postLedgerJournal(tx, "credit_repayment", &order.ID, &adminID, "Credit repaid: "+reference,
+				ledgerLine{account: ledgerCash, amount: due},
+				ledgerLine{account: ledgerBuyerReceivable, userID: &order.BuyerID, amount: -due},
+			); err != nil {
+				return err
+			}
+			if err := tx.Model(order).Updates(map[string]interface{}{
+				"payment_status": "paid",
+				"paid_at":        now,
+			}).Error; err != nil {
+				return errors.New("failed to update order payment")
+			}
+			total += due
+		}
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "user",
+			TargetID:   req.BuyerID,
+			Action:     "credit_repayment",
+			Note:       strings.TrimSpace(formatQuantity(roundMoney(total)) + " ref " + reference + " " + utils.SanitizeString(req.Note)),
+			CreatedAt:  now,
+		}).Error; err != nil {
+			return errors.New("failed to audit credit repayment")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buildBuyerCreditSummary(s.orderRepo.GetDB(), req.BuyerID, time.Now().UTC())
+}
+
+// GetCreditAging buckets every unpaid credit order by how far past due it is,
+// one row per buyer with the most overdue first.
+func (s *AdminService) GetCreditAging(now time.Time) (*CreditAgingReport, error) {
+	db := s.orderRepo.GetDB()
+	var orders []models.Order
+	if err := openCreditOrders(db, 0).Preload("Buyer").Find(&orders).Error; err != nil {
+		return nil, errors.New("failed to load credit orders")
+	}
+	var accounts []models.BuyerCreditAccount
+	if err := db.Find(&accounts).Error; err != nil {
+		return nil, errors.New("failed to load credit accounts")
+	}
+	byBuyer := make(map[uint]models.BuyerCreditAccount, len(accounts))
+	for _, account := range accounts {
+		byBuyer[account.BuyerID] = account
+	}
+
+	report := &CreditAgingReport{AsOf: now, Rows: []CreditAgingRow{}, Currency: "INR"}
+	rows := make(map[uint]*CreditAgingRow)
+	for i := range orders {
+		order := &orders[i]
+		row, ok := rows[order.BuyerID]
+		if !ok {
+			account := byBuyer[order.BuyerID]
+			row = &CreditAgingRow{
+				BuyerID:     order.BuyerID,
+				BuyerName:   order.Buyer.Name,
+				CreditLimit: account.CreditLimit,
+				TermDays:    account.TermDays,
+			}
+			rows[order.BuyerID] = row
+		}
+		row.add(order, now)
+		report.Totals.add(order, now)
+	}
+	for _, row := range rows {
+		row.round()
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if a.Total-a.Current != b.Total-b.Current {
+			return a.Total-a.Current > b.Total-b.Current
+		}
+		return a.BuyerID < b.BuyerID
+	})
+	report.Totals.round()
+	return report, nil
+}
+
+func (s *AdminService) ExportCreditAgingCSV(now time.Time) (string, error) {
+	report, err := s.GetCreditAging(now)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write([]string{
+		"buyer_id", "buyer", "credit_limit_inr", "term_days", "open_orders",
+		"current_inr", "days_1_30_inr", "days_31_60_inr", "days_61_90_inr", "over_90_inr", "total_inr",
+		"oldest_due_at",
+	}); err != nil {
+		return "", err
+	}
+	for _, row := range report.Rows {
+		oldest := ""
+		if row.OldestDueAt != nil {
+			oldest = row.OldestDueAt.Format(time.RFC3339)
+		}
+		if err := writer.Write([]string{
+			strconv.FormatUint(uint64(row.BuyerID), 10),
+			row.BuyerName,
+			strconv.FormatFloat(row.CreditLimit, 'f', 2, 64),
+			strconv.Itoa(row.TermDays),
+			strconv.Itoa(row.OpenOrders),
+			strconv.FormatFloat(row.Current, 'f', 2, 64),
+			strconv.FormatFloat(row.Days1To30, 'f', 2, 64),
+			strconv.FormatFloat(row.Days31To60, 'f', 2, 64),
+			strconv.FormatFloat(row.Days61To90, 'f', 2, 64),
+			strconv.FormatFloat(row.Over90, 'f', 2, 64),
+			strconv.FormatFloat(row.Total, 'f', 2, 64),
+			oldest,
+		}); err != nil {
+			return "", err
+		}
+	}
+	writer.Flush()
+	return buf.String(), writer.Error()
+}
+
+// CreditReminderSweeper emails buyers about overdue credit orders, repeating
+// every creditReminderInterval until the order is paid.
+type CreditReminderSweeper struct {
+	orderRepo    *repository.OrderRepository
+	emailService *EmailService
+}
+
+func NewCreditReminderSweeper(orderRepo *repository.OrderRepository, emailService *EmailService) *CreditReminderSweeper {
+	return &CreditReminderSweeper{orderRepo: orderRepo, emailService: emailService}
+}
+
+// Sweep reminds buyers about overdue orders and returns how many reminders
+// were sent.
+func (s *CreditReminderSweeper) Sweep(now time.Time) (int, error) {
+	orders, err := s.orderRepo.ListOverdueCreditOrders(now, now.Add(-creditReminderInterval))
+	if err != nil {
+		return 0, err
+	}
+	reminded := 0
+	for i := range orders {
+		order := &orders[i]
+		if s.emailService != nil && order.Buyer.Email != "" {
+			if err := s.emailService.SendCreditPaymentOverdue(order.Buyer.Email, order); err != nil {
+				log.Printf("credit order %d: overdue email failed: %v", order.ID, err)
+				continue
+			}
+		}
+		if err := s.orderRepo.MarkCreditReminderSent(order.ID, now); err != nil {
+			return reminded, err
+		}
+		reminded++
+	}
+	return reminded, nil
+}
+
+// Run sweeps on every tick until ctx is cancelled.
+func (s *CreditReminderSweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if reminded, err := s.Sweep(time.Now().UTC()); err != nil {
+			log.Printf("Credit reminder sweep failed: %v", err)
+		} else if reminded > 0 {
+			log.Printf("Credit reminder sweep: %d reminded", reminded)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
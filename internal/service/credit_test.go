@@ -0,0 +1,108 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+)
+
+func TestBuyerCreditLimitsTermsAndAging(t *testing.T) {
+	ctx := setupTestCtx(t)
+	orderRepo := repository.NewOrderRepository(ctx.db)
+	adminSvc := newAdminServiceForTest(ctx)
+	placeOrder := func(method string) (*models.Order, error) {
+		return ctx.orderSvc.CreateOrder(ctx.buyerID, CreateOrderRequest{
+			ProductID:       ctx.productID,
+			Quantity:        2,
+			DeliveryAddress: "Warehouse 4",
+			PaymentMethod:   method,
+		})
+	}
+
+	if _, err := ctx.orderSvc.ApplyForCredit(ctx.buyerID, CreditAccountRequest{RequestedLimit: 500, TermDays: 15}); err == nil {
+		t.Fatalf("expected a buyer without a GSTIN to be refused credit")
+	}
+	ctx.db.Model(&models.User{}).Where("id = ?", ctx.buyerID).Update("gstin", "33AAACB1234C1Z5")
+	if _, err := ctx.orderSvc.ApplyForCredit(ctx.buyerID, CreditAccountRequest{RequestedLimit: 500, TermDays: 45}); err == nil {
+		t.Fatalf("expected unsupported terms to be rejected")
+	}
+	account, err := ctx.orderSvc.ApplyForCredit(ctx.buyerID, CreditAccountRequest{RequestedLimit: 500, TermDays: 15})
+	if err != nil || account.Status != "pending" {
+		t.Fatalf("unexpected credit application: %+v err=%v", account, err)
+	}
+	if _, err := placeOrder("credit"); err == nil {
+		t.Fatalf("expected a credit order before approval to be rejected")
+	}
+	limit := 300.0
+	account, err = adminSvc.ReviewCreditAccount(99, account.ID, ReviewCreditAccountRequest{Status: "active", CreditLimit: &limit})
+	if err != nil || account.Status != "active" || account.CreditLimit != 300 || account.TermDays != 15 {
+		t.Fatalf("unexpected approved account: %+v err=%v", account, err)
+	}
+
+	order, err := placeOrder("credit")
+	if err != nil || order.PaymentStatus != "pending" || order.CreditDueAt != nil {
+		t.Fatalf("expected the credit term to wait for delivery: %+v err=%v", order, err)
+	}
+	if _, err := placeOrder("credit"); err == nil {
+		t.Fatalf("expected an order above the credit limit to be rejected")
+	}
+	credit, err := ctx.orderSvc.GetBuyerCredit(ctx.buyerID)
+	if err != nil || credit.Outstanding != 200 || credit.Available != 100 || len(credit.Orders) != 1 {
+		t.Fatalf("unexpected credit summary: %+v err=%v", credit, err)
+	}
+	// Credit orders ship before payment and are billed on delivery.
+	completeOrderForTest(t, ctx, order.ID)
+	owed, _ := ledgerBalance(ctx.db, order.ID, ledgerBuyerReceivable)
+	if owed != 200 {
+		t.Fatalf("expected the delivered order to be billed to the buyer, got %v", owed)
+	}
+	order, _ = orderRepo.GetByID(order.ID)
+	if order.CreditDueAt == nil {
+		t.Fatalf("expected delivery to start the credit term")
+	}
+	if days := time.Until(*order.CreditDueAt).Hours() / 24; days < 14.9 || days > 15.1 {
+		t.Fatalf("expected net-15 due date from delivery, got %v days", days)
+	}
+
+	report, err := adminSvc.GetCreditAging(order.CreditDueAt.Add(20 * 24 * time.Hour))
+	if err != nil || len(report.Rows) != 1 || report.Rows[0].Days1To30 != 200 || report.Totals.Total != 200 {
+		t.Fatalf("unexpected aging report: %+v err=%v", report, err)
+	}
+	csvReport, err := adminSvc.ExportCreditAgingCSV(time.Now().UTC())
+	if err != nil || !strings.Contains(csvReport, "Buyer One,300.00,15,1,200.00") {
+		t.Fatalf("unexpected aging export: %q err=%v", csvReport, err)
+	}
+
+	// Once the due date passes the buyer is reminded and cannot order.
+	past := time.Now().UTC().Add(-time.Hour)
+	ctx.db.Model(&models.Order{}).Where("id = ?", order.ID).Update("credit_due_at", past)
+	if _, err := placeOrder("cod"); err == nil {
+		t.Fatalf("expected an overdue buyer to be blocked from ordering")
+	}
+	sweeper := NewCreditReminderSweeper(orderRepo, nil)
+	if reminded, err := sweeper.Sweep(time.Now().UTC()); err != nil || reminded != 1 {
+		t.Fatalf("expected one overdue reminder, got %d err=%v", reminded, err)
+	}
+	if reminded, err := sweeper.Sweep(time.Now().UTC()); err != nil || reminded != 0 {
+		t.Fatalf("expected no repeat reminder within the interval, got %d err=%v", reminded, err)
+	}
+
+	if _, err := adminSvc.RecordCreditRepayment(99, CreditRepaymentRequest{BuyerID: ctx.buyerID, OrderIDs: []uint{order.ID}}); err == nil {
+		t.Fatalf("expected a repayment without a reference to be rejected")
+	}
+	credit, err = adminSvc.RecordCreditRepayment(99, CreditRepaymentRequest{BuyerID: ctx.buyerID, OrderIDs: []uint{order.ID}, Reference: "NEFT998877"})
+	if err != nil || credit.Outstanding != 0 || credit.Available != 300 {
+		t.Fatalf("unexpected credit after repayment: %+v err=%v", credit, err)
+	}
+	owed, _ = ledgerBalance(ctx.db, order.ID, ledgerBuyerReceivable)
+	reloaded, _ := orderRepo.GetByID(order.ID)
+	if owed != 0 || reloaded.PaymentStatus != "paid" {
+		t.Fatalf("expected the repayment to clear the order: owed=%v status=%s", owed, reloaded.PaymentStatus)
+	}
+	if _, err := placeOrder("credit"); err != nil {
+		t.Fatalf("expected ordering to resume after repayment: %v", err)
+	}
+}
@@ -117,3 +117,84 @@ func (s *EmailService) SendReviewReminder(to string, order *models.Order) error
 
 	return s.sendEmail(to, subject, body)
 }
+
+func (s *EmailService) SendHarvestRequestReminder(to string, item *models.HarvestRequest) error {
+	subject := fmt.Sprintf("Harvest Request #%d Needs Your Response", item.ID)
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Harvest Request Awaiting Response</h2>
+			<p>A buyer is waiting on your answer to harvest request #%d.</p>
+			<p><strong>Product:</strong> %s</p>
+			<p><strong>Quantity:</strong> %.2f %s</p>
+			<p><strong>Respond by:</strong> %s</p>
+			<br>
+			<p>Requests that are not answered in time expire automatically.</p>
+		</body>
+		</html>
+	`, item.ID, item.Product.CropName, item.RequestedQuantity, item.Product.Unit, item.ResponseDeadline.Format("02 Jan 2006 15:04 MST"))
+
+	return s.sendEmail(to, subject, body)
+}
+
+func (s *EmailService) SendHarvestRequestExpired(to string, item *models.HarvestRequest) error {
+	subject := fmt.Sprintf("Harvest Request #%d Expired", item.ID)
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Harvest Request Expired</h2>
+			<p>The farmer did not respond to your harvest request #%d for %s in time.</p>
+			<p>You can place a new request or browse other listings.</p>
+		</body>
+		</html>
+	`, item.ID, item.Product.CropName)
+
+	return s.sendEmail(to, subject, body)
+}
+
+func (s *EmailService) SendHarvestCounterExpired(to string, item *models.HarvestRequest) error {
+	subject := fmt.Sprintf("Counter-Proposal for Harvest Request #%d Expired", item.ID)
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Counter-Proposal Expired</h2>
+			<p>The buyer did not answer your counter-proposal on harvest request #%d for %s in time.</p>
+			<p>The request has been closed. The buyer can send a new request if they still need the harvest.</p>
+		</body>
+		</html>
+	`, item.ID, item.Product.CropName)
+
+	return s.sendEmail(to, subject, body)
+}
+
+func (s *EmailService) SendListingExpired(to string, product *models.Product) error {
+	subject := fmt.Sprintf("Listing Expired: %s", product.CropName)
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Listing Expired</h2>
+			<p>Your listing #%d for %s passed its best-before date of %s and is no longer shown to buyers.</p>
+			<p>If you have fresh stock, update the harvest and best-before dates and submit the listing for review again.</p>
+		</body>
+		</html>
+	`, product.ID, product.CropName, product.BestBefore.Format("02 Jan 2006"))
+
+	return s.sendEmail(to, subject, body)
+}
+
+func (s *EmailService) SendCreditPaymentOverdue(to string, order *models.Order) error {
+	subject := fmt.Sprintf("Payment Overdue for Order #%d", order.ID)
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Credit Payment Overdue</h2>
+			<p>Payment for order #%d (%s) was due on %s.</p>
+			<p><strong>Amount due:</strong> INR %.2f</p>
+			<br>
+			<p>New orders are on hold until overdue payments are settled.</p>
+		</body>
+		</html>
+	`, order.ID, order.Product.CropName, order.CreditDueAt.Format("02 Jan 2006"), amountDue(order))
+
+	return s.sendEmail(to, subject, body)
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+// defaultPlatformFeePercent applies when no fee rule matches an order.
+const defaultPlatformFeePercent = 5.0
+
+type FeeRuleRequest struct {
+	Name          string  `json:"name"`
+	CategoryID    uint    `json:"category_id"` // taxonomy category or crop
+	Category      string  `json:"category"`    // older clients: a category name or slug
+	OrderType     string  `json:"order_type"`
+	FarmerBadge   string  `json:"farmer_badge"`
+	NewFarmerDays int     `json:"new_farmer_days"`
+	RatePercent   float64 `json:"rate_percent"`
+	MinFee        float64 `json:"min_fee"`
+	MaxFee        float64 `json:"max_fee"`
+	StartsAt      string  `json:"starts_at"`
+	EndsAt        string  `json:"ends_at"`
+	Priority      int     `json:"priority"`
+	IsActive      bool    `json:"is_active"`
+}
+
+func isAllowedOrderType(value string) bool {
+	switch value {
+	case "standard", "bulk", "harvest_request":
+		return true
+	default:
+		return false
+	}
+}
+
+func isAllowedFarmerBadge(value string) bool {
+	switch value {
+	case "GOLD", "SILVER", "BRONZE":
+		return true
+	default:
+		return false
+	}
+}
+
+// platformFeeFor applies a rate and its caps to an order total. The fee never
+// exceeds the total itself.
+func platformFeeFor(total, percent, minFee, maxFee float64) float64 {
+	fee := total * percent / 100
+	if minFee > 0 && fee < minFee {
+		fee = minFee
+	}
+	if maxFee > 0 && fee > maxFee {
+		fee = maxFee
+	}
+	return roundMoney(math.Min(fee, total))
+}
+
+func feeRuleMatches(rule models.FeeRule, placed listingTaxonomy, orderType, badge string, farmerJoined, now time.Time) bool {
+	if !rule.IsActive {
+		return false
+	}
+	if rule.StartsAt != nil && now.Before(*rule.StartsAt) {
+		return false
+	}
+	if rule.EndsAt != nil && !now.Before(*rule.EndsAt) {
+		return false
+	}
+	if !placed.matches(rule.CategoryID, rule.Category) {
+		return false
+	}
+	if rule.OrderType != "" && rule.OrderType != orderType {
+		return false
+	}
+	if rule.FarmerBadge != "" && !strings.EqualFold(rule.FarmerBadge, badge) {
+		return false
+	}
+	if rule.NewFarmerDays > 0 && farmerJoined.Before(now.AddDate(0, 0, -rule.NewFarmerDays)) {
+		return false
+	}
+	return true
+}
+
+func feeRuleSpecificity(rule models.FeeRule) int {
+	count := 0
+	for _, set := range []bool{rule.CategoryID != nil || rule.Category != "", rule.OrderType != "", rule.FarmerBadge != "", rule.NewFarmerDays > 0} {
+		if set {
+			count++
+		}
+	}
+	return count
+}
+
+// selectFeeRule picks the matching rule with the highest priority, then the
+// most specific, then the one set on the taxonomy node nearest the listing,
+// then the most recently created.
+func selectFeeRule(rules []models.FeeRule, placed listingTaxonomy, orderType, badge string, farmerJoined, now time.Time) *models.FeeRule {
+	var best *models.FeeRule
+	for i := range rules {
+		rule := rules[i]
+		if !feeRuleMatches(rule, placed, orderType, badge, farmerJoined, now) {
+			continue
+		}
+		if best != nil {
+			if rule.Priority < best.Priority {
+				continue
+			}
+			if rule.Priority == best.Priority {
+				if feeRuleSpecificity(rule) < feeRuleSpecificity(*best) {
+					continue
+				}
+				if feeRuleSpecificity(rule) == feeRuleSpecificity(*best) {
+					distance, bestDistance := placed.distance(rule.CategoryID), placed.distance(best.CategoryID)
+					if distance > bestDistance || (distance == bestDistance && rule.ID < best.ID) {
+						continue
+					}
+				}
+			}
+		}
+		best = &rule
+	}
+	return best
+}
+
+// assessPlatformFee stores the fee terms on a new order so later rule changes
+// never rewrite its payout.
+func assessPlatformFee(tx *gorm.DB, order *models.Order, product models.Product) error {
+	var rules []models.FeeRule
+	if err := tx.Where("is_active = ?", true).Find(&rules).Error; err != nil {
+		return errors.New("failed to load fee rules")
+	}
+	placed, err := loadListingTaxonomy(tx, product)
+	if err != nil {
+		return err
+	}
+	var farmer models.User
+	badge := ""
+	joined := time.Now().UTC()
+	if err := tx.Preload("FarmerProfile").Where("id = ?", order.FarmerID).First(&farmer).Error; err == nil {
+		joined = farmer.CreatedAt
+		if farmer.FarmerProfile != nil {
+			badge = farmer.FarmerProfile.Badge
+		}
+	}
+
+	order.FeeRuleID = nil
+	order.PlatformFeePercent = defaultPlatformFeePercent
+	order.PlatformFeeMin = 0
+	order.PlatformFeeMax = 0
+	if rule := selectFeeRule(rules, placed, order.OrderType, badge, joined, time.Now().UTC()); rule != nil {
+		order.FeeRuleID = &rule.ID
+		order.PlatformFeePercent = rule.RatePercent
+		order.PlatformFeeMin = rule.MinFee
+		order.PlatformFeeMax = rule.MaxFee
+	}
+	order.PlatformFee = platformFeeFor(order.TotalPrice, order.PlatformFeePercent, order.PlatformFeeMin, order.PlatformFeeMax)
+	return nil
+}
+
+// refreshPlatformFee re-applies the order's stored fee terms after its total
+// or discount changes. A platform-funded discount does not lower the farmer's
+// sale, so the fee is charged on the price before it.
+func refreshPlatformFee(order *models.Order) {
+	base := order.TotalPrice
+	if order.DiscountFundedBy == "platform" {
+		base += order.DiscountAmount
+	}
+	order.PlatformFee = platformFeeFor(base, order.PlatformFeePercent, order.PlatformFeeMin, order.PlatformFeeMax)
+}
+
+func buildFeeRule(req FeeRuleRequest, rule *models.FeeRule) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return errors.New("fee rule name is required")
+	}
+	orderType := strings.ToLower(strings.TrimSpace(req.OrderType))
+	if orderType != "" && !isAllowedOrderType(orderType) {
+		return errors.New("invalid order type")
+	}
+	badge := strings.ToUpper(strings.TrimSpace(req.FarmerBadge))
+	if badge != "" && !isAllowedFarmerBadge(badge) {
+		return errors.New("invalid farmer badge")
+	}
+	if req.RatePercent < 0 || req.RatePercent > 100 {
+		return errors.New("rate percent must be between 0 and 100")
+	}
+	if req.MinFee < 0 || req.MaxFee < 0 {
+		return errors.New("fee caps cannot be negative")
+	}
+	if req.MaxFee > 0 && req.MaxFee < req.MinFee {
+		return errors.New("maximum fee cannot be below the minimum fee")
+	}
+	if req.NewFarmerDays < 0 {
+		return errors.New("new farmer days cannot be negative")
+	}
+	startsAt, err := parseOptionalRFC3339(req.StartsAt)
+	if err != nil {
+		return err
+	}
+	endsAt, err := parseOptionalRFC3339(req.EndsAt)
+	if err != nil {
+		return err
+	}
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		return errors.New("fee rule must end after it starts")
+	}
+
+	rule.Name = utils.SanitizeString(name)
+	rule.OrderType = orderType
+	rule.FarmerBadge = badge
+	rule.NewFarmerDays = req.NewFarmerDays
+	rule.RatePercent = req.RatePercent
+	rule.MinFee = req.MinFee
+	rule.MaxFee = req.MaxFee
+	rule.StartsAt = startsAt
+	rule.EndsAt = endsAt
+	rule.Priority = req.Priority
+	rule.IsActive = req.IsActive
+	return nil
+}
+
+func describeFeeRule(rule models.FeeRule) string {
+	note := rule.Name + ": " + formatQuantity(rule.RatePercent) + "%"
+	if rule.MinFee > 0 {
+		note += ", min " + formatQuantity(rule.MinFee)
+	}
+	if rule.MaxFee > 0 {
+		note += ", max " + formatQuantity(rule.MaxFee)
+	}
+	if !rule.IsActive {
+		note += " (inactive)"
+	}
+	return note
+}
+
+func (s *AdminService) GetFeeRules() ([]models.FeeRule, error) {
+	var rules []models.FeeRule
+	if err := s.orderRepo.GetDB().Order("priority DESC, id DESC").Find(&rules).Error; err != nil {
+		return nil, errors.New("failed to load fee rules")
+	}
+	return rules, nil
+}
+
+func (s *AdminService) CreateFeeRule(adminID uint, req FeeRuleRequest) (*models.FeeRule, error) {
+	return s.saveFeeRule(adminID, 0, req)
+}
+
+// UpdateFeeRule changes a rule for future orders only; orders already placed
+// keep the fee terms they were created with.
+func (s *AdminService) UpdateFeeRule(adminID, ruleID uint, req FeeRuleRequest) (*models.FeeRule, error) {
+	return s.saveFeeRule(adminID, ruleID, req)
+}
+
+func (s *AdminService) saveFeeRule(adminID, ruleID uint, req FeeRuleRequest) (*models.FeeRule, error) {
+	var rule models.FeeRule
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		action := "create_fee_rule"
+		if ruleID > 0 {
+			if err := tx.Where("id = ?", ruleID).First(&rule).Error; err != nil {
+				return errors.New("fee rule not found")
+			}
+			action = "update_fee_rule"
+		}
+		if err := buildFeeRule(req, &rule); err != nil {
+			return err
+		}
+		node, err := resolveRuleTaxonomy(tx, req.CategoryID, req.Category)
+		if err != nil {
+			return err
+		}
+		rule.CategoryID, rule.Category = nil, ""
+		if node != nil {
+			rule.CategoryID, rule.Category = &node.ID, node.Slug
+		}
+		rule.UpdatedBy = &adminID
+		if err := tx.Save(&rule).Error; err != nil {
+			return errors.New("failed to save fee rule")
+		}
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "fee_rule",
+			TargetID:   rule.ID,
+			Action:     action,
+			Note:       describeFeeRule(rule),
+			CreatedAt:  time.Now().UTC(),
+		}).Error; err != nil {
+			return errors.New("failed to audit fee rule")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
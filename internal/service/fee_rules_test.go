@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestFeeRulesAreAssessedOnceAtOrderCreation(t *testing.T) {
+	ctx := setupTestCtx(t)
+	adminSvc := newAdminServiceForTest(ctx)
+	category, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{Name: "Vegetables"})
+	if err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	if err := ctx.db.Model(&models.Product{}).Where("id = ?", ctx.productID).Updates(map[string]interface{}{"category": "vegetables", "category_id": category.ID}).Error; err != nil {
+		t.Fatalf("failed to set category: %v", err)
+	}
+	past := time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)
+	future := time.Now().UTC().Add(24 * time.Hour).Format(time.RFC3339)
+
+	if _, err := adminSvc.CreateFeeRule(99, FeeRuleRequest{Name: "Floor", RatePercent: 1, MinFee: 15, IsActive: true}); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	vegetables, err := adminSvc.CreateFeeRule(99, FeeRuleRequest{Name: "Vegetables", Category: "Vegetables", RatePercent: 4, IsActive: true})
+	if err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	promo, err := adminSvc.CreateFeeRule(99, FeeRuleRequest{Name: "New farmer promo", NewFarmerDays: 30, RatePercent: 0, StartsAt: past, EndsAt: future, Priority: 10, IsActive: true})
+	if err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	if _, err := adminSvc.CreateFeeRule(99, FeeRuleRequest{Name: "Bad caps", RatePercent: 2, MinFee: 20, MaxFee: 10, IsActive: true}); err == nil {
+		t.Fatalf("expected max fee below min fee to be rejected")
+	}
+
+	promoOrder := createOrderForTest(t, ctx)
+	if promoOrder.PlatformFee != 0 || promoOrder.FeeRuleID == nil || *promoOrder.FeeRuleID != promo.ID {
+		t.Fatalf("expected new farmer promo to waive the fee, got %+v", promoOrder)
+	}
+
+	if _, err := adminSvc.UpdateFeeRule(99, promo.ID, FeeRuleRequest{Name: "New farmer promo", NewFarmerDays: 30, Priority: 10, IsActive: false}); err != nil {
+		t.Fatalf("failed to end promo: %v", err)
+	}
+	order := createOrderForTest(t, ctx)
+	if order.PlatformFee != 8 || order.PlatformFeePercent != 4 {
+		t.Fatalf("expected the category rule to charge 8, got %+v", order)
+	}
+
+	// Raising the rate with a cap applies to new orders only.
+	if _, err := adminSvc.UpdateFeeRule(99, vegetables.ID, FeeRuleRequest{Name: "Vegetables", Category: "vegetables", RatePercent: 10, MaxFee: 12, IsActive: true}); err != nil {
+		t.Fatalf("failed to update rule: %v", err)
+	}
+	capped := createOrderForTest(t, ctx)
+	if capped.PlatformFee != 12 {
+		t.Fatalf("expected the capped fee of 12, got %v", capped.PlatformFee)
+	}
+
+	completeOrderForTest(t, ctx, order.ID)
+	summary, err := ctx.orderSvc.GetFarmerPayoutSummary(ctx.farmerID)
+	if err != nil || summary.PlatformFee != 8 || summary.NetPayout != 192 {
+		t.Fatalf("expected the stored fee to be settled, got %+v err=%v", summary, err)
+	}
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+type testCtx struct {
+	db          *gorm.DB
+	orderSvc    *OrderService
+	productSvc  *ProductService
+	cartSvc     *CartService
+	productRepo *repository.ProductRepository
+	buyerID     uint
+	farmerID    uint
+	productID   uint
+}
+
+func setupTestCtx(t *testing.T) *testCtx {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite db: %v", err)
+	}
+
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.FarmerProfile{},
+		&models.Product{},
+		&models.CartItem{},
+		&models.Order{},
+		&models.HarvestRequest{},
+		&models.Review{},
+		&models.OrderStatusLog{},
+		&models.OrderMessage{},
+		&models.OrderMessageAttachment{},
+		&models.OrderMessageRead{},
+		&models.DisputeEvidence{},
+		&models.ProductPriceHistory{},
+		&models.ProductPriceTier{},
+		&models.ProductImage{},
+		&models.Upload{},
+		&models.ProductVariant{},
+		&models.StockMovement{},
+		&models.TaxonomyNode{},
+		&models.TaxonomyName{},
+		&models.Shipment{},
+		&models.ShipmentEvent{},
+		&models.OrderAmendment{},
+		&models.AdminAuditLog{},
+		&models.CancellationPolicy{},
+		&models.PaymentEvent{},
+		&models.LedgerEntry{},
+		&models.FeeRule{},
+		&models.TaxRate{},
+		&models.TaxInvoice{},
+		&models.InvoiceSequence{},
+		&models.PayoutAccount{},
+		&models.SettlementBatch{},
+		&models.FarmerPayout{},
+		&models.CODCollection{},
+		&models.BuyerCreditAccount{},
+		&models.Promotion{},
+	); err != nil {
+		t.Fatalf("failed to migrate db: %v", err)
+	}
+
+	buyer := &models.User{
+		Name:     "Buyer One",
+		Email:    "buyer@example.com",
+		Phone:    "9000000001",
+		Password: "x",
+		UserType: "buyer",
+	}
+	farmer := &models.User{
+		Name:     "Farmer One",
+		Email:    "farmer@example.com",
+		Phone:    "9000000002",
+		Password: "x",
+		UserType: "farmer",
+	}
+	if err := db.Create(buyer).Error; err != nil {
+		t.Fatalf("failed to create buyer: %v", err)
+	}
+	if err := db.Create(farmer).Error; err != nil {
+		t.Fatalf("failed to create farmer: %v", err)
+	}
+
+	product := &models.Product{
+		FarmerID:               farmer.ID,
+		CropName:               "Tomato",
+		Quantity:               10,
+		Unit:                   "kg",
+		PricePerUnit:           100,
+		Description:            "fresh",
+		City:                   "Nagercoil",
+		State:                  "Tamil Nadu",
+		Status:                 "active",
+		IsBulkAvailable:        true,
+		MinimumBulkQuantity:    5,
+		SupportsHarvestRequest: true,
+		HarvestLeadDays:        2,
+	}
+	if err := db.Create(product).Error; err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+
+	userRepo := repository.NewUserRepository(db)
+	productRepo := repository.NewProductRepository(db)
+	orderRepo := repository.NewOrderRepository(db)
+	cartRepo := repository.NewCartRepository(db)
+
+	return &testCtx{
+		db:          db,
+		orderSvc:    NewOrderService(orderRepo, productRepo, userRepo),
+		productSvc:  NewProductService(productRepo),
+		cartSvc:     NewCartService(cartRepo, productRepo, orderRepo),
+		productRepo: productRepo,
+		buyerID:     buyer.ID,
+		farmerID:    farmer.ID,
+		productID:   product.ID,
+	}
+}
+
+func createOrderForTest(t *testing.T, ctx *testCtx) *models.Order {
+	t.Helper()
+	order, err := ctx.orderSvc.CreateOrder(ctx.buyerID, CreateOrderRequest{
+		ProductID:       ctx.productID,
+		Quantity:        2,
+		DeliveryAddress: "Some address",
+		PaymentMethod:   "cod",
+	})
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+	return order
+}
+
+func completeOrderForTest(t *testing.T, ctx *testCtx, orderID uint) {
+	t.Helper()
+	stepsByFarmer := []string{"confirmed", "packed", "out_for_delivery"}
+	for _, step := range stepsByFarmer {
+		if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(orderID, ctx.farmerID, UpdateOrderStatusRequest{
+			Status: step,
+		}); err != nil {
+			t.Fatalf("failed to move order to %s: %v", step, err)
+		}
+	}
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(orderID, ctx.buyerID, UpdateOrderStatusRequest{
+		Status: "completed",
+	}); err != nil {
+		t.Fatalf("failed to mark order completed by buyer: %v", err)
+	}
+}
+
+// newAdminServiceForTest builds the admin service over the fixture's
+// database.
+func newAdminServiceForTest(ctx *testCtx) *AdminService {
+	return NewAdminService(repository.NewUserRepository(ctx.db), ctx.productRepo, repository.NewOrderRepository(ctx.db))
+}
+
+// usePaymentsForTest wires a mock payment provider into the order service.
+func usePaymentsForTest(ctx *testCtx) (*PaymentService, *MockPaymentProvider) {
+	provider := NewMockPaymentProvider("pay-secret")
+	payments := NewPaymentService(repository.NewOrderRepository(ctx.db), provider)
+	ctx.orderSvc.SetPaymentService(payments)
+	return payments, provider
+}
+
+// payPrepaidOrderForTest places a 200 UPI order and captures it through
+// the mock provider.
+func payPrepaidOrderForTest(t *testing.T, ctx *testCtx, payments *PaymentService, provider *MockPaymentProvider) *models.Order {
+	t.Helper()
+	order, err := ctx.orderSvc.CreateOrder(ctx.buyerID, CreateOrderRequest{
+		ProductID:        ctx.productID,
+		Quantity:         2,
+		DeliveryAddress:  "Some address",
+		PaymentMethod:    "upi",
+		PaymentReference: "buyer@upi",
+	})
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+	intent, err := payments.CreateIntent(order.ID, ctx.buyerID)
+	if err != nil {
+		t.Fatalf("failed to create intent: %v", err)
+	}
+	body := []byte(`{"events":[{"event_id":"evt_` + intent.IntentID + `","intent_id":"` + intent.IntentID + `","status":"paid","amount":200}]}`)
+	if _, err := payments.IngestWebhook("mock", body, provider.Sign(body)); err != nil {
+		t.Fatalf("webhook rejected: %v", err)
+	}
+	return order
+}
+
+// deliverPrepaidOrderForTest pays for an order through the mock provider
+// and completes it.
+func deliverPrepaidOrderForTest(t *testing.T, ctx *testCtx, payments *PaymentService, provider *MockPaymentProvider) *models.Order {
+	t.Helper()
+	order := payPrepaidOrderForTest(t, ctx, payments, provider)
+	completeOrderForTest(t, ctx, order.ID)
+	return order
+}
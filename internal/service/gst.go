@@ -0,0 +1,401 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gstStateCodes maps the two-digit GST state code that prefixes a GSTIN to
+// the state or union territory.
+var gstStateCodes = map[string]string{
+	"01": "Jammu and Kashmir", "02": "Himachal Pradesh", "03": "Punjab", "04": "Chandigarh",
+	"05": "Uttarakhand", "06": "Haryana", "07": "Delhi", "08": "Rajasthan",
+	"09": "Uttar Pradesh", "10": "Bihar", "11": "Sikkim", "12": "Arunachal Pradesh",
+	"13": "Nagaland", "14": "Manipur", "15": "Mizoram", "16": "Tripura",
+	"17": "Meghalaya", "18": "Assam", "19": "West Bengal", "20": "Jharkhand",
+	"21": "Odisha", "22": "Chhattisgarh", "23": "Madhya Pradesh", "24": "Gujarat",
+	"26": "Dadra and Nagar Haveli and Daman and Diu", "27": "Maharashtra", "29": "Karnataka",
+	"30": "Goa", "31": "Lakshadweep", "32": "Kerala", "33": "Tamil Nadu",
+	"34": "Puducherry", "35": "Andaman and Nicobar Islands", "36": "Telangana",
+	"37": "Andhra Pradesh", "38": "Ladakh",
+}
+
+var gstinPattern = regexp.MustCompile(`^[0-9]{2}[A-Z]{5}[0-9]{4}[A-Z][1-9A-Z]Z[0-9A-Z]$`)
+
+var hsnPattern = regexp.MustCompile(`^[0-9]{4}([0-9]{2}){0,2}$`)
+
+// defaultTaxRates apply to a category until an admin configures it. Fresh,
+// unprocessed produce is exempt from GST; natural honey is taxed at 5%.
+var defaultTaxRates = map[string]models.TaxRate{
+	"vegetables": {HSNCode: "0709", Description: "Fresh vegetables", IsExempt: true},
+	"fruits":     {HSNCode: "0810", Description: "Fresh fruits", IsExempt: true},
+	"grains":     {HSNCode: "1008", Description: "Unbranded cereals and grains", IsExempt: true},
+	"dairy":      {HSNCode: "0401", Description: "Fresh milk", IsExempt: true},
+	"honey":      {HSNCode: "0409", Description: "Natural honey", RatePercent: 5},
+}
+
+type UpdateTaxRateRequest struct {
+	HSNCode     string  `json:"hsn_code"`
+	CategoryID  uint    `json:"category_id"` // taxonomy category or crop
+	Category    string  `json:"category"`    // older clients: a category name or slug
+	Description string  `json:"description"`
+	RatePercent float64 `json:"rate_percent"`
+	IsExempt    bool    `json:"is_exempt"`
+}
+
+type UpdateTaxProfileRequest struct {
+	GSTIN string `json:"gstin"`
+}
+
+// normalizeGSTIN validates a GSTIN's format and state code. An empty value is
+// allowed and means the user is not GST registered.
+func normalizeGSTIN(value string) (string, error) {
+	gstin := strings.ToUpper(strings.TrimSpace(value))
+	if gstin == "" {
+		return "", nil
+	}
+	if !gstinPattern.MatchString(gstin) {
+		return "", errors.New("invalid GSTIN format")
+	}
+	if _, ok := gstStateCodes[gstin[:2]]; !ok {
+		return "", errors.New("invalid GSTIN state code")
+	}
+	return gstin, nil
+}
+
+func normalizeHSNCode(value string) (string, error) {
+	code := strings.TrimSpace(value)
+	if code != "" && !hsnPattern.MatchString(code) {
+		return "", errors.New("HSN code must be 4, 6 or 8 digits")
+	}
+	return code, nil
+}
+
+// gstState is the state a user is registered in for GST: the one encoded in
+// their GSTIN, or their profile state when they are not registered.
+func gstState(user models.User) string {
+	if len(user.GSTIN) >= 2 {
+		if state, ok := gstStateCodes[user.GSTIN[:2]]; ok {
+			return state
+		}
+	}
+	return strings.TrimSpace(user.State)
+}
+
+// financialYear returns the Indian financial year (April to March) that t
+// falls in, e.g. "2026-27".
+func financialYear(t time.Time) string {
+	local := t.In(time.FixedZone("IST", 5*60*60+30*60))
+	start := local.Year()
+	if local.Month() < time.April {
+		start--
+	}
+	return fmt.Sprintf("%d-%02d", start, (start+1)%100)
+}
+
+// effectiveTaxRate resolves the product's HSN code first, then the rate set
+// on the nearest taxonomy node above the listing, then its top-level
+// category, then the built-in defaults. Anything unclassified is treated as
+// exempt fresh produce.
+func effectiveTaxRate(db *gorm.DB, product models.Product) models.TaxRate {
+	category := strings.ToLower(strings.TrimSpace(product.Category))
+	var rate models.TaxRate
+	if product.HSNCode != "" {
+		if err := db.Where("hsn_code = ?", product.HSNCode).Order("id ASC").First(&rate).Error; err == nil {
+			return rate
+		}
+	}
+	if placed, err := loadListingTaxonomy(db, product); err == nil && len(placed.nodeIDs) > 0 {
+		var rates []models.TaxRate
+		if err := db.Where("hsn_code = ? AND category_id IN ?", "", placed.nodeIDs).Find(&rates).Error; err == nil {
+			for _, id := range placed.nodeIDs {
+				for _, candidate := range rates {
+					if *candidate.CategoryID == id {
+						if product.HSNCode != "" {
+							candidate.HSNCode = product.HSNCode
+						}
+						return candidate
+					}
+				}
+			}
+		}
+	}
+	if category != "" {
+		if err := db.Where("hsn_code = ? AND category = ?", "", category).First(&rate).Error; err == nil {
+			if product.HSNCode != "" {
+				rate.HSNCode = product.HSNCode
+			}
+			return rate
+		}
+	}
+	rate, ok := defaultTaxRates[category]
+	if !ok {
+		rate = models.TaxRate{Description: "Fresh agricultural produce", IsExempt: true}
+	}
+	rate.Category = category
+	if product.HSNCode != "" {
+		rate.HSNCode = product.HSNCode
+	}
+	return rate
+}
+
+// nextInvoiceNumber reserves the seller's next number for the financial year.
+// Numbers stay within GST's 16 character limit, e.g. F12-2627-0001.
+func nextInvoiceNumber(tx *gorm.DB, sellerID uint, year string) (string, int, error) {
+	var seq models.InvoiceSequence
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("seller_id = ? AND financial_year = ?", sellerID, year).
+		First(&seq).Error
+	if err != nil {
+		seq = models.InvoiceSequence{SellerID: sellerID, FinancialYear: year}
+		if err := tx.Create(&seq).Error; err != nil {
+			return "", 0, errors.New("failed to start invoice sequence")
+		}
+	}
+	seq.LastNumber++
+	if err := tx.Model(&seq).Update("last_number", seq.LastNumber).Error; err != nil {
+		return "", 0, errors.New("failed to reserve invoice number")
+	}
+	number := fmt.Sprintf("F%d-%s%s-%04d", sellerID, year[2:4], year[5:7], seq.LastNumber)
+	return number, seq.LastNumber, nil
+}
+
+// issueTaxInvoice raises the GST invoice for a delivered order. Prices are
+// tax inclusive, so the taxable value is backed out of the order total. The
+// supply is intra-state (CGST + SGST) when the farmer and buyer are in the
+// same state and inter-state (IGST) otherwise.
+func issueTaxInvoice(tx *gorm.DB, order *models.Order) (*models.TaxInvoice, error) {
+	var existing models.TaxInvoice
+	if err := tx.Where("order_id = ?", order.ID).First(&existing).Error; err == nil {
+		return &existing, nil
+	}
+
+	var product models.Product
+	var seller, buyer models.User
+	if err := tx.Where("id = ?", order.ProductID).First(&product).Error; err != nil {
+		return nil, errors.New("product not found")
+	}
+	if err := tx.Where("id = ?", order.FarmerID).First(&seller).Error; err != nil {
+		return nil, errors.New("seller not found")
+	}
+	if err := tx.Where("id = ?", order.BuyerID).First(&buyer).Error; err != nil {
+		return nil, errors.New("buyer not found")
+	}
+
+	issuedAt := time.Now().UTC()
+	if order.CompletedAt != nil {
+		issuedAt = *order.CompletedAt
+	}
+	year := financialYear(issuedAt)
+	number, sequence, err := nextInvoiceNumber(tx, seller.ID, year)
+	if err != nil {
+		return nil, err
+	}
+
+	sellerState := gstState(seller)
+	if sellerState == "" {
+		sellerState = strings.TrimSpace(product.State)
+	}
+	placeOfSupply := gstState(buyer)
+	if placeOfSupply == "" {
+		placeOfSupply = sellerState
+	}
+	rate := effectiveTaxRate(tx, product)
+	invoice := &models.TaxInvoice{
+		OrderID:       order.ID,
+		SellerID:      seller.ID,
+		BuyerID:       buyer.ID,
+		InvoiceNumber: number,
+		FinancialYear: year,
+		Sequence:      sequence,
+		IssuedAt:      issuedAt,
+		SellerName:    seller.Name,
+		SellerGSTIN:   seller.GSTIN,
+		SellerState:   sellerState,
+		BuyerName:     buyer.Name,
+		BuyerGSTIN:    buyer.GSTIN,
+		PlaceOfSupply: placeOfSupply,
+		SupplyType:    "intra_state",
+		HSNCode:       rate.HSNCode,
+		Description:   orderItemName(product.CropName, order),
+		Quantity:      order.Quantity,
+		Unit:          product.Unit,
+		TaxExempt:     rate.IsExempt || rate.RatePercent <= 0,
+		Discount:      roundMoney(order.DiscountAmount),
+		TotalAmount:   roundMoney(order.TotalPrice),
+		CreatedAt:     time.Now().UTC(),
+	}
+	if !strings.EqualFold(sellerState, placeOfSupply) {
+		invoice.SupplyType = "inter_state"
+	}
+	invoice.TaxableValue = invoice.TotalAmount
+	if !invoice.TaxExempt {
+		invoice.TaxRatePercent = rate.RatePercent
+		invoice.TaxableValue = roundMoney(invoice.TotalAmount / (1 + rate.RatePercent/100))
+		invoice.TotalTax = roundMoney(invoice.TotalAmount - invoice.TaxableValue)
+		if invoice.SupplyType == "intra_state" {
+			invoice.CGSTAmount = roundMoney(invoice.TotalTax / 2)
+			invoice.SGSTAmount = roundMoney(invoice.TotalTax - invoice.CGSTAmount)
+		} else {
+			invoice.IGSTAmount = invoice.TotalTax
+		}
+	}
+	if err := tx.Create(invoice).Error; err != nil {
+		return nil, errors.New("failed to issue tax invoice")
+	}
+	return invoice, nil
+}
+
+// ensureTaxInvoice returns the order's tax invoice, issuing it for orders
+// delivered before invoicing existed. Orders not yet delivered have none.
+func ensureTaxInvoice(db *gorm.DB, orderID uint) (*models.TaxInvoice, error) {
+	var invoice *models.TaxInvoice
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error; err != nil {
+			return errors.New("order not found")
+		}
+		if order.Status != "completed" {
+			return nil
+		}
+		issued, err := issueTaxInvoice(tx, &order)
+		if err != nil {
+			return err
+		}
+		invoice = issued
+		return nil
+	})
+	return invoice, err
+}
+
+// GetTaxInvoice lets either party to a delivered order fetch its GST invoice.
+func (s *OrderService) GetTaxInvoice(orderID, userID uint) (*models.TaxInvoice, error) {
+	if _, err := s.getAccessibleOrder(orderID, userID); err != nil {
+		return nil, err
+	}
+	invoice, err := ensureTaxInvoice(s.orderRepo.GetDB(), orderID)
+	if err != nil {
+		return nil, err
+	}
+	if invoice == nil {
+		return nil, errors.New("tax invoice is issued once the order is delivered")
+	}
+	return invoice, nil
+}
+
+// UpdateTaxProfile records the user's GSTIN so it appears on their invoices.
+func (s *UserPortalService) UpdateTaxProfile(userID uint, req UpdateTaxProfileRequest) (*models.User, error) {
+	gstin, err := normalizeGSTIN(req.GSTIN)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	user.GSTIN = gstin
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, errors.New("failed to update tax profile")
+	}
+	return user, nil
+}
+
+// GetTaxRates lists configured rates followed by the built-in category
+// defaults that have not been overridden.
+func (s *AdminService) GetTaxRates() ([]models.TaxRate, error) {
+	var items []models.TaxRate
+	if err := s.orderRepo.GetDB().Order("category ASC, hsn_code ASC").Find(&items).Error; err != nil {
+		return nil, errors.New("failed to load tax rates")
+	}
+	configured := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item.HSNCode == "" {
+			configured[item.Category] = true
+		}
+	}
+	for _, category := range []string{"dairy", "fruits", "grains", "honey", "vegetables"} {
+		if configured[category] {
+			continue
+		}
+		rate := defaultTaxRates[category]
+		rate.Category = category
+		items = append(items, rate)
+	}
+	return items, nil
+}
+
+// UpdateTaxRate configures GST for an HSN code or, without one, a category.
+// Invoices already issued keep the rate they were issued with.
+func (s *AdminService) UpdateTaxRate(adminID uint, req UpdateTaxRateRequest) (*models.TaxRate, error) {
+	hsn, err := normalizeHSNCode(req.HSNCode)
+	if err != nil {
+		return nil, err
+	}
+	node, err := resolveRuleTaxonomy(s.orderRepo.GetDB(), req.CategoryID, req.Category)
+	if err != nil {
+		return nil, err
+	}
+	var categoryID *uint
+	category := ""
+	if node != nil {
+		categoryID, category = &node.ID, node.Slug
+	}
+	if hsn == "" && category == "" {
+		return nil, errors.New("HSN code or category is required")
+	}
+	if req.RatePercent < 0 || req.RatePercent > 28 {
+		return nil, errors.New("GST rate must be between 0 and 28 percent")
+	}
+	ratePercent := req.RatePercent
+	if req.IsExempt {
+		ratePercent = 0
+	}
+
+	rate := models.TaxRate{
+		HSNCode:     hsn,
+		CategoryID:  categoryID,
+		Category:    category,
+		Description: utils.SanitizeString(req.Description),
+		RatePercent: ratePercent,
+		IsExempt:    req.IsExempt,
+		UpdatedBy:   &adminID,
+	}
+	note := hsn + "/" + category + ": " + formatQuantity(ratePercent) + "%"
+	if req.IsExempt {
+		note = hsn + "/" + category + ": exempt"
+	}
+	err = s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "hsn_code"}, {Name: "category"}},
+			DoUpdates: clause.AssignmentColumns([]string{"category_id", "description", "rate_percent", "is_exempt", "updated_by", "updated_at"}),
+		}).Create(&rate).Error; err != nil {
+			return errors.New("failed to save tax rate")
+		}
+		if err := tx.Where("hsn_code = ? AND category = ?", hsn, category).First(&rate).Error; err != nil {
+			return errors.New("failed to save tax rate")
+		}
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "tax_rate",
+			TargetID:   rate.ID,
+			Action:     "update_tax_rate",
+			Note:       note,
+			CreatedAt:  time.Now().UTC(),
+		}).Error; err != nil {
+			return errors.New("failed to audit tax rate")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+)
+
+func TestGSTInvoicesSplitTaxByStateAndNumberPerSeller(t *testing.T) {
+	ctx := setupTestCtx(t)
+	userRepo := repository.NewUserRepository(ctx.db)
+	orderRepo := repository.NewOrderRepository(ctx.db)
+	portal := NewUserPortalService(userRepo, ctx.productRepo, orderRepo)
+	adminSvc := newAdminServiceForTest(ctx)
+	setCategory := func(category string) {
+		t.Helper()
+		if err := ctx.db.Model(&models.Product{}).Where("id = ?", ctx.productID).Update("category", category).Error; err != nil {
+			t.Fatalf("failed to set category: %v", err)
+		}
+	}
+
+	if _, err := portal.UpdateTaxProfile(ctx.farmerID, UpdateTaxProfileRequest{GSTIN: "33ABC"}); err == nil {
+		t.Fatalf("expected malformed GSTIN to be rejected")
+	}
+	if _, err := portal.UpdateTaxProfile(ctx.farmerID, UpdateTaxProfileRequest{GSTIN: "33abcde1234f1z5"}); err != nil {
+		t.Fatalf("failed to save farmer GSTIN: %v", err)
+	}
+	prefix := "F" + strconv.FormatUint(uint64(ctx.farmerID), 10) + "-" + strings.ReplaceAll(financialYear(time.Now()), "-", "")[2:] + "-"
+
+	// Fresh vegetables are exempt.
+	setCategory("vegetables")
+	exempt := createOrderForTest(t, ctx)
+	if _, err := ctx.orderSvc.GetTaxInvoice(exempt.ID, ctx.buyerID); err == nil {
+		t.Fatalf("expected no invoice before delivery")
+	}
+	completeOrderForTest(t, ctx, exempt.ID)
+	invoice, err := ctx.orderSvc.GetTaxInvoice(exempt.ID, ctx.buyerID)
+	if err != nil || !invoice.TaxExempt || invoice.TotalTax != 0 || invoice.TaxableValue != 200 || invoice.InvoiceNumber != prefix+"0001" || invoice.SellerGSTIN != "33ABCDE1234F1Z5" {
+		t.Fatalf("unexpected exempt invoice: %+v err=%v", invoice, err)
+	}
+
+	// Honey is taxed at 5%, split into CGST and SGST within Tamil Nadu.
+	setCategory("honey")
+	intra := createOrderForTest(t, ctx)
+	completeOrderForTest(t, ctx, intra.ID)
+	farmerInvoice, err := ctx.orderSvc.GetFarmerInvoice(intra.ID, ctx.farmerID)
+	if err != nil || farmerInvoice.TaxInvoice == nil {
+		t.Fatalf("expected farmer invoice to carry the tax invoice, got %+v err=%v", farmerInvoice, err)
+	}
+	invoice = farmerInvoice.TaxInvoice
+	if invoice.SupplyType != "intra_state" || invoice.TaxableValue != 190.48 || invoice.CGSTAmount != 4.76 || invoice.SGSTAmount != 4.76 || invoice.IGSTAmount != 0 || invoice.InvoiceNumber != prefix+"0002" {
+		t.Fatalf("unexpected intra-state invoice: %+v", invoice)
+	}
+
+	// A buyer registered in Karnataka is billed IGST.
+	if _, err := portal.UpdateTaxProfile(ctx.buyerID, UpdateTaxProfileRequest{GSTIN: "29ABCDE1234F1Z5"}); err != nil {
+		t.Fatalf("failed to save buyer GSTIN: %v", err)
+	}
+	inter := createOrderForTest(t, ctx)
+	completeOrderForTest(t, ctx, inter.ID)
+	invoice, err = ctx.orderSvc.GetTaxInvoice(inter.ID, ctx.farmerID)
+	if err != nil || invoice.SupplyType != "inter_state" || invoice.PlaceOfSupply != "Karnataka" || invoice.IGSTAmount != 9.52 || invoice.CGSTAmount != 0 || invoice.InvoiceNumber != prefix+"0003" {
+		t.Fatalf("unexpected inter-state invoice: %+v err=%v", invoice, err)
+	}
+
+	// Rate changes only affect invoices issued afterwards.
+	if _, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{Name: "Honey"}); err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	if _, err := adminSvc.UpdateTaxRate(99, UpdateTaxRateRequest{Category: "honey", RatePercent: 12}); err != nil {
+		t.Fatalf("failed to update tax rate: %v", err)
+	}
+	adminInvoice, err := adminSvc.GetTransactionInvoice(intra.ID)
+	if err != nil || adminInvoice.TaxInvoice == nil || adminInvoice.TaxInvoice.TaxRatePercent != 5 || adminInvoice.TaxInvoice.TotalTax != 9.52 {
+		t.Fatalf("expected issued invoice to keep its rate, got %+v err=%v", adminInvoice, err)
+	}
+}
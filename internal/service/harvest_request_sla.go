@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+)
+
+const (
+	// harvestResponseWindow is how long a farmer has to answer a new request.
+	harvestResponseWindow = 48 * time.Hour
+	// harvestReminderLead is how close to the deadline the farmer is reminded.
+	harvestReminderLead = 12 * time.Hour
+)
+
+// harvestResponseDeadline never lets the deadline run past the harvest date
+// the buyer asked for.
+func harvestResponseDeadline(createdAt, preferredHarvestDate time.Time) time.Time {
+	deadline := createdAt.Add(harvestResponseWindow)
+	if preferredHarvestDate.After(createdAt) && preferredHarvestDate.Before(deadline) {
+		return preferredHarvestDate
+	}
+	return deadline
+}
+
+func harvestResponseOverdue(item *models.HarvestRequest, now time.Time) bool {
+	return item.Status == "pending" && item.ResponseDeadline != nil && !now.Before(*item.ResponseDeadline)
+}
+
+func harvestCounterExpired(item *models.HarvestRequest, now time.Time) bool {
+	return item.Status == "countered" && item.CounterExpiresAt != nil && !now.Before(*item.CounterExpiresAt)
+}
+
+// HarvestRequestSweeper reminds farmers about requests nearing their response
+// deadline and expires the ones that pass it, along with counter-proposals the
+// buyer has not answered in time.
+type HarvestRequestSweeper struct {
+	orderRepo    *repository.OrderRepository
+	emailService *EmailService
+}
+
+func NewHarvestRequestSweeper(orderRepo *repository.OrderRepository, emailService *EmailService) *HarvestRequestSweeper {
+	return &HarvestRequestSweeper{orderRepo: orderRepo, emailService: emailService}
+}
+
+// Sweep processes every pending request due within the reminder lead and every
+// lapsed counter-proposal, and returns how many reminders were sent and how
+// many requests expired.
+func (s *HarvestRequestSweeper) Sweep(now time.Time) (int, int, error) {
+	items, err := s.orderRepo.ListPendingHarvestRequestsDueBy(now.Add(harvestReminderLead))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	reminded, expired := 0, 0
+	for i := range items {
+		item := &items[i]
+		if harvestResponseOverdue(item, now) {
+			ok, err := s.orderRepo.ExpireHarvestRequest(item.ID, now)
+			if err != nil {
+				return reminded, expired, err
+			}
+			if ok {
+				expired++
+				if s.emailService != nil && item.Buyer.Email != "" {
+					if err := s.emailService.SendHarvestRequestExpired(item.Buyer.Email, item); err != nil {
+						log.Printf("harvest request %d: expiry email failed: %v", item.ID, err)
+					}
+				}
+			}
+			continue
+		}
+		if item.ReminderSentAt != nil {
+			continue
+		}
+		if s.emailService != nil && item.Farmer.Email != "" {
+			if err := s.emailService.SendHarvestRequestReminder(item.Farmer.Email, item); err != nil {
+				log.Printf("harvest request %d: reminder email failed: %v", item.ID, err)
+				continue
+			}
+		}
+		if err := s.orderRepo.MarkHarvestReminderSent(item.ID, now); err != nil {
+			return reminded, expired, err
+		}
+		reminded++
+	}
+
+	countered, err := s.orderRepo.ListCounteredHarvestRequestsExpiredBy(now)
+	if err != nil {
+		return reminded, expired, err
+	}
+	for i := range countered {
+		item := &countered[i]
+		ok, err := s.orderRepo.ExpireHarvestCounter(item.ID, now)
+		if err != nil {
+			return reminded, expired, err
+		}
+		if !ok {
+			continue
+		}
+		expired++
+		if s.emailService != nil && item.Farmer.Email != "" {
+			if err := s.emailService.SendHarvestCounterExpired(item.Farmer.Email, item); err != nil {
+				log.Printf("harvest request %d: counter expiry email failed: %v", item.ID, err)
+			}
+		}
+	}
+	return reminded, expired, nil
+}
+
+// Run sweeps on every tick until ctx is cancelled.
+func (s *HarvestRequestSweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if reminded, expired, err := s.Sweep(time.Now().UTC()); err != nil {
+			log.Printf("Harvest request sweep failed: %v", err)
+		} else if reminded > 0 || expired > 0 {
+			log.Printf("Harvest request sweep: %d reminded, %d expired", reminded, expired)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/repository"
+)
+
+func TestHarvestRequestSweeperRemindsAndExpires(t *testing.T) {
+	ctx := setupTestCtx(t)
+
+	requestItem, err := ctx.orderSvc.CreateHarvestRequest(ctx.buyerID, CreateHarvestRequestRequest{
+		ProductID:            ctx.productID,
+		RequestedQuantity:    2,
+		PreferredHarvestDate: time.Now().UTC().AddDate(0, 0, 5).Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("failed to create harvest request: %v", err)
+	}
+	if requestItem.ResponseDeadline == nil {
+		t.Fatalf("expected response deadline to be set")
+	}
+	deadline := *requestItem.ResponseDeadline
+
+	sweeper := NewHarvestRequestSweeper(repository.NewOrderRepository(ctx.db), nil)
+	if reminded, expired, err := sweeper.Sweep(time.Now().UTC()); err != nil || reminded != 0 || expired != 0 {
+		t.Fatalf("expected fresh request to be left alone, got reminded=%d expired=%d err=%v", reminded, expired, err)
+	}
+	if reminded, expired, err := sweeper.Sweep(deadline.Add(-time.Hour)); err != nil || reminded != 1 || expired != 0 {
+		t.Fatalf("expected one reminder near the deadline, got reminded=%d expired=%d err=%v", reminded, expired, err)
+	}
+	if reminded, _, err := sweeper.Sweep(deadline.Add(-30 * time.Minute)); err != nil || reminded != 0 {
+		t.Fatalf("expected reminder to be sent only once, got %d err=%v", reminded, err)
+	}
+	if _, expired, err := sweeper.Sweep(deadline.Add(time.Minute)); err != nil || expired != 1 {
+		t.Fatalf("expected request to expire after the deadline, got %d err=%v", expired, err)
+	}
+
+	expiredItem, err := ctx.orderSvc.orderRepo.GetHarvestRequestByID(requestItem.ID)
+	if err != nil {
+		t.Fatalf("failed to reload harvest request: %v", err)
+	}
+	if expiredItem.Status != "expired" || expiredItem.ExpiredAt == nil || expiredItem.ReminderSentAt == nil {
+		t.Fatalf("unexpected harvest request after sweep: %+v", expiredItem)
+	}
+	if _, err := ctx.orderSvc.UpdateHarvestRequest(requestItem.ID, ctx.farmerID, UpdateHarvestRequestRequest{Status: "accepted"}); err == nil {
+		t.Fatalf("expected expired harvest request to reject farmer acceptance")
+	}
+
+	// A counter-proposal the buyer leaves unanswered expires too.
+	countered, err := ctx.orderSvc.CreateHarvestRequest(ctx.buyerID, CreateHarvestRequestRequest{
+		ProductID:            ctx.productID,
+		RequestedQuantity:    2,
+		PreferredHarvestDate: time.Now().UTC().AddDate(0, 0, 5).Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("failed to create harvest request: %v", err)
+	}
+	countered, err = ctx.orderSvc.CounterHarvestRequest(countered.ID, ctx.farmerID, CounterHarvestRequestRequest{PricePerUnit: 90})
+	if err != nil || countered.CounterExpiresAt == nil {
+		t.Fatalf("expected the counter to carry an expiry: %+v err=%v", countered, err)
+	}
+	expiresAt := *countered.CounterExpiresAt
+	if _, expired, err := sweeper.Sweep(expiresAt.Add(-time.Minute)); err != nil || expired != 0 {
+		t.Fatalf("expected an open counter to be left alone, got %d err=%v", expired, err)
+	}
+	if _, expired, err := sweeper.Sweep(expiresAt.Add(time.Minute)); err != nil || expired != 1 {
+		t.Fatalf("expected the unanswered counter to expire, got %d err=%v", expired, err)
+	}
+	if _, err := ctx.orderSvc.RespondToHarvestCounter(countered.ID, ctx.buyerID, RespondHarvestCounterRequest{Action: "accept"}); err == nil {
+		t.Fatalf("expected an expired counter to reject acceptance")
+	}
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StockAdjustmentRequest struct {
+	Kind      string  `json:"kind"`     // adjustment/spoilage
+	Quantity  float64 `json:"quantity"` // signed for adjustments; the amount written off for spoilage
+	VariantID uint    `json:"variant_id"`
+	Note      string  `json:"note"`
+}
+
+type StockHistory struct {
+	ProductID   uint                   `json:"product_id"`
+	Unit        string                 `json:"unit"`
+	Quantity    float64                `json:"quantity"`
+	LedgerTotal float64                `json:"ledger_total"`
+	Balanced    bool                   `json:"balanced"`
+	Movements   []models.StockMovement `json:"movements"`
+}
+
+type StockReconciliation struct {
+	ProductID   uint    `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	FarmerID    uint    `json:"farmer_id"`
+	Quantity    float64 `json:"quantity"`
+	LedgerTotal float64 `json:"ledger_total"`
+	Difference  float64 `json:"difference"`
+}
+
+func stockBalanced(quantity, ledgerTotal float64) bool {
+	return math.Abs(quantity-ledgerTotal) < 0.0005
+}
+
+// recordStockMovement appends a movement for a change the caller has just
+// made to product.Quantity. Changes that moved nothing are skipped unless the
+// caller sets movement.Quantity: sales record delivered stock, and changes to
+// inactive variants record stock outside the listing's balance.
+func recordStockMovement(tx *gorm.DB, product *models.Product, delta float64, movement models.StockMovement) error {
+	if delta == 0 && movement.Quantity == 0 {
+		return nil
+	}
+	movement.ProductID = product.ID
+	movement.Delta = delta
+	if movement.Quantity == 0 {
+		movement.Quantity = math.Abs(delta)
+	}
+	movement.BalanceAfter = product.Quantity
+	movement.CreatedAt = time.Now().UTC()
+	if err := tx.Create(&movement).Error; err != nil {
+		return errors.New("failed to record stock movement")
+	}
+	return nil
+}
+
+// createListing inserts a new listing together with its opening stock.
+func (s *ProductService) createListing(product *models.Product, farmerID uint, reference string) error {
+	return s.productRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		return recordStockMovement(tx, product, product.Quantity, models.StockMovement{
+			Kind:      "opening",
+			ActorID:   &farmerID,
+			Reference: reference,
+		})
+	})
+}
+
+// AdjustStock records a manual correction or a spoilage write-off. On
+// listings with variants the change applies to the named variant.
+func (s *ProductService) AdjustStock(productID, farmerID uint, req StockAdjustmentRequest) (*models.Product, error) {
+	kind := strings.ToLower(strings.TrimSpace(req.Kind))
+	change := req.Quantity
+	switch kind {
+	case "adjustment":
+		if change == 0 {
+			return nil, errors.New("adjustment quantity cannot be zero")
+		}
+	case "spoilage":
+		if change <= 0 {
+			return nil, errors.New("spoilage quantity must be greater than 0")
+		}
+		change = -change
+	default:
+		return nil, errors.New("kind must be adjustment or spoilage")
+	}
+	note := utils.SanitizeString(req.Note)
+	if note == "" {
+		return nil, errors.New("a note is required for stock adjustments")
+	}
+	if _, err := s.ownedProduct(productID, farmerID); err != nil {
+		return nil, err
+	}
+
+	err := s.productRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", productID).First(&product).Error; err != nil {
+			return errors.New("product not found")
+		}
+		before := product.Quantity
+		var variantID *uint
+		var hasVariants int64
+		if err := tx.Model(&models.ProductVariant{}).Where("product_id = ?", productID).Count(&hasVariants).Error; err != nil {
+			return errors.New("failed to load product variants")
+		}
+		if hasVariants > 0 {
+			if req.VariantID == 0 {
+				return errors.New("please choose a variant of this product")
+			}
+			var variant models.ProductVariant
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ? AND product_id = ?", req.VariantID, productID).
+				First(&variant).Error; err != nil {
+				return errors.New("product variant not found")
+			}
+			if variant.Quantity+change < 0 {
+				return errors.New("adjustment would take stock below zero")
+			}
+			variant.Quantity += change
+			if err := tx.Save(&variant).Error; err != nil {
+				return errors.New("failed to adjust inventory")
+			}
+			if err := syncVariantStock(tx, &product); err != nil {
+				return err
+			}
+			variantID = &variant.ID
+		} else {
+			if product.Quantity+change < 0 {
+				return errors.New("adjustment would take stock below zero")
+			}
+			product.Quantity += change
+			if product.Quantity <= 0 && product.Status == "active" {
+				product.Status = "sold"
+			} else if product.Quantity > 0 && product.Status == "sold" {
+				product.Status = "active"
+			}
+		}
+		if err := tx.Omit("PriceTiers", "Images", "Variants").Save(&product).Error; err != nil {
+			return errors.New("failed to adjust inventory")
+		}
+		// An adjustment to an inactive variant leaves the listing's stock as
+		// it was; it is still recorded, against the variant, with a zero delta.
+		return recordStockMovement(tx, &product, product.Quantity-before, models.StockMovement{
+			Kind:      kind,
+			Quantity:  math.Abs(change),
+			VariantID: variantID,
+			ActorID:   &farmerID,
+			Reference: "manual",
+			Note:      note,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.productRepo.GetByID(productID)
+}
+
+// GetStockHistory lists a listing's stock movements, newest first, and checks
+// that they add up to its current quantity.
+func (s *ProductService) GetStockHistory(productID, farmerID uint) (*StockHistory, error) {
+	product, err := s.ownedProduct(productID, farmerID)
+	if err != nil {
+		return nil, err
+	}
+	movements, err := s.productRepo.ListStockMovements(productID)
+	if err != nil {
+		return nil, errors.New("failed to load stock history")
+	}
+	total := 0.0
+	for _, movement := range movements {
+		total += movement.Delta
+	}
+	return &StockHistory{
+		ProductID:   product.ID,
+		Unit:        product.Unit,
+		Quantity:    product.Quantity,
+		LedgerTotal: total,
+		Balanced:    stockBalanced(product.Quantity, total),
+		Movements:   movements,
+	}, nil
+}
+
+// GetInventoryReconciliation lists the listings whose quantity no longer
+// matches the sum of their stock movements.
+func (s *AdminService) GetInventoryReconciliation() ([]StockReconciliation, error) {
+	products, err := s.productRepo.ListAllForAdmin()
+	if err != nil {
+		return nil, errors.New("failed to load products")
+	}
+	totals, err := s.productRepo.StockLedgerTotals()
+	if err != nil {
+		return nil, errors.New("failed to load stock ledger")
+	}
+	result := make([]StockReconciliation, 0)
+	for _, product := range products {
+		total := totals[product.ID]
+		if stockBalanced(product.Quantity, total) {
+			continue
+		}
+		result = append(result, StockReconciliation{
+			ProductID:   product.ID,
+			ProductName: product.CropName,
+			FarmerID:    product.FarmerID,
+			Quantity:    product.Quantity,
+			LedgerTotal: total,
+			Difference:  product.Quantity - total,
+		})
+	}
+	return result, nil
+}
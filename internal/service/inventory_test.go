@@ -0,0 +1,83 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestStockLedgerRecordsEveryMovementAndReconciles(t *testing.T) {
+	ctx := setupTestCtx(t)
+	adminSvc := newAdminServiceForTest(ctx)
+	// The fixture listing predates the ledger; open it as the migration does.
+	if err := ctx.db.Create(&models.StockMovement{ProductID: ctx.productID, Kind: "opening", Quantity: 10, Delta: 10, BalanceAfter: 10, Reference: "migration"}).Error; err != nil {
+		t.Fatalf("failed to open stock ledger: %v", err)
+	}
+
+	sold := createOrderForTest(t, ctx)
+	completeOrderForTest(t, ctx, sold.ID)
+	cancelled := createOrderForTest(t, ctx)
+	if _, err := ctx.orderSvc.CancelOrder(cancelled.ID, ctx.buyerID, CancelOrderRequest{}); err != nil {
+		t.Fatalf("failed to cancel order: %v", err)
+	}
+
+	if _, err := ctx.productSvc.AdjustStock(ctx.productID, ctx.farmerID, StockAdjustmentRequest{Kind: "spoilage", Quantity: 1}); err == nil {
+		t.Fatalf("expected an adjustment without a note to be rejected")
+	}
+	if _, err := ctx.productSvc.AdjustStock(ctx.productID, ctx.farmerID, StockAdjustmentRequest{Kind: "spoilage", Quantity: 50, Note: "rain"}); err == nil {
+		t.Fatalf("expected a write-off beyond the stock on hand to be rejected")
+	}
+	if _, err := ctx.productSvc.AdjustStock(ctx.productID, ctx.buyerID, StockAdjustmentRequest{Kind: "adjustment", Quantity: 5, Note: "found"}); err == nil {
+		t.Fatalf("expected only the owner to adjust stock")
+	}
+	product, err := ctx.productSvc.AdjustStock(ctx.productID, ctx.farmerID, StockAdjustmentRequest{Kind: "spoilage", Quantity: 1.5, Note: "crate crushed in transit"})
+	if err != nil || product.Quantity != 6.5 {
+		t.Fatalf("unexpected listing after spoilage: %+v err=%v", product, err)
+	}
+
+	category := models.TaxonomyNode{Kind: "category", Slug: "vegetables", Name: "Vegetables", Path: "/1/", IsActive: true}
+	if err := ctx.db.Create(&category).Error; err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	if _, err := ctx.productSvc.UpdateProduct(ctx.productID, ctx.farmerID, CreateProductRequest{
+		CropName: "Tomato", Quantity: 20, Unit: "kg", PricePerUnit: 100, CategoryID: category.ID,
+	}); err != nil {
+		t.Fatalf("failed to edit listing: %v", err)
+	}
+
+	history, err := ctx.productSvc.GetStockHistory(ctx.productID, ctx.farmerID)
+	if err != nil {
+		t.Fatalf("failed to load stock history: %v", err)
+	}
+	kinds := make([]string, 0, len(history.Movements))
+	for _, movement := range history.Movements {
+		kinds = append(kinds, movement.Kind)
+	}
+	want := []string{"adjustment", "spoilage", "release", "reserve", "sale", "reserve", "opening"}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected movements %v, got %v", want, kinds)
+	}
+	if !history.Balanced || history.Quantity != 20 || history.LedgerTotal != 20 {
+		t.Fatalf("expected the ledger to add up to the listing: %+v", history)
+	}
+	edit, spoilage, sale := history.Movements[0], history.Movements[1], history.Movements[4]
+	if edit.Delta != 13.5 || edit.ActorID == nil || *edit.ActorID != ctx.farmerID || edit.Reference != "listing_edit" {
+		t.Fatalf("unexpected listing edit movement: %+v", edit)
+	}
+	if spoilage.Delta != -1.5 || spoilage.BalanceAfter != 6.5 || spoilage.Note != "crate crushed in transit" {
+		t.Fatalf("unexpected spoilage movement: %+v", spoilage)
+	}
+	if sale.Delta != 0 || sale.Quantity != 2 || sale.OrderID == nil || *sale.OrderID != sold.ID {
+		t.Fatalf("unexpected sale movement: %+v", sale)
+	}
+
+	if mismatches, err := adminSvc.GetInventoryReconciliation(); err != nil || len(mismatches) != 0 {
+		t.Fatalf("expected inventory to reconcile: %+v err=%v", mismatches, err)
+	}
+	ctx.db.Model(&models.Product{}).Where("id = ?", ctx.productID).Update("quantity", 15)
+	mismatches, err := adminSvc.GetInventoryReconciliation()
+	if err != nil || len(mismatches) != 1 || mismatches[0].Difference != -5 {
+		t.Fatalf("expected an untracked change to show up: %+v err=%v", mismatches, err)
+	}
+}
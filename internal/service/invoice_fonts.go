@@ -0,0 +1,132 @@
+package service
+
+import (
+	"embed"
+	"unicode"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// invoiceFontFiles are embedded so invoices print the same on every server.
+// Licences for each font sit next to them in fonts/.
+//
+//go:embed fonts/*.ttf
+var invoiceFontFiles embed.FS
+
+// invoiceFont is a font family registered on every invoice. Styles without a
+// file of their own use the regular face.
+type invoiceFont struct {
+	family string
+	files  map[string]string // style -> file
+}
+
+const (
+	invoiceSansFont       = "sans"
+	invoiceDevanagariFont = "devanagari"
+)
+
+var invoiceFonts = []invoiceFont{
+	{family: invoiceSansFont, files: map[string]string{"": "DejaVuSansCondensed.ttf", "B": "DejaVuSansCondensed-Bold.ttf", "I": "DejaVuSansCondensed-Oblique.ttf"}},
+	{family: invoiceDevanagariFont, files: map[string]string{"": "NotoSansDevanagari-Regular.ttf"}},
+}
+
+func addInvoiceFonts(pdf *fpdf.Fpdf) {
+	for _, font := range invoiceFonts {
+		for _, style := range []string{"", "B", "I"} {
+			file, ok := font.files[style]
+			if !ok {
+				file = font.files[""]
+			}
+			data, err := invoiceFontFiles.ReadFile("fonts/" + file)
+			if err != nil {
+				pdf.SetError(err)
+				return
+			}
+			pdf.AddUTF8FontFromBytes(font.family, style, data)
+		}
+	}
+}
+
+// invoiceFontFor picks the family that has a glyph for r.
+func invoiceFontFor(r rune) string {
+	switch {
+	case r >= 0x0900 && r <= 0x097F, r >= 0xA8E0 && r <= 0xA8FF, r >= 0x1CD0 && r <= 0x1CFF:
+		return invoiceDevanagariFont
+	default:
+		return invoiceSansFont
+	}
+}
+
+type invoiceTextRun struct {
+	family string
+	text   string
+}
+
+// invoiceTextRuns splits text into runs set in one font each. Spaces and
+// combining or joining marks stay with the run before them so a name in one
+// script is drawn as a single run.
+func invoiceTextRuns(text string) []invoiceTextRun {
+	var runs []invoiceTextRun
+	for _, r := range text {
+		family := invoiceFontFor(r)
+		if n := len(runs); n > 0 && (runs[n-1].family == family || unicode.IsSpace(r) || unicode.In(r, unicode.Mn, unicode.Cf)) {
+			runs[n-1].text += string(r)
+			continue
+		}
+		runs = append(runs, invoiceTextRun{family: family, text: string(r)})
+	}
+	return runs
+}
+
+// invoiceCell works like CellFormat but switches fonts part way through the
+// text when it mixes scripts.
+func invoiceCell(pdf *fpdf.Fpdf, style string, size, width, height float64, text, border string, ln int, align string) {
+	runs := invoiceTextRuns(text)
+	if len(runs) <= 1 {
+		family := invoiceSansFont
+		if len(runs) == 1 {
+			family = runs[0].family
+		}
+		pdf.SetFont(family, style, size)
+		pdf.CellFormat(width, height, text, border, ln, align, false, 0, "")
+		return
+	}
+
+	x, y := pdf.GetXY()
+	left, _, right, _ := pdf.GetMargins()
+	if width == 0 {
+		pageWidth, _ := pdf.GetPageSize()
+		width = pageWidth - right - x
+	}
+	textWidth := 0.0
+	for _, run := range runs {
+		pdf.SetFont(run.family, style, size)
+		textWidth += pdf.GetStringWidth(run.text)
+	}
+	pdf.CellFormat(width, height, "", border, 0, "", false, 0, "")
+
+	margin := pdf.GetCellMargin()
+	start := x + margin
+	switch align {
+	case "R":
+		start = x + width - margin - textWidth
+	case "C":
+		start = x + (width-textWidth)/2
+	}
+	pdf.SetXY(start, y)
+	pdf.SetCellMargin(0)
+	for _, run := range runs {
+		pdf.SetFont(run.family, style, size)
+		pdf.CellFormat(pdf.GetStringWidth(run.text), height, run.text, "", 0, "L", false, 0, "")
+	}
+	pdf.SetCellMargin(margin)
+
+	switch ln {
+	case 1:
+		pdf.SetXY(left, y+height)
+	case 2:
+		pdf.SetXY(x, y+height)
+	default:
+		pdf.SetXY(x+width, y)
+	}
+}
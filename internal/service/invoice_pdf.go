@@ -0,0 +1,290 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+	"github.com/go-pdf/fpdf"
+)
+
+// invoiceDocument is everything printed on an invoice or receipt PDF.
+type invoiceDocument struct {
+	Title      string
+	Order      models.Order
+	FarmName   string
+	TaxInvoice *models.TaxInvoice
+	Ledger     orderLedgerTotals
+	ShowFees   bool
+	Payments   []models.PaymentEvent
+}
+
+func formatMoney(value float64) string {
+	return "INR " + fmt.Sprintf("%.2f", value)
+}
+
+func formatDocumentTime(value *time.Time) string {
+	if value == nil {
+		return "-"
+	}
+	return value.In(time.FixedZone("IST", 5*60*60+30*60)).Format("02 Jan 2006 15:04 IST")
+}
+
+// invoiceTitle follows GST practice: exempt supplies are billed on a bill of
+// supply rather than a tax invoice.
+func invoiceTitle(taxInvoice *models.TaxInvoice, receipt bool) string {
+	switch {
+	case taxInvoice == nil:
+		return "Order Summary"
+	case receipt:
+		return "Payment Receipt"
+	case taxInvoice.TaxExempt:
+		return "Bill of Supply"
+	default:
+		return "Tax Invoice"
+	}
+}
+
+func renderInvoicePDF(doc invoiceDocument) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	addInvoiceFonts(pdf)
+	pdf.SetTitle(doc.Title, true)
+	pdf.SetCreator("F2B Portal", true)
+	pdf.SetMargins(15, 15, 15)
+	pdf.AddPage()
+
+	order := doc.Order
+	tax := doc.TaxInvoice
+	text := func(style string, size float64, width float64, value string, ln int, align string) {
+		invoiceCell(pdf, style, size, width, 6, value, "", ln, align)
+	}
+	row := func(label, value string) {
+		text("B", 9, 45, label, 0, "L")
+		text("", 9, 0, value, 1, "L")
+	}
+	heading := func(value string) {
+		pdf.Ln(3)
+		text("B", 11, 0, value, 1, "L")
+		pdf.Line(15, pdf.GetY(), 195, pdf.GetY())
+		pdf.Ln(1)
+	}
+
+	text("B", 18, 120, doc.Title, 0, "L")
+	text("", 9, 0, "Order #"+fmt.Sprint(order.ID), 1, "R")
+	if tax != nil {
+		text("", 9, 0, "Invoice "+tax.InvoiceNumber+" | "+formatDocumentTime(&tax.IssuedAt), 1, "R")
+	} else {
+		text("I", 9, 0, "Tax invoice is issued once the order is delivered", 1, "R")
+	}
+
+	heading("Seller")
+	row("Farm", doc.FarmName)
+	row("Farmer", order.Farmer.Name)
+	if tax != nil {
+		row("GSTIN", valueOrDash(tax.SellerGSTIN))
+		row("State", valueOrDash(tax.SellerState))
+	}
+
+	heading("Buyer")
+	row("Name", order.Buyer.Name)
+	if tax != nil {
+		row("GSTIN", valueOrDash(tax.BuyerGSTIN))
+		row("Place of supply", valueOrDash(tax.PlaceOfSupply))
+	}
+	row("Deliver to", valueOrDash(order.DeliveryAddress))
+
+	heading("Items")
+	widths := []float64{60, 22, 26, 30, 42}
+	headers := []string{"Description", "HSN", "Quantity", "Unit price", "Amount"}
+	for i, header := range headers {
+		invoiceCell(pdf, "B", 9, widths[i], 7, header, "1", 0, "C")
+	}
+	pdf.Ln(-1)
+	listPrice := roundMoney(order.TotalPrice + order.DiscountAmount)
+	unitPrice := 0.0
+	if order.Quantity > 0 {
+		unitPrice = listPrice / order.Quantity
+	}
+	hsn := order.Product.HSNCode
+	if tax != nil {
+		hsn = tax.HSNCode
+	}
+	cells := []string{
+		orderItemName(order.Product.CropName, &order),
+		valueOrDash(hsn),
+		formatQuantity(order.Quantity) + " " + order.Product.Unit,
+		formatMoney(unitPrice),
+		formatMoney(listPrice),
+	}
+	for i, cell := range cells {
+		align := "L"
+		if i >= 2 {
+			align = "R"
+		}
+		invoiceCell(pdf, "", 9, widths[i], 7, cell, "1", 0, align)
+	}
+	pdf.Ln(-1)
+	if order.DiscountAmount > 0 {
+		label := "Discount"
+		if order.PromotionCode != "" {
+			label += " (" + order.PromotionCode + ")"
+		}
+		row(label, "- "+formatMoney(order.DiscountAmount))
+		row("Order total", formatMoney(order.TotalPrice))
+	}
+
+	if tax != nil {
+		heading("Tax")
+		if tax.TaxExempt {
+			row("GST", "Exempt supply of fresh agricultural produce")
+		} else {
+			row("Taxable value", formatMoney(tax.TaxableValue))
+			if tax.SupplyType == "inter_state" {
+				row("IGST "+formatQuantity(tax.TaxRatePercent)+"%", formatMoney(tax.IGSTAmount))
+			} else {
+				half := formatQuantity(tax.TaxRatePercent / 2)
+				row("CGST "+half+"%", formatMoney(tax.CGSTAmount))
+				row("SGST "+half+"%", formatMoney(tax.SGSTAmount))
+			}
+		}
+		row("Total (tax inclusive)", formatMoney(tax.TotalAmount))
+	}
+
+	heading("Payment")
+	row("Method", strings.ToUpper(valueOrDash(order.PaymentMethod)))
+	row("Status", strings.ReplaceAll(valueOrDash(order.PaymentStatus), "_", " "))
+	if order.WalletAmount > 0 {
+		row("Paid from wallet", formatMoney(order.WalletAmount))
+	}
+	if order.PaymentReference != "" {
+		row("Reference", order.PaymentReference)
+	}
+	if order.PaymentIntentID != "" {
+		row("Provider ref", order.PaymentProvider+" "+order.PaymentIntentID)
+	}
+	row("Paid at", formatDocumentTime(order.PaidAt))
+	for _, event := range doc.Payments {
+		if event.Type == "refund_request" {
+			row("Refund requested", formatMoney(event.Amount)+" on "+formatDocumentTime(&event.OccurredAt))
+		}
+	}
+	if doc.Ledger.Refunded > 0 {
+		row("Refunded", formatMoney(doc.Ledger.Refunded))
+	}
+	if doc.Ledger.CancellationFee > 0 {
+		row("Cancellation fee", formatMoney(doc.Ledger.CancellationFee))
+	}
+
+	if doc.ShowFees {
+		heading("Settlement")
+		row("Order value", formatMoney(order.TotalPrice))
+		if order.DiscountAmount > 0 {
+			row("Discount funded by", order.DiscountFundedBy)
+		}
+		if doc.Ledger.Subsidy != 0 {
+			row("Platform-funded discount", formatMoney(doc.Ledger.Subsidy))
+		}
+		row("Platform fee", formatMoney(doc.Ledger.PlatformFee)+" ("+formatQuantity(order.PlatformFeePercent)+"%)")
+		row("Net payout to farmer", formatMoney(doc.Ledger.FarmerShare))
+	}
+
+	pdf.Ln(6)
+	text("I", 8, 0, "This is a computer generated document and does not require a signature.", 1, "L")
+
+	if err := pdf.Error(); err != nil {
+		return nil, errors.New("failed to render invoice")
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, errors.New("failed to render invoice")
+	}
+	return buf.Bytes(), nil
+}
+
+func valueOrDash(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "-"
+	}
+	return value
+}
+
+func invoiceFilename(order *models.Order, taxInvoice *models.TaxInvoice, kind string) string {
+	if taxInvoice != nil {
+		return kind + "_" + taxInvoice.InvoiceNumber + ".pdf"
+	}
+	return kind + "_order_" + fmt.Sprint(order.ID) + ".pdf"
+}
+
+// buildInvoiceDocument gathers what an order's PDF prints. Both the order and
+// admin services render invoices, so it takes the repositories it reads.
+func buildInvoiceDocument(orderRepo *repository.OrderRepository, userRepo *repository.UserRepository, order *models.Order, showFees bool, kind string) (*invoiceDocument, error) {
+	taxInvoice, err := ensureTaxInvoice(orderRepo.GetDB(), order.ID)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := loadOrderLedgerTotals(orderRepo, []models.Order{*order})
+	if err != nil {
+		return nil, err
+	}
+	payments, err := orderRepo.GetPaymentEvents(order.ID)
+	if err != nil {
+		return nil, errors.New("failed to load payment events")
+	}
+	farmName := order.Farmer.Name
+	if profile, err := userRepo.GetFarmerProfile(order.FarmerID); err == nil && strings.TrimSpace(profile.FarmName) != "" {
+		farmName = profile.FarmName
+	}
+	return &invoiceDocument{
+		Title:      invoiceTitle(taxInvoice, kind == "receipt"),
+		Order:      *order,
+		FarmName:   farmName,
+		TaxInvoice: taxInvoice,
+		Ledger:     ledger[order.ID],
+		ShowFees:   showFees,
+		Payments:   payments,
+	}, nil
+}
+
+// RenderOrderInvoicePDF gives the farmer their invoice with fees and payout,
+// and the buyer a receipt with tax and payment details. It returns the PDF
+// and a download filename.
+func (s *OrderService) RenderOrderInvoicePDF(orderID, userID uint) ([]byte, string, error) {
+	order, err := s.getAccessibleOrder(orderID, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	isFarmer := order.FarmerID == userID
+	kind := "receipt"
+	if isFarmer {
+		kind = "invoice"
+	}
+	doc, err := buildInvoiceDocument(s.orderRepo, s.userRepo, order, isFarmer, kind)
+	if err != nil {
+		return nil, "", err
+	}
+	content, err := renderInvoicePDF(*doc)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, invoiceFilename(order, doc.TaxInvoice, kind), nil
+}
+
+func (s *AdminService) RenderTransactionInvoicePDF(orderID uint) ([]byte, string, error) {
+	order, err := s.orderRepo.GetByID(orderID)
+	if err != nil {
+		return nil, "", errors.New("transaction not found")
+	}
+	doc, err := buildInvoiceDocument(s.orderRepo, s.userRepo, order, true, "invoice")
+	if err != nil {
+		return nil, "", err
+	}
+	content, err := renderInvoicePDF(*doc)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, invoiceFilename(order, doc.TaxInvoice, "invoice"), nil
+}
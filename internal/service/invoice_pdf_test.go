@@ -0,0 +1,53 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestInvoicePDFsRenderForOrderParticipants(t *testing.T) {
+	ctx := setupTestCtx(t)
+	adminSvc := newAdminServiceForTest(ctx)
+
+	order := createOrderForTest(t, ctx)
+	content, filename, err := ctx.orderSvc.RenderOrderInvoicePDF(order.ID, ctx.buyerID)
+	if err != nil || !strings.HasPrefix(string(content), "%PDF") || filename != "receipt_order_"+strconv.FormatUint(uint64(order.ID), 10)+".pdf" {
+		t.Fatalf("unexpected pending receipt: %q err=%v", filename, err)
+	}
+
+	completeOrderForTest(t, ctx, order.ID)
+	invoice, err := ctx.orderSvc.GetTaxInvoice(order.ID, ctx.farmerID)
+	if err != nil {
+		t.Fatalf("failed to load tax invoice: %v", err)
+	}
+	content, filename, err = ctx.orderSvc.RenderOrderInvoicePDF(order.ID, ctx.farmerID)
+	if err != nil || !strings.HasPrefix(string(content), "%PDF") || filename != "invoice_"+invoice.InvoiceNumber+".pdf" {
+		t.Fatalf("unexpected farmer invoice: %q err=%v", filename, err)
+	}
+	if _, _, err := ctx.orderSvc.RenderOrderInvoicePDF(order.ID, 9999); err == nil {
+		t.Fatalf("expected unrelated user to be rejected")
+	}
+	content, _, err = adminSvc.RenderTransactionInvoicePDF(order.ID)
+	if err != nil || !strings.HasPrefix(string(content), "%PDF") {
+		t.Fatalf("unexpected admin invoice: err=%v", err)
+	}
+
+	// Names in Hindi are set in the embedded Devanagari font.
+	if err := ctx.db.Model(&models.User{}).Where("id = ?", ctx.buyerID).Update("name", "राम कुमार (Ram)").Error; err != nil {
+		t.Fatalf("failed to rename buyer: %v", err)
+	}
+	runs := invoiceTextRuns("राम कुमार (Ram)")
+	if len(runs) != 2 || runs[0].family != invoiceDevanagariFont || runs[0].text != "राम कुमार " || runs[1].family != invoiceSansFont {
+		t.Fatalf("unexpected font runs: %+v", runs)
+	}
+	content, _, err = ctx.orderSvc.RenderOrderInvoicePDF(order.ID, ctx.buyerID)
+	if err != nil || !strings.Contains(string(content), "/utf8devanagari") {
+		t.Fatalf("expected the receipt to carry the Devanagari font: err=%v", err)
+	}
+	if _, _, err := adminSvc.RenderTransactionInvoicePDF(9999); err == nil {
+		t.Fatalf("expected missing transaction to be rejected")
+	}
+}
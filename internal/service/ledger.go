@@ -0,0 +1,592 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Ledger accounts. cash is money held by the platform's payment provider or
+// bank, escrow is buyer money held against undelivered orders, and
+// farmer_payable / buyer_receivable / buyer_wallet are kept per user. The
+// wallet holds store credit the platform owes the buyer.
+const (
+	ledgerCash            = "cash"
+	ledgerEscrow          = "escrow"
+	ledgerPlatformFee     = "platform_fee"
+	ledgerFarmerPayable   = "farmer_payable"
+	ledgerBuyerReceivable = "buyer_receivable"
+	ledgerBuyerWallet     = "buyer_wallet"
+)
+
+// ledgerLine is one side of a posting. Positive amounts are debits, negative
+// amounts credits.
+type ledgerLine struct {
+	account string
+	userID  *uint
+	amount  float64
+}
+
+type LedgerAccountBalance struct {
+	Account string  `json:"account"`
+	Debit   float64 `json:"debit"`
+	Credit  float64 `json:"credit"`
+	Balance float64 `json:"balance"` // debit - credit
+}
+
+type FarmerLedgerStatement struct {
+	FarmerID uint                 `json:"farmer_id"`
+	Earned   float64              `json:"earned"`
+	PaidOut  float64              `json:"paid_out"`
+	Balance  float64              `json:"balance"` // owed to the farmer
+	Entries  []models.LedgerEntry `json:"entries"`
+	Currency string               `json:"currency"`
+}
+
+type FarmerPayoutRequest struct {
+	FarmerID  uint    `json:"farmer_id"`
+	Amount    float64 `json:"amount"`
+	Reference string  `json:"reference"`
+}
+
+// orderLedgerTotals is what the ledger says happened to one order's money.
+type orderLedgerTotals struct {
+	Paid            float64 // buyer money taken into escrow
+	Refunded        float64 // returned to the buyer after a dispute
+	CancellationFee float64 // charged to the buyer on cancellation
+	PlatformFee     float64 // net fee kept by the platform
+	FarmerShare     float64 // net earned by the farmer
+	Subsidy         float64 // platform-funded discounts paid to the farmer
+}
+
+func roundMoney(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// splitPlatformFee divides part of an order's value between the platform and
+// the farmer in the same proportion as the fee assessed on the whole order.
+func splitPlatformFee(order *models.Order, amount float64) (float64, float64) {
+	fee := 0.0
+	if order.TotalPrice > 0 {
+		fee = roundMoney(amount * order.PlatformFee / order.TotalPrice)
+	}
+	return fee, roundMoney(amount - fee)
+}
+
+// postLedgerJournal appends one balanced journal. Zero lines are dropped and a
+// journal with nothing left is not written.
+func postLedgerJournal(tx *gorm.DB, kind string, orderID *uint, actorID *uint, memo string, lines ...ledgerLine) error {
+	now := time.Now().UTC()
+	journalID := fmt.Sprintf("%s-%d", kind, now.UnixNano())
+	if orderID != nil {
+		journalID = fmt.Sprintf("%s-%d-%d", kind, *orderID, now.UnixNano())
+	}
+
+	entries := make([]models.LedgerEntry, 0, len(lines))
+	var total float64
+	for _, line := range lines {
+		amount := roundMoney(line.amount)
+		if amount == 0 {
+			continue
+		}
+		entry := models.LedgerEntry{
+			JournalID: journalID,
+			Kind:      kind,
+			OrderID:   orderID,
+			Account:   line.account,
+			UserID:    line.userID,
+			Memo:      memo,
+			CreatedBy: actorID,
+			CreatedAt: now,
+		}
+		if amount > 0 {
+			entry.Debit = amount
+		} else {
+			entry.Credit = -amount
+		}
+		total += amount
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if math.Abs(total) > 0.005 {
+		return errors.New("ledger journal does not balance")
+	}
+	if err := tx.Create(&entries).Error; err != nil {
+		return errors.New("failed to post ledger journal")
+	}
+	return nil
+}
+
+func hasLedgerJournal(tx *gorm.DB, orderID uint, kind string) (bool, error) {
+	var count int64
+	err := tx.Model(&models.LedgerEntry{}).Where("order_id = ? AND kind = ?", orderID, kind).Count(&count).Error
+	return count > 0, err
+}
+
+// ledgerBalance returns debits minus credits on an account for one order.
+func ledgerBalance(tx *gorm.DB, orderID uint, account string) (float64, error) {
+	var balance float64
+	err := tx.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(debit - credit), 0)").
+		Where("order_id = ? AND account = ?", orderID, account).
+		Scan(&balance).Error
+	return roundMoney(balance), err
+}
+
+// postBuyerPayment moves the part of the order not paid from the wallet into
+// escrow. Cash on delivery is collected by the farmer, who then holds it on
+// the platform's behalf; a credit order is billed to the buyer's receivable
+// until they settle it.
+func postBuyerPayment(tx *gorm.DB, order *models.Order, memo string) error {
+	due := amountDue(order)
+	if due <= 0 {
+		return nil
+	}
+	posted, err := hasLedgerJournal(tx, order.ID, "buyer_payment")
+	if err != nil {
+		return errors.New("failed to read ledger")
+	}
+	if posted {
+		return nil
+	}
+	debit := ledgerLine{account: ledgerCash, amount: due}
+	if isCreditMethod(order.PaymentMethod) {
+		debit = ledgerLine{account: ledgerBuyerReceivable, userID: &order.BuyerID, amount: due}
+	} else if !isPrepaidMethod(order.PaymentMethod) {
+		debit = ledgerLine{account: ledgerFarmerPayable, userID: &order.FarmerID, amount: due}
+	}
+	return postLedgerJournal(tx, "buyer_payment", &order.ID, &order.BuyerID, memo,
+		debit,
+		ledgerLine{account: ledgerEscrow, amount: -due},
+	)
+}
+
+// postOrderSettlement releases whatever is left in escrow on a delivered order
+// to the platform fee and the farmer.
+func postOrderSettlement(tx *gorm.DB, order *models.Order, actorID uint) error {
+	memo := "Cash on delivery collected by farmer"
+	if isCreditMethod(order.PaymentMethod) {
+		memo = "Billed to buyer credit account"
+	}
+	if err := postBuyerPayment(tx, order, memo); err != nil {
+		return err
+	}
+	posted, err := hasLedgerJournal(tx, order.ID, "settlement")
+	if err != nil {
+		return errors.New("failed to read ledger")
+	}
+	if posted {
+		return nil
+	}
+	escrow, err := ledgerBalance(tx, order.ID, ledgerEscrow)
+	if err != nil {
+		return errors.New("failed to read ledger")
+	}
+	held := -escrow
+	fee, share := splitPlatformFee(order, held)
+	if err := postLedgerJournal(tx, "settlement", &order.ID, &actorID, "Escrow released on delivery",
+		ledgerLine{account: ledgerEscrow, amount: held},
+		ledgerLine{account: ledgerPlatformFee, amount: -fee},
+		ledgerLine{account: ledgerFarmerPayable, userID: &order.FarmerID, amount: -share},
+	); err != nil {
+		return err
+	}
+	return postPromotionSubsidy(tx, order, actorID, held, "Platform-funded discount")
+}
+
+// postPromotionSubsidy pays the farmer the platform-funded discount on amount
+// of the order's total; a negative amount takes it back after a refund.
+func postPromotionSubsidy(tx *gorm.DB, order *models.Order, actorID uint, amount float64, memo string) error {
+	if order.DiscountFundedBy != "platform" || order.DiscountAmount <= 0 || order.TotalPrice <= 0 {
+		return nil
+	}
+	subsidy := roundMoney(order.DiscountAmount * amount / order.TotalPrice)
+	return postLedgerJournal(tx, "promotion", &order.ID, &actorID, memo,
+		ledgerLine{account: ledgerPlatformFee, amount: subsidy},
+		ledgerLine{account: ledgerFarmerPayable, userID: &order.FarmerID, amount: -subsidy},
+	)
+}
+
+// refundSplit is where money going back to the buyer ends up.
+type refundSplit struct {
+	wallet     float64 // credited to the buyer's wallet
+	provider   float64 // returned through the payment provider
+	receivable float64 // taken off a credit bill the buyer has not paid yet
+	waived     float64 // never collected; the buyer owes that much less
+}
+
+// collected is the part of the refund the buyer had actually paid or been
+// billed for, which the ledger moves out of escrow or the farmer's share.
+func (r refundSplit) collected() float64 {
+	return roundMoney(r.wallet + r.provider + r.receivable)
+}
+
+// refundDestinations splits money the buyer has paid between their wallet,
+// the payment provider and an unpaid credit bill. An open credit bill is
+// reduced first, then wallet-funded money returns to the wallet; the rest
+// goes back through the provider, or to the wallet when the order was not
+// paid through one.
+func refundDestinations(tx *gorm.DB, order *models.Order, amount float64) (refundSplit, error) {
+	var split refundSplit
+	if isCreditMethod(order.PaymentMethod) {
+		open, err := ledgerBalance(tx, order.ID, ledgerBuyerReceivable)
+		if err != nil {
+			return split, errors.New("failed to read ledger")
+		}
+		split.receivable = math.Min(amount, math.Max(0, open))
+		amount -= split.receivable
+	}
+	var returned float64
+	if err := tx.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(credit - debit), 0)").
+		Where("order_id = ? AND account = ? AND kind IN ?", order.ID, ledgerBuyerWallet, []string{"refund", "cancellation"}).
+		Scan(&returned).Error; err != nil {
+		return split, errors.New("failed to read ledger")
+	}
+	split.wallet = math.Min(amount, math.Max(0, order.WalletAmount-returned))
+	split.provider = amount - split.wallet
+	if order.PaymentIntentID == "" {
+		split.wallet, split.provider = amount, 0
+	}
+	split.wallet = roundMoney(split.wallet)
+	split.provider = roundMoney(split.provider)
+	split.receivable = roundMoney(split.receivable)
+	return split, nil
+}
+
+// refundableBalance is what the buyer has put into escrow for the order and
+// not yet had back through a refund.
+func refundableBalance(tx *gorm.DB, order *models.Order) (float64, error) {
+	var paid, refunded float64
+	if err := tx.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(credit - debit), 0)").
+		Where("order_id = ? AND account = ? AND kind IN ?", order.ID, ledgerEscrow, []string{"buyer_payment", "wallet_payment"}).
+		Scan(&paid).Error; err != nil {
+		return 0, errors.New("failed to read ledger")
+	}
+	if err := tx.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(credit - debit), 0)").
+		Where("order_id = ? AND kind = ? AND account IN ?", order.ID, "refund", []string{ledgerBuyerWallet, ledgerCash, ledgerBuyerReceivable}).
+		Scan(&refunded).Error; err != nil {
+		return 0, errors.New("failed to read ledger")
+	}
+	return math.Max(0, roundMoney(paid-refunded)), nil
+}
+
+// postOrderRefund returns amount to the buyer, capped at what they paid.
+// Money not yet collected, such as cash on delivery still to be handed over
+// or a credit order not yet billed, is waived from what the buyer owes
+// rather than credited anywhere. Before settlement the rest comes out of
+// escrow; afterwards the platform fee and the farmer's share are reversed in
+// proportion.
+func postOrderRefund(tx *gorm.DB, order *models.Order, actorID uint, amount float64, memo string) (refundSplit, error) {
+	var split refundSplit
+	if amount <= 0 {
+		return split, nil
+	}
+	billed, err := hasLedgerJournal(tx, order.ID, "buyer_payment")
+	if err != nil {
+		return split, errors.New("failed to read ledger")
+	}
+	waived := 0.0
+	if !billed {
+		waived = roundMoney(math.Min(amount, math.Max(0, amountDue(order))))
+		amount -= waived
+	}
+	refundable, err := refundableBalance(tx, order)
+	if err != nil {
+		return split, err
+	}
+	split, err = refundDestinations(tx, order, roundMoney(math.Min(amount, refundable)))
+	if err != nil {
+		return split, err
+	}
+	split.waived = waived
+
+	collected := split.collected()
+	settled, err := hasLedgerJournal(tx, order.ID, "settlement")
+	if err != nil {
+		return split, errors.New("failed to read ledger")
+	}
+	lines := []ledgerLine{
+		{account: ledgerBuyerWallet, userID: &order.BuyerID, amount: -split.wallet},
+		{account: ledgerCash, amount: -split.provider},
+		{account: ledgerBuyerReceivable, userID: &order.BuyerID, amount: -split.receivable},
+	}
+	if !settled {
+		lines = append(lines, ledgerLine{account: ledgerEscrow, amount: collected})
+	} else {
+		fee, share := splitPlatformFee(order, collected)
+		lines = append(lines,
+			ledgerLine{account: ledgerPlatformFee, amount: fee},
+			ledgerLine{account: ledgerFarmerPayable, userID: &order.FarmerID, amount: share},
+		)
+	}
+	if err := postLedgerJournal(tx, "refund", &order.ID, &actorID, memo, lines...); err != nil {
+		return split, err
+	}
+	if settled {
+		if err := postPromotionSubsidy(tx, order, actorID, -collected, "Discount reversed on refund"); err != nil {
+			return split, err
+		}
+	}
+	// A waived amount, or a credit bill taken down, is no longer owed.
+	if reduced := roundMoney(split.waived + split.receivable); reduced > 0 {
+		order.WaivedAmount = roundMoney(order.WaivedAmount + reduced)
+		if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("waived_amount", order.WaivedAmount).Error; err != nil {
+			return split, errors.New("failed to update order")
+		}
+	}
+	return split, nil
+}
+
+// postOrderCancellation empties escrow for a cancelled order: the buyer gets
+// back what they paid less any cancellation fee, and the fee is settled like a
+// sale. The fee only comes out of what the buyer paid; on an unpaid order it
+// is dropped rather than billed. It returns the part to be refunded through
+// the provider.
+func postOrderCancellation(tx *gorm.DB, order *models.Order, actorID uint) (float64, error) {
+	escrow, err := ledgerBalance(tx, order.ID, ledgerEscrow)
+	if err != nil {
+		return 0, errors.New("failed to read ledger")
+	}
+	held := -escrow
+	if order.CancellationFee > held {
+		order.CancellationFee = math.Max(0, held)
+		if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("cancellation_fee", order.CancellationFee).Error; err != nil {
+			return 0, errors.New("failed to update order")
+		}
+	}
+	fee, share := splitPlatformFee(order, order.CancellationFee)
+	returned := roundMoney(held - order.CancellationFee)
+	lines := []ledgerLine{
+		{account: ledgerEscrow, amount: held},
+		{account: ledgerPlatformFee, amount: -fee},
+		{account: ledgerFarmerPayable, userID: &order.FarmerID, amount: -share},
+	}
+	split, err := refundDestinations(tx, order, returned)
+	if err != nil {
+		return 0, err
+	}
+	lines = append(lines,
+		ledgerLine{account: ledgerBuyerWallet, userID: &order.BuyerID, amount: -split.wallet},
+		ledgerLine{account: ledgerCash, amount: -split.provider},
+		ledgerLine{account: ledgerBuyerReceivable, userID: &order.BuyerID, amount: -split.receivable},
+	)
+	if err := postLedgerJournal(tx, "cancellation", &order.ID, &actorID, "Order cancelled", lines...); err != nil {
+		return 0, err
+	}
+	return split.provider, nil
+}
+
+// BackfillOrderLedger posts journals for orders settled before the ledger
+// existed, using the amounts recorded on the order. It returns how many orders
+// were posted and is safe to run on every startup.
+func BackfillOrderLedger(repo *repository.OrderRepository) (int, error) {
+	orders, err := repo.ListOrdersWithoutLedger()
+	if err != nil {
+		return 0, errors.New("failed to load orders for ledger backfill")
+	}
+	posted := 0
+	for i := range orders {
+		order := orders[i]
+		err := repo.GetDB().Transaction(func(tx *gorm.DB) error {
+			if isPrepaidMethod(order.PaymentMethod) && order.PaymentStatus != "initiated" && order.PaymentStatus != "failed" {
+				if err := postBuyerPayment(tx, &order, "Backfilled payment"); err != nil {
+					return err
+				}
+			}
+			switch order.Status {
+			case "completed":
+				if err := postOrderSettlement(tx, &order, order.BuyerID); err != nil {
+					return err
+				}
+				_, err := postOrderRefund(tx, &order, order.FarmerID, order.RefundAmount, "Backfilled refund")
+				return err
+			case "cancelled":
+				actorID := order.BuyerID
+				if order.CancelledBy != nil {
+					actorID = *order.CancelledBy
+				}
+				_, err := postOrderCancellation(tx, &order, actorID)
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return posted, err
+		}
+		posted++
+	}
+	return posted, nil
+}
+
+// loadOrderLedgerTotals summarises the ledger per order for reports.
+func loadOrderLedgerTotals(repo *repository.OrderRepository, orders []models.Order) (map[uint]orderLedgerTotals, error) {
+	ids := make([]uint, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	totals := make(map[uint]orderLedgerTotals, len(ids))
+	if len(ids) == 0 {
+		return totals, nil
+	}
+	sums, err := repo.SumLedgerByOrder(ids)
+	if err != nil {
+		return nil, errors.New("failed to read ledger")
+	}
+	for _, sum := range sums {
+		item := totals[sum.OrderID]
+		net := sum.Credit - sum.Debit
+		switch {
+		case (sum.Kind == "buyer_payment" || sum.Kind == "wallet_payment") && sum.Account == ledgerEscrow:
+			item.Paid += net
+		case sum.Kind == "refund" && (sum.Account == ledgerCash || sum.Account == ledgerBuyerWallet || sum.Account == ledgerBuyerReceivable):
+			item.Refunded += net
+		case sum.Kind == "cancellation" && (sum.Account == ledgerPlatformFee || sum.Account == ledgerFarmerPayable):
+			item.CancellationFee += net
+		}
+		if sum.Kind == "promotion" && sum.Account == ledgerFarmerPayable {
+			item.Subsidy += net
+		}
+		switch {
+		case sum.Kind == "promotion" && sum.Account == ledgerPlatformFee:
+			// The platform's cost of the discount, not a fee refund.
+		case sum.Kind == "cod_adjustment" || sum.Kind == "wallet_credit":
+			// Settles missing cash or grants goodwill; not a fee or an earning.
+		case sum.Account == ledgerPlatformFee:
+			item.PlatformFee += net
+		case sum.Account == ledgerFarmerPayable && sum.Kind != "buyer_payment":
+			item.FarmerShare += net
+		}
+		totals[sum.OrderID] = item
+	}
+	for id, item := range totals {
+		item.Paid = roundMoney(item.Paid)
+		item.Refunded = roundMoney(item.Refunded)
+		item.CancellationFee = roundMoney(item.CancellationFee)
+		item.PlatformFee = roundMoney(item.PlatformFee)
+		item.FarmerShare = roundMoney(item.FarmerShare)
+		item.Subsidy = roundMoney(item.Subsidy)
+		totals[id] = item
+	}
+	return totals, nil
+}
+
+// GetFarmerLedger lists the farmer's payable postings with what they have
+// earned, what has been paid out and what is still owed.
+func (s *OrderService) GetFarmerLedger(farmerID uint) (*FarmerLedgerStatement, error) {
+	return buildFarmerLedgerStatement(s.orderRepo, farmerID)
+}
+
+func buildFarmerLedgerStatement(repo *repository.OrderRepository, farmerID uint) (*FarmerLedgerStatement, error) {
+	entries, err := repo.GetLedgerEntriesByUser(ledgerFarmerPayable, farmerID)
+	if err != nil {
+		return nil, errors.New("failed to read ledger")
+	}
+	statement := &FarmerLedgerStatement{FarmerID: farmerID, Entries: entries, Currency: "INR"}
+	for _, entry := range entries {
+		switch entry.Kind {
+		case "farmer_payout":
+			statement.PaidOut += entry.Debit - entry.Credit
+		case "buyer_payment", "cod_adjustment", "cod_remittance":
+			// Cash on delivery the farmer holds or has handed back.
+		default:
+			statement.Earned += entry.Credit - entry.Debit
+		}
+		statement.Balance += entry.Credit - entry.Debit
+	}
+	statement.Earned = roundMoney(statement.Earned)
+	statement.PaidOut = roundMoney(statement.PaidOut)
+	statement.Balance = roundMoney(statement.Balance)
+	return statement, nil
+}
+
+// GetLedgerBalances is the trial balance: debits and credits per account,
+// which always net to zero across the ledger.
+func (s *AdminService) GetLedgerBalances() ([]LedgerAccountBalance, error) {
+	sums, err := s.orderRepo.SumLedgerByAccount()
+	if err != nil {
+		return nil, errors.New("failed to read ledger")
+	}
+	items := make([]LedgerAccountBalance, 0, len(sums))
+	for _, sum := range sums {
+		items = append(items, LedgerAccountBalance{
+			Account: sum.Account,
+			Debit:   roundMoney(sum.Debit),
+			Credit:  roundMoney(sum.Credit),
+			Balance: roundMoney(sum.Debit - sum.Credit),
+		})
+	}
+	return items, nil
+}
+
+// RecordFarmerPayout posts money sent to a farmer outside a settlement batch
+// against what they are owed, less payouts already on their way.
+func (s *AdminService) RecordFarmerPayout(adminID uint, req FarmerPayoutRequest) (*FarmerLedgerStatement, error) {
+	amount := roundMoney(req.Amount)
+	if req.FarmerID == 0 {
+		return nil, errors.New("farmer is required")
+	}
+	if amount <= 0 {
+		return nil, errors.New("payout amount must be greater than 0")
+	}
+	farmer, err := s.userRepo.GetByID(req.FarmerID)
+	if err != nil || farmer.UserType != "farmer" {
+		return nil, errors.New("farmer not found")
+	}
+
+	reference := utils.SanitizeString(req.Reference)
+	err = s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := lockPayoutFarmer(tx, req.FarmerID); err != nil {
+			return err
+		}
+		var owed float64
+		if err := tx.Model(&models.LedgerEntry{}).
+			Select("COALESCE(SUM(credit - debit), 0)").
+			Where("account = ? AND user_id = ?", ledgerFarmerPayable, req.FarmerID).
+			Scan(&owed).Error; err != nil {
+			return errors.New("failed to read ledger")
+		}
+		inFlight, err := payoutsInFlight(tx, req.FarmerID)
+		if err != nil {
+			return err
+		}
+		if amount > roundMoney(owed-inFlight) {
+			return errors.New("payout exceeds the farmer's balance")
+		}
+		memo := "Payout to farmer"
+		if reference != "" {
+			memo += " (" + reference + ")"
+		}
+		if err := postLedgerJournal(tx, "farmer_payout", nil, &adminID, memo,
+			ledgerLine{account: ledgerFarmerPayable, userID: &req.FarmerID, amount: amount},
+			ledgerLine{account: ledgerCash, amount: -amount},
+		); err != nil {
+			return err
+		}
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "user",
+			TargetID:   req.FarmerID,
+			Action:     "farmer_payout",
+			Note:       memo + ": " + formatQuantity(amount),
+			CreatedAt:  time.Now().UTC(),
+		}).Error; err != nil {
+			return errors.New("failed to audit payout")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buildFarmerLedgerStatement(s.orderRepo, req.FarmerID)
+}
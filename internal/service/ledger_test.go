@@ -0,0 +1,80 @@
+package service
+
+import "testing"
+
+func TestLedgerReconcilesReportsAndPayouts(t *testing.T) {
+	ctx := setupTestCtx(t)
+	payments, provider := usePaymentsForTest(ctx)
+	adminSvc := newAdminServiceForTest(ctx)
+
+	// Prepaid and delivered: 10 fee, 190 to the farmer.
+	prepaid := payPrepaidOrderForTest(t, ctx, payments, provider)
+	completeOrderForTest(t, ctx, prepaid.ID)
+
+	// Cash on delivery with 60 refunded after settlement.
+	cod := createOrderForTest(t, ctx)
+	completeOrderForTest(t, ctx, cod.ID)
+	if _, err := ctx.orderSvc.OpenDispute(cod.ID, ctx.buyerID, OpenDisputeRequest{Category: "quality", Remedy: "partial_refund", ClaimAmount: 60, Note: "bruised"}); err != nil {
+		t.Fatalf("failed to open dispute: %v", err)
+	}
+	if _, err := ctx.orderSvc.ResolveDispute(cod.ID, ctx.farmerID, "agreed"); err != nil {
+		t.Fatalf("failed to resolve dispute: %v", err)
+	}
+
+	// Prepaid, cancelled once packed: 180 back to the buyer, 20 fee settled.
+	cancelled := payPrepaidOrderForTest(t, ctx, payments, provider)
+	for _, step := range []string{"confirmed", "packed"} {
+		if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(cancelled.ID, ctx.farmerID, UpdateOrderStatusRequest{Status: step}); err != nil {
+			t.Fatalf("failed to move order to %s: %v", step, err)
+		}
+	}
+	if _, err := ctx.orderSvc.CancelOrder(cancelled.ID, ctx.buyerID, CancelOrderRequest{}); err != nil {
+		t.Fatalf("failed to cancel order: %v", err)
+	}
+
+	balances, err := adminSvc.GetLedgerBalances()
+	if err != nil {
+		t.Fatalf("failed to load balances: %v", err)
+	}
+	var net float64
+	for _, item := range balances {
+		net += item.Balance
+		if item.Account == "escrow" && item.Balance != 0 {
+			t.Fatalf("expected escrow to be empty, got %v", item.Balance)
+		}
+		if item.Account == "platform_fee" && item.Balance != -18 {
+			t.Fatalf("expected 18 in platform fees, got %v", -item.Balance)
+		}
+	}
+	if roundMoney(net) != 0 {
+		t.Fatalf("expected trial balance to net to zero, got %v", net)
+	}
+
+	summary, err := ctx.orderSvc.GetFarmerPayoutSummary(ctx.farmerID)
+	if err != nil {
+		t.Fatalf("failed to load payout summary: %v", err)
+	}
+	if summary.TotalGross != 400 || summary.Refunds != 60 || summary.CancellationFees != 20 || summary.PlatformFee != 18 || summary.NetPayout != 342 {
+		t.Fatalf("unexpected payout summary: %+v", summary)
+	}
+	// The farmer already holds the 200 collected on delivery.
+	if summary.Balance != 142 {
+		t.Fatalf("expected balance of 142, got %+v", summary)
+	}
+	overview, err := adminSvc.GetOverview()
+	if err != nil || overview.PlatformFees != summary.PlatformFee || overview.TotalRevenue != 340 {
+		t.Fatalf("expected overview to reconcile with the ledger, got %+v err=%v", overview, err)
+	}
+	invoice, err := ctx.orderSvc.GetFarmerInvoice(cancelled.ID, ctx.farmerID)
+	if err != nil || invoice.CancellationFee != 20 || invoice.PlatformFee != 1 || invoice.NetPayout != 19 {
+		t.Fatalf("unexpected cancelled invoice: %+v err=%v", invoice, err)
+	}
+
+	if _, err := adminSvc.RecordFarmerPayout(99, FarmerPayoutRequest{FarmerID: ctx.farmerID, Amount: 150}); err == nil {
+		t.Fatalf("expected payout above the balance to be rejected")
+	}
+	statement, err := adminSvc.RecordFarmerPayout(99, FarmerPayoutRequest{FarmerID: ctx.farmerID, Amount: 100, Reference: "NEFT-1"})
+	if err != nil || statement.PaidOut != 100 || statement.Balance != 42 || statement.Earned != 342 {
+		t.Fatalf("unexpected statement after payout: %+v err=%v", statement, err)
+	}
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RequestOrderAmendmentRequest struct {
+	Quantity        float64 `json:"quantity"`
+	DeliveryAddress string  `json:"delivery_address"`
+	DeliveryDate    string  `json:"delivery_date"`
+	DeliverySlot    string  `json:"delivery_slot"`
+	Reason          string  `json:"reason"`
+}
+
+type RespondOrderAmendmentRequest struct {
+	Action string `json:"action"` // approve/decline
+	Note   string `json:"note"`
+}
+
+func isAmendableOrderStatus(status string) bool {
+	return status == "pending" || status == "confirmed"
+}
+
+func formatQuantity(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// checkAmendedQuantity holds an amended quantity to the minimums the order
+// was placed under: the listing's bulk minimum for bulk orders and the
+// variant's minimum order.
+func checkAmendedQuantity(tx *gorm.DB, order *models.Order, product *models.Product, quantity float64) error {
+	if order.OrderType == "bulk" && product.MinimumBulkQuantity > 0 && quantity < product.MinimumBulkQuantity {
+		return errors.New("bulk quantity is below the farmer minimum")
+	}
+	if order.VariantID == nil {
+		return nil
+	}
+	var variant models.ProductVariant
+	if err := tx.Where("id = ?", *order.VariantID).First(&variant).Error; err != nil {
+		return errors.New("product variant not found")
+	}
+	if quantity < variant.MinOrderQuantity {
+		return errors.New("quantity is below the variant minimum order")
+	}
+	return nil
+}
+
+func (s *OrderService) RequestOrderAmendment(orderID, buyerID uint, req RequestOrderAmendmentRequest) (*models.OrderAmendment, error) {
+	order, err := s.orderRepo.GetByID(orderID)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	if order.BuyerID != buyerID {
+		return nil, errors.New("unauthorized: you can only amend your own orders")
+	}
+	if !isAmendableOrderStatus(order.Status) {
+		return nil, errors.New("orders can only be amended before dispatch")
+	}
+	if pending, err := s.orderRepo.HasPendingAmendment(orderID); err != nil {
+		return nil, errors.New("failed to check existing amendments")
+	} else if pending {
+		return nil, errors.New("an amendment is already awaiting the farmer's response")
+	}
+
+	item := &models.OrderAmendment{
+		OrderID:     orderID,
+		RequestedBy: buyerID,
+		Status:      "pending",
+		Reason:      utils.SanitizeString(req.Reason),
+	}
+	changed := false
+	if req.Quantity < 0 {
+		return nil, errors.New("quantity must be greater than 0")
+	}
+	if req.Quantity > 0 && req.Quantity != order.Quantity {
+		available := order.Product.Quantity
+		if order.VariantID != nil {
+			var variant models.ProductVariant
+			if err := s.orderRepo.GetDB().Where("id = ?", *order.VariantID).First(&variant).Error; err != nil || !variant.IsActive {
+				return nil, errors.New("product variant is not available")
+			}
+			available = variant.Quantity
+		}
+		if req.Quantity-order.Quantity > available {
+			return nil, errors.New("insufficient quantity available")
+		}
+		if err := checkAmendedQuantity(s.orderRepo.GetDB(), order, &order.Product, req.Quantity); err != nil {
+			return nil, err
+		}
+		qty := req.Quantity
+		item.NewQuantity = &qty
+		changed = true
+	}
+	if address := utils.SanitizeString(req.DeliveryAddress); address != "" && address != order.DeliveryAddress {
+		item.NewDeliveryAddress = address
+		changed = true
+	}
+	if req.DeliverySlot != "" {
+		if !isAllowedDeliverySlot(req.DeliverySlot) {
+			return nil, errors.New("invalid delivery slot")
+		}
+		if req.DeliverySlot != order.DeliverySlot {
+			item.NewDeliverySlot = req.DeliverySlot
+			changed = true
+		}
+	}
+	if strings.TrimSpace(req.DeliveryDate) != "" {
+		parsed, parseErr := time.Parse(time.RFC3339, strings.TrimSpace(req.DeliveryDate))
+		if parseErr != nil {
+			return nil, errors.New("invalid delivery date format")
+		}
+		if parsed.Before(time.Now().UTC().Add(-5 * time.Minute)) {
+			return nil, errors.New("delivery date cannot be in the past")
+		}
+		item.NewDeliveryDate = &parsed
+		changed = true
+	}
+	if !changed {
+		return nil, errors.New("amendment does not change the order")
+	}
+
+	if err := s.orderRepo.CreateAmendment(item); err != nil {
+		return nil, errors.New("failed to request amendment")
+	}
+	statusLog := &models.OrderStatusLog{
+		OrderID:    orderID,
+		ActorID:    buyerID,
+		FromStatus: order.Status,
+		ToStatus:   order.Status,
+		Reason:     "amendment_requested",
+		Category:   "amendment",
+		Note:       item.Reason,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := s.orderRepo.CreateStatusLog(statusLog); err == nil {
+		s.publishStatusLog(statusLog)
+	}
+	return item, nil
+}
+
+func (s *OrderService) GetOrderAmendments(orderID, userID uint) ([]models.OrderAmendment, error) {
+	if _, err := s.getAccessibleOrder(orderID, userID); err != nil {
+		return nil, err
+	}
+	items, err := s.orderRepo.GetAmendmentsByOrder(orderID)
+	if err != nil {
+		return nil, errors.New("failed to load amendments")
+	}
+	return items, nil
+}
+
+// RespondToOrderAmendment lets the farmer approve or decline a pending
+// amendment. Approval applies every requested change, the matching stock and
+// price adjustment, and the status-log entry in a single transaction.
+func (s *OrderService) RespondToOrderAmendment(orderID, amendmentID, farmerID uint, req RespondOrderAmendmentRequest) (*models.OrderAmendment, error) {
+	action := strings.ToLower(strings.TrimSpace(req.Action))
+	if action != "approve" && action != "decline" {
+		return nil, errors.New("invalid amendment action")
+	}
+
+	var result models.OrderAmendment
+	var statusLog *models.OrderStatusLog
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", orderID).
+			First(&order).Error; err != nil {
+			return errors.New("order not found")
+		}
+		if order.FarmerID != farmerID {
+			return errors.New("unauthorized: you can only update your own orders")
+		}
+
+		var amendment models.OrderAmendment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND order_id = ?", amendmentID, orderID).
+			First(&amendment).Error; err != nil {
+			return errors.New("amendment not found")
+		}
+		if amendment.Status != "pending" {
+			return errors.New("amendment has already been answered")
+		}
+
+		now := time.Now().UTC()
+		amendment.RespondedBy = &farmerID
+		amendment.RespondedAt = &now
+		amendment.FarmerNote = utils.SanitizeString(req.Note)
+
+		if action == "decline" {
+			amendment.Status = "declined"
+			if err := tx.Save(&amendment).Error; err != nil {
+				return errors.New("failed to update amendment")
+			}
+			statusLog = &models.OrderStatusLog{
+				OrderID:    order.ID,
+				ActorID:    farmerID,
+				FromStatus: order.Status,
+				ToStatus:   order.Status,
+				Reason:     "amendment_declined",
+				Category:   "amendment",
+				Note:       amendment.FarmerNote,
+				CreatedAt:  now,
+			}
+			if err := tx.Create(statusLog).Error; err != nil {
+				return errors.New("failed to create status log")
+			}
+			result = amendment
+			return nil
+		}
+
+		if !isAmendableOrderStatus(order.Status) {
+			return errors.New("order has moved past the amendable stage")
+		}
+
+		diff := make([]string, 0, 5)
+		if amendment.NewQuantity != nil && *amendment.NewQuantity != order.Quantity {
+			var product models.Product
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ?", order.ProductID).
+				First(&product).Error; err != nil {
+				return errors.New("product not found")
+			}
+			// The listing's minimums may have changed since the request.
+			if err := checkAmendedQuantity(tx, &order, &product, *amendment.NewQuantity); err != nil {
+				return err
+			}
+			delta := *amendment.NewQuantity - order.Quantity
+			if delta > 0 && product.Quantity < delta {
+				return errors.New("insufficient quantity available")
+			}
+			counted, err := adjustOrderVariantStock(tx, &order, delta)
+			if err != nil {
+				return err
+			}
+			if !counted {
+				delta = 0
+			}
+			before := product.Quantity
+			product.Quantity -= delta
+			if product.Quantity <= 0 {
+				product.Quantity = 0
+				product.Status = "sold"
+			} else if product.Status == "sold" {
+				product.Status = "active"
+			}
+			if err := tx.Save(&product).Error; err != nil {
+				return errors.New("failed to adjust inventory")
+			}
+			kind := "reserve"
+			if delta < 0 {
+				kind = "release"
+			}
+			if err := recordStockMovement(tx, &product, product.Quantity-before, models.StockMovement{
+				Kind:      kind,
+				VariantID: order.VariantID,
+				ActorID:   &farmerID,
+				OrderID:   &order.ID,
+				Reference: "amendment",
+			}); err != nil {
+				return err
+			}
+
+			// Keep the unit price the buyer originally agreed to rather than
+			// re-pricing the whole order at today's listing price or price
+			// tiers: the farmer approves the change against the price on the
+			// order, and a harvest request's agreed price has no tiers at all.
+			unitPrice := 0.0
+			if order.Quantity > 0 {
+				unitPrice = order.TotalPrice / order.Quantity
+			}
+			oldTotal := order.TotalPrice
+			newTotal := *amendment.NewQuantity * unitPrice
+			amendment.PriceDelta = newTotal - oldTotal
+			diff = append(diff,
+				"quantity: "+formatQuantity(order.Quantity)+" -> "+formatQuantity(*amendment.NewQuantity),
+				fmt.Sprintf("total_price: %.2f -> %.2f", oldTotal, newTotal),
+			)
+			if order.DiscountAmount > 0 && order.Quantity > 0 {
+				order.DiscountAmount = roundMoney(order.DiscountAmount * *amendment.NewQuantity / order.Quantity)
+			}
+			order.Quantity = *amendment.NewQuantity
+			order.TotalPrice = newTotal
+
+			// A standard order raised to the bulk minimum becomes a bulk
+			// order, with the fee terms and cancellation policy that go with
+			// it. Bulk orders cannot drop below the minimum, and harvest
+			// requests keep their type.
+			if order.OrderType == "standard" {
+				if orderType := deriveCartOrderType(product, order.Quantity); orderType != order.OrderType {
+					diff = append(diff, "order_type: "+order.OrderType+" -> "+orderType)
+					order.OrderType = orderType
+					if err := assessPlatformFee(tx, &order, product); err != nil {
+						return err
+					}
+				}
+			}
+			refreshPlatformFee(&order)
+		}
+		if amendment.NewDeliveryAddress != "" && amendment.NewDeliveryAddress != order.DeliveryAddress {
+			diff = append(diff, fmt.Sprintf("delivery_address: %q -> %q", order.DeliveryAddress, amendment.NewDeliveryAddress))
+			order.DeliveryAddress = amendment.NewDeliveryAddress
+		}
+		if amendment.NewDeliverySlot != "" && amendment.NewDeliverySlot != order.DeliverySlot {
+			diff = append(diff, fmt.Sprintf("delivery_slot: %q -> %q", order.DeliverySlot, amendment.NewDeliverySlot))
+			order.DeliverySlot = amendment.NewDeliverySlot
+		}
+		if amendment.NewDeliveryDate != nil {
+			previous := ""
+			if order.DeliveryDate != nil {
+				previous = order.DeliveryDate.Format(time.RFC3339)
+			}
+			diff = append(diff, fmt.Sprintf("delivery_date: %q -> %q", previous, amendment.NewDeliveryDate.Format(time.RFC3339)))
+			deliveryDate := *amendment.NewDeliveryDate
+			order.DeliveryDate = &deliveryDate
+		}
+
+		if err := tx.Save(&order).Error; err != nil {
+			return errors.New("failed to apply amendment")
+		}
+		amendment.Status = "approved"
+		amendment.AppliedDiff = strings.Join(diff, "; ")
+		if err := tx.Save(&amendment).Error; err != nil {
+			return errors.New("failed to update amendment")
+		}
+		statusLog = &models.OrderStatusLog{
+			OrderID:    order.ID,
+			ActorID:    farmerID,
+			FromStatus: order.Status,
+			ToStatus:   order.Status,
+			Reason:     "amendment_applied",
+			Category:   "amendment",
+			Note:       amendment.AppliedDiff,
+			CreatedAt:  now,
+		}
+		if err := tx.Create(statusLog).Error; err != nil {
+			return errors.New("failed to create status log")
+		}
+		result = amendment
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.publishStatusLog(statusLog)
+	return &result, nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestOrderAmendmentApprovalAdjustsStockAndPrice(t *testing.T) {
+	ctx := setupTestCtx(t)
+	order := createOrderForTest(t, ctx)
+
+	if _, err := ctx.orderSvc.RequestOrderAmendment(order.ID, ctx.buyerID, RequestOrderAmendmentRequest{}); err == nil {
+		t.Fatalf("expected empty amendment to be rejected")
+	}
+	amendment, err := ctx.orderSvc.RequestOrderAmendment(order.ID, ctx.buyerID, RequestOrderAmendmentRequest{
+		Quantity:        3,
+		DeliveryAddress: "New address",
+		DeliverySlot:    "09:00-12:00",
+		Reason:          "Need one more kg",
+	})
+	if err != nil {
+		t.Fatalf("failed to request amendment: %v", err)
+	}
+	if _, err := ctx.orderSvc.RequestOrderAmendment(order.ID, ctx.buyerID, RequestOrderAmendmentRequest{Quantity: 4}); err == nil {
+		t.Fatalf("expected second pending amendment to be rejected")
+	}
+	if _, err := ctx.orderSvc.RespondToOrderAmendment(order.ID, amendment.ID, ctx.buyerID, RespondOrderAmendmentRequest{Action: "approve"}); err == nil {
+		t.Fatalf("expected buyer approval to fail")
+	}
+
+	approved, err := ctx.orderSvc.RespondToOrderAmendment(order.ID, amendment.ID, ctx.farmerID, RespondOrderAmendmentRequest{Action: "approve"})
+	if err != nil {
+		t.Fatalf("failed to approve amendment: %v", err)
+	}
+	if approved.Status != "approved" || approved.PriceDelta != 100 {
+		t.Fatalf("unexpected amendment result: status=%s delta=%v", approved.Status, approved.PriceDelta)
+	}
+
+	updated, err := ctx.orderSvc.GetOrderByID(order.ID)
+	if err != nil {
+		t.Fatalf("failed to reload order: %v", err)
+	}
+	if updated.Quantity != 3 || updated.TotalPrice != 300 || updated.DeliveryAddress != "New address" || updated.DeliverySlot != "09:00-12:00" {
+		t.Fatalf("amendment not applied: %+v", updated)
+	}
+	product, err := ctx.productRepo.GetByID(ctx.productID)
+	if err != nil {
+		t.Fatalf("failed to reload product: %v", err)
+	}
+	if product.Quantity != 7 {
+		t.Fatalf("expected stock 7 after amendment, got %v", product.Quantity)
+	}
+
+	foundLog := false
+	for _, log := range updated.StatusLogs {
+		if log.Reason == "amendment_applied" && log.Note != "" {
+			foundLog = true
+		}
+	}
+	if !foundLog {
+		t.Fatalf("expected amendment_applied status log with diff")
+	}
+
+	// Raising a standard order to the bulk minimum makes it a bulk order
+	// under the bulk fee terms, still at the price the buyer agreed to.
+	if err := ctx.db.Create(&models.FeeRule{Name: "Bulk", OrderType: "bulk", RatePercent: 2, IsActive: true}).Error; err != nil {
+		t.Fatalf("failed to create fee rule: %v", err)
+	}
+	small := createOrderForTest(t, ctx)
+	if small.OrderType != "standard" {
+		t.Fatalf("expected a standard order, got %s", small.OrderType)
+	}
+	amendment, err = ctx.orderSvc.RequestOrderAmendment(small.ID, ctx.buyerID, RequestOrderAmendmentRequest{Quantity: 5})
+	if err != nil {
+		t.Fatalf("failed to request amendment: %v", err)
+	}
+	approved, err = ctx.orderSvc.RespondToOrderAmendment(small.ID, amendment.ID, ctx.farmerID, RespondOrderAmendmentRequest{Action: "approve"})
+	if err != nil || !strings.Contains(approved.AppliedDiff, "order_type: standard -> bulk") {
+		t.Fatalf("unexpected amendment: %+v err=%v", approved, err)
+	}
+	updated, _ = ctx.orderSvc.GetOrderByID(small.ID)
+	if updated.OrderType != "bulk" || updated.TotalPrice != 500 || updated.PlatformFeePercent != 2 || updated.PlatformFee != 10 {
+		t.Fatalf("expected a bulk order at the agreed price: %+v", updated)
+	}
+}
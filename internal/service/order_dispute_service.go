@@ -0,0 +1,328 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// disputeResponseWindow is how long the farmer has to answer a dispute before
+// either party may escalate it to an admin.
+const disputeResponseWindow = 72 * time.Hour
+
+type OpenDisputeRequest struct {
+	Category    string  `json:"category"` // quality/quantity/damaged/late_delivery/not_delivered/other
+	Remedy      string  `json:"remedy"`   // refund/partial_refund/replacement/other
+	ClaimAmount float64 `json:"claim_amount"`
+	Note        string  `json:"note"`
+}
+
+type DisputeDecisionRequest struct {
+	Decision       string  `json:"decision"` // refund/partial_refund/no_action
+	RefundAmount   float64 `json:"refund_amount"`
+	GoodwillCredit float64 `json:"goodwill_credit"` // store credit on top of any refund
+	Note           string  `json:"note"`
+}
+
+func isAllowedDisputeCategory(value string) bool {
+	switch value {
+	case "quality", "quantity", "damaged", "late_delivery", "not_delivered", "other":
+		return true
+	default:
+		return false
+	}
+}
+
+func isAllowedDisputeRemedy(value string) bool {
+	switch value {
+	case "refund", "partial_refund", "replacement", "other":
+		return true
+	default:
+		return false
+	}
+}
+
+func isAllowedDisputeDecision(value string) bool {
+	switch value {
+	case "refund", "partial_refund", "no_action":
+		return true
+	default:
+		return false
+	}
+}
+
+// isDisputeLocked reports whether a dispute is in admin hands, after which
+// neither party can change it.
+func isDisputeLocked(status string) bool {
+	return status == "escalated" || status == "decided"
+}
+
+// applyDisputeSettlement records the refund a dispute outcome grants on the
+// order. Refunds reduce the farmer's payout.
+func applyDisputeSettlement(order *models.Order, decision string, amount float64) error {
+	switch decision {
+	case "refund":
+		order.RefundAmount = order.TotalPrice
+	case "partial_refund":
+		if amount <= 0 || amount >= order.TotalPrice {
+			return errors.New("partial refund must be greater than 0 and less than the order total")
+		}
+		order.RefundAmount = amount
+	case "no_action":
+		order.RefundAmount = 0
+	default:
+		return errors.New("invalid dispute decision")
+	}
+	return nil
+}
+
+// applyRefundPaymentStatus marks the payment refunded once a dispute refund
+// has returned money the buyer paid to their wallet. Orders paid through a
+// provider keep their payment status until the provider confirms the refund,
+// and a refund taken off what the buyer still owes leaves the payment as it
+// was.
+func applyRefundPaymentStatus(tx *gorm.DB, order *models.Order, split refundSplit) error {
+	if order.PaymentIntentID != "" || split.wallet <= 0 {
+		return nil
+	}
+	order.PaymentStatus = "partially_refunded"
+	if order.RefundAmount >= order.TotalPrice {
+		order.PaymentStatus = "refunded"
+	}
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("payment_status", order.PaymentStatus).Error; err != nil {
+		return errors.New("failed to update order payment")
+	}
+	return nil
+}
+
+// OpenDispute lets the buyer raise a dispute on a delivered (or overdue
+// delivery) order, stating what went wrong and what they want done about it.
+func (s *OrderService) OpenDispute(orderID, buyerID uint, req OpenDisputeRequest) (*models.Order, error) {
+	category := strings.ToLower(strings.TrimSpace(req.Category))
+	remedy := strings.ToLower(strings.TrimSpace(req.Remedy))
+	if !isAllowedDisputeCategory(category) {
+		return nil, errors.New("invalid dispute category")
+	}
+	if !isAllowedDisputeRemedy(remedy) {
+		return nil, errors.New("invalid dispute remedy")
+	}
+	if strings.TrimSpace(req.Note) == "" {
+		return nil, errors.New("dispute description is required")
+	}
+
+	var updatedOrderID uint
+	var statusLog *models.OrderStatusLog
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error; err != nil {
+			return errors.New("order not found")
+		}
+		if order.BuyerID != buyerID {
+			return errors.New("unauthorized: you can only dispute your own orders")
+		}
+		if order.Status != "completed" && order.Status != "out_for_delivery" {
+			return errors.New("dispute can only be opened once the order is out for delivery")
+		}
+		if order.DisputeStatus != "none" && order.DisputeStatus != "" {
+			return errors.New("dispute already exists for this order")
+		}
+
+		claim := 0.0
+		if remedy == "partial_refund" {
+			if req.ClaimAmount <= 0 || req.ClaimAmount >= order.TotalPrice {
+				return errors.New("claim amount must be greater than 0 and less than the order total")
+			}
+			claim = req.ClaimAmount
+		} else if remedy == "refund" {
+			claim = order.TotalPrice
+		}
+
+		now := time.Now().UTC()
+		dueAt := now.Add(disputeResponseWindow)
+		order.DisputeStatus = "open"
+		order.DisputeNote = utils.SanitizeString(req.Note)
+		order.DisputeCategory = category
+		order.DisputeRemedy = remedy
+		order.DisputeClaimAmount = claim
+		order.DisputeOpenedBy = &buyerID
+		order.DisputeOpenedAt = &now
+		order.DisputeResponseDueAt = &dueAt
+		order.AdminReviewStatus = "open"
+		if err := tx.Save(&order).Error; err != nil {
+			return errors.New("failed to open dispute")
+		}
+
+		statusLog = &models.OrderStatusLog{
+			OrderID:    order.ID,
+			ActorID:    buyerID,
+			FromStatus: order.Status,
+			ToStatus:   order.Status,
+			Reason:     "dispute_update",
+			Category:   "open",
+			Note:       "Buyer opened a " + category + " dispute requesting " + remedy + ": " + order.DisputeNote,
+			CreatedAt:  now,
+		}
+		if err := tx.Create(statusLog).Error; err != nil {
+			return errors.New("failed to log dispute update")
+		}
+
+		updatedOrderID = order.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.publishStatusLog(statusLog)
+	return s.orderRepo.GetByID(updatedOrderID)
+}
+
+// ResolveDispute is the farmer accepting the buyer's requested remedy.
+func (s *OrderService) ResolveDispute(orderID, farmerID uint, note string) (*models.Order, error) {
+	return s.updateDisputeStatus(orderID, farmerID, "resolved", note)
+}
+
+// RejectDispute is the farmer contesting the claim; the buyer may then
+// escalate to an admin straight away.
+func (s *OrderService) RejectDispute(orderID, farmerID uint, note string) (*models.Order, error) {
+	return s.updateDisputeStatus(orderID, farmerID, "rejected", note)
+}
+
+func (s *OrderService) updateDisputeStatus(orderID, farmerID uint, nextStatus, note string) (*models.Order, error) {
+	if strings.TrimSpace(note) == "" {
+		return nil, errors.New("resolution reason is required")
+	}
+
+	var updatedOrderID uint
+	var statusLog *models.OrderStatusLog
+	var refundDue float64
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error; err != nil {
+			return errors.New("order not found")
+		}
+		if order.FarmerID != farmerID {
+			return errors.New("unauthorized: you can only update your own orders")
+		}
+		if order.DisputeStatus != "open" {
+			return errors.New("only open disputes can be updated")
+		}
+
+		if nextStatus == "resolved" {
+			decision := "no_action"
+			if order.DisputeRemedy == "refund" || order.DisputeRemedy == "partial_refund" {
+				decision = order.DisputeRemedy
+			}
+			if err := applyDisputeSettlement(&order, decision, order.DisputeClaimAmount); err != nil {
+				return err
+			}
+			refundDue = order.RefundAmount
+			order.AdminReviewStatus = "closed"
+		}
+		order.DisputeStatus = nextStatus
+		order.DisputeNote = utils.SanitizeString(note)
+		if err := tx.Save(&order).Error; err != nil {
+			return errors.New("failed to update dispute")
+		}
+		split, err := postOrderRefund(tx, &order, farmerID, refundDue, "Refund agreed in dispute")
+		if err != nil {
+			return err
+		}
+		if err := applyRefundPaymentStatus(tx, &order, split); err != nil {
+			return err
+		}
+		refundDue = split.provider
+
+		statusLog = &models.OrderStatusLog{
+			OrderID:    order.ID,
+			ActorID:    farmerID,
+			FromStatus: order.Status,
+			ToStatus:   order.Status,
+			Reason:     "dispute_update",
+			Category:   nextStatus,
+			Note:       order.DisputeNote,
+			CreatedAt:  time.Now().UTC(),
+		}
+		if err := tx.Create(statusLog).Error; err != nil {
+			return errors.New("failed to log dispute update")
+		}
+
+		updatedOrderID = order.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.publishStatusLog(statusLog)
+	if err := s.payments.RequestRefund(updatedOrderID, refundDue); err != nil {
+		return nil, errors.New("dispute resolved but refund request failed: " + err.Error())
+	}
+	return s.orderRepo.GetByID(updatedOrderID)
+}
+
+// EscalateDispute hands a dispute to the admins for a binding decision. Either
+// party may escalate once the farmer's response window has lapsed; the buyer
+// may also escalate as soon as the farmer rejects the claim.
+func (s *OrderService) EscalateDispute(orderID, userID uint, note string) (*models.Order, error) {
+	var updatedOrderID uint
+	var statusLog *models.OrderStatusLog
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error; err != nil {
+			return errors.New("order not found")
+		}
+		if order.BuyerID != userID && order.FarmerID != userID {
+			return errors.New("unauthorized: you can only escalate your own disputes")
+		}
+
+		now := time.Now().UTC()
+		switch order.DisputeStatus {
+		case "open":
+			if order.DisputeResponseDueAt != nil && now.Before(*order.DisputeResponseDueAt) {
+				return errors.New("dispute can be escalated once the farmer's response window has passed")
+			}
+		case "rejected":
+			if order.BuyerID != userID {
+				return errors.New("only the buyer can escalate a rejected dispute")
+			}
+		case "escalated":
+			return errors.New("dispute is already escalated")
+		default:
+			return errors.New("only open or rejected disputes can be escalated")
+		}
+
+		order.DisputeStatus = "escalated"
+		order.DisputeEscalatedBy = &userID
+		order.DisputeEscalatedAt = &now
+		order.AdminReviewStatus = "open"
+		if err := tx.Save(&order).Error; err != nil {
+			return errors.New("failed to escalate dispute")
+		}
+
+		statusLog = &models.OrderStatusLog{
+			OrderID:    order.ID,
+			ActorID:    userID,
+			FromStatus: order.Status,
+			ToStatus:   order.Status,
+			Reason:     "dispute_update",
+			Category:   "escalated",
+			Note:       utils.SanitizeString(note),
+			CreatedAt:  now,
+		}
+		if err := tx.Create(statusLog).Error; err != nil {
+			return errors.New("failed to log dispute update")
+		}
+
+		updatedOrderID = order.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.publishStatusLog(statusLog)
+	return s.orderRepo.GetByID(updatedOrderID)
+}
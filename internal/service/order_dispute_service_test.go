@@ -0,0 +1,180 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+)
+
+func TestDisputeLifecycleOpenResolveReject(t *testing.T) {
+	ctx := setupTestCtx(t)
+	order := createOrderForTest(t, ctx)
+	completeOrderForTest(t, ctx, order.ID)
+
+	if _, err := ctx.orderSvc.OpenDispute(order.ID, ctx.farmerID, OpenDisputeRequest{Category: "quality", Remedy: "replacement", Note: "quality mismatch"}); err == nil {
+		t.Fatalf("expected farmer to be unable to open a dispute")
+	}
+	opened, err := ctx.orderSvc.OpenDispute(order.ID, ctx.buyerID, OpenDisputeRequest{Category: "quality", Remedy: "replacement", Note: "quality mismatch"})
+	if err != nil {
+		t.Fatalf("failed to open dispute: %v", err)
+	}
+	if opened.DisputeStatus != "open" {
+		t.Fatalf("expected dispute status open, got %s", opened.DisputeStatus)
+	}
+	for _, actor := range []uint{ctx.farmerID, ctx.buyerID} {
+		if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, actor, UpdateOrderStatusRequest{Status: "completed", DisputeStatus: "resolved"}); err == nil {
+			t.Fatalf("expected the status endpoint to leave disputes alone")
+		}
+	}
+	if unchanged, _ := repository.NewOrderRepository(ctx.db).GetByID(order.ID); unchanged.DisputeStatus != "open" {
+		t.Fatalf("expected dispute to stay open, got %s", unchanged.DisputeStatus)
+	}
+
+	resolved, err := ctx.orderSvc.ResolveDispute(order.ID, ctx.farmerID, "resolved with buyer")
+	if err != nil {
+		t.Fatalf("failed to resolve dispute: %v", err)
+	}
+	if resolved.DisputeStatus != "resolved" {
+		t.Fatalf("expected dispute status resolved, got %s", resolved.DisputeStatus)
+	}
+
+	if _, err := ctx.orderSvc.RejectDispute(order.ID, ctx.farmerID, "cannot reject resolved dispute"); err == nil {
+		t.Fatalf("expected reject to fail for non-open dispute")
+	}
+}
+
+func TestEscalatedDisputeAdminDecisionIsBinding(t *testing.T) {
+	ctx := setupTestCtx(t)
+	if err := ctx.db.Create(&models.FarmerProfile{UserID: ctx.farmerID, RatingAverage: 5}).Error; err != nil {
+		t.Fatalf("failed to create farmer profile: %v", err)
+	}
+	adminSvc := newAdminServiceForTest(ctx)
+	order := createOrderForTest(t, ctx)
+	completeOrderForTest(t, ctx, order.ID)
+
+	if _, err := ctx.orderSvc.OpenDispute(order.ID, ctx.buyerID, OpenDisputeRequest{Category: "damaged", Remedy: "partial_refund", ClaimAmount: order.TotalPrice * 2, Note: "half rotten"}); err == nil {
+		t.Fatalf("expected claim above the order total to be rejected")
+	}
+	if _, err := ctx.orderSvc.OpenDispute(order.ID, ctx.buyerID, OpenDisputeRequest{Category: "damaged", Remedy: "partial_refund", ClaimAmount: 80, Note: "half rotten"}); err != nil {
+		t.Fatalf("failed to open dispute: %v", err)
+	}
+	if _, err := ctx.orderSvc.EscalateDispute(order.ID, ctx.buyerID, "no answer"); err == nil {
+		t.Fatalf("expected escalation to wait for the farmer's response window")
+	}
+	if _, err := ctx.orderSvc.RejectDispute(order.ID, ctx.farmerID, "produce was fine"); err != nil {
+		t.Fatalf("failed to reject dispute: %v", err)
+	}
+	if _, err := ctx.orderSvc.EscalateDispute(order.ID, ctx.farmerID, ""); err == nil {
+		t.Fatalf("expected only the buyer to escalate a rejected dispute")
+	}
+	escalated, err := ctx.orderSvc.EscalateDispute(order.ID, ctx.buyerID, "farmer refused")
+	if err != nil || escalated.DisputeStatus != "escalated" {
+		t.Fatalf("failed to escalate dispute: %v", err)
+	}
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{Status: "completed", DisputeStatus: "resolved", DisputeNote: "closing"}); err == nil || !strings.Contains(err.Error(), "admin") {
+		t.Fatalf("expected escalated dispute to be locked for the parties")
+	}
+
+	decided, err := adminSvc.DecideDispute(order.ID, 99, DisputeDecisionRequest{Decision: "partial_refund", RefundAmount: 60, Note: "photos show damage"})
+	if err != nil {
+		t.Fatalf("failed to decide dispute: %v", err)
+	}
+	if decided.DisputeStatus != "decided" || decided.RefundAmount != 60 || decided.PaymentStatus != "partially_refunded" {
+		t.Fatalf("unexpected decided order: status=%s refund=%v payment=%s", decided.DisputeStatus, decided.RefundAmount, decided.PaymentStatus)
+	}
+	if _, err := adminSvc.DecideDispute(order.ID, 99, DisputeDecisionRequest{Decision: "no_action", Note: "changed mind"}); err == nil {
+		t.Fatalf("expected decision to be final")
+	}
+	if _, err := adminSvc.ResolveReport(order.ID, 99, ResolveReportRequest{Action: "reopen"}); err == nil {
+		t.Fatalf("expected decided dispute to stay closed")
+	}
+
+	payout, err := ctx.orderSvc.GetFarmerPayoutSummary(ctx.farmerID)
+	if err != nil {
+		t.Fatalf("failed to load payout: %v", err)
+	}
+	if payout.Refunds != 60 || payout.NetPayout != (order.TotalPrice-60)*0.95 {
+		t.Fatalf("unexpected payout after refund: %+v", payout)
+	}
+	var profile models.FarmerProfile
+	if err := ctx.db.Where("user_id = ?", ctx.farmerID).First(&profile).Error; err != nil {
+		t.Fatalf("failed to load farmer profile: %v", err)
+	}
+	if profile.DisputesLost != 1 || profile.TrustScore >= 1 {
+		t.Fatalf("expected trust to reflect the lost dispute, got %+v", profile)
+	}
+	var audits int64
+	ctx.db.Model(&models.AdminAuditLog{}).Where("target_id = ? AND action = ?", order.ID, "dispute_partial_refund").Count(&audits)
+	if audits != 1 {
+		t.Fatalf("expected decision to be audited, got %d entries", audits)
+	}
+}
+
+func TestDisputeRefundsOnUnpaidOrdersReduceWhatIsOwed(t *testing.T) {
+	ctx := setupTestCtx(t)
+	adminSvc := newAdminServiceForTest(ctx)
+
+	// Cash on delivery not yet handed over is waived, not credited.
+	order := createOrderForTest(t, ctx)
+	for _, step := range []string{"confirmed", "packed", "out_for_delivery"} {
+		if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{Status: step}); err != nil {
+			t.Fatalf("failed to move order to %s: %v", step, err)
+		}
+	}
+	if _, err := ctx.orderSvc.OpenDispute(order.ID, ctx.buyerID, OpenDisputeRequest{Category: "quantity", Remedy: "partial_refund", ClaimAmount: 80, Note: "short by a crate"}); err != nil {
+		t.Fatalf("failed to open dispute: %v", err)
+	}
+	resolved, err := ctx.orderSvc.ResolveDispute(order.ID, ctx.farmerID, "agreed")
+	if err != nil {
+		t.Fatalf("failed to resolve dispute: %v", err)
+	}
+	if balance, _ := walletBalance(ctx.db, ctx.buyerID); balance != 0 {
+		t.Fatalf("expected nothing credited for an unpaid order, got wallet %v", balance)
+	}
+	if resolved.PaymentStatus != "pending" || resolved.WaivedAmount != 80 || amountDue(resolved) != 120 {
+		t.Fatalf("unexpected unpaid refund: status=%s waived=%v due=%v", resolved.PaymentStatus, resolved.WaivedAmount, amountDue(resolved))
+	}
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.buyerID, UpdateOrderStatusRequest{Status: "completed"}); err != nil {
+		t.Fatalf("failed to complete order: %v", err)
+	}
+	// The farmer holds the 120 collected and has earned 95% of it.
+	if held, _ := ledgerBalance(ctx.db, order.ID, ledgerFarmerPayable); held != 6 {
+		t.Fatalf("expected the farmer to collect only what is still owed, got payable balance %v", held)
+	}
+
+	// A credit bill the buyer has not paid is reduced instead.
+	ctx.db.Model(&models.User{}).Where("id = ?", ctx.buyerID).Update("gstin", "33AAACB1234C1Z5")
+	account, err := ctx.orderSvc.ApplyForCredit(ctx.buyerID, CreditAccountRequest{RequestedLimit: 500, TermDays: 15})
+	if err != nil {
+		t.Fatalf("failed to apply for credit: %v", err)
+	}
+	if _, err := adminSvc.ReviewCreditAccount(99, account.ID, ReviewCreditAccountRequest{Status: "active"}); err != nil {
+		t.Fatalf("failed to approve credit: %v", err)
+	}
+	creditOrder, err := ctx.orderSvc.CreateOrder(ctx.buyerID, CreateOrderRequest{ProductID: ctx.productID, Quantity: 2, DeliveryAddress: "Warehouse 4", PaymentMethod: "credit"})
+	if err != nil {
+		t.Fatalf("failed to place credit order: %v", err)
+	}
+	completeOrderForTest(t, ctx, creditOrder.ID)
+	if _, err := ctx.orderSvc.OpenDispute(creditOrder.ID, ctx.buyerID, OpenDisputeRequest{Category: "quality", Remedy: "partial_refund", ClaimAmount: 50, Note: "bruised"}); err != nil {
+		t.Fatalf("failed to open dispute: %v", err)
+	}
+	resolved, err = ctx.orderSvc.ResolveDispute(creditOrder.ID, ctx.farmerID, "agreed")
+	if err != nil {
+		t.Fatalf("failed to resolve dispute: %v", err)
+	}
+	owed, _ := ledgerBalance(ctx.db, creditOrder.ID, ledgerBuyerReceivable)
+	if balance, _ := walletBalance(ctx.db, ctx.buyerID); balance != 0 || owed != 150 || resolved.PaymentStatus != "pending" {
+		t.Fatalf("unexpected credit refund: wallet=%v owed=%v status=%s", balance, owed, resolved.PaymentStatus)
+	}
+	credit, err := ctx.orderSvc.GetBuyerCredit(ctx.buyerID)
+	if err != nil || credit.Outstanding != 150 {
+		t.Fatalf("expected the refund to come off the credit balance: %+v err=%v", credit, err)
+	}
+	credit, err = adminSvc.RecordCreditRepayment(99, CreditRepaymentRequest{BuyerID: ctx.buyerID, OrderIDs: []uint{creditOrder.ID}, Reference: "NEFT1"})
+	if owed, _ = ledgerBalance(ctx.db, creditOrder.ID, ledgerBuyerReceivable); err != nil || credit.Outstanding != 0 || owed != 0 {
+		t.Fatalf("unexpected repayment after refund: %+v owed=%v err=%v", credit, owed, err)
+	}
+}
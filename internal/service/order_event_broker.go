@@ -0,0 +1,262 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+const orderEventBuffer = 32
+
+// orderEventReplayLimit caps how many missed events of each kind a
+// reconnecting client is sent.
+const orderEventReplayLimit = 500
+
+// OrderEvent is one real-time update pushed to an order's participants. ID is
+// the ID of the status log or message the event carries.
+type OrderEvent struct {
+	ID        uint        `json:"id"`
+	OrderID   uint        `json:"order_id"`
+	Type      string      `json:"type"` // message/status/dispute
+	Data      interface{} `json:"data"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func (e OrderEvent) isMessage() bool {
+	return e.Type == "message"
+}
+
+// orderEventKey identifies the status log or message behind an event.
+type orderEventKey struct {
+	message bool
+	id      uint
+}
+
+func (e OrderEvent) key() orderEventKey {
+	return orderEventKey{message: e.isMessage(), id: e.ID}
+}
+
+// OrderEventCursor records the last status log and message a client has
+// been sent. It is the stream's event ID, so a client that reconnects, to
+// this server or another, resumes from the database with Last-Event-ID.
+type OrderEventCursor struct {
+	StatusLogID uint
+	MessageID   uint
+}
+
+// ParseOrderEventCursor reads a cursor written by OrderEventCursor.String.
+func ParseOrderEventCursor(raw string) (OrderEventCursor, error) {
+	var cursor OrderEventCursor
+	statusLogID, messageID, ok := strings.Cut(raw, "-")
+	if !ok {
+		return cursor, errors.New("invalid last event ID")
+	}
+	logID, err := strconv.ParseUint(statusLogID, 10, 32)
+	if err != nil {
+		return cursor, errors.New("invalid last event ID")
+	}
+	msgID, err := strconv.ParseUint(messageID, 10, 32)
+	if err != nil {
+		return cursor, errors.New("invalid last event ID")
+	}
+	cursor.StatusLogID = uint(logID)
+	cursor.MessageID = uint(msgID)
+	return cursor, nil
+}
+
+func (c OrderEventCursor) String() string {
+	return strconv.FormatUint(uint64(c.StatusLogID), 10) + "-" + strconv.FormatUint(uint64(c.MessageID), 10)
+}
+
+// Include moves the cursor past event.
+func (c *OrderEventCursor) Include(event OrderEvent) {
+	if event.isMessage() {
+		if event.ID > c.MessageID {
+			c.MessageID = event.ID
+		}
+		return
+	}
+	if event.ID > c.StatusLogID {
+		c.StatusLogID = event.ID
+	}
+}
+
+// OrderEventBroker fans order events out to connected participants within a
+// single process. Events are not kept here: every event is a persisted status
+// log or message, and clients that missed some replay them from the database.
+type OrderEventBroker struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[chan OrderEvent]struct{}
+}
+
+func NewOrderEventBroker() *OrderEventBroker {
+	return &OrderEventBroker{
+		subscribers: make(map[uint]map[chan OrderEvent]struct{}),
+	}
+}
+
+// Publish delivers an event for the status log or message with the given ID
+// to every connected recipient. A subscriber that cannot keep up is
+// disconnected rather than blocking the publisher; it catches up when it
+// reconnects.
+func (b *OrderEventBroker) Publish(orderID uint, recipients []uint, eventType string, id uint, data interface{}) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := OrderEvent{
+		ID:        id,
+		OrderID:   orderID,
+		Type:      eventType,
+		Data:      data,
+		CreatedAt: time.Now().UTC(),
+	}
+	for _, userID := range recipients {
+		for ch := range b.subscribers[userID] {
+			select {
+			case ch <- event:
+			default:
+				b.removeLocked(userID, ch)
+			}
+		}
+	}
+}
+
+// Subscribe registers a listener for userID. It returns a channel for new
+// events and a function that must be called to unsubscribe. The channel is
+// closed when the subscriber is dropped.
+func (b *OrderEventBroker) Subscribe(userID uint) (<-chan OrderEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan OrderEvent, orderEventBuffer)
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan OrderEvent]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.removeLocked(userID, ch)
+	}
+	return ch, unsubscribe
+}
+
+func (b *OrderEventBroker) removeLocked(userID uint, ch chan OrderEvent) {
+	subs := b.subscribers[userID]
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(b.subscribers, userID)
+	}
+}
+
+// orderEventTypeForLog separates dispute activity from ordinary lifecycle
+// updates so clients can route them to the right view.
+func orderEventTypeForLog(log *models.OrderStatusLog) string {
+	if strings.HasPrefix(log.Reason, "dispute") {
+		return "dispute"
+	}
+	return "status"
+}
+
+// SetEventBroker enables real-time events for this service's order updates.
+func (s *OrderService) SetEventBroker(broker *OrderEventBroker) {
+	s.events = broker
+}
+
+// publishStatusLog pushes a committed status log to both order participants.
+func (s *OrderService) publishStatusLog(log *models.OrderStatusLog) {
+	if s.events == nil || log == nil {
+		return
+	}
+	order, err := s.orderRepo.GetByID(log.OrderID)
+	if err != nil {
+		return
+	}
+	s.events.Publish(order.ID, []uint{order.BuyerID, order.FarmerID}, orderEventTypeForLog(log), log.ID, log)
+}
+
+// OrderEventStream is an open subscription: the events a reconnecting client
+// missed, followed by live ones.
+type OrderEventStream struct {
+	Cursor OrderEventCursor // position before Replay
+	Replay []OrderEvent
+	Events <-chan OrderEvent
+	Close  func()
+
+	replayed map[orderEventKey]bool
+}
+
+// Replayed reports whether a live event was already sent in Replay, which
+// happens when it is published while the replay is being loaded.
+func (s *OrderEventStream) Replayed(event OrderEvent) bool {
+	return s.replayed[event.key()]
+}
+
+// SubscribeOrderEvents opens an event stream for userID, optionally limited to
+// one order the user takes part in. With a cursor from an earlier stream the
+// status logs and messages written since are replayed first; without one the
+// stream starts from now.
+func (s *OrderService) SubscribeOrderEvents(userID, orderID uint, resume *OrderEventCursor) (*OrderEventStream, error) {
+	if s.events == nil {
+		return nil, errors.New("real-time updates are not enabled")
+	}
+	if orderID > 0 {
+		if _, err := s.getAccessibleOrder(orderID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Subscribe before reading so nothing written in between is missed.
+	events, unsubscribe := s.events.Subscribe(userID)
+	stream := &OrderEventStream{Events: events, Close: unsubscribe, replayed: make(map[orderEventKey]bool)}
+	if resume == nil {
+		statusLogID, messageID, err := s.orderRepo.GetLatestOrderEventIDs()
+		if err != nil {
+			unsubscribe()
+			return nil, errors.New("failed to load order events")
+		}
+		stream.Cursor = OrderEventCursor{StatusLogID: statusLogID, MessageID: messageID}
+		return stream, nil
+	}
+
+	stream.Cursor = *resume
+	logs, err := s.orderRepo.ListStatusLogsForUserSince(userID, orderID, resume.StatusLogID, orderEventReplayLimit)
+	if err != nil {
+		unsubscribe()
+		return nil, errors.New("failed to load order events")
+	}
+	messages, err := s.orderRepo.ListOrderMessagesForUserSince(userID, orderID, resume.MessageID, orderEventReplayLimit)
+	if err != nil {
+		unsubscribe()
+		return nil, errors.New("failed to load order events")
+	}
+	for i := range logs {
+		log := &logs[i]
+		stream.Replay = append(stream.Replay, OrderEvent{ID: log.ID, OrderID: log.OrderID, Type: orderEventTypeForLog(log), Data: log, CreatedAt: log.CreatedAt})
+	}
+	for i := range messages {
+		message := &messages[i]
+		linkMessageAttachments(message)
+		stream.Replay = append(stream.Replay, OrderEvent{ID: message.ID, OrderID: message.OrderID, Type: "message", Data: message, CreatedAt: message.CreatedAt})
+	}
+	sort.SliceStable(stream.Replay, func(i, j int) bool {
+		return stream.Replay[i].CreatedAt.Before(stream.Replay[j].CreatedAt)
+	})
+	for _, event := range stream.Replay {
+		stream.replayed[event.key()] = true
+	}
+	return stream, nil
+}
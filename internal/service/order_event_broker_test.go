@@ -0,0 +1,91 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestOrderEventsReachParticipantsAndReplay(t *testing.T) {
+	ctx := setupTestCtx(t)
+	ctx.orderSvc.SetEventBroker(NewOrderEventBroker())
+
+	farmerStream, err := ctx.orderSvc.SubscribeOrderEvents(ctx.farmerID, 0, nil)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer farmerStream.Close()
+	strangerStream, err := ctx.orderSvc.SubscribeOrderEvents(ctx.farmerID+ctx.buyerID+100, 0, nil)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer strangerStream.Close()
+
+	order := createOrderForTest(t, ctx)
+	if _, err := ctx.orderSvc.SendOrderMessage(order.ID, ctx.buyerID, SendOrderMessageRequest{Message: "ready?"}); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{Status: "confirmed"}); err != nil {
+		t.Fatalf("failed to confirm order: %v", err)
+	}
+
+	wantTypes := []string{"status", "message", "status"}
+	received := make([]OrderEvent, 0, len(wantTypes))
+	for range wantTypes {
+		select {
+		case event := <-farmerStream.Events:
+			received = append(received, event)
+		default:
+			t.Fatalf("expected %d events for farmer, got %d", len(wantTypes), len(received))
+		}
+	}
+	for i, event := range received {
+		if event.Type != wantTypes[i] || event.OrderID != order.ID || event.ID == 0 {
+			t.Fatalf("unexpected event %d: %+v", i, event)
+		}
+	}
+	if message, ok := received[1].Data.(*models.OrderMessage); !ok || message.ID != received[1].ID {
+		t.Fatalf("expected the message event to carry the message ID: %+v", received[1])
+	}
+	select {
+	case event := <-strangerStream.Events:
+		t.Fatalf("non-participant received event: %+v", event)
+	default:
+	}
+
+	// Event IDs come from the database, so a client resumes even after the
+	// server restarts with an empty broker.
+	cursor := farmerStream.Cursor
+	cursor.Include(received[0])
+	resumed, err := ParseOrderEventCursor(cursor.String())
+	if err != nil || resumed != cursor {
+		t.Fatalf("expected the cursor to round-trip, got %+v err=%v", resumed, err)
+	}
+	broker := NewOrderEventBroker()
+	ctx.orderSvc.SetEventBroker(broker)
+	buyerStream, err := ctx.orderSvc.SubscribeOrderEvents(ctx.buyerID, 0, &resumed)
+	if err != nil {
+		t.Fatalf("failed to resume: %v", err)
+	}
+	defer buyerStream.Close()
+	replay := buyerStream.Replay
+	if len(replay) != 2 || replay[0].Type != "message" || replay[0].ID != received[1].ID || replay[1].Type != "status" || replay[1].ID != received[2].ID {
+		t.Fatalf("unexpected replay after reconnect: %+v", replay)
+	}
+	broker.Publish(order.ID, []uint{ctx.buyerID}, "message", received[1].ID, nil)
+	if event := <-buyerStream.Events; !buyerStream.Replayed(event) {
+		t.Fatalf("expected an event already replayed to be recognised")
+	}
+
+	fresh, err := ctx.orderSvc.SubscribeOrderEvents(ctx.buyerID, order.ID, nil)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer fresh.Close()
+	if len(fresh.Replay) != 0 || fresh.Cursor.StatusLogID != received[2].ID || fresh.Cursor.MessageID != received[1].ID {
+		t.Fatalf("expected a new stream to start from the latest events: %+v", fresh)
+	}
+	if _, err := ParseOrderEventCursor("42"); err == nil {
+		t.Fatalf("expected a malformed last event ID to be rejected")
+	}
+}
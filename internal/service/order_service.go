@@ -21,6 +21,8 @@ type OrderService struct {
 	orderRepo   *repository.OrderRepository
 	productRepo *repository.ProductRepository
 	userRepo    *repository.UserRepository
+	events      *OrderEventBroker
+	payments    *PaymentService
 }
 
 func NewOrderService(orderRepo *repository.OrderRepository, productRepo *repository.ProductRepository, userRepo *repository.UserRepository) *OrderService {
@@ -33,12 +35,15 @@ func NewOrderService(orderRepo *repository.OrderRepository, productRepo *reposit
 
 type CreateOrderRequest struct {
 	ProductID        uint    `json:"product_id"`
+	VariantID        uint    `json:"variant_id"`
 	Quantity         float64 `json:"quantity"`
 	DeliveryAddress  string  `json:"delivery_address"`
 	BuyerNote        string  `json:"buyer_note"`
 	PaymentMethod    string  `json:"payment_method"`
 	PaymentReference string  `json:"payment_reference"`
 	PreferredDate    string  `json:"preferred_date"`
+	WalletAmount     float64 `json:"wallet_amount"`
+	CouponCode       string  `json:"coupon_code"`
 }
 
 type CreateHarvestRequestRequest struct {
@@ -54,6 +59,18 @@ type UpdateHarvestRequestRequest struct {
 	FarmerResponseNote string `json:"farmer_response_note"`
 }
 
+type CounterHarvestRequestRequest struct {
+	Quantity     float64 `json:"quantity"`
+	HarvestDate  string  `json:"harvest_date"`
+	PricePerUnit float64 `json:"price_per_unit"`
+	Note         string  `json:"note"`
+}
+
+type RespondHarvestCounterRequest struct {
+	Action string `json:"action"` // accept/decline
+	Note   string `json:"note"`
+}
+
 type UpdateOrderStatusRequest struct {
 	Status             string `json:"status"`
 	CancellationReason string `json:"cancellation_reason"`
@@ -61,8 +78,16 @@ type UpdateOrderStatusRequest struct {
 	CancellationNote   string `json:"cancellation_note"`
 	DeliveryDate       string `json:"delivery_date"`
 	DeliverySlot       string `json:"delivery_slot"`
-	DisputeStatus      string `json:"dispute_status"`
-	DisputeNote        string `json:"dispute_note"`
+	// Disputes are opened, answered and decided through their own
+	// endpoints; these are only read to reject requests that set them.
+	DisputeStatus string `json:"dispute_status"`
+	DisputeNote   string `json:"dispute_note"`
+}
+
+type CancelOrderRequest struct {
+	Reason           string `json:"reason"`
+	CancellationType string `json:"cancellation_type"`
+	Note             string `json:"note"`
 }
 
 type UpdateDisputeRequest struct {
@@ -70,7 +95,29 @@ type UpdateDisputeRequest struct {
 }
 
 type SendOrderMessageRequest struct {
-	Message string `json:"message"`
+	Message     string                   `json:"message"`
+	Attachments []OrderMessageAttachment `json:"attachments"`
+}
+
+// OrderMessageAttachment describes a file already stored by the upload
+// pipeline that should be attached to a new message.
+type OrderMessageAttachment struct {
+	URL         string `json:"url"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+type OrderMessagePage struct {
+	Items       []models.OrderMessage     `json:"items"`
+	NextCursor  uint                      `json:"next_cursor"`
+	HasMore     bool                      `json:"has_more"`
+	ReadMarkers []models.OrderMessageRead `json:"read_markers"`
+}
+
+type UnreadMessageSummary struct {
+	Total  int64                         `json:"total"`
+	Orders []repository.OrderUnreadCount `json:"orders"`
 }
 
 type AddDisputeEvidenceRequest struct {
@@ -125,35 +172,50 @@ type FarmerPayoutSummary struct {
 	CompletedOrders   int     `json:"completed_orders"`
 	PendingSettlement int     `json:"pending_settlement"`
 	TotalGross        float64 `json:"total_gross"`
+	Refunds           float64 `json:"refunds"`
+	CancellationFees  float64 `json:"cancellation_fees"`
+	FarmerDiscounts   float64 `json:"farmer_discounts"`  // farmer-funded discounts on delivered orders
+	PromotionSubsidy  float64 `json:"promotion_subsidy"` // platform-funded discounts paid to the farmer
 	PlatformFee       float64 `json:"platform_fee"`
 	NetPayout         float64 `json:"net_payout"`
+	PaidOut           float64 `json:"paid_out"`
+	Balance           float64 `json:"balance"`
 	Currency          string  `json:"currency"`
 }
 
 type FarmerInvoice struct {
-	OrderID            uint    `json:"order_id"`
-	Status             string  `json:"status"`
-	ProductName        string  `json:"product_name"`
-	BuyerName          string  `json:"buyer_name"`
-	Quantity           float64 `json:"quantity"`
-	Unit               string  `json:"unit"`
-	UnitPrice          float64 `json:"unit_price"`
-	GrossAmount        float64 `json:"gross_amount"`
-	PlatformFee        float64 `json:"platform_fee"`
-	NetPayout          float64 `json:"net_payout"`
-	CancellationReason string  `json:"cancellation_reason"`
-	CreatedAt          string  `json:"created_at"`
-	CompletedAt        *string `json:"completed_at,omitempty"`
-	OutForDeliveryAt   *string `json:"out_for_delivery_at,omitempty"`
-	Currency           string  `json:"currency"`
+	OrderID            uint               `json:"order_id"`
+	Status             string             `json:"status"`
+	ProductName        string             `json:"product_name"`
+	BuyerName          string             `json:"buyer_name"`
+	Quantity           float64            `json:"quantity"`
+	Unit               string             `json:"unit"`
+	UnitPrice          float64            `json:"unit_price"`
+	GrossAmount        float64            `json:"gross_amount"`
+	RefundAmount       float64            `json:"refund_amount"`
+	CancellationFee    float64            `json:"cancellation_fee"`
+	DiscountAmount     float64            `json:"discount_amount"`
+	DiscountFundedBy   string             `json:"discount_funded_by,omitempty"`
+	PromotionSubsidy   float64            `json:"promotion_subsidy"`
+	PlatformFee        float64            `json:"platform_fee"`
+	NetPayout          float64            `json:"net_payout"`
+	CancellationReason string             `json:"cancellation_reason"`
+	CreatedAt          string             `json:"created_at"`
+	CompletedAt        *string            `json:"completed_at,omitempty"`
+	OutForDeliveryAt   *string            `json:"out_for_delivery_at,omitempty"`
+	Currency           string             `json:"currency"`
+	TaxInvoice         *models.TaxInvoice `json:"tax_invoice,omitempty"`
 }
 
 type FarmerTopProduct struct {
-	ProductID   uint    `json:"product_id"`
-	ProductName string  `json:"product_name"`
-	OrdersCount int     `json:"orders_count"`
-	UnitsSold   float64 `json:"units_sold"`
-	Revenue     float64 `json:"revenue"`
+	ProductID     uint    `json:"product_id"`
+	ProductName   string  `json:"product_name"`
+	OrdersCount   int     `json:"orders_count"`
+	UnitsSold     float64 `json:"units_sold"` // in the listing's unit
+	Unit          string  `json:"unit"`
+	BaseUnitsSold float64 `json:"base_units_sold"`
+	BaseUnit      string  `json:"base_unit"`
+	Revenue       float64 `json:"revenue"`
 }
 
 type FarmerAnalyticsSummary struct {
@@ -167,6 +229,7 @@ type FarmerAnalyticsSummary struct {
 	LastMonthRevenue  float64            `json:"last_month_revenue"`
 	RevenueGrowth     float64            `json:"revenue_growth"`
 	TopProducts       []FarmerTopProduct `json:"top_products"`
+	VolumeSold        map[string]float64 `json:"volume_sold"` // completed quantity per base unit
 	Currency          string             `json:"currency"`
 }
 
@@ -211,18 +274,9 @@ func isAllowedDeliverySlot(value string) bool {
 	}
 }
 
-func isAllowedDisputeStatus(value string) bool {
-	switch value {
-	case "none", "open", "resolved", "rejected":
-		return true
-	default:
-		return false
-	}
-}
-
 func isAllowedHarvestRequestStatus(value string) bool {
 	switch value {
-	case "pending", "accepted", "rejected", "ready", "completed", "cancelled":
+	case "pending", "countered", "accepted", "declined", "rejected", "ready", "completed", "cancelled":
 		return true
 	default:
 		return false
@@ -235,7 +289,7 @@ func normalizePaymentMethod(value string) string {
 
 func isAllowedPaymentMethod(value string) bool {
 	switch normalizePaymentMethod(value) {
-	case "cod", "upi", "online_banking":
+	case "cod", "upi", "online_banking", "wallet", "credit":
 		return true
 	default:
 		return false
@@ -243,7 +297,7 @@ func isAllowedPaymentMethod(value string) bool {
 }
 
 func derivePaymentStatus(method string) string {
-	if normalizePaymentMethod(method) == "cod" {
+	if !isPrepaidMethod(method) {
 		return "pending"
 	}
 	return "initiated"
@@ -321,7 +375,11 @@ func (s *OrderService) createInventoryOrder(buyerID uint, req CreateOrderRequest
 	}
 
 	var createdOrderID uint
+	var statusLog *models.OrderStatusLog
 	err = s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := ensureBuyerNotOverdue(tx, buyerID, time.Now().UTC()); err != nil {
+			return err
+		}
 		var product models.Product
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
 			Where("id = ?", req.ProductID).
@@ -329,7 +387,7 @@ func (s *OrderService) createInventoryOrder(buyerID uint, req CreateOrderRequest
 			return errors.New("product not found")
 		}
 
-		if product.Status != "active" {
+		if product.Status != "active" || pastBestBefore(&product, time.Now().UTC()) {
 			return errors.New("product is not available")
 		}
 		if product.Quantity < req.Quantity {
@@ -338,13 +396,39 @@ func (s *OrderService) createInventoryOrder(buyerID uint, req CreateOrderRequest
 		if product.FarmerID == buyerID {
 			return errors.New("you cannot order your own product")
 		}
+		variant, err := lockOrderVariant(tx, product.ID, req.VariantID)
+		if err != nil {
+			return err
+		}
+		if err := checkVariantQuantity(variant, req.Quantity); err != nil {
+			return err
+		}
+
+		var tiers []models.ProductPriceTier
+		if err := tx.Where("product_id = ?", product.ID).Order("min_quantity ASC").Find(&tiers).Error; err != nil {
+			return errors.New("failed to load price tiers")
+		}
+		unitPrice := tierUnitPrice(product.PricePerUnit, tiers, req.Quantity)
+		var request *models.HarvestRequest
+		if sourceRequestID > 0 {
+			var item models.HarvestRequest
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ?", sourceRequestID).
+				First(&item).Error; err == nil {
+				request = &item
+				if item.AgreedPricePerUnit != nil && *item.AgreedPricePerUnit > 0 {
+					unitPrice = *item.AgreedPricePerUnit
+				}
+			}
+		}
 
 		order := &models.Order{
 			ProductID:        req.ProductID,
 			BuyerID:          buyerID,
 			FarmerID:         product.FarmerID,
 			Quantity:         req.Quantity,
-			TotalPrice:       req.Quantity * product.PricePerUnit,
+			Unit:             product.Unit,
+			TotalPrice:       req.Quantity * unitPrice,
 			OrderType:        orderType,
 			BuyerNote:        utils.SanitizeString(req.BuyerNote),
 			PaymentMethod:    normalizePaymentMethod(req.PaymentMethod),
@@ -357,10 +441,32 @@ func (s *OrderService) createInventoryOrder(buyerID uint, req CreateOrderRequest
 		if sourceRequestID > 0 {
 			order.SourceRequestID = &sourceRequestID
 		}
+		if variant != nil {
+			agreedTotal := order.TotalPrice
+			applyOrderVariant(order, variant)
+			if request != nil && request.AgreedPricePerUnit != nil && *request.AgreedPricePerUnit > 0 {
+				order.TotalPrice = agreedTotal
+			}
+		}
+		if err := assessPlatformFee(tx, order, product); err != nil {
+			return err
+		}
+		if err := applyPromotion(tx, order, product, req.CouponCode, time.Now().UTC()); err != nil {
+			return err
+		}
+		if err := reserveWalletPayment(tx, order, req.WalletAmount); err != nil {
+			return err
+		}
+		if err := reserveCreditPayment(tx, order); err != nil {
+			return err
+		}
 		if err := tx.Create(order).Error; err != nil {
 			return errors.New("failed to create order")
 		}
 		createdOrderID = order.ID
+		if err := postWalletPayment(tx, order); err != nil {
+			return err
+		}
 
 		logNote := "Order placed by buyer"
 		if orderType == "bulk" {
@@ -368,7 +474,7 @@ func (s *OrderService) createInventoryOrder(buyerID uint, req CreateOrderRequest
 		} else if sourceRequestID > 0 {
 			logNote = "Order converted from harvest request"
 		}
-		if err := tx.Create(&models.OrderStatusLog{
+		statusLog = &models.OrderStatusLog{
 			OrderID:    order.ID,
 			ActorID:    buyerID,
 			FromStatus: "new",
@@ -377,10 +483,15 @@ func (s *OrderService) createInventoryOrder(buyerID uint, req CreateOrderRequest
 			Category:   orderType,
 			Note:       logNote,
 			CreatedAt:  time.Now().UTC(),
-		}).Error; err != nil {
+		}
+		if err := tx.Create(statusLog).Error; err != nil {
 			return errors.New("failed to initialize order timeline")
 		}
 
+		if err := takeVariantStock(tx, variant, req.Quantity); err != nil {
+			return err
+		}
+		before := product.Quantity
 		product.Quantity -= req.Quantity
 		if product.Quantity <= 0 {
 			product.Quantity = 0
@@ -389,22 +500,27 @@ func (s *OrderService) createInventoryOrder(buyerID uint, req CreateOrderRequest
 		if err := tx.Save(&product).Error; err != nil {
 			return errors.New("failed to reserve inventory")
 		}
-
-		if sourceRequestID > 0 {
-			var request models.HarvestRequest
-			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-				Where("id = ?", sourceRequestID).
-				First(&request).Error; err == nil {
-				now := time.Now().UTC()
-				request.Status = "completed"
-				request.ConvertedOrderID = &order.ID
-				request.RespondedAt = &now
-				if strings.TrimSpace(request.FarmerResponseNote) == "" {
-					request.FarmerResponseNote = "Converted into confirmed buyer order flow"
-				}
-				if err := tx.Save(&request).Error; err != nil {
-					return errors.New("failed to update harvest request")
-				}
+		if err := recordStockMovement(tx, &product, product.Quantity-before, models.StockMovement{
+			Kind:      "reserve",
+			VariantID: order.VariantID,
+			ActorID:   &buyerID,
+			OrderID:   &order.ID,
+			Reference: "order",
+		}); err != nil {
+			return err
+		}
+
+		if request != nil {
+			now := time.Now().UTC()
+			request.Status = "completed"
+			request.ConvertedOrderID = &order.ID
+			// RespondedAt stays the farmer's answer, which response metrics use.
+			request.ConvertedAt = &now
+			if strings.TrimSpace(request.FarmerResponseNote) == "" {
+				request.FarmerResponseNote = "Converted into confirmed buyer order flow"
+			}
+			if err := tx.Save(request).Error; err != nil {
+				return errors.New("failed to update harvest request")
 			}
 		}
 
@@ -413,6 +529,7 @@ func (s *OrderService) createInventoryOrder(buyerID uint, req CreateOrderRequest
 	if err != nil {
 		return nil, err
 	}
+	s.publishStatusLog(statusLog)
 
 	return s.orderRepo.GetByID(createdOrderID)
 }
@@ -459,6 +576,8 @@ func (s *OrderService) CreateHarvestRequest(buyerID uint, req CreateHarvestReque
 		BuyerNote:            utils.SanitizeString(req.BuyerNote),
 		Status:               "pending",
 	}
+	deadline := harvestResponseDeadline(time.Now().UTC(), *preferredDate)
+	item.ResponseDeadline = &deadline
 	if err := s.orderRepo.CreateHarvestRequest(item); err != nil {
 		return nil, errors.New("failed to create harvest request")
 	}
@@ -496,11 +615,14 @@ func (s *OrderService) UpdateHarvestRequest(requestID, actorID uint, req UpdateH
 
 		validTransitions := map[string][]string{
 			"pending":   {"accepted", "rejected", "cancelled"},
+			"countered": {"cancelled"},
 			"accepted":  {"ready", "rejected", "cancelled"},
 			"ready":     {"completed", "cancelled"},
+			"declined":  {},
 			"rejected":  {},
 			"completed": {},
 			"cancelled": {},
+			"expired":   {},
 		}
 		allowed := false
 		for _, candidate := range validTransitions[item.Status] {
@@ -523,11 +645,161 @@ func (s *OrderService) UpdateHarvestRequest(requestID, actorID uint, req UpdateH
 		if isFarmer && nextStatus == "cancelled" {
 			return errors.New("farmers cannot cancel buyer harvest requests")
 		}
+		if item.Status == "countered" && nextStatus != "cancelled" {
+			return errors.New("counter-proposal is awaiting the buyer's response")
+		}
+		if isFarmer && harvestResponseOverdue(&item, time.Now().UTC()) {
+			return errors.New("harvest request response deadline has passed")
+		}
+
+		if nextStatus == "accepted" && item.Status == "pending" {
+			// Plain acceptance locks in the buyer's terms at today's listing price.
+			var product models.Product
+			if err := tx.Where("id = ?", item.ProductID).First(&product).Error; err != nil {
+				return errors.New("product not found")
+			}
+			quantity := item.RequestedQuantity
+			harvestDate := item.PreferredHarvestDate
+			price := product.PricePerUnit
+			item.AgreedQuantity = &quantity
+			item.AgreedHarvestDate = &harvestDate
+			item.AgreedPricePerUnit = &price
+		}
 
 		now := time.Now().UTC()
 		item.Status = nextStatus
 		item.FarmerResponseNote = utils.SanitizeString(req.FarmerResponseNote)
+		if isFarmer && item.RespondedAt == nil {
+			item.RespondedAt = &now
+		}
+		if err := tx.Save(&item).Error; err != nil {
+			return errors.New("failed to update harvest request")
+		}
+		updatedID = item.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetHarvestRequestByID(updatedID)
+}
+
+// CounterHarvestRequest lets the farmer answer a pending request with the
+// quantity, harvest date and price they can actually offer. Omitted terms
+// default to what the buyer asked for.
+func (s *OrderService) CounterHarvestRequest(requestID, farmerID uint, req CounterHarvestRequestRequest) (*models.HarvestRequest, error) {
+	if req.Quantity < 0 {
+		return nil, errors.New("counter quantity must be greater than 0")
+	}
+	if req.PricePerUnit < 0 {
+		return nil, errors.New("counter price must be greater than 0")
+	}
+	harvestDate, err := parseOptionalRFC3339(req.HarvestDate)
+	if err != nil {
+		return nil, errors.New("invalid counter harvest date format")
+	}
+	if harvestDate != nil && harvestDate.Before(time.Now().UTC().Add(-5*time.Minute)) {
+		return nil, errors.New("counter harvest date cannot be in the past")
+	}
+
+	var updatedID uint
+	err = s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var item models.HarvestRequest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", requestID).
+			First(&item).Error; err != nil {
+			return errors.New("harvest request not found")
+		}
+		if item.FarmerID != farmerID {
+			return errors.New("unauthorized harvest request access")
+		}
+		if item.Status != "pending" {
+			return errors.New("only pending harvest requests can be countered")
+		}
+		if harvestResponseOverdue(&item, time.Now().UTC()) {
+			return errors.New("harvest request response deadline has passed")
+		}
+
+		var product models.Product
+		if err := tx.Where("id = ?", item.ProductID).First(&product).Error; err != nil {
+			return errors.New("product not found")
+		}
+
+		quantity := item.RequestedQuantity
+		if req.Quantity > 0 {
+			quantity = req.Quantity
+		}
+		counterDate := item.PreferredHarvestDate
+		if harvestDate != nil {
+			counterDate = *harvestDate
+		}
+		price := product.PricePerUnit
+		if req.PricePerUnit > 0 {
+			price = req.PricePerUnit
+		}
+		if quantity == item.RequestedQuantity && counterDate.Equal(item.PreferredHarvestDate) && price == product.PricePerUnit {
+			return errors.New("counter-proposal does not change the requested terms")
+		}
+
+		now := time.Now().UTC()
+		item.Status = "countered"
+		item.CounterQuantity = &quantity
+		item.CounterHarvestDate = &counterDate
+		item.CounterPricePerUnit = &price
+		item.CounterNote = utils.SanitizeString(req.Note)
+		item.CounteredAt = &now
+		// The buyer gets the same window to answer, closing no later than the
+		// proposed harvest date.
+		expiresAt := harvestResponseDeadline(now, counterDate)
+		item.CounterExpiresAt = &expiresAt
 		item.RespondedAt = &now
+		if err := tx.Save(&item).Error; err != nil {
+			return errors.New("failed to save counter-proposal")
+		}
+		updatedID = item.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetHarvestRequestByID(updatedID)
+}
+
+// RespondToHarvestCounter records the buyer's answer to a farmer counter.
+// Accepting makes the counter terms the agreed terms used at conversion.
+func (s *OrderService) RespondToHarvestCounter(requestID, buyerID uint, req RespondHarvestCounterRequest) (*models.HarvestRequest, error) {
+	action := strings.ToLower(strings.TrimSpace(req.Action))
+	if action != "accept" && action != "decline" {
+		return nil, errors.New("invalid counter-proposal action")
+	}
+
+	var updatedID uint
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var item models.HarvestRequest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", requestID).
+			First(&item).Error; err != nil {
+			return errors.New("harvest request not found")
+		}
+		if item.BuyerID != buyerID {
+			return errors.New("unauthorized harvest request access")
+		}
+		if item.Status != "countered" {
+			return errors.New("harvest request has no open counter-proposal")
+		}
+		if harvestCounterExpired(&item, time.Now().UTC()) {
+			return errors.New("counter-proposal has expired")
+		}
+
+		item.BuyerResponseNote = utils.SanitizeString(req.Note)
+		if action == "accept" {
+			item.Status = "accepted"
+			item.AgreedQuantity = item.CounterQuantity
+			item.AgreedHarvestDate = item.CounterHarvestDate
+			item.AgreedPricePerUnit = item.CounterPricePerUnit
+		} else {
+			item.Status = "declined"
+		}
 		if err := tx.Save(&item).Error; err != nil {
 			return errors.New("failed to update harvest request")
 		}
@@ -555,8 +827,15 @@ func (s *OrderService) ConvertHarvestRequestToOrder(requestID, buyerID uint, req
 		return nil, errors.New("harvest request has already been converted")
 	}
 	req.ProductID = requestItem.ProductID
+	agreedQuantity := requestItem.RequestedQuantity
+	if requestItem.AgreedQuantity != nil && *requestItem.AgreedQuantity > 0 {
+		agreedQuantity = *requestItem.AgreedQuantity
+	}
 	if req.Quantity <= 0 {
-		req.Quantity = requestItem.RequestedQuantity
+		req.Quantity = agreedQuantity
+	}
+	if req.Quantity > agreedQuantity {
+		return nil, errors.New("quantity exceeds the agreed harvest quantity")
 	}
 	if strings.TrimSpace(req.DeliveryAddress) == "" {
 		req.DeliveryAddress = requestItem.DeliveryAddress
@@ -569,6 +848,9 @@ func (s *OrderService) ConvertHarvestRequestToOrder(requestID, buyerID uint, req
 	}
 	if strings.TrimSpace(req.PreferredDate) == "" {
 		req.PreferredDate = requestItem.PreferredHarvestDate.Format(time.RFC3339)
+		if requestItem.AgreedHarvestDate != nil {
+			req.PreferredDate = requestItem.AgreedHarvestDate.Format(time.RFC3339)
+		}
 	}
 	return s.createInventoryOrder(buyerID, req, "harvest_request", requestItem.ID)
 }
@@ -592,41 +874,165 @@ func (s *OrderService) getAccessibleOrder(orderID, userID uint) (*models.Order,
 	return order, nil
 }
 
-func (s *OrderService) GetOrderMessages(orderID, userID uint) ([]models.OrderMessage, error) {
+// GetOrderMessages returns one page of an order thread. Without cursors it
+// returns the latest messages; before pages back through history and after
+// fetches anything newer than the last message the client has.
+func (s *OrderService) GetOrderMessages(orderID, userID, before, after uint, limit int) (*OrderMessagePage, error) {
 	if _, err := s.getAccessibleOrder(orderID, userID); err != nil {
 		return nil, err
 	}
-	items, err := s.orderRepo.GetOrderMessages(orderID)
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	items, err := s.orderRepo.GetOrderMessagesPage(orderID, before, after, limit+1)
 	if err != nil {
 		return nil, errors.New("failed to load order messages")
 	}
-	return items, nil
+
+	page := &OrderMessagePage{}
+	if len(items) > limit {
+		page.HasMore = true
+		if after > 0 {
+			items = items[:limit]
+		} else {
+			items = items[1:]
+		}
+	}
+	for i := range items {
+		linkMessageAttachments(&items[i])
+	}
+	page.Items = items
+	if page.HasMore && len(items) > 0 {
+		if after > 0 {
+			page.NextCursor = items[len(items)-1].ID
+		} else {
+			page.NextCursor = items[0].ID
+		}
+	}
+	markers, err := s.orderRepo.GetMessageReads(orderID)
+	if err != nil {
+		return nil, errors.New("failed to load read receipts")
+	}
+	page.ReadMarkers = markers
+	return page, nil
 }
 
-func (s *OrderService) SendOrderMessage(orderID, userID uint, req SendOrderMessageRequest) ([]models.OrderMessage, error) {
+func (s *OrderService) SendOrderMessage(orderID, userID uint, req SendOrderMessageRequest) (*OrderMessagePage, error) {
 	order, err := s.getAccessibleOrder(orderID, userID)
 	if err != nil {
 		return nil, err
 	}
 	body := utils.SanitizeString(req.Message)
-	if strings.TrimSpace(body) == "" {
-		return nil, errors.New("message is required")
+	if strings.TrimSpace(body) == "" && len(req.Attachments) == 0 {
+		return nil, errors.New("message or attachment is required")
+	}
+	if len(req.Attachments) > utils.MaxAttachments {
+		return nil, errors.New("maximum 3 attachments allowed")
+	}
+
+	now := time.Now().UTC()
+	attachments := make([]models.OrderMessageAttachment, 0, len(req.Attachments))
+	for _, item := range req.Attachments {
+		if !utils.IsAttachmentURL(item.URL) {
+			return nil, errors.New("attachments must be uploaded first")
+		}
+		attachments = append(attachments, models.OrderMessageAttachment{
+			URL:         item.URL,
+			FileName:    utils.SanitizeString(item.FileName),
+			ContentType: item.ContentType,
+			Size:        item.Size,
+			CreatedAt:   now,
+		})
 	}
 
 	senderRole := "buyer"
 	if order.FarmerID == userID {
 		senderRole = "farmer"
 	}
-	if err := s.orderRepo.CreateOrderMessage(&models.OrderMessage{
-		OrderID:    orderID,
-		SenderID:   userID,
-		SenderRole: senderRole,
-		Message:    body,
-		CreatedAt:  time.Now().UTC(),
-	}); err != nil {
+	message := &models.OrderMessage{
+		OrderID:     orderID,
+		SenderID:    userID,
+		SenderRole:  senderRole,
+		Message:     body,
+		CreatedAt:   now,
+		Attachments: attachments,
+	}
+	if err := s.orderRepo.CreateOrderMessage(message); err != nil {
 		return nil, errors.New("failed to send message")
 	}
-	return s.orderRepo.GetOrderMessages(orderID)
+	linkMessageAttachments(message)
+	s.events.Publish(orderID, []uint{order.BuyerID, order.FarmerID}, "message", message.ID, message)
+	// Senders have obviously seen their own message.
+	_, _ = s.orderRepo.MarkMessagesRead(orderID, userID, message.ID, now)
+	return s.GetOrderMessages(orderID, userID, 0, 0, 0)
+}
+
+// linkMessageAttachments points each attachment at the authenticated route
+// that serves it.
+func linkMessageAttachments(message *models.OrderMessage) {
+	for i := range message.Attachments {
+		attachment := &message.Attachments[i]
+		attachment.DownloadURL = fmt.Sprintf("/api/v1/orders/%d/messages/attachments/%d", message.OrderID, attachment.ID)
+	}
+}
+
+// GetOrderMessageAttachment returns an attachment from an order thread to a
+// party to that order.
+func (s *OrderService) GetOrderMessageAttachment(orderID, attachmentID, userID uint) (*models.OrderMessageAttachment, error) {
+	if _, err := s.getAccessibleOrder(orderID, userID); err != nil {
+		return nil, err
+	}
+	attachment, err := s.orderRepo.GetOrderMessageAttachment(orderID, attachmentID)
+	if err != nil {
+		return nil, errors.New("attachment not found")
+	}
+	return attachment, nil
+}
+
+// MarkOrderMessagesRead advances the caller's read marker to messageID, or to
+// the latest message in the thread when messageID is 0.
+func (s *OrderService) MarkOrderMessagesRead(orderID, userID, messageID uint) (*models.OrderMessageRead, error) {
+	if _, err := s.getAccessibleOrder(orderID, userID); err != nil {
+		return nil, err
+	}
+	latest, err := s.orderRepo.GetLatestOrderMessageID(orderID)
+	if err != nil {
+		return nil, errors.New("failed to load order messages")
+	}
+	if messageID == 0 || messageID > latest {
+		messageID = latest
+	}
+	marker, err := s.orderRepo.MarkMessagesRead(orderID, userID, messageID, time.Now().UTC())
+	if err != nil {
+		return nil, errors.New("failed to update read receipt")
+	}
+	return marker, nil
+}
+
+func (s *OrderService) GetOrderUnreadCount(orderID, userID uint) (int64, error) {
+	if _, err := s.getAccessibleOrder(orderID, userID); err != nil {
+		return 0, err
+	}
+	counts, err := s.orderRepo.CountUnreadMessages(userID, orderID)
+	if err != nil {
+		return 0, errors.New("failed to count unread messages")
+	}
+	if len(counts) == 0 {
+		return 0, nil
+	}
+	return counts[0].Unread, nil
+}
+
+func (s *OrderService) GetUnreadMessageSummary(userID uint) (*UnreadMessageSummary, error) {
+	counts, err := s.orderRepo.CountUnreadMessages(userID, 0)
+	if err != nil {
+		return nil, errors.New("failed to count unread messages")
+	}
+	summary := &UnreadMessageSummary{Orders: counts}
+	for _, item := range counts {
+		summary.Total += item.Unread
+	}
+	return summary, nil
 }
 
 func (s *OrderService) GetDisputeEvidences(orderID, userID uint) ([]models.DisputeEvidence, error) {
@@ -669,7 +1075,7 @@ func (s *OrderService) AddDisputeEvidence(orderID, userID uint, req AddDisputeEv
 	if logNote == "" {
 		logNote = "Evidence attached to dispute"
 	}
-	_ = s.orderRepo.CreateStatusLog(&models.OrderStatusLog{
+	statusLog := &models.OrderStatusLog{
 		OrderID:    orderID,
 		ActorID:    userID,
 		FromStatus: order.Status,
@@ -678,7 +1084,10 @@ func (s *OrderService) AddDisputeEvidence(orderID, userID uint, req AddDisputeEv
 		Category:   order.DisputeStatus,
 		Note:       logNote,
 		CreatedAt:  time.Now().UTC(),
-	})
+	}
+	if err := s.orderRepo.CreateStatusLog(statusLog); err == nil {
+		s.publishStatusLog(statusLog)
+	}
 	return s.orderRepo.GetDisputeEvidences(orderID)
 }
 
@@ -694,6 +1103,9 @@ func (s *OrderService) UpdateOrderStatus(orderID, userID uint, newStatus string)
 
 func (s *OrderService) UpdateOrderStatusWithDetails(orderID, userID uint, req UpdateOrderStatusRequest) (*models.Order, error) {
 	var updatedOrderID uint
+	var statusLog *models.OrderStatusLog
+	var farmerCancelled uint
+	var refundDue float64
 	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
 		var order models.Order
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
@@ -718,8 +1130,11 @@ func (s *OrderService) UpdateOrderStatusWithDetails(orderID, userID uint, req Up
 		if req.DeliverySlot != "" && !isAllowedDeliverySlot(req.DeliverySlot) {
 			return errors.New("invalid delivery slot")
 		}
-		if req.DisputeStatus != "" && !isAllowedDisputeStatus(req.DisputeStatus) {
-			return errors.New("invalid dispute status")
+		if req.DisputeStatus != "" || req.DisputeNote != "" {
+			if isDisputeLocked(order.DisputeStatus) {
+				return errors.New("dispute is with an admin and can no longer be changed")
+			}
+			return errors.New("disputes can only be changed through the dispute endpoints")
 		}
 
 		validStatuses := map[string][]string{
@@ -759,6 +1174,17 @@ func (s *OrderService) UpdateOrderStatusWithDetails(orderID, userID uint, req Up
 				if newStatus == "completed" && oldStatus != "out_for_delivery" {
 					return errors.New("order can be marked received only after out for delivery")
 				}
+				if newStatus == "cancelled" {
+					policy := effectiveCancellationPolicy(tx, order.OrderType, oldStatus)
+					if !policy.Allowed {
+						return errors.New(describeCancellationPolicy(policy, 0))
+					}
+					fee, err := chargeableCancellationFee(tx, &order, policy.FeePercent)
+					if err != nil {
+						return err
+					}
+					order.CancellationFee = fee
+				}
 			}
 
 			// Farmer-side actions: fulfillment/shipping operations.
@@ -766,6 +1192,19 @@ func (s *OrderService) UpdateOrderStatusWithDetails(orderID, userID uint, req Up
 				if newStatus == "completed" {
 					return errors.New("completed status must be confirmed by buyer as received")
 				}
+				if newStatus == "confirmed" && isPrepaidMethod(order.PaymentMethod) && order.PaymentStatus != "paid" {
+					return errors.New("prepaid orders can only be confirmed once payment is received")
+				}
+			}
+		}
+
+		if isStatusChange && newStatus == "cancelled" {
+			var collected int64
+			if err := tx.Model(&models.CODCollection{}).Where("order_id = ?", order.ID).Count(&collected).Error; err != nil {
+				return errors.New("failed to load collection")
+			}
+			if collected > 0 {
+				return errors.New("cash on delivery has already been collected for this order")
 			}
 		}
 
@@ -780,6 +1219,14 @@ func (s *OrderService) UpdateOrderStatusWithDetails(orderID, userID uint, req Up
 			order.CancellationReason = utils.SanitizeString(req.CancellationReason)
 			order.CancellationType = utils.SanitizeString(req.CancellationType)
 			order.CancellationNote = utils.SanitizeString(req.CancellationNote)
+			if isStatusChange {
+				order.CancelledBy = &userID
+				order.CancelledByRole = "buyer"
+				if isFarmerActor {
+					order.CancelledByRole = "farmer"
+					farmerCancelled = order.FarmerID
+				}
+			}
 		}
 		if req.DeliverySlot != "" {
 			order.DeliverySlot = utils.SanitizeString(req.DeliverySlot)
@@ -794,12 +1241,6 @@ func (s *OrderService) UpdateOrderStatusWithDetails(orderID, userID uint, req Up
 			}
 			order.DeliveryDate = &parsed
 		}
-		if req.DisputeStatus != "" {
-			order.DisputeStatus = utils.SanitizeString(req.DisputeStatus)
-		}
-		if req.DisputeNote != "" {
-			order.DisputeNote = utils.SanitizeString(req.DisputeNote)
-		}
 
 		now := time.Now().UTC()
 		if isStatusChange {
@@ -818,10 +1259,14 @@ func (s *OrderService) UpdateOrderStatusWithDetails(orderID, userID uint, req Up
 		}
 
 		if isStatusChange && newStatus == "cancelled" {
+			counted, err := adjustOrderVariantStock(tx, &order, -order.Quantity)
+			if err != nil {
+				return errors.New("failed to rollback inventory")
+			}
 			var product models.Product
 			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
 				Where("id = ?", order.ProductID).
-				First(&product).Error; err == nil {
+				First(&product).Error; err == nil && counted {
 				product.Quantity += order.Quantity
 				if product.Quantity > 0 {
 					product.Status = "active"
@@ -829,21 +1274,58 @@ func (s *OrderService) UpdateOrderStatusWithDetails(orderID, userID uint, req Up
 				if err := tx.Save(&product).Error; err != nil {
 					return errors.New("failed to rollback inventory")
 				}
+				if err := recordStockMovement(tx, &product, order.Quantity, models.StockMovement{
+					Kind:      "release",
+					VariantID: order.VariantID,
+					ActorID:   &userID,
+					OrderID:   &order.ID,
+					Reference: "cancellation",
+				}); err != nil {
+					return err
+				}
 			}
 		}
 
 		if err := tx.Save(&order).Error; err != nil {
 			return errors.New("failed to update order")
 		}
+		if isStatusChange && newStatus == "completed" {
+			if err := postOrderSettlement(tx, &order, userID); err != nil {
+				return err
+			}
+			if err := startCreditTerm(tx, &order, time.Now().UTC()); err != nil {
+				return err
+			}
+			var product models.Product
+			if err := tx.Where("id = ?", order.ProductID).First(&product).Error; err != nil {
+				return errors.New("product not found")
+			}
+			if err := recordStockMovement(tx, &product, 0, models.StockMovement{
+				Kind:      "sale",
+				Quantity:  order.Quantity,
+				VariantID: order.VariantID,
+				ActorID:   &userID,
+				OrderID:   &order.ID,
+				Reference: "order_completed",
+			}); err != nil {
+				return err
+			}
+			if _, err := issueTaxInvoice(tx, &order); err != nil {
+				return err
+			}
+		}
+		if isStatusChange && newStatus == "cancelled" {
+			providerRefund, err := postOrderCancellation(tx, &order, userID)
+			if err != nil {
+				return err
+			}
+			refundDue = providerRefund
+		}
 
 		logReason := utils.SanitizeString(req.CancellationReason)
 		logCategory := utils.SanitizeString(req.CancellationType)
 		logNote := utils.SanitizeString(req.CancellationNote)
-		if req.DisputeStatus != "" {
-			logReason = "dispute_update"
-			logCategory = utils.SanitizeString(req.DisputeStatus)
-			logNote = utils.SanitizeString(req.DisputeNote)
-		} else if isStatusChange {
+		if isStatusChange {
 			if logReason == "" {
 				logReason = "status_update"
 			}
@@ -854,9 +1336,12 @@ func (s *OrderService) UpdateOrderStatusWithDetails(orderID, userID uint, req Up
 				logReason = "buyer_received"
 				logNote = "Buyer confirmed delivery received"
 			}
+			if newStatus == "cancelled" && order.CancellationFee > 0 {
+				logNote = strings.TrimSpace(logNote + " (cancellation fee " + formatQuantity(order.CancellationFee) + ")")
+			}
 		}
 
-		if err := tx.Create(&models.OrderStatusLog{
+		statusLog = &models.OrderStatusLog{
 			OrderID:    order.ID,
 			ActorID:    userID,
 			FromStatus: oldStatus,
@@ -865,7 +1350,8 @@ func (s *OrderService) UpdateOrderStatusWithDetails(orderID, userID uint, req Up
 			Category:   logCategory,
 			Note:       logNote,
 			CreatedAt:  time.Now().UTC(),
-		}).Error; err != nil {
+		}
+		if err := tx.Create(statusLog).Error; err != nil {
 			return errors.New("failed to create status log")
 		}
 
@@ -875,32 +1361,57 @@ func (s *OrderService) UpdateOrderStatusWithDetails(orderID, userID uint, req Up
 	if err != nil {
 		return nil, err
 	}
+	s.publishStatusLog(statusLog)
+	if farmerCancelled > 0 {
+		// Best effort: the score is recalculated again whenever the profile is read.
+		_ = NewTrustScoreService(s.userRepo, s.orderRepo).UpdateTrustScore(farmerCancelled)
+	}
+	if err := s.payments.RequestRefund(updatedOrderID, refundDue); err != nil {
+		return nil, errors.New("order cancelled but refund request failed: " + err.Error())
+	}
 
 	return s.orderRepo.GetByID(updatedOrderID)
 }
 
-func (s *OrderService) CancelOrder(orderID, userID uint) error {
+func (s *OrderService) CancelOrder(orderID, userID uint, req CancelOrderRequest) (*models.Order, error) {
 	order, err := s.orderRepo.GetByID(orderID)
 	if err != nil {
-		return errors.New("order not found")
+		return nil, errors.New("order not found")
 	}
 
 	// Check authorization
 	if order.BuyerID != userID && order.FarmerID != userID {
-		return errors.New("unauthorized: you can only cancel your own orders")
+		return nil, errors.New("unauthorized: you can only cancel your own orders")
 	}
 
 	// Can only cancel if not completed
 	if order.Status == "completed" {
-		return errors.New("cannot cancel completed order")
+		return nil, errors.New("cannot cancel completed order")
+	}
+
+	cancellationType := strings.TrimSpace(req.CancellationType)
+	reason := strings.TrimSpace(req.Reason)
+	if order.BuyerID == userID {
+		if cancellationType == "" {
+			cancellationType = "buyer_request"
+		}
+		if reason == "" {
+			reason = "Cancelled by buyer"
+		}
+	} else {
+		if cancellationType == "" {
+			cancellationType = "other"
+		}
+		if reason == "" {
+			reason = "Cancelled by farmer"
+		}
 	}
-	_, err = s.UpdateOrderStatusWithDetails(orderID, userID, UpdateOrderStatusRequest{
+	return s.UpdateOrderStatusWithDetails(orderID, userID, UpdateOrderStatusRequest{
 		Status:             "cancelled",
-		CancellationReason: "Cancelled by user",
-		CancellationType:   "other",
-		CancellationNote:   "Cancelled by user",
+		CancellationReason: reason,
+		CancellationType:   cancellationType,
+		CancellationNote:   req.Note,
 	})
-	return err
 }
 
 func (s *OrderService) GetFarmerPayoutSummary(farmerID uint) (*FarmerPayoutSummary, error) {
@@ -908,19 +1419,43 @@ func (s *OrderService) GetFarmerPayoutSummary(farmerID uint) (*FarmerPayoutSumma
 	if err != nil {
 		return nil, errors.New("failed to load farmer orders")
 	}
+	ledger, err := loadOrderLedgerTotals(s.orderRepo, orders)
+	if err != nil {
+		return nil, err
+	}
+	statement, err := buildFarmerLedgerStatement(s.orderRepo, farmerID)
+	if err != nil {
+		return nil, err
+	}
 
 	summary := &FarmerPayoutSummary{Currency: "INR"}
 	for _, order := range orders {
+		totals := ledger[order.ID]
 		if order.Status == "completed" {
 			summary.CompletedOrders++
-			summary.TotalGross += order.TotalPrice
+			summary.TotalGross += totals.Paid
+			if order.DiscountFundedBy == "farmer" {
+				summary.FarmerDiscounts += order.DiscountAmount
+			}
 		}
 		if order.Status == "confirmed" || order.Status == "packed" || order.Status == "out_for_delivery" {
 			summary.PendingSettlement++
 		}
-	}
-	summary.PlatformFee = summary.TotalGross * 0.05
-	summary.NetPayout = summary.TotalGross - summary.PlatformFee
+		summary.Refunds += totals.Refunded
+		summary.CancellationFees += totals.CancellationFee
+		summary.PromotionSubsidy += totals.Subsidy
+		summary.PlatformFee += totals.PlatformFee
+		summary.NetPayout += totals.FarmerShare
+	}
+	summary.FarmerDiscounts = roundMoney(summary.FarmerDiscounts)
+	summary.PromotionSubsidy = roundMoney(summary.PromotionSubsidy)
+	summary.TotalGross = roundMoney(summary.TotalGross)
+	summary.Refunds = roundMoney(summary.Refunds)
+	summary.CancellationFees = roundMoney(summary.CancellationFees)
+	summary.PlatformFee = roundMoney(summary.PlatformFee)
+	summary.NetPayout = roundMoney(summary.NetPayout)
+	summary.PaidOut = statement.PaidOut
+	summary.Balance = statement.Balance
 	return summary, nil
 }
 
@@ -937,8 +1472,15 @@ func (s *OrderService) GetFarmerInvoice(orderID, farmerID uint) (*FarmerInvoice,
 	if order.Quantity > 0 {
 		unitPrice = order.TotalPrice / order.Quantity
 	}
-	fee := order.TotalPrice * 0.05
-	net := order.TotalPrice - fee
+	ledger, err := loadOrderLedgerTotals(s.orderRepo, []models.Order{*order})
+	if err != nil {
+		return nil, err
+	}
+	totals := ledger[order.ID]
+	taxInvoice, err := ensureTaxInvoice(s.orderRepo.GetDB(), order.ID)
+	if err != nil {
+		return nil, err
+	}
 
 	invoice := &FarmerInvoice{
 		OrderID:            order.ID,
@@ -949,11 +1491,17 @@ func (s *OrderService) GetFarmerInvoice(orderID, farmerID uint) (*FarmerInvoice,
 		Unit:               order.Product.Unit,
 		UnitPrice:          unitPrice,
 		GrossAmount:        order.TotalPrice,
-		PlatformFee:        fee,
-		NetPayout:          net,
+		RefundAmount:       totals.Refunded,
+		CancellationFee:    totals.CancellationFee,
+		DiscountAmount:     order.DiscountAmount,
+		DiscountFundedBy:   order.DiscountFundedBy,
+		PromotionSubsidy:   totals.Subsidy,
+		PlatformFee:        totals.PlatformFee,
+		NetPayout:          totals.FarmerShare,
 		CancellationReason: order.CancellationReason,
 		CreatedAt:          order.CreatedAt.Format(time.RFC3339),
 		Currency:           "INR",
+		TaxInvoice:         taxInvoice,
 	}
 	if order.CompletedAt != nil {
 		completed := order.CompletedAt.Format(time.RFC3339)
@@ -973,7 +1521,7 @@ func (s *OrderService) GetFarmerAnalytics(farmerID uint) (*FarmerAnalyticsSummar
 		return nil, errors.New("failed to load farmer analytics")
 	}
 
-	summary := &FarmerAnalyticsSummary{Currency: "INR"}
+	summary := &FarmerAnalyticsSummary{VolumeSold: map[string]float64{}, Currency: "INR"}
 	summary.OrdersTotal = len(orders)
 
 	now := time.Now().UTC()
@@ -981,11 +1529,13 @@ func (s *OrderService) GetFarmerAnalytics(farmerID uint) (*FarmerAnalyticsSummar
 	lastMonthStart := thisMonthStart.AddDate(0, -1, 0)
 
 	type topAgg struct {
-		id     uint
-		name   string
-		orders int
-		units  float64
-		rev    float64
+		id       uint
+		name     string
+		unit     string
+		orders   int
+		base     float64
+		baseUnit string
+		rev      float64
 	}
 	topMap := map[uint]*topAgg{}
 	completedRevenue := 0.0
@@ -1001,12 +1551,16 @@ func (s *OrderService) GetFarmerAnalytics(farmerID uint) (*FarmerAnalyticsSummar
 			if !order.CreatedAt.Before(lastMonthStart) && order.CreatedAt.Before(thisMonthStart) {
 				summary.LastMonthRevenue += order.TotalPrice
 			}
+			// Volumes use the unit the order was placed in, so a listing
+			// that later changes unit does not restate past sales.
+			baseUnit, factor := models.BaseUnitOf(orderUnit(&order))
 			if _, ok := topMap[order.ProductID]; !ok {
-				topMap[order.ProductID] = &topAgg{id: order.ProductID, name: order.Product.CropName}
+				topMap[order.ProductID] = &topAgg{id: order.ProductID, name: order.Product.CropName, unit: order.Product.Unit, baseUnit: baseUnit}
 			}
 			topMap[order.ProductID].orders++
-			topMap[order.ProductID].units += order.Quantity
+			topMap[order.ProductID].base += order.Quantity * factor
 			topMap[order.ProductID].rev += order.TotalPrice
+			summary.VolumeSold[baseUnit] += order.Quantity * factor
 		case "cancelled":
 			summary.CancelledOrders++
 		default:
@@ -1026,12 +1580,16 @@ func (s *OrderService) GetFarmerAnalytics(farmerID uint) (*FarmerAnalyticsSummar
 
 	tops := make([]FarmerTopProduct, 0, len(topMap))
 	for _, item := range topMap {
+		_, factor := models.BaseUnitOf(item.unit)
 		tops = append(tops, FarmerTopProduct{
-			ProductID:   item.id,
-			ProductName: item.name,
-			OrdersCount: item.orders,
-			UnitsSold:   item.units,
-			Revenue:     item.rev,
+			ProductID:     item.id,
+			ProductName:   item.name,
+			OrdersCount:   item.orders,
+			UnitsSold:     item.base / factor,
+			Unit:          item.unit,
+			BaseUnitsSold: item.base,
+			BaseUnit:      item.baseUnit,
+			Revenue:       item.rev,
 		})
 	}
 	sort.Slice(tops, func(i, j int) bool { return tops[i].Revenue > tops[j].Revenue })
@@ -1087,11 +1645,23 @@ func (s *OrderService) GetFarmerNotifications(farmerID uint) ([]FarmerNotificati
 
 	for _, item := range harvestRequests {
 		if item.Status == "pending" {
+			title := "New Harvest Request"
+			message := "Harvest request #" + strconv.FormatUint(uint64(item.ID), 10) + " asks for " + strconv.FormatFloat(item.RequestedQuantity, 'f', 1, 64) + " " + item.Product.Unit + " of " + item.Product.CropName + "."
+			if item.ResponseDeadline != nil {
+				remaining := item.ResponseDeadline.Sub(now)
+				switch {
+				case remaining <= 0:
+					title = "Harvest Request Overdue (CRITICAL)"
+				case remaining <= harvestReminderLead:
+					title = "Harvest Request Due Soon (HIGH)"
+				}
+				message += " Respond by " + item.ResponseDeadline.Format(time.RFC3339) + " (open for " + strconv.Itoa(int(now.Sub(item.CreatedAt).Hours())) + "h)."
+			}
 			items = append(items, FarmerNotificationItem{
 				ID:        "harvest-pending-" + strconv.FormatUint(uint64(item.ID), 10),
 				Type:      "harvest_request",
-				Title:     "New Harvest Request",
-				Message:   "Harvest request #" + strconv.FormatUint(uint64(item.ID), 10) + " asks for " + strconv.FormatFloat(item.RequestedQuantity, 'f', 1, 64) + " " + item.Product.Unit + " of " + item.Product.CropName + ".",
+				Title:     title,
+				Message:   message,
 				CreatedAt: item.CreatedAt.Format(time.RFC3339),
 			})
 		}
@@ -1116,6 +1686,15 @@ func (s *OrderService) GetFarmerNotifications(farmerID uint) ([]FarmerNotificati
 				CreatedAt: product.UpdatedAt.Format(time.RFC3339),
 			})
 		}
+		if product.Status == "expired" && product.ExpiredAt != nil && product.ExpiredAt.After(now.Add(-72*time.Hour)) {
+			items = append(items, FarmerNotificationItem{
+				ID:        "expired-" + strconv.FormatUint(uint64(product.ID), 10),
+				Type:      "inventory",
+				Title:     "Listing Expired",
+				Message:   product.CropName + " passed its best-before date and is hidden from buyers. Update its dates to list it again.",
+				CreatedAt: product.ExpiredAt.Format(time.RFC3339),
+			})
+		}
 	}
 
 	sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt > items[j].CreatedAt })
@@ -1220,105 +1799,6 @@ func (s *OrderService) GetFarmerDisputesPaginated(farmerID uint, page, limit int
 	return items, total, nil
 }
 
-func (s *OrderService) OpenDispute(orderID, farmerID uint, note string) (*models.Order, error) {
-	var updatedOrderID uint
-	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
-		var order models.Order
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error; err != nil {
-			return errors.New("order not found")
-		}
-		if order.FarmerID != farmerID {
-			return errors.New("unauthorized: you can only update your own orders")
-		}
-		if order.Status != "completed" {
-			return errors.New("dispute can only be opened for completed orders")
-		}
-		if order.DisputeStatus != "none" && order.DisputeStatus != "" {
-			return errors.New("dispute already exists for this order")
-		}
-
-		order.DisputeStatus = "open"
-		order.DisputeNote = utils.SanitizeString(note)
-		if err := tx.Save(&order).Error; err != nil {
-			return errors.New("failed to open dispute")
-		}
-
-		if err := tx.Create(&models.OrderStatusLog{
-			OrderID:    order.ID,
-			ActorID:    farmerID,
-			FromStatus: order.Status,
-			ToStatus:   order.Status,
-			Reason:     "dispute_update",
-			Category:   "open",
-			Note:       order.DisputeNote,
-			CreatedAt:  time.Now().UTC(),
-		}).Error; err != nil {
-			return errors.New("failed to log dispute update")
-		}
-
-		updatedOrderID = order.ID
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	return s.orderRepo.GetByID(updatedOrderID)
-}
-
-func (s *OrderService) ResolveDispute(orderID, farmerID uint, note string) (*models.Order, error) {
-	return s.updateDisputeStatus(orderID, farmerID, "resolved", note)
-}
-
-func (s *OrderService) RejectDispute(orderID, farmerID uint, note string) (*models.Order, error) {
-	return s.updateDisputeStatus(orderID, farmerID, "rejected", note)
-}
-
-func (s *OrderService) updateDisputeStatus(orderID, farmerID uint, nextStatus, note string) (*models.Order, error) {
-	if strings.TrimSpace(note) == "" {
-		return nil, errors.New("resolution reason is required")
-	}
-
-	var updatedOrderID uint
-	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
-		var order models.Order
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error; err != nil {
-			return errors.New("order not found")
-		}
-		if order.FarmerID != farmerID {
-			return errors.New("unauthorized: you can only update your own orders")
-		}
-		if order.DisputeStatus != "open" {
-			return errors.New("only open disputes can be updated")
-		}
-
-		order.DisputeStatus = nextStatus
-		order.DisputeNote = utils.SanitizeString(note)
-		if err := tx.Save(&order).Error; err != nil {
-			return errors.New("failed to update dispute")
-		}
-
-		if err := tx.Create(&models.OrderStatusLog{
-			OrderID:    order.ID,
-			ActorID:    farmerID,
-			FromStatus: order.Status,
-			ToStatus:   order.Status,
-			Reason:     "dispute_update",
-			Category:   nextStatus,
-			Note:       order.DisputeNote,
-			CreatedAt:  time.Now().UTC(),
-		}).Error; err != nil {
-			return errors.New("failed to log dispute update")
-		}
-
-		updatedOrderID = order.ID
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	return s.orderRepo.GetByID(updatedOrderID)
-}
-
 func (s *OrderService) SubmitBuyerReview(orderID, buyerID uint, req SubmitReviewRequest) (*models.Review, error) {
 	if req.Rating < 1 || req.Rating > 5 {
 		return nil, errors.New("rating must be between 1 and 5")
@@ -1511,24 +1991,32 @@ func (s *OrderService) GetFarmerPeriodSummary(farmerID uint, period string) (*Fa
 		Currency:    "INR",
 	}
 
+	ledger, err := loadOrderLedgerTotals(s.orderRepo, orders)
+	if err != nil {
+		return nil, err
+	}
 	for _, order := range orders {
 		if order.CreatedAt.Before(start) || order.CreatedAt.After(end) {
 			continue
 		}
+		totals := ledger[order.ID]
 		summary.OrdersCount++
 		switch order.Status {
 		case "completed":
 			summary.CompletedOrders++
-			summary.GrossRevenue += order.TotalPrice
+			summary.GrossRevenue += totals.Paid
 		case "cancelled":
 			summary.CancelledOrders++
 		default:
 			summary.PendingOrders++
 		}
+		summary.PlatformFees += totals.PlatformFee
+		summary.NetPayout += totals.FarmerShare
 	}
 
-	summary.PlatformFees = summary.GrossRevenue * 0.05
-	summary.NetPayout = summary.GrossRevenue - summary.PlatformFees
+	summary.GrossRevenue = roundMoney(summary.GrossRevenue)
+	summary.PlatformFees = roundMoney(summary.PlatformFees)
+	summary.NetPayout = roundMoney(summary.NetPayout)
 	if summary.OrdersCount > 0 {
 		summary.AverageOrderSize = summary.GrossRevenue / float64(summary.OrdersCount)
 	}
@@ -1546,7 +2034,7 @@ func (s *OrderService) BuildFarmerCSVReport(farmerID uint, reportType string) (s
 
 	switch reportType {
 	case "orders":
-		if err := writer.Write([]string{"order_id", "date", "buyer", "product", "quantity", "unit", "status", "total_inr"}); err != nil {
+		if err := writer.Write([]string{"order_id", "date", "buyer", "product", "quantity", "unit", "base_quantity", "base_unit", "status", "total_inr"}); err != nil {
 			return "", err
 		}
 		for _, o := range orders {
@@ -1556,7 +2044,9 @@ func (s *OrderService) BuildFarmerCSVReport(farmerID uint, reportType string) (s
 				o.Buyer.Name,
 				o.Product.CropName,
 				strconv.FormatFloat(o.Quantity, 'f', 2, 64),
-				o.Product.Unit,
+				orderUnit(&o),
+				strconv.FormatFloat(models.ToBaseQuantity(o.Quantity, orderUnit(&o)), 'f', 3, 64),
+				baseUnitName(orderUnit(&o)),
 				o.Status,
 				strconv.FormatFloat(o.TotalPrice, 'f', 2, 64),
 			}
@@ -1565,19 +2055,24 @@ func (s *OrderService) BuildFarmerCSVReport(farmerID uint, reportType string) (s
 			}
 		}
 	case "payouts":
-		if err := writer.Write([]string{"order_id", "date", "status", "gross_inr", "fee_inr", "net_inr"}); err != nil {
+		ledger, err := loadOrderLedgerTotals(s.orderRepo, orders)
+		if err != nil {
+			return "", err
+		}
+		if err := writer.Write([]string{"order_id", "date", "status", "gross_inr", "refund_inr", "cancellation_fee_inr", "fee_inr", "net_inr"}); err != nil {
 			return "", err
 		}
 		for _, o := range orders {
-			fee := o.TotalPrice * 0.05
-			net := o.TotalPrice - fee
+			totals := ledger[o.ID]
 			row := []string{
 				strconv.FormatUint(uint64(o.ID), 10),
 				o.CreatedAt.Format(time.RFC3339),
 				o.Status,
-				strconv.FormatFloat(o.TotalPrice, 'f', 2, 64),
-				strconv.FormatFloat(fee, 'f', 2, 64),
-				strconv.FormatFloat(net, 'f', 2, 64),
+				strconv.FormatFloat(totals.Paid, 'f', 2, 64),
+				strconv.FormatFloat(totals.Refunded, 'f', 2, 64),
+				strconv.FormatFloat(totals.CancellationFee, 'f', 2, 64),
+				strconv.FormatFloat(totals.PlatformFee, 'f', 2, 64),
+				strconv.FormatFloat(totals.FarmerShare, 'f', 2, 64),
 			}
 			if err := writer.Write(row); err != nil {
 				return "", err
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestCreateOrderReservesStockAndCancelRollsBack(t *testing.T) {
+	ctx := setupTestCtx(t)
+	order := createOrderForTest(t, ctx)
+
+	productAfterOrder, err := ctx.productRepo.GetByID(ctx.productID)
+	if err != nil {
+		t.Fatalf("failed to fetch product after order: %v", err)
+	}
+	if productAfterOrder.Quantity != 8 {
+		t.Fatalf("expected quantity 8 after reserve, got %v", productAfterOrder.Quantity)
+	}
+
+	_, err = ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{
+		Status:             "cancelled",
+		CancellationType:   "other",
+		CancellationReason: "test rollback",
+		CancellationNote:   "rollback note",
+	})
+	if err != nil {
+		t.Fatalf("failed to cancel order: %v", err)
+	}
+
+	productAfterCancel, err := ctx.productRepo.GetByID(ctx.productID)
+	if err != nil {
+		t.Fatalf("failed to fetch product after cancel: %v", err)
+	}
+	if productAfterCancel.Quantity != 10 {
+		t.Fatalf("expected quantity 10 after rollback, got %v", productAfterCancel.Quantity)
+	}
+}
+
+func TestOrderStatusTransitionMatrix(t *testing.T) {
+	ctx := setupTestCtx(t)
+	order := createOrderForTest(t, ctx)
+
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{
+		Status: "completed",
+	}); err == nil {
+		t.Fatalf("expected invalid transition error for pending -> completed")
+	}
+
+	completeOrderForTest(t, ctx, order.ID)
+}
+
+func TestBuyerAndFarmerStatusPermissions(t *testing.T) {
+	ctx := setupTestCtx(t)
+	order := createOrderForTest(t, ctx)
+
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.buyerID, UpdateOrderStatusRequest{
+		Status: "confirmed",
+	}); err == nil {
+		t.Fatalf("expected buyer confirmation attempt to fail")
+	}
+
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.buyerID, UpdateOrderStatusRequest{
+		Status: "completed",
+	}); err == nil {
+		t.Fatalf("expected buyer to be blocked from completing before out_for_delivery")
+	}
+
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{
+		Status: "confirmed",
+	}); err != nil {
+		t.Fatalf("expected farmer confirmation to succeed: %v", err)
+	}
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{
+		Status: "packed",
+	}); err != nil {
+		t.Fatalf("expected farmer packing to succeed: %v", err)
+	}
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{
+		Status: "out_for_delivery",
+	}); err != nil {
+		t.Fatalf("expected farmer delivery handoff to succeed: %v", err)
+	}
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{
+		Status: "completed",
+	}); err == nil {
+		t.Fatalf("expected farmer to be blocked from marking order completed")
+	}
+}
+
+func TestCreateBulkOrderAndHarvestRequestFlow(t *testing.T) {
+	ctx := setupTestCtx(t)
+
+	bulkOrder, err := ctx.orderSvc.CreateBulkOrder(ctx.buyerID, CreateOrderRequest{
+		ProductID:        ctx.productID,
+		Quantity:         5,
+		DeliveryAddress:  "Bulk address",
+		BuyerNote:        "Need wholesale quantity",
+		PaymentMethod:    "upi",
+		PaymentReference: "buyer@upi",
+	})
+	if err != nil {
+		t.Fatalf("failed to create bulk order: %v", err)
+	}
+	if bulkOrder.OrderType != "bulk" {
+		t.Fatalf("expected bulk order type, got %s", bulkOrder.OrderType)
+	}
+	if _, err := ctx.orderSvc.RequestOrderAmendment(bulkOrder.ID, ctx.buyerID, RequestOrderAmendmentRequest{Quantity: 3}); err == nil {
+		t.Fatalf("expected an amendment below the bulk minimum to be rejected")
+	}
+	amendment, err := ctx.orderSvc.RequestOrderAmendment(bulkOrder.ID, ctx.buyerID, RequestOrderAmendmentRequest{Quantity: 6})
+	if err != nil {
+		t.Fatalf("failed to request amendment: %v", err)
+	}
+	ctx.db.Model(&models.Product{}).Where("id = ?", ctx.productID).Update("minimum_bulk_quantity", 7)
+	if _, err := ctx.orderSvc.RespondToOrderAmendment(bulkOrder.ID, amendment.ID, ctx.farmerID, RespondOrderAmendmentRequest{Action: "approve"}); err == nil {
+		t.Fatalf("expected approval below the current bulk minimum to be rejected")
+	}
+
+	requestItem, err := ctx.orderSvc.CreateHarvestRequest(ctx.buyerID, CreateHarvestRequestRequest{
+		ProductID:            ctx.productID,
+		RequestedQuantity:    3,
+		PreferredHarvestDate: time.Now().UTC().AddDate(0, 0, 3).Format(time.RFC3339),
+		DeliveryAddress:      "Harvest address",
+		BuyerNote:            "Fresh harvest requested",
+	})
+	if err != nil {
+		t.Fatalf("failed to create harvest request: %v", err)
+	}
+	if requestItem.Status != "pending" {
+		t.Fatalf("expected pending harvest request, got %s", requestItem.Status)
+	}
+
+	if _, err := ctx.orderSvc.UpdateHarvestRequest(requestItem.ID, ctx.farmerID, UpdateHarvestRequestRequest{
+		Status:             "accepted",
+		FarmerResponseNote: "Can fulfill",
+	}); err != nil {
+		t.Fatalf("failed to accept harvest request: %v", err)
+	}
+	respondedAt := time.Now().UTC().Add(-2 * time.Hour).Truncate(time.Second)
+	ctx.db.Model(&models.HarvestRequest{}).Where("id = ?", requestItem.ID).Update("responded_at", respondedAt)
+
+	converted, err := ctx.orderSvc.ConvertHarvestRequestToOrder(requestItem.ID, ctx.buyerID, CreateOrderRequest{})
+	if err != nil {
+		t.Fatalf("failed to convert harvest request: %v", err)
+	}
+	if converted.OrderType != "harvest_request" {
+		t.Fatalf("expected harvest_request order type, got %s", converted.OrderType)
+	}
+	if converted.SourceRequestID == nil || *converted.SourceRequestID != requestItem.ID {
+		t.Fatalf("expected converted order to reference harvest request")
+	}
+	var request models.HarvestRequest
+	if err := ctx.db.First(&request, requestItem.ID).Error; err != nil {
+		t.Fatalf("failed to reload harvest request: %v", err)
+	}
+	if request.RespondedAt == nil || !request.RespondedAt.Equal(respondedAt) || request.ConvertedAt == nil {
+		t.Fatalf("expected conversion to keep the farmer's response time: responded=%v converted=%v", request.RespondedAt, request.ConvertedAt)
+	}
+}
+
+func TestHarvestCounterProposalSetsAgreedPrice(t *testing.T) {
+	ctx := setupTestCtx(t)
+
+	requestItem, err := ctx.orderSvc.CreateHarvestRequest(ctx.buyerID, CreateHarvestRequestRequest{
+		ProductID:            ctx.productID,
+		RequestedQuantity:    4,
+		PreferredHarvestDate: time.Now().UTC().AddDate(0, 0, 3).Format(time.RFC3339),
+		DeliveryAddress:      "Harvest address",
+	})
+	if err != nil {
+		t.Fatalf("failed to create harvest request: %v", err)
+	}
+
+	countered, err := ctx.orderSvc.CounterHarvestRequest(requestItem.ID, ctx.farmerID, CounterHarvestRequestRequest{
+		Quantity:     3,
+		PricePerUnit: 90,
+		Note:         "Only 3kg ready this week",
+	})
+	if err != nil {
+		t.Fatalf("failed to counter harvest request: %v", err)
+	}
+	if countered.Status != "countered" {
+		t.Fatalf("expected countered status, got %s", countered.Status)
+	}
+	if _, err := ctx.orderSvc.UpdateHarvestRequest(requestItem.ID, ctx.farmerID, UpdateHarvestRequestRequest{Status: "accepted"}); err == nil {
+		t.Fatalf("expected farmer to be blocked from accepting while counter is open")
+	}
+	if _, err := ctx.orderSvc.ConvertHarvestRequestToOrder(requestItem.ID, ctx.buyerID, CreateOrderRequest{}); err == nil {
+		t.Fatalf("expected conversion to fail before the counter is accepted")
+	}
+
+	accepted, err := ctx.orderSvc.RespondToHarvestCounter(requestItem.ID, ctx.buyerID, RespondHarvestCounterRequest{Action: "accept"})
+	if err != nil {
+		t.Fatalf("failed to accept counter-proposal: %v", err)
+	}
+	if accepted.Status != "accepted" || accepted.AgreedPricePerUnit == nil || *accepted.AgreedPricePerUnit != 90 {
+		t.Fatalf("expected agreed price 90 after acceptance, got %+v", accepted)
+	}
+
+	if _, err := ctx.orderSvc.ConvertHarvestRequestToOrder(requestItem.ID, ctx.buyerID, CreateOrderRequest{Quantity: 4}); err == nil {
+		t.Fatalf("expected conversion above the agreed quantity to fail")
+	}
+	converted, err := ctx.orderSvc.ConvertHarvestRequestToOrder(requestItem.ID, ctx.buyerID, CreateOrderRequest{})
+	if err != nil {
+		t.Fatalf("failed to convert harvest request: %v", err)
+	}
+	if converted.Quantity != 3 || converted.TotalPrice != 270 {
+		t.Fatalf("expected 3 units at the negotiated price, got quantity=%v total=%v", converted.Quantity, converted.TotalPrice)
+	}
+}
+
+func TestOrderMessagesPaginationAndUnreadCounts(t *testing.T) {
+	ctx := setupTestCtx(t)
+	order := createOrderForTest(t, ctx)
+
+	for i := 0; i < 5; i++ {
+		if _, err := ctx.orderSvc.SendOrderMessage(order.ID, ctx.buyerID, SendOrderMessageRequest{Message: "question"}); err != nil {
+			t.Fatalf("failed to send message: %v", err)
+		}
+	}
+
+	farmerUnread, err := ctx.orderSvc.GetOrderUnreadCount(order.ID, ctx.farmerID)
+	if err != nil || farmerUnread != 5 {
+		t.Fatalf("expected farmer to have 5 unread, got %d err=%v", farmerUnread, err)
+	}
+	if _, err := ctx.orderSvc.MarkOrderMessagesRead(order.ID, ctx.farmerID, 2); err != nil {
+		t.Fatalf("failed to mark messages read: %v", err)
+	}
+	if farmerUnread, _ = ctx.orderSvc.GetOrderUnreadCount(order.ID, ctx.farmerID); farmerUnread != 3 {
+		t.Fatalf("expected farmer to have 3 unread after reading two, got %d", farmerUnread)
+	}
+	if _, err := ctx.orderSvc.SendOrderMessage(order.ID, ctx.farmerID, SendOrderMessageRequest{
+		Attachments: []OrderMessageAttachment{{URL: "/uploads/attachments/invoice.pdf", FileName: "invoice.pdf", ContentType: "application/pdf"}},
+	}); err != nil {
+		t.Fatalf("failed to send attachment-only message: %v", err)
+	}
+	if _, err := ctx.orderSvc.SendOrderMessage(order.ID, ctx.farmerID, SendOrderMessageRequest{
+		Attachments: []OrderMessageAttachment{{URL: "https://example.com/x.pdf"}},
+	}); err == nil {
+		t.Fatalf("expected attachment outside the upload pipeline to be rejected")
+	}
+	for _, url := range []string{"/uploads/products/a.jpg", "/uploads/attachments/../../config.env"} {
+		if _, err := ctx.orderSvc.SendOrderMessage(order.ID, ctx.farmerID, SendOrderMessageRequest{
+			Attachments: []OrderMessageAttachment{{URL: url}},
+		}); err == nil {
+			t.Fatalf("expected %s to be rejected as an attachment", url)
+		}
+	}
+
+	latest, err := ctx.orderSvc.GetOrderMessages(order.ID, ctx.buyerID, 0, 0, 4)
+	if err != nil {
+		t.Fatalf("failed to load messages: %v", err)
+	}
+	if len(latest.Items) != 4 || !latest.HasMore || latest.Items[3].Attachments == nil || len(latest.Items[3].Attachments) != 1 {
+		t.Fatalf("unexpected latest page: %+v", latest)
+	}
+	attachment := latest.Items[3].Attachments[0]
+	if attachment.DownloadURL != fmt.Sprintf("/api/v1/orders/%d/messages/attachments/%d", order.ID, attachment.ID) {
+		t.Fatalf("expected an authenticated download link, got %q", attachment.DownloadURL)
+	}
+	if found, err := ctx.orderSvc.GetOrderMessageAttachment(order.ID, attachment.ID, ctx.buyerID); err != nil || found.URL != "/uploads/attachments/invoice.pdf" {
+		t.Fatalf("expected the buyer to reach the attachment: %+v err=%v", found, err)
+	}
+	if _, err := ctx.orderSvc.GetOrderMessageAttachment(order.ID, attachment.ID, ctx.buyerID+ctx.farmerID+100); err == nil {
+		t.Fatalf("expected an outsider to be refused the attachment")
+	}
+	other := createOrderForTest(t, ctx)
+	if _, err := ctx.orderSvc.GetOrderMessageAttachment(other.ID, attachment.ID, ctx.buyerID); err == nil {
+		t.Fatalf("expected an attachment to be reachable only through its own order")
+	}
+	older, err := ctx.orderSvc.GetOrderMessages(order.ID, ctx.buyerID, latest.NextCursor, 0, 4)
+	if err != nil {
+		t.Fatalf("failed to load older messages: %v", err)
+	}
+	if len(older.Items) != 2 || older.HasMore || older.Items[1].ID >= latest.Items[0].ID {
+		t.Fatalf("unexpected older page: %+v", older)
+	}
+
+	buyerSummary, err := ctx.orderSvc.GetUnreadMessageSummary(ctx.buyerID)
+	if err != nil || buyerSummary.Total != 1 {
+		t.Fatalf("expected buyer to have 1 unread, got %+v err=%v", buyerSummary, err)
+	}
+
+	if _, err := ctx.orderSvc.MarkOrderMessagesRead(order.ID, ctx.buyerID, 0); err != nil {
+		t.Fatalf("failed to mark messages read: %v", err)
+	}
+	buyerSummary, err = ctx.orderSvc.GetUnreadMessageSummary(ctx.buyerID)
+	if err != nil || buyerSummary.Total != 0 {
+		t.Fatalf("expected buyer unread to reset, got %+v err=%v", buyerSummary, err)
+	}
+}
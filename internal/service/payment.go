@@ -0,0 +1,160 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PaymentIntent is a provider-side request to collect money for one order.
+type PaymentIntent struct {
+	Provider     string  `json:"provider"`
+	IntentID     string  `json:"intent_id"`
+	ClientSecret string  `json:"client_secret"`
+	Amount       float64 `json:"amount"`
+	Currency     string  `json:"currency"`
+	Method       string  `json:"method"`
+}
+
+// PaymentWebhookEvent is the provider-neutral form of a single payment status
+// change received from a provider webhook.
+type PaymentWebhookEvent struct {
+	ExternalEventID string
+	IntentID        string
+	Status          string // paid/failed/refunded
+	Amount          float64
+	OccurredAt      time.Time
+}
+
+// PaymentProvider is implemented once per payment gateway and registered on
+// the PaymentService under its code.
+type PaymentProvider interface {
+	Code() string
+	CreateIntent(orderID uint, amount float64, currency, method string) (*PaymentIntent, error)
+	VerifyWebhook(payload []byte, signature string) error
+	ParseWebhook(payload []byte) ([]PaymentWebhookEvent, error)
+	// Refund returns amount to the buyer. Calls repeated with the same
+	// idempotency key must not refund twice.
+	Refund(intentID string, amount float64, idempotencyKey string) (string, error)
+}
+
+func isPrepaidMethod(method string) bool {
+	switch normalizePaymentMethod(method) {
+	case "", "cod", "credit":
+		return false
+	default:
+		return true
+	}
+}
+
+// isCreditMethod reports whether an order is billed to the buyer's credit
+// account and settled after delivery.
+func isCreditMethod(method string) bool {
+	return normalizePaymentMethod(method) == "credit"
+}
+
+// paymentTransitions lists the payment status changes a webhook may apply.
+var paymentTransitions = map[string][]string{
+	"initiated":          {"paid", "failed"},
+	"failed":             {"paid"},
+	"paid":               {"refunded", "partially_refunded"},
+	"partially_refunded": {"refunded", "partially_refunded"},
+}
+
+func canTransitionPayment(from, to string) bool {
+	for _, next := range paymentTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// MockPaymentProvider is a local gateway used for development and tests.
+// Webhook payloads are JSON and signed with a hex HMAC-SHA256 of the raw body.
+type MockPaymentProvider struct {
+	secret string
+}
+
+func NewMockPaymentProvider(secret string) *MockPaymentProvider {
+	return &MockPaymentProvider{secret: secret}
+}
+
+type mockPaymentPayload struct {
+	Events []struct {
+		EventID    string  `json:"event_id"`
+		IntentID   string  `json:"intent_id"`
+		Status     string  `json:"status"`
+		Amount     float64 `json:"amount"`
+		OccurredAt string  `json:"occurred_at"`
+	} `json:"events"`
+}
+
+func (m *MockPaymentProvider) Code() string {
+	return "mock"
+}
+
+// Sign returns the signature the mock gateway would send for payload.
+func (m *MockPaymentProvider) Sign(payload []byte) string {
+	return signHMACSHA256(m.secret, payload)
+}
+
+func (m *MockPaymentProvider) CreateIntent(orderID uint, amount float64, currency, method string) (*PaymentIntent, error) {
+	if amount <= 0 {
+		return nil, errors.New("payment amount must be greater than 0")
+	}
+	id := fmt.Sprintf("mock_pi_%d_%d", orderID, time.Now().UnixNano())
+	return &PaymentIntent{
+		Provider:     m.Code(),
+		IntentID:     id,
+		ClientSecret: id + "_secret",
+		Amount:       amount,
+		Currency:     currency,
+		Method:       method,
+	}, nil
+}
+
+func (m *MockPaymentProvider) VerifyWebhook(payload []byte, signature string) error {
+	return verifyHMACSHA256(m.secret, payload, signature)
+}
+
+func (m *MockPaymentProvider) ParseWebhook(payload []byte) ([]PaymentWebhookEvent, error) {
+	var body mockPaymentPayload
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, errors.New("invalid payment payload")
+	}
+	events := make([]PaymentWebhookEvent, 0, len(body.Events))
+	for _, event := range body.Events {
+		occurredAt := time.Now().UTC()
+		if strings.TrimSpace(event.OccurredAt) != "" {
+			parsed, err := time.Parse(time.RFC3339, event.OccurredAt)
+			if err != nil {
+				return nil, errors.New("invalid payment event time")
+			}
+			occurredAt = parsed.UTC()
+		}
+		events = append(events, PaymentWebhookEvent{
+			ExternalEventID: strings.TrimSpace(event.EventID),
+			IntentID:        strings.TrimSpace(event.IntentID),
+			Status:          strings.ToLower(strings.TrimSpace(event.Status)),
+			Amount:          event.Amount,
+			OccurredAt:      occurredAt,
+		})
+	}
+	return events, nil
+}
+
+func (m *MockPaymentProvider) Refund(intentID string, amount float64, idempotencyKey string) (string, error) {
+	if strings.TrimSpace(intentID) == "" {
+		return "", errors.New("payment intent is required for a refund")
+	}
+	if amount <= 0 {
+		return "", errors.New("refund amount must be greater than 0")
+	}
+	if idempotencyKey != "" {
+		return "mock_re_" + idempotencyKey, nil
+	}
+	return fmt.Sprintf("mock_re_%d", time.Now().UnixNano()), nil
+}
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PaymentService struct {
+	orderRepo       *repository.OrderRepository
+	providers       map[string]PaymentProvider
+	defaultProvider string
+	events          *OrderEventBroker
+}
+
+// NewPaymentService registers the given providers; the first one is used for
+// new payment intents.
+func NewPaymentService(orderRepo *repository.OrderRepository, providers ...PaymentProvider) *PaymentService {
+	registry := make(map[string]PaymentProvider, len(providers))
+	defaultProvider := ""
+	for _, provider := range providers {
+		registry[provider.Code()] = provider
+		if defaultProvider == "" {
+			defaultProvider = provider.Code()
+		}
+	}
+	return &PaymentService{
+		orderRepo:       orderRepo,
+		providers:       registry,
+		defaultProvider: defaultProvider,
+	}
+}
+
+// SetEventBroker enables real-time events for payment status changes.
+func (s *PaymentService) SetEventBroker(broker *OrderEventBroker) {
+	s.events = broker
+}
+
+// SetPaymentService lets cancellations and dispute settlements refund prepaid
+// orders through the payment provider.
+func (s *OrderService) SetPaymentService(payments *PaymentService) {
+	s.payments = payments
+}
+
+// SetPaymentService lets binding dispute decisions refund prepaid orders
+// through the payment provider.
+func (s *AdminService) SetPaymentService(payments *PaymentService) {
+	s.payments = payments
+}
+
+// CreateIntent starts (or restarts, after a failure) collection for a prepaid
+// order and returns what the client needs to complete the payment.
+func (s *PaymentService) CreateIntent(orderID, buyerID uint) (*PaymentIntent, error) {
+	provider, ok := s.providers[s.defaultProvider]
+	if !ok {
+		return nil, errors.New("no payment provider is configured")
+	}
+	order, err := s.orderRepo.GetByID(orderID)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	if order.BuyerID != buyerID {
+		return nil, errors.New("unauthorized: you can only pay for your own orders")
+	}
+	if isCreditMethod(order.PaymentMethod) {
+		return nil, errors.New("credit orders are settled against the buyer's credit account")
+	}
+	if !isPrepaidMethod(order.PaymentMethod) {
+		return nil, errors.New("cash on delivery orders are paid to the farmer on delivery")
+	}
+	if order.Status == "cancelled" {
+		return nil, errors.New("cancelled orders cannot be paid")
+	}
+	if order.PaymentStatus != "initiated" && order.PaymentStatus != "failed" {
+		return nil, errors.New("order is already " + order.PaymentStatus)
+	}
+
+	intent, err := provider.CreateIntent(order.ID, amountDue(order), "INR", order.PaymentMethod)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	err = s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+			"payment_provider":  provider.Code(),
+			"payment_intent_id": intent.IntentID,
+			"payment_status":    "initiated",
+		}).Error; err != nil {
+			return errors.New("failed to save payment intent")
+		}
+		if err := tx.Create(&models.PaymentEvent{
+			OrderID:     order.ID,
+			Provider:    provider.Code(),
+			Type:        "intent",
+			Status:      "initiated",
+			Amount:      intent.Amount,
+			ProviderRef: intent.IntentID,
+			OccurredAt:  now,
+			CreatedAt:   now,
+		}).Error; err != nil {
+			return errors.New("failed to record payment intent")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return intent, nil
+}
+
+// IngestWebhook verifies and applies a provider webhook. It returns the number
+// of events recorded; events already seen are skipped so providers can safely
+// retry deliveries.
+func (s *PaymentService) IngestWebhook(providerCode string, payload []byte, signature string) (int, error) {
+	provider, ok := s.providers[strings.ToLower(strings.TrimSpace(providerCode))]
+	if !ok {
+		return 0, errors.New("unsupported payment provider")
+	}
+	if err := provider.VerifyWebhook(payload, signature); err != nil {
+		return 0, err
+	}
+	events, err := provider.ParseWebhook(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	for _, event := range events {
+		if event.IntentID == "" || event.ExternalEventID == "" {
+			continue
+		}
+		if event.Status != "paid" && event.Status != "failed" && event.Status != "refunded" {
+			continue
+		}
+		order, err := s.orderRepo.GetByPaymentIntent(provider.Code(), event.IntentID)
+		if err != nil {
+			continue
+		}
+		seen, err := s.orderRepo.HasPaymentEvent(provider.Code(), event.ExternalEventID)
+		if err != nil {
+			return recorded, errors.New("failed to check payment events")
+		}
+		if seen {
+			continue
+		}
+		if err := s.applyWebhookEvent(provider.Code(), order.ID, event); err != nil {
+			return recorded, err
+		}
+		recorded++
+	}
+	return recorded, nil
+}
+
+func (s *PaymentService) applyWebhookEvent(providerCode string, orderID uint, event PaymentWebhookEvent) error {
+	var statusLog *models.OrderStatusLog
+	var recipients []uint
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error; err != nil {
+			return errors.New("order not found")
+		}
+
+		if err := tx.Create(&models.PaymentEvent{
+			OrderID:         order.ID,
+			Provider:        providerCode,
+			Type:            "webhook",
+			Status:          event.Status,
+			Amount:          event.Amount,
+			ProviderRef:     event.IntentID,
+			ExternalEventID: event.ExternalEventID,
+			OccurredAt:      event.OccurredAt,
+			CreatedAt:       time.Now().UTC(),
+		}).Error; err != nil {
+			return errors.New("failed to record payment event")
+		}
+
+		next := event.Status
+		if next == "refunded" && event.Amount > 0 {
+			// Refunds arrive one event per refund; the order is fully
+			// refunded once they add up to what was captured.
+			var refunded float64
+			if err := tx.Model(&models.PaymentEvent{}).
+				Where("order_id = ? AND type = ? AND status = ?", order.ID, "webhook", "refunded").
+				Select("COALESCE(SUM(amount), 0)").Scan(&refunded).Error; err != nil {
+				return errors.New("failed to total refunds")
+			}
+			if roundMoney(refunded) < amountDue(&order) {
+				next = "partially_refunded"
+			}
+		}
+		// A short payment never counts as paid; the event stays on record.
+		if next == "paid" && event.Amount > 0 && event.Amount < amountDue(&order) {
+			return nil
+		}
+		if !canTransitionPayment(order.PaymentStatus, next) {
+			return nil
+		}
+
+		previous := order.PaymentStatus
+		updates := map[string]interface{}{"payment_status": next}
+		if next == "paid" {
+			updates["paid_at"] = event.OccurredAt
+		}
+		if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(updates).Error; err != nil {
+			return errors.New("failed to update payment status")
+		}
+		if next == "paid" {
+			if err := postBuyerPayment(tx, &order, "Paid via "+providerCode); err != nil {
+				return err
+			}
+		}
+		// A capture that lands after the order was cancelled goes straight
+		// back to the buyer. The refund is requested before the payment is
+		// committed, so a failed request fails the webhook and the provider
+		// retries it; the key ties the refund to this capture so a retry
+		// cannot refund it twice.
+		if next == "paid" && order.Status == "cancelled" {
+			split, err := postOrderRefund(tx, &order, order.BuyerID, amountDue(&order), "Payment captured after cancellation")
+			if err != nil {
+				return err
+			}
+			captureID := event.ExternalEventID
+			if captureID == "" {
+				captureID = event.IntentID
+			}
+			key := fmt.Sprintf("order-%d-capture-%s", order.ID, captureID)
+			if err := s.requestProviderRefund(tx, &order, split.provider, key); err != nil {
+				return err
+			}
+		}
+
+		statusLog = &models.OrderStatusLog{
+			OrderID:    order.ID,
+			ActorID:    order.BuyerID,
+			FromStatus: order.Status,
+			ToStatus:   order.Status,
+			Reason:     "payment_update",
+			Category:   next,
+			Note:       "Payment " + strings.ReplaceAll(previous, "_", " ") + " → " + strings.ReplaceAll(next, "_", " ") + " via " + providerCode,
+			CreatedAt:  time.Now().UTC(),
+		}
+		if err := tx.Create(statusLog).Error; err != nil {
+			return errors.New("failed to log payment update")
+		}
+		recipients = []uint{order.BuyerID, order.FarmerID}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if statusLog != nil {
+		s.events.Publish(orderID, recipients, "status", statusLog.ID, statusLog)
+	}
+	return nil
+}
+
+// RequestRefund asks the order's provider to return amount to the buyer. The
+// payment status moves to refunded when the provider confirms by webhook.
+// Orders not paid through a provider are left alone.
+func (s *PaymentService) RequestRefund(orderID uint, amount float64) error {
+	if s == nil || amount <= 0 {
+		return nil
+	}
+	order, err := s.orderRepo.GetByID(orderID)
+	if err != nil {
+		return errors.New("order not found")
+	}
+	if order.PaymentIntentID == "" || (order.PaymentStatus != "paid" && order.PaymentStatus != "partially_refunded") {
+		return nil
+	}
+	// Each refund has already been posted to the ledger, so counting the
+	// refund journals gives every refund a key that stays the same if the
+	// request is retried.
+	var journals int64
+	if err := s.orderRepo.GetDB().Model(&models.LedgerEntry{}).
+		Where("order_id = ? AND kind IN ?", order.ID, []string{"refund", "cancellation"}).
+		Distinct("journal_id").Count(&journals).Error; err != nil {
+		return errors.New("failed to load refunds")
+	}
+	key := fmt.Sprintf("order-%d-refund-%d", order.ID, journals)
+	return s.requestProviderRefund(s.orderRepo.GetDB(), order, amount, key)
+}
+
+// requestProviderRefund sends a refund to the order's provider under the
+// given idempotency key and records the request on tx.
+func (s *PaymentService) requestProviderRefund(tx *gorm.DB, order *models.Order, amount float64, idempotencyKey string) error {
+	if amount <= 0 || order.PaymentIntentID == "" {
+		return nil
+	}
+	provider, ok := s.providers[order.PaymentProvider]
+	if !ok {
+		return errors.New("unsupported payment provider")
+	}
+	refundID, err := provider.Refund(order.PaymentIntentID, amount, idempotencyKey)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if err := tx.Create(&models.PaymentEvent{
+		OrderID:     order.ID,
+		Provider:    provider.Code(),
+		Type:        "refund_request",
+		Status:      "requested",
+		Amount:      amount,
+		ProviderRef: refundID,
+		OccurredAt:  now,
+		CreatedAt:   now,
+	}).Error; err != nil {
+		return errors.New("failed to record refund request")
+	}
+	return nil
+}
+
+func (s *PaymentService) GetPaymentEvents(orderID, userID uint) ([]models.PaymentEvent, error) {
+	order, err := s.orderRepo.GetByID(orderID)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	if order.BuyerID != userID && order.FarmerID != userID {
+		return nil, errors.New("unauthorized access to order")
+	}
+	items, err := s.orderRepo.GetPaymentEvents(orderID)
+	if err != nil {
+		return nil, errors.New("failed to load payment events")
+	}
+	return items, nil
+}
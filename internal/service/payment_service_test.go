@@ -0,0 +1,109 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPaymentWebhookGatesConfirmationAndRefunds(t *testing.T) {
+	ctx := setupTestCtx(t)
+	payments, provider := usePaymentsForTest(ctx)
+	deliver := func(body string) int {
+		t.Helper()
+		recorded, err := payments.IngestWebhook("mock", []byte(body), provider.Sign([]byte(body)))
+		if err != nil {
+			t.Fatalf("webhook rejected: %v", err)
+		}
+		return recorded
+	}
+
+	order, err := ctx.orderSvc.CreateOrder(ctx.buyerID, CreateOrderRequest{
+		ProductID:        ctx.productID,
+		Quantity:         2,
+		DeliveryAddress:  "Some address",
+		PaymentMethod:    "upi",
+		PaymentReference: "buyer@upi",
+	})
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{Status: "confirmed"}); err == nil {
+		t.Fatalf("expected unpaid prepaid order to be unconfirmable")
+	}
+
+	intent, err := payments.CreateIntent(order.ID, ctx.buyerID)
+	if err != nil {
+		t.Fatalf("failed to create intent: %v", err)
+	}
+	paid := `{"events":[{"event_id":"evt_1","intent_id":"` + intent.IntentID + `","status":"paid","amount":200}]}`
+	if _, err := payments.IngestWebhook("mock", []byte(paid), "bad"); err == nil {
+		t.Fatalf("expected unsigned webhook to be rejected")
+	}
+	if recorded := deliver(paid); recorded != 1 {
+		t.Fatalf("expected one payment event, got %d", recorded)
+	}
+	if recorded := deliver(paid); recorded != 0 {
+		t.Fatalf("expected retried webhook to be ignored, got %d", recorded)
+	}
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{Status: "confirmed"}); err != nil {
+		t.Fatalf("failed to confirm paid order: %v", err)
+	}
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{Status: "packed"}); err != nil {
+		t.Fatalf("failed to pack order: %v", err)
+	}
+
+	// Cancelling after packing refunds the order less the 10% fee.
+	if _, err := ctx.orderSvc.CancelOrder(order.ID, ctx.buyerID, CancelOrderRequest{}); err != nil {
+		t.Fatalf("failed to cancel paid order: %v", err)
+	}
+	events, err := payments.GetPaymentEvents(order.ID, ctx.buyerID)
+	if err != nil || len(events) != 3 || events[2].Type != "refund_request" || events[2].Amount != 180 {
+		t.Fatalf("expected refund request for 180, got %+v err=%v", events, err)
+	}
+	// Retrying the same refund reuses its idempotency key.
+	if events[2].ProviderRef != fmt.Sprintf("mock_re_order-%d-refund-1", order.ID) {
+		t.Fatalf("expected a keyed refund request, got %s", events[2].ProviderRef)
+	}
+	refunded := `{"events":[{"event_id":"evt_2","intent_id":"` + intent.IntentID + `","status":"refunded","amount":180}]}`
+	deliver(refunded)
+	updated, err := ctx.orderSvc.GetOrderByID(order.ID)
+	if err != nil || updated.PaymentStatus != "partially_refunded" || updated.PaidAt == nil {
+		t.Fatalf("unexpected payment state after refund: %+v err=%v", updated, err)
+	}
+	failed := `{"events":[{"event_id":"evt_3","intent_id":"` + intent.IntentID + `","status":"failed"}]}`
+	deliver(failed)
+	if updated, _ = ctx.orderSvc.GetOrderByID(order.ID); updated.PaymentStatus != "partially_refunded" {
+		t.Fatalf("expected invalid transition to be ignored, got %s", updated.PaymentStatus)
+	}
+	// A second refund that brings the total up to the capture completes it.
+	deliver(`{"events":[{"event_id":"evt_4","intent_id":"` + intent.IntentID + `","status":"refunded","amount":20}]}`)
+	if updated, _ = ctx.orderSvc.GetOrderByID(order.ID); updated.PaymentStatus != "refunded" {
+		t.Fatalf("expected the refunds to add up to a full refund, got %s", updated.PaymentStatus)
+	}
+
+	// A capture that arrives after the buyer cancelled is refunded at once.
+	late, err := ctx.orderSvc.CreateOrder(ctx.buyerID, CreateOrderRequest{
+		ProductID: ctx.productID, Quantity: 2, DeliveryAddress: "Some address", PaymentMethod: "upi", PaymentReference: "buyer@upi",
+	})
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+	lateIntent, err := payments.CreateIntent(late.ID, ctx.buyerID)
+	if err != nil {
+		t.Fatalf("failed to create intent: %v", err)
+	}
+	if _, err := ctx.orderSvc.CancelOrder(late.ID, ctx.buyerID, CancelOrderRequest{}); err != nil {
+		t.Fatalf("failed to cancel unpaid order: %v", err)
+	}
+	deliver(`{"events":[{"event_id":"evt_late","intent_id":"` + lateIntent.IntentID + `","status":"paid","amount":200}]}`)
+	events, err = payments.GetPaymentEvents(late.ID, ctx.buyerID)
+	if err != nil || len(events) == 0 || events[len(events)-1].Type != "refund_request" || events[len(events)-1].Amount != 200 {
+		t.Fatalf("expected the late capture to be refunded, got %+v err=%v", events, err)
+	}
+	if ref := events[len(events)-1].ProviderRef; ref != fmt.Sprintf("mock_re_order-%d-capture-evt_late", late.ID) {
+		t.Fatalf("expected the late refund to be keyed by its capture, got %s", ref)
+	}
+	if escrow, err := ledgerBalance(ctx.db, late.ID, ledgerEscrow); err != nil || escrow != 0 {
+		t.Fatalf("expected nothing left in escrow, got %v err=%v", escrow, err)
+	}
+}
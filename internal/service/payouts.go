@@ -0,0 +1,622 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultSettlementHoldDays keeps completed orders out of payouts long enough
+// for buyers to raise disputes.
+const defaultSettlementHoldDays = 7
+
+var (
+	ifscPattern          = regexp.MustCompile(`^[A-Z]{4}0[A-Z0-9]{6}$`)
+	bankAccountPattern   = regexp.MustCompile(`^[0-9]{9,18}$`)
+	upiIDPattern         = regexp.MustCompile(`^[a-zA-Z0-9._-]{2,256}@[a-zA-Z]{2,64}$`)
+	payoutUTRPattern     = regexp.MustCompile(`^[A-Za-z0-9]{6,30}$`)
+	errNoPayoutAvailable = errors.New("no settled earnings are available for payout")
+)
+
+type PayoutAccountRequest struct {
+	Method            string `json:"method"`
+	AccountHolderName string `json:"account_holder_name"`
+	AccountNumber     string `json:"account_number"`
+	IFSC              string `json:"ifsc"`
+	BankName          string `json:"bank_name"`
+	UPIID             string `json:"upi_id"`
+}
+
+type ReviewPayoutAccountRequest struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+type CreateSettlementBatchRequest struct {
+	HoldDays *int `json:"hold_days"`
+}
+
+type UpdateFarmerPayoutRequest struct {
+	Status string `json:"status"`
+	UTR    string `json:"utr"`
+	Reason string `json:"reason"`
+}
+
+// FarmerPayoutOverview is what a farmer sees about getting paid: their
+// accounts, past payouts and what could be withdrawn now.
+type FarmerPayoutOverview struct {
+	Available float64                `json:"available"`
+	HoldDays  int                    `json:"hold_days"`
+	Accounts  []models.PayoutAccount `json:"accounts"`
+	Payouts   []models.FarmerPayout  `json:"payouts"`
+	Currency  string                 `json:"currency"`
+}
+
+func buildPayoutAccount(farmerID uint, req PayoutAccountRequest) (*models.PayoutAccount, error) {
+	account := &models.PayoutAccount{
+		FarmerID:          farmerID,
+		Method:            strings.ToLower(strings.TrimSpace(req.Method)),
+		AccountHolderName: utils.SanitizeString(strings.TrimSpace(req.AccountHolderName)),
+		Status:            "pending",
+	}
+	if account.AccountHolderName == "" {
+		return nil, errors.New("account holder name is required")
+	}
+	switch account.Method {
+	case "bank":
+		number := strings.ReplaceAll(strings.TrimSpace(req.AccountNumber), " ", "")
+		if !bankAccountPattern.MatchString(number) {
+			return nil, errors.New("account number must be 9 to 18 digits")
+		}
+		ifsc := strings.ToUpper(strings.TrimSpace(req.IFSC))
+		if !ifscPattern.MatchString(ifsc) {
+			return nil, errors.New("invalid IFSC code")
+		}
+		account.AccountNumber = number
+		account.AccountLast4 = number[len(number)-4:]
+		account.IFSC = ifsc
+		account.BankName = utils.SanitizeString(strings.TrimSpace(req.BankName))
+	case "upi":
+		upiID := strings.ToLower(strings.TrimSpace(req.UPIID))
+		if !upiIDPattern.MatchString(upiID) {
+			return nil, errors.New("invalid UPI ID")
+		}
+		account.UPIID = upiID
+	default:
+		return nil, errors.New("payout method must be bank or upi")
+	}
+	return account, nil
+}
+
+// lockPayoutFarmer serialises everything that pays a farmer out, so two
+// payouts never both count the same balance.
+func lockPayoutFarmer(tx *gorm.DB, farmerID uint) error {
+	var farmer models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", farmerID).First(&farmer).Error; err != nil {
+		return errors.New("farmer not found")
+	}
+	return nil
+}
+
+// payoutsInFlight is what the farmer has been promised in payouts the bank
+// has not confirmed yet.
+func payoutsInFlight(tx *gorm.DB, farmerID uint) (float64, error) {
+	var inFlight float64
+	if err := tx.Model(&models.FarmerPayout{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("farmer_id = ? AND status IN ?", farmerID, []string{"requested", "pending"}).
+		Scan(&inFlight).Error; err != nil {
+		return 0, errors.New("failed to load payouts")
+	}
+	return roundMoney(inFlight), nil
+}
+
+func settlementCutoff(now time.Time, holdDays int) time.Time {
+	return now.AddDate(0, 0, -holdDays)
+}
+
+// payoutAvailable returns the farmer's completed orders past the cutoff that
+// no payout has settled yet, and what they are owed for them. Orders under an
+// open or escalated dispute wait until it is settled. Cash-on-delivery orders
+// count against the total since the farmer already holds the cash. The amount
+// never exceeds the farmer's ledger balance less payouts already in flight;
+// orders that would take it over are left for a later payout.
+func payoutAvailable(tx *gorm.DB, farmerID uint, cutoff time.Time) ([]uint, float64, error) {
+	var orderIDs []uint
+	if err := tx.Model(&models.Order{}).
+		Where("farmer_id = ? AND status = ? AND completed_at <= ? AND farmer_payout_id IS NULL", farmerID, "completed", cutoff).
+		Where("dispute_status IS NULL OR dispute_status NOT IN ?", []string{"open", "escalated"}).
+		Order("completed_at ASC, id ASC").
+		Pluck("id", &orderIDs).Error; err != nil {
+		return nil, 0, errors.New("failed to load settled orders")
+	}
+	if len(orderIDs) == 0 {
+		return nil, 0, nil
+	}
+
+	var earnedRows []struct {
+		OrderID uint
+		Amount  float64
+	}
+	var balance float64
+	if err := tx.Model(&models.LedgerEntry{}).
+		Select("order_id, COALESCE(SUM(credit - debit), 0) AS amount").
+		Where("account = ? AND user_id = ? AND order_id IN ?", ledgerFarmerPayable, farmerID, orderIDs).
+		Group("order_id").
+		Scan(&earnedRows).Error; err != nil {
+		return nil, 0, errors.New("failed to read ledger")
+	}
+	if err := tx.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(credit - debit), 0)").
+		Where("account = ? AND user_id = ?", ledgerFarmerPayable, farmerID).
+		Scan(&balance).Error; err != nil {
+		return nil, 0, errors.New("failed to read ledger")
+	}
+	inFlight, err := payoutsInFlight(tx, farmerID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	earned := make(map[uint]float64, len(earnedRows))
+	for _, row := range earnedRows {
+		earned[row.OrderID] = row.Amount
+	}
+	limit := roundMoney(balance - inFlight)
+	covered := make([]uint, 0, len(orderIDs))
+	amount := 0.0
+	for _, orderID := range orderIDs {
+		next := roundMoney(amount + earned[orderID])
+		if next > limit {
+			continue
+		}
+		covered = append(covered, orderID)
+		amount = next
+	}
+	return covered, amount, nil
+}
+
+// createFarmerPayout links the farmer's eligible orders to a new payout. It
+// returns errNoPayoutAvailable when nothing is owed.
+func createFarmerPayout(tx *gorm.DB, farmerID, accountID uint, cutoff time.Time, batchID *uint, status string) (*models.FarmerPayout, error) {
+	if err := lockPayoutFarmer(tx, farmerID); err != nil {
+		return nil, err
+	}
+	orderIDs, amount, err := payoutAvailable(tx, farmerID, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	if amount <= 0 {
+		return nil, errNoPayoutAvailable
+	}
+	now := time.Now().UTC()
+	payout := &models.FarmerPayout{
+		BatchID:         batchID,
+		FarmerID:        farmerID,
+		PayoutAccountID: accountID,
+		Amount:          amount,
+		OrderCount:      len(orderIDs),
+		Status:          status,
+	}
+	if status == "requested" {
+		payout.RequestedAt = &now
+	}
+	if err := tx.Create(payout).Error; err != nil {
+		return nil, errors.New("failed to create payout")
+	}
+	if err := tx.Model(&models.Order{}).Where("id IN ?", orderIDs).Update("farmer_payout_id", payout.ID).Error; err != nil {
+		return nil, errors.New("failed to link orders to payout")
+	}
+	return payout, nil
+}
+
+func defaultPayoutAccount(tx *gorm.DB, farmerID uint) (*models.PayoutAccount, error) {
+	var account models.PayoutAccount
+	if err := tx.Where("farmer_id = ? AND status = ? AND is_default = ?", farmerID, "verified", true).First(&account).Error; err != nil {
+		return nil, errors.New("a verified payout account is required")
+	}
+	return &account, nil
+}
+
+func (s *OrderService) GetPayoutAccounts(farmerID uint) ([]models.PayoutAccount, error) {
+	var accounts []models.PayoutAccount
+	if err := s.orderRepo.GetDB().Where("farmer_id = ?", farmerID).Order("created_at DESC").Find(&accounts).Error; err != nil {
+		return nil, errors.New("failed to load payout accounts")
+	}
+	return accounts, nil
+}
+
+// AddPayoutAccount registers a bank or UPI account. It is not used for
+// payouts until an admin verifies it.
+func (s *OrderService) AddPayoutAccount(farmerID uint, req PayoutAccountRequest) (*models.PayoutAccount, error) {
+	account, err := buildPayoutAccount(farmerID, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.orderRepo.GetDB().Create(account).Error; err != nil {
+		return nil, errors.New("failed to save payout account")
+	}
+	return account, nil
+}
+
+func (s *OrderService) GetFarmerPayouts(farmerID uint) (*FarmerPayoutOverview, error) {
+	db := s.orderRepo.GetDB()
+	accounts, err := s.GetPayoutAccounts(farmerID)
+	if err != nil {
+		return nil, err
+	}
+	var payouts []models.FarmerPayout
+	if err := db.Preload("PayoutAccount").Where("farmer_id = ?", farmerID).Order("created_at DESC").Find(&payouts).Error; err != nil {
+		return nil, errors.New("failed to load payouts")
+	}
+	_, available, err := payoutAvailable(db, farmerID, settlementCutoff(time.Now().UTC(), defaultSettlementHoldDays))
+	if err != nil {
+		return nil, err
+	}
+	if available < 0 {
+		available = 0
+	}
+	return &FarmerPayoutOverview{
+		Available: available,
+		HoldDays:  defaultSettlementHoldDays,
+		Accounts:  accounts,
+		Payouts:   payouts,
+		Currency:  "INR",
+	}, nil
+}
+
+// RequestFarmerPayout asks for everything currently available to be paid in
+// the next settlement batch.
+func (s *OrderService) RequestFarmerPayout(farmerID uint) (*models.FarmerPayout, error) {
+	var payout *models.FarmerPayout
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := lockPayoutFarmer(tx, farmerID); err != nil {
+			return err
+		}
+		account, err := defaultPayoutAccount(tx, farmerID)
+		if err != nil {
+			return err
+		}
+		var open int64
+		if err := tx.Model(&models.FarmerPayout{}).Where("farmer_id = ? AND status = ?", farmerID, "requested").Count(&open).Error; err != nil {
+			return errors.New("failed to load payouts")
+		}
+		if open > 0 {
+			return errors.New("a payout request is already waiting for settlement")
+		}
+		payout, err = createFarmerPayout(tx, farmerID, account.ID, settlementCutoff(time.Now().UTC(), defaultSettlementHoldDays), nil, "requested")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payout, nil
+}
+
+func (s *AdminService) GetPayoutAccounts(status string) ([]models.PayoutAccount, error) {
+	query := s.orderRepo.GetDB().Order("created_at DESC")
+	if status = strings.ToLower(strings.TrimSpace(status)); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var accounts []models.PayoutAccount
+	if err := query.Find(&accounts).Error; err != nil {
+		return nil, errors.New("failed to load payout accounts")
+	}
+	return accounts, nil
+}
+
+// ReviewPayoutAccount verifies or rejects a farmer's account. A verified
+// account becomes the farmer's default for future payouts.
+func (s *AdminService) ReviewPayoutAccount(adminID, accountID uint, req ReviewPayoutAccountRequest) (*models.PayoutAccount, error) {
+	status := strings.ToLower(strings.TrimSpace(req.Status))
+	if status != "verified" && status != "rejected" {
+		return nil, errors.New("invalid payout account status")
+	}
+	var account models.PayoutAccount
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", accountID).First(&account).Error; err != nil {
+			return errors.New("payout account not found")
+		}
+		now := time.Now().UTC()
+		account.Status = status
+		account.ReviewNote = utils.SanitizeString(req.Note)
+		account.ReviewedBy = &adminID
+		account.ReviewedAt = &now
+		account.IsDefault = status == "verified"
+		if account.IsDefault {
+			if err := tx.Model(&models.PayoutAccount{}).
+				Where("farmer_id = ? AND id <> ?", account.FarmerID, account.ID).
+				Update("is_default", false).Error; err != nil {
+				return errors.New("failed to update payout accounts")
+			}
+		}
+		if err := tx.Save(&account).Error; err != nil {
+			return errors.New("failed to review payout account")
+		}
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "payout_account",
+			TargetID:   account.ID,
+			Action:     "payout_account_" + status,
+			Note:       account.ReviewNote,
+			CreatedAt:  now,
+		}).Error; err != nil {
+			return errors.New("failed to audit payout account")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// CreateSettlementBatch collects waiting payout requests and pays every other
+// farmer with a verified account for orders completed before the hold period.
+func (s *AdminService) CreateSettlementBatch(adminID uint, req CreateSettlementBatchRequest) (*models.SettlementBatch, error) {
+	holdDays := defaultSettlementHoldDays
+	if req.HoldDays != nil {
+		holdDays = *req.HoldDays
+	}
+	if holdDays < 0 || holdDays > 90 {
+		return nil, errors.New("hold days must be between 0 and 90")
+	}
+
+	now := time.Now().UTC()
+	batch := models.SettlementBatch{
+		Reference: fmt.Sprintf("SB-%d", now.UnixNano()),
+		Status:    "pending",
+		HoldDays:  holdDays,
+		CutoffAt:  settlementCutoff(now, holdDays),
+		CreatedBy: adminID,
+	}
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&batch).Error; err != nil {
+			return errors.New("failed to create settlement batch")
+		}
+
+		var requested []models.FarmerPayout
+		if err := tx.Where("batch_id IS NULL AND status = ?", "requested").Find(&requested).Error; err != nil {
+			return errors.New("failed to load payout requests")
+		}
+		covered := map[uint]bool{}
+		for _, payout := range requested {
+			payout.BatchID = &batch.ID
+			payout.Status = "pending"
+			if err := tx.Save(&payout).Error; err != nil {
+				return errors.New("failed to add payout to batch")
+			}
+			covered[payout.FarmerID] = true
+			batch.TotalAmount += payout.Amount
+			batch.PayoutCount++
+		}
+
+		var accounts []models.PayoutAccount
+		if err := tx.Where("status = ? AND is_default = ?", "verified", true).Order("farmer_id").Find(&accounts).Error; err != nil {
+			return errors.New("failed to load payout accounts")
+		}
+		for _, account := range accounts {
+			if covered[account.FarmerID] {
+				continue
+			}
+			payout, err := createFarmerPayout(tx, account.FarmerID, account.ID, batch.CutoffAt, &batch.ID, "pending")
+			if errors.Is(err, errNoPayoutAvailable) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			batch.TotalAmount += payout.Amount
+			batch.PayoutCount++
+		}
+		if batch.PayoutCount == 0 {
+			return errors.New("no payouts are due")
+		}
+		batch.Reference = fmt.Sprintf("SB-%s-%d", now.Format("20060102"), batch.ID)
+		batch.TotalAmount = roundMoney(batch.TotalAmount)
+		if err := tx.Save(&batch).Error; err != nil {
+			return errors.New("failed to create settlement batch")
+		}
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "settlement_batch",
+			TargetID:   batch.ID,
+			Action:     "create_settlement_batch",
+			Note:       fmt.Sprintf("%s: %d payouts, %s", batch.Reference, batch.PayoutCount, formatQuantity(batch.TotalAmount)),
+			CreatedAt:  now,
+		}).Error; err != nil {
+			return errors.New("failed to audit settlement batch")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetSettlementBatch(batch.ID)
+}
+
+func (s *AdminService) GetSettlementBatches() ([]models.SettlementBatch, error) {
+	var batches []models.SettlementBatch
+	if err := s.orderRepo.GetDB().Order("created_at DESC").Find(&batches).Error; err != nil {
+		return nil, errors.New("failed to load settlement batches")
+	}
+	return batches, nil
+}
+
+func (s *AdminService) GetSettlementBatch(batchID uint) (*models.SettlementBatch, error) {
+	var batch models.SettlementBatch
+	if err := s.orderRepo.GetDB().
+		Preload("Payouts", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Payouts.Farmer").
+		Preload("Payouts.PayoutAccount").
+		Where("id = ?", batchID).First(&batch).Error; err != nil {
+		return nil, errors.New("settlement batch not found")
+	}
+	return &batch, nil
+}
+
+// ExportSettlementBatch writes the batch's pending payouts as a bank bulk
+// transfer file: NEFT for bank accounts and UPI for UPI IDs.
+func (s *AdminService) ExportSettlementBatch(adminID, batchID uint) (string, string, error) {
+	batch, err := s.GetSettlementBatch(batchID)
+	if err != nil {
+		return "", "", err
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write([]string{
+		"payment_mode", "beneficiary_name", "account_number", "ifsc", "upi_id",
+		"amount_inr", "payout_reference", "narration",
+	}); err != nil {
+		return "", "", err
+	}
+	for _, payout := range batch.Payouts {
+		if payout.Status != "pending" || payout.PayoutAccount == nil {
+			continue
+		}
+		account := payout.PayoutAccount
+		mode := "NEFT"
+		if account.Method == "upi" {
+			mode = "UPI"
+		}
+		if err := writer.Write([]string{
+			mode,
+			account.AccountHolderName,
+			account.AccountNumber,
+			account.IFSC,
+			account.UPIID,
+			strconv.FormatFloat(payout.Amount, 'f', 2, 64),
+			fmt.Sprintf("%s-%d", batch.Reference, payout.ID),
+			"F2B settlement " + batch.Reference,
+		}); err != nil {
+			return "", "", err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", "", err
+	}
+
+	now := time.Now().UTC()
+	err = s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"exported_at": now}
+		if batch.Status == "pending" {
+			updates["status"] = "exported"
+		}
+		if err := tx.Model(&models.SettlementBatch{}).Where("id = ?", batch.ID).Updates(updates).Error; err != nil {
+			return errors.New("failed to update settlement batch")
+		}
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "settlement_batch",
+			TargetID:   batch.ID,
+			Action:     "export_settlement_batch",
+			Note:       batch.Reference,
+			CreatedAt:  now,
+		}).Error; err != nil {
+			return errors.New("failed to audit settlement batch")
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return buf.String(), strings.ToLower(batch.Reference) + ".csv", nil
+}
+
+// UpdateFarmerPayout records the bank's answer for one payout. A paid payout
+// is posted to the ledger with its UTR; a failed one releases its orders for
+// the next batch.
+func (s *AdminService) UpdateFarmerPayout(adminID, payoutID uint, req UpdateFarmerPayoutRequest) (*models.FarmerPayout, error) {
+	status := strings.ToLower(strings.TrimSpace(req.Status))
+	utr := strings.ToUpper(strings.TrimSpace(req.UTR))
+	reason := utils.SanitizeString(strings.TrimSpace(req.Reason))
+	switch status {
+	case "paid":
+		if !payoutUTRPattern.MatchString(utr) {
+			return nil, errors.New("a valid UTR reference is required")
+		}
+	case "failed":
+		if reason == "" {
+			return nil, errors.New("failure reason is required")
+		}
+	default:
+		return nil, errors.New("payout status must be paid or failed")
+	}
+
+	var payout models.FarmerPayout
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", payoutID).First(&payout).Error; err != nil {
+			return errors.New("payout not found")
+		}
+		if payout.Status != "pending" || payout.BatchID == nil {
+			return errors.New("only payouts in a settlement batch can be updated")
+		}
+		var batch models.SettlementBatch
+		if err := tx.Where("id = ?", *payout.BatchID).First(&batch).Error; err != nil {
+			return errors.New("settlement batch not found")
+		}
+
+		now := time.Now().UTC()
+		payout.Status = status
+		payout.UpdatedBy = &adminID
+		note := fmt.Sprintf("%s-%d", batch.Reference, payout.ID)
+		if status == "paid" {
+			payout.UTR = utr
+			payout.PaidAt = &now
+			note += " UTR " + utr
+			if err := postLedgerJournal(tx, "farmer_payout", nil, &adminID, "Settlement "+note,
+				ledgerLine{account: ledgerFarmerPayable, userID: &payout.FarmerID, amount: payout.Amount},
+				ledgerLine{account: ledgerCash, amount: -payout.Amount},
+			); err != nil {
+				return err
+			}
+		} else {
+			payout.FailureReason = reason
+			note += ": " + reason
+			if err := tx.Model(&models.Order{}).Where("farmer_payout_id = ?", payout.ID).Update("farmer_payout_id", nil).Error; err != nil {
+				return errors.New("failed to release payout orders")
+			}
+		}
+		if err := tx.Save(&payout).Error; err != nil {
+			return errors.New("failed to update payout")
+		}
+
+		var open int64
+		if err := tx.Model(&models.FarmerPayout{}).Where("batch_id = ? AND status = ?", batch.ID, "pending").Count(&open).Error; err != nil {
+			return errors.New("failed to load payouts")
+		}
+		if open == 0 {
+			batch.Status = "completed"
+			batch.CompletedAt = &now
+			if err := tx.Save(&batch).Error; err != nil {
+				return errors.New("failed to update settlement batch")
+			}
+		}
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "farmer_payout",
+			TargetID:   payout.ID,
+			Action:     "payout_" + status,
+			Note:       note,
+			CreatedAt:  now,
+		}).Error; err != nil {
+			return errors.New("failed to audit payout")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &payout, nil
+}
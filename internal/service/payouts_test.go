@@ -0,0 +1,177 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestFarmerPayoutsSettleInBatchesAfterHoldPeriod(t *testing.T) {
+	ctx := setupTestCtx(t)
+	payments, provider := usePaymentsForTest(ctx)
+	adminSvc := newAdminServiceForTest(ctx)
+	payoutOf := func(orderID uint) *uint {
+		t.Helper()
+		var order models.Order
+		if err := ctx.db.First(&order, orderID).Error; err != nil {
+			t.Fatalf("failed to reload order: %v", err)
+		}
+		return order.FarmerPayoutID
+	}
+
+	// One order is past the seven day hold, the other was just delivered.
+	settled := deliverPrepaidOrderForTest(t, ctx, payments, provider)
+	recent := deliverPrepaidOrderForTest(t, ctx, payments, provider)
+	if err := ctx.db.Model(&models.Order{}).Where("id = ?", settled.ID).Update("completed_at", time.Now().UTC().AddDate(0, 0, -10)).Error; err != nil {
+		t.Fatalf("failed to backdate order: %v", err)
+	}
+
+	if _, err := ctx.orderSvc.RequestFarmerPayout(ctx.farmerID); err == nil {
+		t.Fatalf("expected payout without a verified account to be rejected")
+	}
+	if _, err := ctx.orderSvc.AddPayoutAccount(ctx.farmerID, PayoutAccountRequest{Method: "bank", AccountHolderName: "Farmer One", AccountNumber: "123456789012", IFSC: "SBIN123"}); err == nil {
+		t.Fatalf("expected malformed IFSC to be rejected")
+	}
+	account, err := ctx.orderSvc.AddPayoutAccount(ctx.farmerID, PayoutAccountRequest{Method: "bank", AccountHolderName: "Farmer One", AccountNumber: "1234 5678 9012", IFSC: "sbin0001234"})
+	if err != nil || account.Status != "pending" || account.AccountLast4 != "9012" {
+		t.Fatalf("unexpected payout account: %+v err=%v", account, err)
+	}
+	if _, err := ctx.orderSvc.RequestFarmerPayout(ctx.farmerID); err == nil {
+		t.Fatalf("expected payout to an unverified account to be rejected")
+	}
+	if _, err := adminSvc.ReviewPayoutAccount(99, account.ID, ReviewPayoutAccountRequest{Status: "verified"}); err != nil {
+		t.Fatalf("failed to verify account: %v", err)
+	}
+
+	// A withdrawal request only takes orders past the hold period.
+	request, err := ctx.orderSvc.RequestFarmerPayout(ctx.farmerID)
+	if err != nil || request.Amount != 190 || request.OrderCount != 1 || request.Status != "requested" {
+		t.Fatalf("unexpected payout request: %+v err=%v", request, err)
+	}
+	if _, err := ctx.orderSvc.RequestFarmerPayout(ctx.farmerID); err == nil {
+		t.Fatalf("expected a second open request to be rejected")
+	}
+	if payoutOf(recent.ID) != nil {
+		t.Fatalf("expected the recent order to stay unsettled")
+	}
+
+	batch, err := adminSvc.CreateSettlementBatch(99, CreateSettlementBatchRequest{})
+	if err != nil || batch.PayoutCount != 1 || batch.TotalAmount != 190 || batch.Payouts[0].Status != "pending" {
+		t.Fatalf("unexpected settlement batch: %+v err=%v", batch, err)
+	}
+	file, _, err := adminSvc.ExportSettlementBatch(99, batch.ID)
+	if err != nil || !strings.Contains(file, "NEFT,Farmer One,123456789012,SBIN0001234,,190.00") {
+		t.Fatalf("unexpected bank file: %q err=%v", file, err)
+	}
+	if _, err := adminSvc.UpdateFarmerPayout(99, request.ID, UpdateFarmerPayoutRequest{Status: "paid"}); err == nil {
+		t.Fatalf("expected paid payout without UTR to be rejected")
+	}
+	paid, err := adminSvc.UpdateFarmerPayout(99, request.ID, UpdateFarmerPayoutRequest{Status: "paid", UTR: "sbin26001234"})
+	if err != nil || paid.Status != "paid" || paid.UTR != "SBIN26001234" {
+		t.Fatalf("unexpected paid payout: %+v err=%v", paid, err)
+	}
+	batch, _ = adminSvc.GetSettlementBatch(batch.ID)
+	if batch.Status != "completed" {
+		t.Fatalf("expected settled batch to complete, got %s", batch.Status)
+	}
+	statement, err := ctx.orderSvc.GetFarmerLedger(ctx.farmerID)
+	if err != nil || statement.PaidOut != 190 || statement.Balance != 190 {
+		t.Fatalf("unexpected ledger after payout: %+v err=%v", statement, err)
+	}
+
+	// A failed transfer releases its orders to the next batch.
+	next, err := adminSvc.CreateSettlementBatch(99, CreateSettlementBatchRequest{HoldDays: new(int)})
+	if err != nil || next.TotalAmount != 190 || payoutOf(recent.ID) == nil {
+		t.Fatalf("unexpected second batch: %+v err=%v", next, err)
+	}
+	if _, err := adminSvc.UpdateFarmerPayout(99, next.Payouts[0].ID, UpdateFarmerPayoutRequest{Status: "failed", Reason: "account closed"}); err != nil {
+		t.Fatalf("failed to mark payout failed: %v", err)
+	}
+	if payoutOf(recent.ID) != nil {
+		t.Fatalf("expected failed payout to release its order")
+	}
+	overview, err := ctx.orderSvc.GetFarmerPayouts(ctx.farmerID)
+	if err != nil || len(overview.Payouts) != 2 || overview.Available != 0 {
+		t.Fatalf("unexpected payout overview: %+v err=%v", overview, err)
+	}
+	retry, err := adminSvc.CreateSettlementBatch(99, CreateSettlementBatchRequest{HoldDays: new(int)})
+	if err != nil || retry.TotalAmount != 190 {
+		t.Fatalf("expected released order to be settled again: %+v err=%v", retry, err)
+	}
+
+	// A disputed order waits for the dispute to settle.
+	disputed := deliverPrepaidOrderForTest(t, ctx, payments, provider)
+	ctx.db.Model(&models.Order{}).Where("id = ?", disputed.ID).Update("dispute_status", "open")
+	if _, err := adminSvc.CreateSettlementBatch(99, CreateSettlementBatchRequest{HoldDays: new(int)}); err == nil || payoutOf(disputed.ID) != nil {
+		t.Fatalf("expected a disputed order to be held back: %v", err)
+	}
+	// An order the balance cannot cover stays unlinked for a later payout.
+	ctx.db.Model(&models.Order{}).Where("id = ?", disputed.ID).Update("dispute_status", "resolved")
+	farmerID := ctx.farmerID
+	ctx.db.Create(&models.LedgerEntry{JournalID: "adj-1", Kind: "refund", Account: ledgerFarmerPayable, UserID: &farmerID, Debit: 100})
+	if _, err := adminSvc.CreateSettlementBatch(99, CreateSettlementBatchRequest{HoldDays: new(int)}); err == nil || payoutOf(disputed.ID) != nil {
+		t.Fatalf("expected an order above the balance to wait: %v", err)
+	}
+	ctx.db.Create(&models.LedgerEntry{JournalID: "adj-2", Kind: "refund", Account: ledgerFarmerPayable, UserID: &farmerID, Credit: 100})
+	last, err := adminSvc.CreateSettlementBatch(99, CreateSettlementBatchRequest{HoldDays: new(int)})
+	if err != nil || last.TotalAmount != 190 || payoutOf(disputed.ID) == nil {
+		t.Fatalf("expected the order to be paid once covered: %+v err=%v", last, err)
+	}
+}
+
+func TestManualFarmerPayoutsLeaveInFlightPayoutsAlone(t *testing.T) {
+	ctx := setupTestCtx(t)
+	// The goroutines below must share the one in-memory database.
+	sqlDB, err := ctx.db.DB()
+	if err != nil {
+		t.Fatalf("failed to open sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	payments, provider := usePaymentsForTest(ctx)
+	adminSvc := newAdminServiceForTest(ctx)
+
+	// 380 owed, 190 of it already requested for the next batch.
+	settled := deliverPrepaidOrderForTest(t, ctx, payments, provider)
+	deliverPrepaidOrderForTest(t, ctx, payments, provider)
+	ctx.db.Model(&models.Order{}).Where("id = ?", settled.ID).Update("completed_at", time.Now().UTC().AddDate(0, 0, -10))
+	account, err := ctx.orderSvc.AddPayoutAccount(ctx.farmerID, PayoutAccountRequest{Method: "upi", AccountHolderName: "Farmer One", UPIID: "farmer@upi"})
+	if err != nil {
+		t.Fatalf("failed to add account: %v", err)
+	}
+	if _, err := adminSvc.ReviewPayoutAccount(99, account.ID, ReviewPayoutAccountRequest{Status: "verified"}); err != nil {
+		t.Fatalf("failed to verify account: %v", err)
+	}
+	if request, err := ctx.orderSvc.RequestFarmerPayout(ctx.farmerID); err != nil || request.Amount != 190 {
+		t.Fatalf("unexpected payout request: %+v err=%v", request, err)
+	}
+	if _, err := adminSvc.RecordFarmerPayout(99, FarmerPayoutRequest{FarmerID: ctx.farmerID, Amount: 200}); err == nil {
+		t.Fatalf("expected a manual payout to leave the requested payout covered")
+	}
+
+	// Two admins paying the rest at once: only one of them can.
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := adminSvc.RecordFarmerPayout(99, FarmerPayoutRequest{FarmerID: ctx.farmerID, Amount: 150})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	failed := 0
+	for err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	statement, err := ctx.orderSvc.GetFarmerLedger(ctx.farmerID)
+	if failed != 1 || err != nil || statement.PaidOut != 150 || statement.Balance != 230 {
+		t.Fatalf("expected exactly one concurrent payout: failed=%d statement=%+v err=%v", failed, statement, err)
+	}
+}
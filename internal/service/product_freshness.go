@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+)
+
+// defaultShelfLifeDays applies when neither the crop nor any of its
+// categories has a shelf life set.
+const defaultShelfLifeDays = 7
+
+// HarvestFreshness scores a listing by the age of its produce, counted from
+// the harvest date and, for listings without one, from when it was listed.
+// A best-before date caps the score by the share of shelf life left, and
+// produce past it scores 0. It also returns the age in hours.
+func HarvestFreshness(product models.Product, now time.Time) (float64, float64) {
+	harvested := product.CreatedAt
+	if product.HarvestedAt != nil {
+		harvested = *product.HarvestedAt
+	}
+	ageHours := now.Sub(harvested).Hours()
+	if ageHours < 0 {
+		ageHours = 0
+	}
+	ageDays := ageHours / 24.0
+
+	score := 0.3
+	switch {
+	case ageDays <= 1:
+		score = 1.0
+	case ageDays <= 2:
+		score = 0.9
+	case ageDays <= 4:
+		score = 0.75
+	case ageDays <= 7:
+		score = 0.6
+	case ageDays <= 10:
+		score = 0.45
+	}
+
+	if product.BestBefore != nil {
+		if !now.Before(*product.BestBefore) {
+			return 0, ageHours
+		}
+		if shelfLife := product.BestBefore.Sub(harvested); shelfLife > 0 {
+			left := product.BestBefore.Sub(now).Hours() / shelfLife.Hours()
+			score = math.Min(score, 0.3+0.7*left)
+		}
+	}
+	return score, ageHours
+}
+
+// parseListingDate accepts an RFC3339 timestamp or a plain date.
+func parseListingDate(value, field string) (*time.Time, error) {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return nil, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, trimmed); err == nil {
+		parsed = parsed.UTC()
+		return &parsed, nil
+	}
+	parsed, err := time.Parse("2006-01-02", trimmed)
+	if err != nil {
+		return nil, errors.New("invalid " + field + " format")
+	}
+	return &parsed, nil
+}
+
+// shelfLifeDays looks up the shelf life of a listing's crop, else of its
+// category.
+func (s *ProductService) shelfLifeDays(product *models.Product) int {
+	for _, nodeID := range []*uint{product.CropID, product.CategoryID} {
+		if nodeID == nil {
+			continue
+		}
+		if days, err := s.productRepo.ShelfLifeDays(*nodeID); err == nil && days > 0 {
+			return days
+		}
+	}
+	return defaultShelfLifeDays
+}
+
+// applyProduceDates sets a listing's harvest and best-before dates from a
+// create or update request. Dates left out keep their current value, except
+// that a best-before is filled in from the shelf life when there is none or
+// when only the harvest date changed.
+func (s *ProductService) applyProduceDates(product *models.Product, req CreateProductRequest, now time.Time) error {
+	harvestedAt, err := parseListingDate(req.HarvestedAt, "harvest date")
+	if err != nil {
+		return err
+	}
+	bestBefore, err := parseListingDate(req.BestBefore, "best before date")
+	if err != nil {
+		return err
+	}
+	if harvestedAt != nil {
+		if harvestedAt.After(now.Add(5 * time.Minute)) {
+			return errors.New("harvest date cannot be in the future")
+		}
+		product.HarvestedAt = harvestedAt
+	}
+	if bestBefore != nil {
+		product.BestBefore = bestBefore
+	} else if product.BestBefore == nil || harvestedAt != nil {
+		start := now
+		if product.HarvestedAt != nil {
+			start = *product.HarvestedAt
+		}
+		best := start.AddDate(0, 0, s.shelfLifeDays(product))
+		product.BestBefore = &best
+	}
+	if product.HarvestedAt != nil && !product.BestBefore.After(*product.HarvestedAt) {
+		return errors.New("best before date must be after the harvest date")
+	}
+	if bestBefore != nil && !bestBefore.After(now) {
+		return errors.New("best before date must be in the future")
+	}
+	return nil
+}
+
+func pastBestBefore(product *models.Product, now time.Time) bool {
+	return product.BestBefore != nil && !now.Before(*product.BestBefore)
+}
+
+// ListingExpirySweeper expires listings past their best-before date and
+// tells their farmers.
+type ListingExpirySweeper struct {
+	productRepo  *repository.ProductRepository
+	emailService *EmailService
+}
+
+func NewListingExpirySweeper(productRepo *repository.ProductRepository, emailService *EmailService) *ListingExpirySweeper {
+	return &ListingExpirySweeper{productRepo: productRepo, emailService: emailService}
+}
+
+// Sweep expires every listing past its best-before date and returns how many
+// it expired.
+func (s *ListingExpirySweeper) Sweep(now time.Time) (int, error) {
+	products, err := s.productRepo.ListListingsPastBestBefore(now)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for i := range products {
+		product := &products[i]
+		ok, err := s.productRepo.ExpireListing(product.ID, now)
+		if err != nil {
+			return expired, err
+		}
+		if !ok {
+			continue
+		}
+		expired++
+		if s.emailService != nil && product.Farmer.Email != "" {
+			if err := s.emailService.SendListingExpired(product.Farmer.Email, product); err != nil {
+				log.Printf("product %d: expiry email failed: %v", product.ID, err)
+			}
+		}
+	}
+	return expired, nil
+}
+
+// Run sweeps on every tick until ctx is cancelled.
+func (s *ListingExpirySweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if expired, err := s.Sweep(time.Now().UTC()); err != nil {
+			log.Printf("Listing expiry sweep failed: %v", err)
+		} else if expired > 0 {
+			log.Printf("Listing expiry sweep: %d expired", expired)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestProduceDatesFreshnessAndListingExpiry(t *testing.T) {
+	ctx := setupTestCtx(t)
+	adminSvc := newAdminServiceForTest(ctx)
+	fruits, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{Name: "Fruits", ShelfLifeDays: 10})
+	if err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	mango, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{ParentID: fruits.ID, Kind: "crop", Name: "Mango"})
+	if err != nil {
+		t.Fatalf("failed to create crop: %v", err)
+	}
+	if _, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{Name: "Spoilt", ShelfLifeDays: -1}); err == nil {
+		t.Fatalf("expected a negative shelf life to be rejected")
+	}
+
+	now := time.Now().UTC()
+	harvested := now.AddDate(0, 0, -3).Truncate(time.Second)
+	// A copy of an undated listing counts from when the original was listed.
+	listedAt := now.AddDate(0, 0, -12).Truncate(time.Second)
+	ctx.db.Model(&models.Product{}).Where("id = ?", ctx.productID).Update("created_at", listedAt)
+	undated, err := ctx.productSvc.DuplicateProduct(ctx.productID, ctx.farmerID)
+	if err != nil || undated.HarvestedAt == nil || !undated.HarvestedAt.Equal(listedAt) {
+		t.Fatalf("expected a copy to keep the original's age: %+v err=%v", undated, err)
+	}
+	if score, _ := HarvestFreshness(*undated, now); score != 0.3 {
+		t.Fatalf("expected a copy of old stock to score 0.3, got %.2f", score)
+	}
+
+	req := CreateProductRequest{CropName: "Mango", Quantity: 10, Unit: "kg", PricePerUnit: 100, CropID: mango.ID}
+	req.HarvestedAt = now.Add(48 * time.Hour).Format(time.RFC3339)
+	if _, err := ctx.productSvc.UpdateProduct(ctx.productID, ctx.farmerID, req); err == nil {
+		t.Fatalf("expected a future harvest date to be rejected")
+	}
+	req.HarvestedAt = harvested.Format(time.RFC3339)
+	req.BestBefore = now.AddDate(0, 0, -1).Format("2006-01-02")
+	if _, err := ctx.productSvc.UpdateProduct(ctx.productID, ctx.farmerID, req); err == nil {
+		t.Fatalf("expected a best-before date in the past to be rejected")
+	}
+	req.BestBefore = ""
+	product, err := ctx.productSvc.UpdateProduct(ctx.productID, ctx.farmerID, req)
+	if err != nil {
+		t.Fatalf("failed to set harvest date: %v", err)
+	}
+	// Mango has no shelf life of its own, so it inherits the 10 days of fruits.
+	if product.HarvestedAt == nil || !product.HarvestedAt.Equal(harvested) ||
+		product.BestBefore == nil || !product.BestBefore.Equal(harvested.AddDate(0, 0, 10)) {
+		t.Fatalf("expected best-before to default from the shelf life: %+v", product)
+	}
+
+	// Freshness counts from the harvest, not from when the listing was made.
+	score, ageHours := HarvestFreshness(*product, now)
+	if score != 0.75 || ageHours < 71 || ageHours > 73 {
+		t.Fatalf("expected a three-day-old harvest to score 0.75, got %.2f after %.1fh", score, ageHours)
+	}
+	copied, err := ctx.productSvc.DuplicateProduct(ctx.productID, ctx.farmerID)
+	if err != nil || copied.HarvestedAt == nil || !copied.HarvestedAt.Equal(harvested) {
+		t.Fatalf("expected a copy to keep the harvest date: %+v err=%v", copied, err)
+	}
+	if score, _ := HarvestFreshness(*product, harvested.AddDate(0, 0, 9)); score > 0.4 {
+		t.Fatalf("expected produce near its best-before to score low, got %.2f", score)
+	}
+	if score, _ := HarvestFreshness(*product, harvested.AddDate(0, 0, 10)); score != 0 {
+		t.Fatalf("expected produce past its best-before to score 0, got %.2f", score)
+	}
+
+	ctx.db.Model(&models.Product{}).Where("id = ?", ctx.productID).Updates(map[string]interface{}{
+		"status":      "active",
+		"best_before": now.Add(-time.Hour),
+	})
+	if _, err := ctx.orderSvc.CreateOrder(ctx.buyerID, CreateOrderRequest{
+		ProductID: ctx.productID, Quantity: 2, DeliveryAddress: "Some address", PaymentMethod: "cod",
+	}); err == nil {
+		t.Fatalf("expected produce past its best-before to be unavailable")
+	}
+
+	sweeper := NewListingExpirySweeper(ctx.productRepo, nil)
+	expired, err := sweeper.Sweep(now)
+	if err != nil || expired != 1 {
+		t.Fatalf("expected one listing to expire, got %d err=%v", expired, err)
+	}
+	product, _ = ctx.productRepo.GetByID(ctx.productID)
+	if product.Status != "expired" || product.ExpiredAt == nil {
+		t.Fatalf("expected the listing to be expired: %+v", product)
+	}
+	if expired, err := sweeper.Sweep(now); err != nil || expired != 0 {
+		t.Fatalf("expected a second sweep to expire nothing, got %d err=%v", expired, err)
+	}
+	notifications, err := ctx.orderSvc.GetFarmerNotifications(ctx.farmerID)
+	if err != nil {
+		t.Fatalf("failed to load notifications: %v", err)
+	}
+	found := false
+	for _, item := range notifications {
+		found = found || item.Title == "Listing Expired"
+	}
+	if !found {
+		t.Fatalf("expected the farmer to be told about the expired listing: %+v", notifications)
+	}
+	if _, err := adminSvc.UpdateProductModeration(ctx.productID, 1, UpdateProductModerationRequest{Status: "active"}); err == nil {
+		t.Fatalf("expected approval past the best-before date to be rejected")
+	}
+
+	// New dates send the listing back for review.
+	req.HarvestedAt = now.Add(-time.Hour).Format(time.RFC3339)
+	product, err = ctx.productSvc.UpdateProduct(ctx.productID, ctx.farmerID, req)
+	if err != nil || product.Status != "pending_review" || product.ExpiredAt != nil {
+		t.Fatalf("expected a relisted product to await review: %+v err=%v", product, err)
+	}
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/utils"
+)
+
+const maxProductImages = 10
+
+type ProductImageInput struct {
+	URL       string `json:"url"`
+	AltText   string `json:"alt_text"`
+	IsPrimary bool   `json:"is_primary"`
+}
+
+type AddProductImagesRequest struct {
+	Images []ProductImageInput `json:"images"`
+}
+
+type ReorderProductImagesRequest struct {
+	ImageIDs       []uint `json:"image_ids"`
+	PrimaryImageID uint   `json:"primary_image_id"` // 0 keeps the current primary
+}
+
+type UpdateProductImageRequest struct {
+	AltText   string `json:"alt_text"`
+	IsPrimary bool   `json:"is_primary"`
+}
+
+func newProductImage(product *models.Product, url, altText string) models.ProductImage {
+	altText = strings.TrimSpace(utils.SanitizeString(altText))
+	if altText == "" {
+		altText = product.CropName
+	}
+	thumbnail := url
+	if utils.IsUploadURL(url) {
+		thumbnail = utils.ThumbnailURL(url)
+	}
+	return models.ProductImage{ProductID: product.ID, URL: url, ThumbnailURL: thumbnail, AltText: altText}
+}
+
+// arrangeGallery numbers images in slice order and leaves exactly one
+// primary: the image at primary, else the current primary, else the first.
+func arrangeGallery(images []models.ProductImage, primary int) {
+	if primary < 0 {
+		for i := range images {
+			if images[i].IsPrimary {
+				primary = i
+				break
+			}
+		}
+	}
+	if primary < 0 {
+		primary = 0
+	}
+	for i := range images {
+		images[i].Position = i
+		images[i].IsPrimary = i == primary
+	}
+}
+
+func (s *ProductService) ownedProduct(productID, farmerID uint) (*models.Product, error) {
+	product, err := s.productRepo.GetByID(productID)
+	if err != nil {
+		return nil, errors.New("product not found")
+	}
+	if product.FarmerID != farmerID {
+		return nil, errors.New("unauthorized: you can only update your own products")
+	}
+	return product, nil
+}
+
+// RecordImageUploads makes farmerID the owner of freshly saved product
+// images; only the owner can put them on a listing.
+func (s *ProductService) RecordImageUploads(farmerID uint, urls []string) error {
+	return s.productRepo.RecordUploads(farmerID, urls)
+}
+
+// checkImageUpload accepts a product image the farmer uploaded themselves.
+func (s *ProductService) checkImageUpload(url string, farmerID uint) error {
+	if !utils.IsProductImageURL(url) {
+		return errors.New("image url must point to an uploaded product image")
+	}
+	upload, err := s.productRepo.GetUpload(url)
+	if err != nil || upload.UploaderID != farmerID {
+		return errors.New("image url must point to an image you uploaded")
+	}
+	return nil
+}
+
+// releaseImage deletes a file the product's farmer uploaded once no product
+// or gallery image refers to it any more. Duplicated listings share their
+// files; anything else, such as external urls or other users' uploads, is
+// left alone.
+func (s *ProductService) releaseImage(product *models.Product, url string) {
+	if url == "" {
+		return
+	}
+	upload, err := s.productRepo.GetUpload(url)
+	if err != nil || upload.UploaderID != product.FarmerID {
+		return
+	}
+	if refs, err := s.productRepo.CountImageReferences(url); err == nil && refs == 0 {
+		if utils.DeleteImage(url) == nil {
+			_ = s.productRepo.DeleteUpload(upload.ID)
+		}
+	}
+}
+
+// setPrimaryImageURL keeps the gallery in step with the single image_url
+// field of create/update requests: the primary image is replaced, or added
+// when the product has no images yet.
+func (s *ProductService) setPrimaryImageURL(product *models.Product, url string) error {
+	images := append([]models.ProductImage(nil), product.Images...)
+	replaced := false
+	for i := range images {
+		if images[i].IsPrimary {
+			next := newProductImage(product, url, images[i].AltText)
+			next.ID = images[i].ID
+			next.CreatedAt = images[i].CreatedAt
+			images[i] = next
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		images = append([]models.ProductImage{newProductImage(product, url, "")}, images...)
+	}
+	arrangeGallery(images, -1)
+	return s.productRepo.SaveProductImages(product.ID, images, nil)
+}
+
+// AddProductImages appends uploaded images to a product's gallery. URLs come
+// from the upload endpoints, so only product images the farmer uploaded are
+// accepted.
+func (s *ProductService) AddProductImages(productID, farmerID uint, req AddProductImagesRequest) (*models.Product, error) {
+	if len(req.Images) == 0 {
+		return nil, errors.New("at least one image is required")
+	}
+	product, err := s.ownedProduct(productID, farmerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(product.Images)+len(req.Images) > maxProductImages {
+		return nil, errors.New("a product can have at most 10 images")
+	}
+
+	images := append([]models.ProductImage(nil), product.Images...)
+	seen := make(map[string]bool, len(images)+len(req.Images))
+	for _, image := range images {
+		seen[image.URL] = true
+	}
+	primary := -1
+	for _, input := range req.Images {
+		url := strings.TrimSpace(input.URL)
+		if err := s.checkImageUpload(url, farmerID); err != nil {
+			return nil, err
+		}
+		if seen[url] {
+			return nil, errors.New("image is already in the gallery")
+		}
+		seen[url] = true
+		if input.IsPrimary {
+			primary = len(images)
+		}
+		images = append(images, newProductImage(product, url, input.AltText))
+	}
+	arrangeGallery(images, primary)
+
+	if err := s.productRepo.SaveProductImages(product.ID, images, nil); err != nil {
+		return nil, errors.New("failed to save product images")
+	}
+	return s.productRepo.GetByID(product.ID)
+}
+
+// ReorderProductImages puts the gallery in the given order, which must list
+// every image of the product exactly once.
+func (s *ProductService) ReorderProductImages(productID, farmerID uint, req ReorderProductImagesRequest) (*models.Product, error) {
+	product, err := s.ownedProduct(productID, farmerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.ImageIDs) != len(product.Images) {
+		return nil, errors.New("image order must list every image of the product")
+	}
+
+	byID := make(map[uint]models.ProductImage, len(product.Images))
+	for _, image := range product.Images {
+		byID[image.ID] = image
+	}
+	images := make([]models.ProductImage, 0, len(req.ImageIDs))
+	primary := -1
+	for _, id := range req.ImageIDs {
+		image, ok := byID[id]
+		if !ok {
+			return nil, errors.New("image order must list every image of the product")
+		}
+		delete(byID, id)
+		if id == req.PrimaryImageID {
+			primary = len(images)
+		}
+		images = append(images, image)
+	}
+	if req.PrimaryImageID != 0 && primary < 0 {
+		return nil, errors.New("primary image not found")
+	}
+	arrangeGallery(images, primary)
+
+	if err := s.productRepo.SaveProductImages(product.ID, images, nil); err != nil {
+		return nil, errors.New("failed to save product images")
+	}
+	return s.productRepo.GetByID(product.ID)
+}
+
+// UpdateProductImage changes an image's alt text and can make it primary.
+func (s *ProductService) UpdateProductImage(productID, imageID, farmerID uint, req UpdateProductImageRequest) (*models.Product, error) {
+	product, err := s.ownedProduct(productID, farmerID)
+	if err != nil {
+		return nil, err
+	}
+	images := append([]models.ProductImage(nil), product.Images...)
+	index := -1
+	for i := range images {
+		if images[i].ID == imageID {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, errors.New("image not found")
+	}
+	altText := strings.TrimSpace(utils.SanitizeString(req.AltText))
+	if altText == "" {
+		altText = product.CropName
+	}
+	images[index].AltText = altText
+	primary := -1
+	if req.IsPrimary {
+		primary = index
+	}
+	arrangeGallery(images, primary)
+
+	if err := s.productRepo.SaveProductImages(product.ID, images, nil); err != nil {
+		return nil, errors.New("failed to save product images")
+	}
+	return s.productRepo.GetByID(product.ID)
+}
+
+// RemoveProductImage drops an image from the gallery and deletes its file.
+// When the primary image goes, the next image in order takes its place.
+func (s *ProductService) RemoveProductImage(productID, imageID, farmerID uint) (*models.Product, error) {
+	product, err := s.ownedProduct(productID, farmerID)
+	if err != nil {
+		return nil, err
+	}
+	images := make([]models.ProductImage, 0, len(product.Images))
+	var removed *models.ProductImage
+	for i := range product.Images {
+		if product.Images[i].ID == imageID {
+			removed = &product.Images[i]
+			continue
+		}
+		images = append(images, product.Images[i])
+	}
+	if removed == nil {
+		return nil, errors.New("image not found")
+	}
+	arrangeGallery(images, -1)
+
+	if err := s.productRepo.SaveProductImages(product.ID, images, []uint{removed.ID}); err != nil {
+		return nil, errors.New("failed to remove product image")
+	}
+	s.releaseImage(product, removed.URL)
+	return s.productRepo.GetByID(product.ID)
+}
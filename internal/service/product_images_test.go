@@ -0,0 +1,142 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestProductImageGalleryOrderingAndCleanup(t *testing.T) {
+	ctx := setupTestCtx(t)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to read working directory: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("failed to enter temp dir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	writeTestUploads(t, "a.jpg", "thumb_a.jpg", "b.jpg", "thumb_b.jpg", "c.jpg", "thumb_c.jpg")
+	if err := ctx.productSvc.RecordImageUploads(ctx.farmerID, []string{"/uploads/products/a.jpg", "/uploads/products/b.jpg", "/uploads/products/c.jpg"}); err != nil {
+		t.Fatalf("failed to record uploads: %v", err)
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join("uploads", "products", name))
+		return err == nil
+	}
+
+	if _, err := ctx.productSvc.AddProductImages(ctx.productID, ctx.farmerID, AddProductImagesRequest{Images: []ProductImageInput{{URL: "https://elsewhere.test/x.jpg"}}}); err == nil {
+		t.Fatalf("expected an image outside the uploads dir to be rejected")
+	}
+	if _, err := ctx.productSvc.AddProductImages(ctx.productID, ctx.farmerID, AddProductImagesRequest{Images: []ProductImageInput{{URL: "/uploads/products/thumb_a.jpg"}}}); err == nil {
+		t.Fatalf("expected a thumbnail to be rejected")
+	}
+	if _, err := ctx.productSvc.AddProductImages(ctx.productID, ctx.buyerID, AddProductImagesRequest{Images: []ProductImageInput{{URL: "/uploads/products/a.jpg"}}}); err == nil {
+		t.Fatalf("expected another user to be rejected")
+	}
+	product, err := ctx.productSvc.AddProductImages(ctx.productID, ctx.farmerID, AddProductImagesRequest{Images: []ProductImageInput{
+		{URL: "/uploads/products/a.jpg", AltText: "Crate of tomatoes"},
+		{URL: "/uploads/products/b.jpg"},
+		{URL: "/uploads/products/c.jpg"},
+	}})
+	if err != nil || len(product.Images) != 3 || product.ImageURL != "/uploads/products/a.jpg" || !product.Images[0].IsPrimary {
+		t.Fatalf("unexpected gallery: %+v err=%v", product, err)
+	}
+	if product.Images[0].ThumbnailURL != "/uploads/products/thumb_a.jpg" || product.Images[1].AltText != product.CropName {
+		t.Fatalf("unexpected image details: %+v", product.Images)
+	}
+	if _, err := ctx.productSvc.AddProductImages(ctx.productID, ctx.farmerID, AddProductImagesRequest{Images: []ProductImageInput{{URL: "/uploads/products/b.jpg"}}}); err == nil {
+		t.Fatalf("expected a duplicate image to be rejected")
+	}
+
+	a, b, c := product.Images[0].ID, product.Images[1].ID, product.Images[2].ID
+	if _, err := ctx.productSvc.ReorderProductImages(ctx.productID, ctx.farmerID, ReorderProductImagesRequest{ImageIDs: []uint{c, a}}); err == nil {
+		t.Fatalf("expected a partial order to be rejected")
+	}
+	product, err = ctx.productSvc.ReorderProductImages(ctx.productID, ctx.farmerID, ReorderProductImagesRequest{ImageIDs: []uint{c, a, b}, PrimaryImageID: c})
+	if err != nil || product.Images[0].ID != c || !product.Images[0].IsPrimary || product.Images[1].IsPrimary || product.ImageURL != "/uploads/products/c.jpg" {
+		t.Fatalf("unexpected reordered gallery: %+v err=%v", product.Images, err)
+	}
+
+	// A duplicated listing shares the files, so removing the image from the
+	// original keeps the file until the copy lets go of it too.
+	clone, err := ctx.productSvc.DuplicateProduct(ctx.productID, ctx.farmerID)
+	if err != nil || len(clone.Images) != 3 || clone.ImageURL != "/uploads/products/c.jpg" {
+		t.Fatalf("unexpected duplicate: %+v err=%v", clone, err)
+	}
+	product, err = ctx.productSvc.RemoveProductImage(ctx.productID, c, ctx.farmerID)
+	if err != nil || len(product.Images) != 2 || product.Images[0].ID != a || !product.Images[0].IsPrimary || product.ImageURL != "/uploads/products/a.jpg" {
+		t.Fatalf("unexpected gallery after removal: %+v err=%v", product, err)
+	}
+	if !exists("c.jpg") {
+		t.Fatalf("expected a file still used by the duplicate to be kept")
+	}
+	if err := ctx.productSvc.DeleteProduct(clone.ID, ctx.farmerID); err != nil {
+		t.Fatalf("failed to delete duplicate: %v", err)
+	}
+	if exists("c.jpg") || exists("thumb_c.jpg") || !exists("a.jpg") || !exists("b.jpg") {
+		t.Fatalf("expected only the unused image and its thumbnail to be deleted")
+	}
+
+	product, err = ctx.productSvc.UpdateProductImage(ctx.productID, b, ctx.farmerID, UpdateProductImageRequest{AltText: "Close-up", IsPrimary: true})
+	if err != nil || product.ImageURL != "/uploads/products/b.jpg" || product.Images[1].AltText != "Close-up" || product.Images[0].IsPrimary {
+		t.Fatalf("unexpected image update: %+v err=%v", product.Images, err)
+	}
+}
+
+// writeTestUploads creates placeholder product image files under the
+// current directory, which the caller has pointed at a temp dir.
+func writeTestUploads(t *testing.T, names ...string) {
+	t.Helper()
+	dir := filepath.Join("uploads", "products")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create uploads dir: %v", err)
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("img"), 0644); err != nil {
+			t.Fatalf("failed to write upload: %v", err)
+		}
+	}
+}
+
+func TestProductImagesOnlyUseAndDeleteOwnUploads(t *testing.T) {
+	ctx := setupTestCtx(t)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to read working directory: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("failed to enter temp dir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	writeTestUploads(t, "theirs.jpg", "thumb_theirs.jpg", "mine.jpg", "thumb_mine.jpg")
+
+	other := &models.User{Name: "Farmer Two", Email: "farmer2@example.com", Phone: "9000000003", Password: "x", UserType: "farmer"}
+	if err := ctx.db.Create(other).Error; err != nil {
+		t.Fatalf("failed to create farmer: %v", err)
+	}
+	if err := ctx.productSvc.RecordImageUploads(other.ID, []string{"/uploads/products/theirs.jpg"}); err != nil {
+		t.Fatalf("failed to record upload: %v", err)
+	}
+	if _, err := ctx.productSvc.AddProductImages(ctx.productID, ctx.farmerID, AddProductImagesRequest{Images: []ProductImageInput{{URL: "/uploads/products/theirs.jpg"}}}); err == nil {
+		t.Fatalf("expected another farmer's upload to be rejected")
+	}
+	if _, err := ctx.productSvc.AddProductImages(ctx.productID, ctx.farmerID, AddProductImagesRequest{Images: []ProductImageInput{{URL: "/uploads/products/mine.jpg"}}}); err == nil {
+		t.Fatalf("expected an unrecorded file to be rejected")
+	}
+
+	// A gallery row pointing at someone else's file, as older data can, is
+	// dropped without deleting the file.
+	image := models.ProductImage{ProductID: ctx.productID, URL: "/uploads/products/theirs.jpg", IsPrimary: true}
+	if err := ctx.db.Create(&image).Error; err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if _, err := ctx.productSvc.RemoveProductImage(ctx.productID, image.ID, ctx.farmerID); err != nil {
+		t.Fatalf("failed to remove image: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join("uploads", "products", "theirs.jpg")); err != nil {
+		t.Fatalf("expected another farmer's file to be kept: %v", err)
+	}
+}
@@ -2,12 +2,15 @@ package service
 
 import (
 	"errors"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/f2b-portal/backend/internal/models"
 	"github.com/f2b-portal/backend/internal/repository"
 	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProductService struct {
@@ -20,7 +23,10 @@ func NewProductService(productRepo *repository.ProductRepository) *ProductServic
 
 type CreateProductRequest struct {
 	CropName               string  `json:"crop_name"`
-	Category               string  `json:"category"`
+	Category               string  `json:"category"` // free text from older clients; resolved against the taxonomy
+	CategoryID             uint    `json:"category_id"`
+	CropID                 uint    `json:"crop_id"`
+	HSNCode                string  `json:"hsn_code"`
 	Quantity               float64 `json:"quantity"`
 	Unit                   string  `json:"unit"`
 	PricePerUnit           float64 `json:"price_per_unit"`
@@ -32,6 +38,11 @@ type CreateProductRequest struct {
 	MinimumBulkQuantity    float64 `json:"minimum_bulk_quantity"`
 	SupportsHarvestRequest bool    `json:"supports_harvest_request"`
 	HarvestLeadDays        int     `json:"harvest_lead_days"`
+	HarvestedAt            string  `json:"harvested_at"` // RFC3339 or YYYY-MM-DD
+	BestBefore             string  `json:"best_before"`  // defaults from the crop's shelf life
+	// Variants are only read on create; afterwards they have their own
+	// endpoints. A listing with variants takes its stock and price from them.
+	Variants []ProductVariantRequest `json:"variants"`
 }
 
 type UpdateProductStatusRequest struct {
@@ -47,6 +58,30 @@ type UpdateProductPriceRequest struct {
 	PricePerUnit float64 `json:"price_per_unit"`
 }
 
+type PriceTierInput struct {
+	MinQuantity  float64 `json:"min_quantity"`
+	PricePerUnit float64 `json:"price_per_unit"`
+}
+
+type UpdatePriceTiersRequest struct {
+	Tiers []PriceTierInput `json:"tiers"`
+}
+
+const maxPriceTiers = 10
+
+// tierUnitPrice returns the unit price for the largest quantity break the
+// order reaches, or the base price when no tier applies. Tiers must be
+// sorted by MinQuantity ascending.
+func tierUnitPrice(basePrice float64, tiers []models.ProductPriceTier, quantity float64) float64 {
+	price := basePrice
+	for _, tier := range tiers {
+		if quantity >= tier.MinQuantity {
+			price = tier.PricePerUnit
+		}
+	}
+	return price
+}
+
 func isAllowedProductStatus(status string) bool {
 	switch status {
 	case "active", "sold", "expired", "draft", "pending_review", "rejected":
@@ -57,6 +92,27 @@ func isAllowedProductStatus(status string) bool {
 }
 
 func (s *ProductService) CreateProduct(farmerID uint, req CreateProductRequest) (*models.Product, error) {
+	if len(req.Variants) > maxProductVariants {
+		return nil, errors.New("a product can have at most 20 variants")
+	}
+	variants := make([]*models.ProductVariant, 0, len(req.Variants))
+	for _, input := range req.Variants {
+		variant, err := buildProductVariant(input)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, &variant)
+		if req.PricePerUnit <= 0 || variant.PricePerUnit < req.PricePerUnit {
+			req.PricePerUnit = variant.PricePerUnit
+		}
+	}
+	if len(variants) > 0 {
+		req.Quantity = 0
+		for _, variant := range variants {
+			req.Quantity += variant.Quantity
+		}
+	}
+
 	// Validate input
 	if req.CropName == "" {
 		return nil, errors.New("crop name is required")
@@ -67,11 +123,13 @@ func (s *ProductService) CreateProduct(farmerID uint, req CreateProductRequest)
 	if req.PricePerUnit <= 0 {
 		return nil, errors.New("price per unit must be greater than 0")
 	}
-	if req.Unit == "" {
-		return nil, errors.New("unit is required")
+	unit, err := normalizeUnit(req.Unit)
+	if err != nil {
+		return nil, err
 	}
-	if strings.TrimSpace(req.Category) == "" {
-		return nil, errors.New("category is required")
+	categoryID, cropID, category, err := resolveProductTaxonomy(s.productRepo, req.CategoryID, req.CropID, req.Category)
+	if err != nil {
+		return nil, err
 	}
 	if req.MinimumBulkQuantity < 0 {
 		return nil, errors.New("minimum bulk quantity cannot be negative")
@@ -82,13 +140,25 @@ func (s *ProductService) CreateProduct(farmerID uint, req CreateProductRequest)
 	if req.HarvestLeadDays < 0 {
 		return nil, errors.New("harvest lead days cannot be negative")
 	}
+	hsnCode, err := normalizeHSNCode(req.HSNCode)
+	if err != nil {
+		return nil, err
+	}
+	if utils.IsUploadURL(req.ImageURL) {
+		if err := s.checkImageUpload(req.ImageURL, farmerID); err != nil {
+			return nil, err
+		}
+	}
 
 	product := &models.Product{
 		FarmerID:               farmerID,
 		CropName:               utils.SanitizeString(req.CropName),
-		Category:               strings.ToLower(strings.TrimSpace(utils.SanitizeString(req.Category))),
+		Category:               category,
+		CategoryID:             categoryID,
+		CropID:                 cropID,
+		HSNCode:                hsnCode,
 		Quantity:               req.Quantity,
-		Unit:                   utils.SanitizeString(req.Unit),
+		Unit:                   unit,
 		PricePerUnit:           req.PricePerUnit,
 		Description:            utils.SanitizeString(req.Description),
 		City:                   utils.SanitizeString(req.City),
@@ -100,10 +170,23 @@ func (s *ProductService) CreateProduct(farmerID uint, req CreateProductRequest)
 		HarvestLeadDays:        req.HarvestLeadDays,
 		Status:                 "pending_review",
 	}
+	if err := s.applyProduceDates(product, req, time.Now().UTC()); err != nil {
+		return nil, err
+	}
 
-	if err := s.productRepo.Create(product); err != nil {
+	if err := s.createListing(product, farmerID, "listing_created"); err != nil {
 		return nil, errors.New("failed to create product")
 	}
+	if product.ImageURL != "" {
+		if err := s.setPrimaryImageURL(product, product.ImageURL); err != nil {
+			return nil, errors.New("failed to save product images")
+		}
+	}
+	if len(variants) > 0 {
+		if err := s.saveVariants(product, variants...); err != nil {
+			return nil, err
+		}
+	}
 
 	return s.productRepo.GetByID(product.ID)
 }
@@ -112,6 +195,37 @@ func (s *ProductService) GetProductByID(id uint) (*models.Product, error) {
 	return s.productRepo.GetByID(id)
 }
 
+// normalizeUnit maps a unit or one of its aliases to its registry code.
+func normalizeUnit(text string) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		return "", errors.New("unit is required")
+	}
+	unit, ok := models.LookupUnit(text)
+	if !ok {
+		return "", errors.New("unsupported unit: choose one from the unit list")
+	}
+	return unit.Code, nil
+}
+
+// orderUnit is the unit an order was placed in. Orders from before units were
+// recorded on them fall back to the listing's unit.
+func orderUnit(order *models.Order) string {
+	if order.Unit != "" {
+		return order.Unit
+	}
+	return order.Product.Unit
+}
+
+func baseUnitName(unit string) string {
+	baseUnit, _ := models.BaseUnitOf(unit)
+	return baseUnit
+}
+
+// GetUnits returns the units listings can be sold in.
+func (s *ProductService) GetUnits() []models.UnitOfMeasure {
+	return models.Units()
+}
+
 func (s *ProductService) GetAllProducts(filters map[string]interface{}, page, limit int) ([]models.Product, int64, int, error) {
 	if page < 1 {
 		page = 1
@@ -123,6 +237,35 @@ func (s *ProductService) GetAllProducts(filters map[string]interface{}, page, li
 		limit = 100
 	}
 
+	// A free-text category filter names a node of the taxonomy, by slug,
+	// name, local name or alias; nothing matches text it does not know.
+	if text, ok := filters["category"].(string); ok {
+		delete(filters, "category")
+		if _, ok := filters["category_id"]; !ok {
+			node, err := s.productRepo.FindTaxonomyNode(text)
+			if err != nil {
+				return []models.Product{}, 0, 0, nil
+			}
+			filters["category_id"] = node.ID
+		}
+	}
+
+	// Price filters may be given per any unit; the repository compares per
+	// base unit and only within that unit's dimension.
+	if code, ok := filters["price_unit"].(string); ok {
+		delete(filters, "price_unit")
+		unit, found := models.LookupUnit(code)
+		if !found {
+			return nil, 0, 0, errors.New("unknown price unit")
+		}
+		filters["base_unit"] = unit.BaseUnit
+		for _, key := range []string{"min_price", "max_price"} {
+			if price, ok := filters[key].(float64); ok {
+				filters[key] = price / unit.Factor
+			}
+		}
+	}
+
 	products, total, err := s.productRepo.GetAll(filters, page, limit)
 	if err != nil {
 		return nil, 0, 0, err
@@ -145,6 +288,14 @@ func (s *ProductService) UpdateProduct(productID, farmerID uint, req CreateProdu
 	if product.FarmerID != farmerID {
 		return nil, errors.New("unauthorized: you can only update your own products")
 	}
+	if len(product.Variants) > 0 {
+		// Stock and price are managed per variant, and such listings have no
+		// price tiers to stay above.
+		req.Quantity = product.Quantity
+		req.PricePerUnit = product.PricePerUnit
+	} else if !basePriceAboveTiers(req.PricePerUnit, product.PriceTiers) {
+		return nil, errors.New("base price must stay above every price tier")
+	}
 	if req.Quantity < 0 {
 		return nil, errors.New("quantity cannot be negative")
 	}
@@ -154,11 +305,13 @@ func (s *ProductService) UpdateProduct(productID, farmerID uint, req CreateProdu
 	if req.CropName == "" {
 		return nil, errors.New("crop name is required")
 	}
-	if req.Unit == "" {
-		return nil, errors.New("unit is required")
+	unit, err := normalizeUnit(req.Unit)
+	if err != nil {
+		return nil, err
 	}
-	if strings.TrimSpace(req.Category) == "" {
-		return nil, errors.New("category is required")
+	categoryID, cropID, category, err := resolveProductTaxonomy(s.productRepo, req.CategoryID, req.CropID, req.Category)
+	if err != nil {
+		return nil, err
 	}
 	if req.MinimumBulkQuantity < 0 {
 		return nil, errors.New("minimum bulk quantity cannot be negative")
@@ -169,11 +322,18 @@ func (s *ProductService) UpdateProduct(productID, farmerID uint, req CreateProdu
 	if req.HarvestLeadDays < 0 {
 		return nil, errors.New("harvest lead days cannot be negative")
 	}
+	hsnCode, err := normalizeHSNCode(req.HSNCode)
+	if err != nil {
+		return nil, err
+	}
 
 	product.CropName = utils.SanitizeString(req.CropName)
-	product.Category = strings.ToLower(strings.TrimSpace(utils.SanitizeString(req.Category)))
+	product.Category = category
+	product.CategoryID = categoryID
+	product.CropID = cropID
+	product.HSNCode = hsnCode
 	product.Quantity = req.Quantity
-	product.Unit = utils.SanitizeString(req.Unit)
+	product.Unit = unit
 	oldPrice := product.PricePerUnit
 	product.PricePerUnit = req.PricePerUnit
 	product.Description = utils.SanitizeString(req.Description)
@@ -183,11 +343,26 @@ func (s *ProductService) UpdateProduct(productID, farmerID uint, req CreateProdu
 	product.MinimumBulkQuantity = req.MinimumBulkQuantity
 	product.SupportsHarvestRequest = req.SupportsHarvestRequest
 	product.HarvestLeadDays = req.HarvestLeadDays
-	// If image url changes, delete the old one to prevent orphan uploads.
+	if err := s.applyProduceDates(product, req, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	// A new image url replaces the primary gallery image; the old file is
+	// deleted once nothing refers to it, to prevent orphan uploads.
+	oldImageURL := ""
 	if req.ImageURL != "" && req.ImageURL != product.ImageURL {
-		_ = utils.DeleteImage(product.ImageURL)
+		if utils.IsUploadURL(req.ImageURL) {
+			if err := s.checkImageUpload(req.ImageURL, farmerID); err != nil {
+				return nil, err
+			}
+		}
+		oldImageURL = product.ImageURL
 		product.ImageURL = req.ImageURL
 	}
+	// An expired listing goes back for review once its dates are updated.
+	if product.Status == "expired" && !pastBestBefore(product, time.Now().UTC()) {
+		product.Status = "pending_review"
+		product.ExpiredAt = nil
+	}
 	if product.Status == "rejected" || product.Status == "active" {
 		product.Status = "pending_review"
 	}
@@ -201,21 +376,44 @@ func (s *ProductService) UpdateProduct(productID, farmerID uint, req CreateProdu
 		product.Status = "active"
 	}
 
-	if err := s.productRepo.Update(product); err != nil {
+	// Record the edit against the stock the listing actually had, which
+	// orders may have moved since it was loaded.
+	err = s.productRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var locked models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", product.ID).First(&locked).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit("PriceTiers", "Images", "Variants").Save(product).Error; err != nil {
+			return err
+		}
+		return recordStockMovement(tx, product, product.Quantity-locked.Quantity, models.StockMovement{
+			Kind:      "adjustment",
+			ActorID:   &farmerID,
+			Reference: "listing_edit",
+		})
+	})
+	if err != nil {
 		return nil, errors.New("failed to update product")
 	}
+	if oldImageURL != "" || (product.ImageURL != "" && len(product.Images) == 0) {
+		if err := s.setPrimaryImageURL(product, product.ImageURL); err != nil {
+			return nil, errors.New("failed to save product images")
+		}
+		s.releaseImage(product, oldImageURL)
+	}
 
 	if oldPrice != req.PricePerUnit {
 		_ = s.productRepo.CreatePriceHistory(&models.ProductPriceHistory{
-			ProductID: product.ID,
-			FarmerID:  farmerID,
-			OldPrice:  oldPrice,
-			NewPrice:  req.PricePerUnit,
-			ChangedAt: time.Now().UTC(),
+			ProductID:  product.ID,
+			FarmerID:   farmerID,
+			ChangeType: "base_price",
+			OldPrice:   oldPrice,
+			NewPrice:   req.PricePerUnit,
+			ChangedAt:  time.Now().UTC(),
 		})
 	}
 
-	return product, nil
+	return s.productRepo.GetByID(product.ID)
 }
 
 func (s *ProductService) UpdateProductPrice(productID, farmerID uint, pricePerUnit float64) (*models.Product, error) {
@@ -229,18 +427,24 @@ func (s *ProductService) UpdateProductPrice(productID, farmerID uint, pricePerUn
 	if product.FarmerID != farmerID {
 		return nil, errors.New("unauthorized: you can only update your own products")
 	}
+	if len(product.Variants) > 0 {
+		return nil, errors.New("set prices on the variants of this product")
+	}
+	if !basePriceAboveTiers(pricePerUnit, product.PriceTiers) {
+		return nil, errors.New("base price must stay above every price tier")
+	}
 
 	oldPrice := product.PricePerUnit
-	product.PricePerUnit = pricePerUnit
-	if err := s.productRepo.Update(product); err != nil {
+	if err := s.productRepo.UpdatePrice(product.ID, pricePerUnit); err != nil {
 		return nil, errors.New("failed to update product price")
 	}
 	_ = s.productRepo.CreatePriceHistory(&models.ProductPriceHistory{
-		ProductID: product.ID,
-		FarmerID:  farmerID,
-		OldPrice:  oldPrice,
-		NewPrice:  pricePerUnit,
-		ChangedAt: time.Now().UTC(),
+		ProductID:  product.ID,
+		FarmerID:   farmerID,
+		ChangeType: "base_price",
+		OldPrice:   oldPrice,
+		NewPrice:   pricePerUnit,
+		ChangedAt:  time.Now().UTC(),
 	})
 
 	return s.productRepo.GetByID(productID)
@@ -256,24 +460,190 @@ func (s *ProductService) DuplicateProduct(productID, farmerID uint) (*models.Pro
 	}
 
 	clone := &models.Product{
-		FarmerID:     product.FarmerID,
-		CropName:     product.CropName + " (Copy)",
-		Category:     product.Category,
-		Quantity:     product.Quantity,
-		Unit:         product.Unit,
-		PricePerUnit: product.PricePerUnit,
-		Description:  product.Description,
-		City:         product.City,
-		State:        product.State,
-		ImageURL:     product.ImageURL,
-		Status:       "draft",
-	}
-	if err := s.productRepo.Create(clone); err != nil {
+		FarmerID:            product.FarmerID,
+		CropName:            product.CropName + " (Copy)",
+		Category:            product.Category,
+		HSNCode:             product.HSNCode,
+		Quantity:            product.Quantity,
+		Unit:                product.Unit,
+		PricePerUnit:        product.PricePerUnit,
+		Description:         product.Description,
+		City:                product.City,
+		State:               product.State,
+		ImageURL:            product.ImageURL,
+		HarvestedAt:         product.HarvestedAt,
+		BestBefore:          product.BestBefore,
+		IsBulkAvailable:     product.IsBulkAvailable,
+		MinimumBulkQuantity: product.MinimumBulkQuantity,
+		Status:              "draft",
+	}
+	// Quantity breaks are part of the listing's pricing and are created with
+	// the copy.
+	for _, tier := range product.PriceTiers {
+		clone.PriceTiers = append(clone.PriceTiers, models.ProductPriceTier{MinQuantity: tier.MinQuantity, PricePerUnit: tier.PricePerUnit})
+	}
+	// The copy keeps the listing's place in the taxonomy. A crop retired
+	// since falls back to its category; listings from before the taxonomy
+	// keep their free-text category until the farmer next edits them.
+	if product.CategoryID != nil || product.CropID != nil {
+		var categoryID, cropID uint
+		if product.CategoryID != nil {
+			categoryID = *product.CategoryID
+		}
+		if product.CropID != nil {
+			cropID = *product.CropID
+		}
+		category, crop, slug, err := resolveProductTaxonomy(s.productRepo, categoryID, cropID, "")
+		if err != nil && cropID > 0 && categoryID > 0 {
+			category, crop, slug, err = resolveProductTaxonomy(s.productRepo, categoryID, 0, "")
+		}
+		if err != nil {
+			return nil, err
+		}
+		clone.CategoryID, clone.CropID, clone.Category = category, crop, slug
+	}
+	// Undated listings are as old as their listing date; a copy must not
+	// look fresher than the stock it copies.
+	if clone.HarvestedAt == nil {
+		harvested := product.CreatedAt
+		clone.HarvestedAt = &harvested
+	}
+	if err := s.createListing(clone, farmerID, "listing_duplicated"); err != nil {
 		return nil, errors.New("failed to duplicate product")
 	}
+	// The copy shares the original's files; releaseImage only deletes a file
+	// once neither listing uses it.
+	if len(product.Images) > 0 {
+		images := make([]models.ProductImage, 0, len(product.Images))
+		for _, image := range product.Images {
+			images = append(images, models.ProductImage{
+				URL:          image.URL,
+				ThumbnailURL: image.ThumbnailURL,
+				AltText:      image.AltText,
+				Position:     image.Position,
+				IsPrimary:    image.IsPrimary,
+			})
+		}
+		if err := s.productRepo.SaveProductImages(clone.ID, images, nil); err != nil {
+			return nil, errors.New("failed to duplicate product images")
+		}
+	}
+	if len(product.Variants) > 0 {
+		variants := make([]*models.ProductVariant, 0, len(product.Variants))
+		for _, variant := range product.Variants {
+			variants = append(variants, &models.ProductVariant{
+				SKU:              variant.SKU,
+				Grade:            variant.Grade,
+				Size:             variant.Size,
+				Packaging:        variant.Packaging,
+				PricePerUnit:     variant.PricePerUnit,
+				Quantity:         variant.Quantity,
+				MinOrderQuantity: variant.MinOrderQuantity,
+				IsActive:         variant.IsActive,
+			})
+		}
+		if err := s.saveVariants(clone, variants...); err != nil {
+			return nil, errors.New("failed to duplicate product variants")
+		}
+	}
 	return s.productRepo.GetByID(clone.ID)
 }
 
+func basePriceAboveTiers(basePrice float64, tiers []models.ProductPriceTier) bool {
+	for _, tier := range tiers {
+		if tier.PricePerUnit >= basePrice {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdatePriceTiers replaces a product's quantity breaks. Larger breaks must
+// be cheaper than smaller ones and every tier must undercut the base price.
+// Listings with variants are priced per variant and can only clear theirs.
+func (s *ProductService) UpdatePriceTiers(productID, farmerID uint, req UpdatePriceTiersRequest) (*models.Product, error) {
+	if len(req.Tiers) > maxPriceTiers {
+		return nil, errors.New("a product can have at most 10 price tiers")
+	}
+	product, err := s.productRepo.GetByID(productID)
+	if err != nil {
+		return nil, errors.New("product not found")
+	}
+	if product.FarmerID != farmerID {
+		return nil, errors.New("unauthorized: you can only update your own products")
+	}
+	if len(product.Variants) > 0 && len(req.Tiers) > 0 {
+		return nil, errors.New("products with variants are priced per variant and cannot have price tiers")
+	}
+
+	inputs := append([]PriceTierInput(nil), req.Tiers...)
+	sort.Slice(inputs, func(i, j int) bool { return inputs[i].MinQuantity < inputs[j].MinQuantity })
+	tiers := make([]models.ProductPriceTier, 0, len(inputs))
+	for i, input := range inputs {
+		if input.MinQuantity <= 0 {
+			return nil, errors.New("tier minimum quantity must be greater than 0")
+		}
+		if input.PricePerUnit <= 0 {
+			return nil, errors.New("tier price per unit must be greater than 0")
+		}
+		if input.PricePerUnit >= product.PricePerUnit {
+			return nil, errors.New("tier price must be lower than the base price")
+		}
+		if i > 0 {
+			if input.MinQuantity == inputs[i-1].MinQuantity {
+				return nil, errors.New("tier minimum quantities must be unique")
+			}
+			if input.PricePerUnit >= inputs[i-1].PricePerUnit {
+				return nil, errors.New("larger tiers must have a lower price per unit")
+			}
+		}
+		tiers = append(tiers, models.ProductPriceTier{
+			ProductID:    product.ID,
+			MinQuantity:  input.MinQuantity,
+			PricePerUnit: input.PricePerUnit,
+		})
+	}
+
+	now := time.Now().UTC()
+	previous := make(map[float64]float64, len(product.PriceTiers))
+	for _, tier := range product.PriceTiers {
+		previous[tier.MinQuantity] = tier.PricePerUnit
+	}
+	history := make([]models.ProductPriceHistory, 0)
+	for _, tier := range tiers {
+		oldPrice, existed := previous[tier.MinQuantity]
+		delete(previous, tier.MinQuantity)
+		if existed && oldPrice == tier.PricePerUnit {
+			continue
+		}
+		history = append(history, models.ProductPriceHistory{
+			ProductID:   product.ID,
+			FarmerID:    farmerID,
+			ChangeType:  "tier",
+			MinQuantity: tier.MinQuantity,
+			OldPrice:    oldPrice,
+			NewPrice:    tier.PricePerUnit,
+			ChangedAt:   now,
+		})
+	}
+	for minQuantity, oldPrice := range previous {
+		history = append(history, models.ProductPriceHistory{
+			ProductID:   product.ID,
+			FarmerID:    farmerID,
+			ChangeType:  "tier",
+			MinQuantity: minQuantity,
+			OldPrice:    oldPrice,
+			NewPrice:    0,
+			ChangedAt:   now,
+		})
+	}
+
+	if err := s.productRepo.ReplacePriceTiers(product.ID, tiers, history); err != nil {
+		return nil, errors.New("failed to update price tiers")
+	}
+	return s.productRepo.GetByID(product.ID)
+}
+
 func (s *ProductService) GetProductPriceHistory(productID, farmerID uint) ([]models.ProductPriceHistory, error) {
 	product, err := s.productRepo.GetByID(productID)
 	if err != nil {
@@ -362,10 +732,17 @@ func (s *ProductService) DeleteProduct(productID, farmerID uint) error {
 		return errors.New("unauthorized: you can only delete your own products")
 	}
 
+	if err := s.productRepo.Delete(productID); err != nil {
+		return err
+	}
 	// Best-effort cleanup for uploaded images.
-	_ = utils.DeleteImage(product.ImageURL)
-
-	return s.productRepo.Delete(productID)
+	s.releaseImage(product, product.ImageURL)
+	for _, image := range product.Images {
+		if image.URL != product.ImageURL {
+			s.releaseImage(product, image.URL)
+		}
+	}
+	return nil
 }
 
 func (s *ProductService) SearchProducts(query string, limit int) ([]models.Product, error) {
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestUpdateProductPriceCreatesHistory(t *testing.T) {
+	ctx := setupTestCtx(t)
+
+	if _, err := ctx.productSvc.UpdateProductPrice(ctx.productID, ctx.farmerID, 120); err != nil {
+		t.Fatalf("failed to update product price: %v", err)
+	}
+
+	history, err := ctx.productSvc.GetProductPriceHistory(ctx.productID, ctx.farmerID)
+	if err != nil {
+		t.Fatalf("failed to load price history: %v", err)
+	}
+	if len(history) == 0 {
+		t.Fatalf("expected at least one price history record")
+	}
+	if history[0].OldPrice != 100 || history[0].NewPrice != 120 {
+		t.Fatalf("unexpected price history values: old=%v new=%v", history[0].OldPrice, history[0].NewPrice)
+	}
+}
+
+func TestPriceTiersApplyToOrdersAndCheckout(t *testing.T) {
+	ctx := setupTestCtx(t)
+
+	if _, err := ctx.productSvc.UpdatePriceTiers(ctx.productID, ctx.farmerID, UpdatePriceTiersRequest{
+		Tiers: []PriceTierInput{{MinQuantity: 5, PricePerUnit: 80}, {MinQuantity: 3, PricePerUnit: 90}},
+	}); err != nil {
+		t.Fatalf("failed to set price tiers: %v", err)
+	}
+	if _, err := ctx.productSvc.UpdatePriceTiers(ctx.productID, ctx.farmerID, UpdatePriceTiersRequest{
+		Tiers: []PriceTierInput{{MinQuantity: 3, PricePerUnit: 80}, {MinQuantity: 5, PricePerUnit: 90}},
+	}); err == nil {
+		t.Fatalf("expected larger tier with higher price to be rejected")
+	}
+	if _, err := ctx.productSvc.UpdateProductPrice(ctx.productID, ctx.farmerID, 85); err == nil {
+		t.Fatalf("expected base price below a tier to be rejected")
+	}
+
+	order, err := ctx.orderSvc.CreateOrder(ctx.buyerID, CreateOrderRequest{
+		ProductID:       ctx.productID,
+		Quantity:        3,
+		DeliveryAddress: "Some address",
+		PaymentMethod:   "cod",
+	})
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+	if order.TotalPrice != 270 {
+		t.Fatalf("expected 3 units at tier price 90, got %v", order.TotalPrice)
+	}
+
+	if err := ctx.cartSvc.AddToCart(ctx.buyerID, AddToCartRequest{ProductID: ctx.productID, Quantity: 5}); err != nil {
+		t.Fatalf("failed to add item to cart: %v", err)
+	}
+	orders, err := ctx.cartSvc.Checkout(ctx.buyerID, "Cart address")
+	if err != nil {
+		t.Fatalf("failed to checkout cart: %v", err)
+	}
+	if len(orders) != 1 || orders[0].TotalPrice != 400 {
+		t.Fatalf("expected 5 units at tier price 80 in checkout, got %+v", orders)
+	}
+
+	history, err := ctx.productSvc.GetProductPriceHistory(ctx.productID, ctx.farmerID)
+	if err != nil {
+		t.Fatalf("failed to load price history: %v", err)
+	}
+	tierChanges := 0
+	for _, entry := range history {
+		if entry.ChangeType == "tier" {
+			tierChanges++
+		}
+	}
+	if tierChanges != 2 {
+		t.Fatalf("expected 2 tier history entries, got %d", tierChanges)
+	}
+}
+
+func TestDuplicateProductKeepsListingSetup(t *testing.T) {
+	ctx := setupTestCtx(t)
+	adminSvc := newAdminServiceForTest(ctx)
+	vegetables, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{Name: "Vegetables"})
+	if err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	tomato, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{ParentID: vegetables.ID, Kind: "crop", Name: "Tomato"})
+	if err != nil {
+		t.Fatalf("failed to create crop: %v", err)
+	}
+	ctx.db.Model(&models.Product{}).Where("id = ?", ctx.productID).Updates(map[string]interface{}{
+		"category_id": vegetables.ID, "crop_id": tomato.ID, "category": "vegetables", "hsn_code": "0702",
+	})
+
+	clone, err := ctx.productSvc.DuplicateProduct(ctx.productID, ctx.farmerID)
+	if err != nil || clone.CategoryID == nil || *clone.CategoryID != vegetables.ID || clone.CropID == nil || *clone.CropID != tomato.ID || clone.Category != "vegetables" {
+		t.Fatalf("expected the copy to keep its taxonomy: %+v err=%v", clone, err)
+	}
+	if clone.HSNCode != "0702" {
+		t.Fatalf("expected the copy to keep its HSN code, got %q", clone.HSNCode)
+	}
+	if !clone.IsBulkAvailable || clone.MinimumBulkQuantity != 5 {
+		t.Fatalf("expected the copy to keep bulk ordering: %+v", clone)
+	}
+
+	if _, err := ctx.productSvc.UpdatePriceTiers(ctx.productID, ctx.farmerID, UpdatePriceTiersRequest{Tiers: []PriceTierInput{{MinQuantity: 5, PricePerUnit: 90}}}); err != nil {
+		t.Fatalf("failed to set price tiers: %v", err)
+	}
+	clone, err = ctx.productSvc.DuplicateProduct(ctx.productID, ctx.farmerID)
+	if err != nil || len(clone.PriceTiers) != 1 || clone.PriceTiers[0].MinQuantity != 5 || clone.PriceTiers[0].PricePerUnit != 90 {
+		t.Fatalf("expected the copy to keep its price tiers: %+v err=%v", clone, err)
+	}
+
+	// A crop retired since the listing was made falls back to its category.
+	ctx.db.Model(&models.TaxonomyNode{}).Where("id = ?", tomato.ID).Update("is_active", false)
+	clone, err = ctx.productSvc.DuplicateProduct(ctx.productID, ctx.farmerID)
+	if err != nil || clone.CropID != nil || clone.CategoryID == nil || *clone.CategoryID != vegetables.ID {
+		t.Fatalf("expected a retired crop to fall back to its category: %+v err=%v", clone, err)
+	}
+	ctx.db.Model(&models.TaxonomyNode{}).Where("id = ?", vegetables.ID).Update("is_active", false)
+	if _, err := ctx.productSvc.DuplicateProduct(ctx.productID, ctx.farmerID); err == nil {
+		t.Fatalf("expected a listing in a retired category not to be copied")
+	}
+}
+
+func TestUnitConversionNormalizesListingsAndAnalytics(t *testing.T) {
+	ctx := setupTestCtx(t)
+	onion := &models.Product{
+		FarmerID: ctx.farmerID, CropName: "Onion", Quantity: 4, Unit: "qtl", PricePerUnit: 5000,
+		City: "Nashik", State: "Maharashtra", Status: "active",
+	}
+	if err := ctx.db.Create(onion).Error; err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+
+	listed := func(filters map[string]interface{}) []uint {
+		products, _, _, err := ctx.productSvc.GetAllProducts(filters, 1, 20)
+		if err != nil {
+			t.Fatalf("failed to list products with %v: %v", filters, err)
+		}
+		ids := make([]uint, 0, len(products))
+		for _, product := range products {
+			ids = append(ids, product.ID)
+		}
+		return ids
+	}
+	if ids := listed(map[string]interface{}{"sort_by": "price_asc"}); len(ids) != 2 || ids[0] != onion.ID {
+		t.Fatalf("expected Rs 50/kg onions to sort before Rs 100/kg tomatoes: %v", ids)
+	}
+	if ids := listed(map[string]interface{}{"max_price": 60.0}); len(ids) != 1 || ids[0] != onion.ID {
+		t.Fatalf("expected the price filter to compare per kg: %v", ids)
+	}
+	if ids := listed(map[string]interface{}{"price_unit": "quintal", "min_price": 6000.0}); len(ids) != 1 || ids[0] != ctx.productID {
+		t.Fatalf("expected a per-quintal price filter to convert: %v", ids)
+	}
+	if ids := listed(map[string]interface{}{"base_unit": "litre"}); len(ids) != 0 {
+		t.Fatalf("expected no listings sold by volume: %v", ids)
+	}
+
+	category := models.TaxonomyNode{Kind: "category", Slug: "vegetables", Name: "Vegetables", Path: "/1/", IsActive: true}
+	if err := ctx.db.Create(&category).Error; err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	req := CreateProductRequest{CropName: "Tomato", Quantity: 10, Unit: "bushel", PricePerUnit: 100, CategoryID: category.ID}
+	if _, err := ctx.productSvc.UpdateProduct(ctx.productID, ctx.farmerID, req); err == nil {
+		t.Fatalf("expected a unit outside the registry to be rejected")
+	}
+	req.Unit = " Kgs "
+	product, err := ctx.productSvc.UpdateProduct(ctx.productID, ctx.farmerID, req)
+	if err != nil || product.Unit != "kg" {
+		t.Fatalf("expected the unit alias to be normalized: %+v err=%v", product, err)
+	}
+	ctx.db.Model(&models.Product{}).Where("id = ?", ctx.productID).Update("status", "active")
+
+	completeOrderForTest(t, ctx, createOrderForTest(t, ctx).ID)
+	onionOrder, err := ctx.orderSvc.CreateOrder(ctx.buyerID, CreateOrderRequest{
+		ProductID: onion.ID, Quantity: 1, DeliveryAddress: "Some address", PaymentMethod: "cod",
+	})
+	if err != nil {
+		t.Fatalf("failed to order onions: %v", err)
+	}
+	completeOrderForTest(t, ctx, onionOrder.ID)
+
+	summary, err := ctx.orderSvc.GetFarmerAnalytics(ctx.farmerID)
+	if err != nil {
+		t.Fatalf("failed to load analytics: %v", err)
+	}
+	if summary.VolumeSold["kg"] != 102 {
+		t.Fatalf("expected 2 kg + 1 quintal to total 102 kg, got %v", summary.VolumeSold)
+	}
+	for _, top := range summary.TopProducts {
+		if top.ProductID == onion.ID && (top.UnitsSold != 1 || top.Unit != "qtl" || top.BaseUnitsSold != 100 || top.BaseUnit != "kg") {
+			t.Fatalf("expected onions in the farmer's unit and in kg: %+v", top)
+		}
+	}
+
+	// Relisting in another unit does not restate past sales.
+	if onionOrder.Unit != "qtl" {
+		t.Fatalf("expected the order to record its unit, got %q", onionOrder.Unit)
+	}
+	ctx.db.Model(&models.Product{}).Where("id = ?", onion.ID).Update("unit", "kg")
+	summary, err = ctx.orderSvc.GetFarmerAnalytics(ctx.farmerID)
+	if err != nil || summary.VolumeSold["kg"] != 102 {
+		t.Fatalf("expected past sales to keep their unit, got %v err=%v", summary.VolumeSold, err)
+	}
+}
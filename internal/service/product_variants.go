@@ -0,0 +1,325 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxProductVariants = 20
+
+type ProductVariantRequest struct {
+	SKU              string  `json:"sku"`
+	Grade            string  `json:"grade"`
+	Size             string  `json:"size"`
+	Packaging        string  `json:"packaging"`
+	PricePerUnit     float64 `json:"price_per_unit"`
+	Quantity         float64 `json:"quantity"`
+	MinOrderQuantity float64 `json:"min_order_quantity"`
+	IsActive         bool    `json:"is_active"` // only read on update; new variants start active
+}
+
+// variantLabel names a variant by its grade, size and packaging, falling back
+// to the SKU.
+func variantLabel(variant models.ProductVariant) string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{variant.Grade, variant.Size, variant.Packaging} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return variant.SKU
+	}
+	return strings.Join(parts, " / ")
+}
+
+// orderItemName is the product name shown on invoices and receipts.
+func orderItemName(cropName string, order *models.Order) string {
+	if order.VariantLabel == "" {
+		return cropName
+	}
+	return cropName + " (" + order.VariantLabel + ")"
+}
+
+func buildProductVariant(req ProductVariantRequest) (models.ProductVariant, error) {
+	variant := models.ProductVariant{
+		SKU:              strings.ToUpper(strings.TrimSpace(utils.SanitizeString(req.SKU))),
+		Grade:            strings.TrimSpace(utils.SanitizeString(req.Grade)),
+		Size:             strings.TrimSpace(utils.SanitizeString(req.Size)),
+		Packaging:        strings.TrimSpace(utils.SanitizeString(req.Packaging)),
+		PricePerUnit:     req.PricePerUnit,
+		Quantity:         req.Quantity,
+		MinOrderQuantity: req.MinOrderQuantity,
+		IsActive:         true,
+	}
+	if variant.SKU == "" && variant.Grade == "" && variant.Size == "" && variant.Packaging == "" {
+		return variant, errors.New("a variant needs a sku, grade, size or packaging")
+	}
+	if variant.PricePerUnit <= 0 {
+		return variant, errors.New("variant price per unit must be greater than 0")
+	}
+	if variant.Quantity < 0 {
+		return variant, errors.New("variant quantity cannot be negative")
+	}
+	if variant.MinOrderQuantity < 0 {
+		return variant, errors.New("variant minimum order cannot be negative")
+	}
+	return variant, nil
+}
+
+// syncVariantStock makes a listing with variants reflect them: its quantity
+// is the stock of its active variants and its price the cheapest of them, so
+// listing filters and sold-out handling keep working. The caller saves the
+// product.
+func syncVariantStock(tx *gorm.DB, product *models.Product) error {
+	var variants []models.ProductVariant
+	if err := tx.Where("product_id = ?", product.ID).Find(&variants).Error; err != nil {
+		return errors.New("failed to load product variants")
+	}
+	if len(variants) == 0 {
+		return nil
+	}
+	quantity, price := 0.0, 0.0
+	for _, variant := range variants {
+		if !variant.IsActive {
+			continue
+		}
+		quantity += variant.Quantity
+		if price == 0 || variant.PricePerUnit < price {
+			price = variant.PricePerUnit
+		}
+	}
+	product.Quantity = quantity
+	if price > 0 {
+		product.PricePerUnit = price
+	}
+	if product.Quantity <= 0 {
+		product.Quantity = 0
+		if product.Status == "active" {
+			product.Status = "sold"
+		}
+	} else if product.Status == "sold" {
+		product.Status = "active"
+	}
+	return nil
+}
+
+// lockOrderVariant locks the variant an order or cart line is for. Listings
+// with active variants must name one.
+func lockOrderVariant(tx *gorm.DB, productID, variantID uint) (*models.ProductVariant, error) {
+	if variantID == 0 {
+		var active int64
+		if err := tx.Model(&models.ProductVariant{}).Where("product_id = ? AND is_active = ?", productID, true).Count(&active).Error; err != nil {
+			return nil, errors.New("failed to load product variants")
+		}
+		if active > 0 {
+			return nil, errors.New("please choose a variant of this product")
+		}
+		return nil, nil
+	}
+	var variant models.ProductVariant
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND product_id = ?", variantID, productID).
+		First(&variant).Error; err != nil {
+		return nil, errors.New("product variant not found")
+	}
+	if !variant.IsActive {
+		return nil, errors.New("product variant is not available")
+	}
+	return &variant, nil
+}
+
+// checkVariantQuantity applies a variant's stock and minimum order.
+func checkVariantQuantity(variant *models.ProductVariant, quantity float64) error {
+	if variant == nil {
+		return nil
+	}
+	if variant.Quantity < quantity {
+		return errors.New("insufficient quantity available")
+	}
+	if quantity < variant.MinOrderQuantity {
+		return errors.New("quantity is below the variant minimum order")
+	}
+	return nil
+}
+
+// applyOrderVariant prices an order at the variant's price and records which
+// variant it was for. Listings with variants have no price tiers, so the
+// variant price is the order price.
+func applyOrderVariant(order *models.Order, variant *models.ProductVariant) {
+	if variant == nil {
+		return
+	}
+	order.VariantID = &variant.ID
+	order.VariantSKU = variant.SKU
+	order.VariantLabel = variantLabel(*variant)
+	order.TotalPrice = order.Quantity * variant.PricePerUnit
+}
+
+// takeVariantStock moves quantity out of a variant's stock.
+func takeVariantStock(tx *gorm.DB, variant *models.ProductVariant, quantity float64) error {
+	if variant == nil {
+		return nil
+	}
+	variant.Quantity -= quantity
+	if variant.Quantity < 0 {
+		variant.Quantity = 0
+	}
+	if err := tx.Save(variant).Error; err != nil {
+		return errors.New("failed to reserve inventory")
+	}
+	return nil
+}
+
+// adjustOrderVariantStock moves delta out of, or when negative back into, the
+// variant an order was placed for. It reports whether the listing quantity
+// should move with it, which it only does for active variants.
+func adjustOrderVariantStock(tx *gorm.DB, order *models.Order, delta float64) (bool, error) {
+	if order.VariantID == nil {
+		return true, nil
+	}
+	var variant models.ProductVariant
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", *order.VariantID).
+		First(&variant).Error; err != nil {
+		return false, errors.New("product variant not found")
+	}
+	if delta > 0 {
+		if !variant.IsActive {
+			return false, errors.New("product variant is not available")
+		}
+		if variant.Quantity < delta {
+			return false, errors.New("insufficient quantity available")
+		}
+	}
+	variant.Quantity -= delta
+	if variant.Quantity < 0 {
+		variant.Quantity = 0
+	}
+	if err := tx.Save(&variant).Error; err != nil {
+		return false, errors.New("failed to adjust inventory")
+	}
+	return variant.IsActive, nil
+}
+
+// saveVariants writes variants for a listing and re-syncs its stock and price.
+func (s *ProductService) saveVariants(product *models.Product, variants ...*models.ProductVariant) error {
+	return s.productRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var locked models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", product.ID).First(&locked).Error; err != nil {
+			return errors.New("product not found")
+		}
+		for _, variant := range variants {
+			variant.ProductID = product.ID
+			if variant.SKU != "" {
+				var clash int64
+				if err := tx.Model(&models.ProductVariant{}).
+					Where("product_id = ? AND sku = ? AND id <> ?", product.ID, variant.SKU, variant.ID).
+					Count(&clash).Error; err != nil {
+					return errors.New("failed to check variant sku")
+				}
+				if clash > 0 {
+					return errors.New("sku is already used by another variant of this product")
+				}
+			}
+			if err := tx.Save(variant).Error; err != nil {
+				return errors.New("failed to save product variant")
+			}
+		}
+		before := locked.Quantity
+		if err := syncVariantStock(tx, &locked); err != nil {
+			return err
+		}
+		if err := tx.Omit("PriceTiers", "Images", "Variants").Save(&locked).Error; err != nil {
+			return errors.New("failed to update product stock")
+		}
+		var variantID *uint
+		if len(variants) == 1 {
+			variantID = &variants[0].ID
+		}
+		return recordStockMovement(tx, &locked, locked.Quantity-before, models.StockMovement{
+			Kind:      "adjustment",
+			VariantID: variantID,
+			ActorID:   &locked.FarmerID,
+			Reference: "variant_change",
+		})
+	})
+}
+
+func findVariant(product *models.Product, variantID uint) *models.ProductVariant {
+	for i := range product.Variants {
+		if product.Variants[i].ID == variantID {
+			return &product.Variants[i]
+		}
+	}
+	return nil
+}
+
+// CreateProductVariant adds a grade, size or packaging to a listing.
+func (s *ProductService) CreateProductVariant(productID, farmerID uint, req ProductVariantRequest) (*models.Product, error) {
+	product, err := s.ownedProduct(productID, farmerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(product.Variants) >= maxProductVariants {
+		return nil, errors.New("a product can have at most 20 variants")
+	}
+	if len(product.PriceTiers) > 0 {
+		return nil, errors.New("remove the price tiers of this product before adding variants")
+	}
+	variant, err := buildProductVariant(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveVariants(product, &variant); err != nil {
+		return nil, err
+	}
+	return s.productRepo.GetByID(product.ID)
+}
+
+// UpdateProductVariant replaces a variant's attributes, price and stock. A
+// deactivated variant stays on past orders but can no longer be bought.
+func (s *ProductService) UpdateProductVariant(productID, variantID, farmerID uint, req ProductVariantRequest) (*models.Product, error) {
+	product, err := s.ownedProduct(productID, farmerID)
+	if err != nil {
+		return nil, err
+	}
+	existing := findVariant(product, variantID)
+	if existing == nil {
+		return nil, errors.New("product variant not found")
+	}
+	variant, err := buildProductVariant(req)
+	if err != nil {
+		return nil, err
+	}
+	variant.ID = existing.ID
+	variant.CreatedAt = existing.CreatedAt
+	variant.IsActive = req.IsActive
+	if err := s.saveVariants(product, &variant); err != nil {
+		return nil, err
+	}
+	return s.productRepo.GetByID(product.ID)
+}
+
+// DeactivateProductVariant takes a variant off sale. It is kept because
+// orders and carts refer to it.
+func (s *ProductService) DeactivateProductVariant(productID, variantID, farmerID uint) (*models.Product, error) {
+	product, err := s.ownedProduct(productID, farmerID)
+	if err != nil {
+		return nil, err
+	}
+	variant := findVariant(product, variantID)
+	if variant == nil {
+		return nil, errors.New("product variant not found")
+	}
+	variant.IsActive = false
+	if err := s.saveVariants(product, variant); err != nil {
+		return nil, err
+	}
+	return s.productRepo.GetByID(product.ID)
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestProductVariantsPriceAndReserveStockPerVariant(t *testing.T) {
+	ctx := setupTestCtx(t)
+	variantStock := func(id uint) float64 {
+		var variant models.ProductVariant
+		if err := ctx.db.First(&variant, id).Error; err != nil {
+			t.Fatalf("failed to load variant: %v", err)
+		}
+		return variant.Quantity
+	}
+	listing := func() *models.Product {
+		product, err := ctx.productRepo.GetByID(ctx.productID)
+		if err != nil {
+			t.Fatalf("failed to load product: %v", err)
+		}
+		return product
+	}
+
+	tiered := UpdatePriceTiersRequest{Tiers: []PriceTierInput{{MinQuantity: 5, PricePerUnit: 90}}}
+	if _, err := ctx.productSvc.UpdatePriceTiers(ctx.productID, ctx.farmerID, tiered); err != nil {
+		t.Fatalf("failed to set price tiers: %v", err)
+	}
+	if _, err := ctx.productSvc.CreateProductVariant(ctx.productID, ctx.farmerID, ProductVariantRequest{SKU: "TOM-X", PricePerUnit: 120, Quantity: 6}); err == nil {
+		t.Fatalf("expected variants to be rejected on a listing with price tiers")
+	}
+	if _, err := ctx.productSvc.UpdatePriceTiers(ctx.productID, ctx.farmerID, UpdatePriceTiersRequest{}); err != nil {
+		t.Fatalf("failed to clear price tiers: %v", err)
+	}
+
+	product, err := ctx.productSvc.CreateProductVariant(ctx.productID, ctx.farmerID, ProductVariantRequest{
+		SKU: "tom-a-5", Grade: "A", Packaging: "5 kg crate", PricePerUnit: 120, Quantity: 6, MinOrderQuantity: 2,
+	})
+	if err != nil || len(product.Variants) != 1 || product.Quantity != 6 || product.PricePerUnit != 120 {
+		t.Fatalf("unexpected listing after first variant: %+v err=%v", product, err)
+	}
+	if _, err := ctx.productSvc.CreateProductVariant(ctx.productID, ctx.farmerID, ProductVariantRequest{SKU: "TOM-A-5", Grade: "B", PricePerUnit: 80, Quantity: 20}); err == nil {
+		t.Fatalf("expected a duplicate sku to be rejected")
+	}
+	product, err = ctx.productSvc.CreateProductVariant(ctx.productID, ctx.farmerID, ProductVariantRequest{SKU: "TOM-B-25", Grade: "B", Packaging: "25 kg sack", PricePerUnit: 80, Quantity: 20})
+	if err != nil || product.Quantity != 26 || product.PricePerUnit != 80 {
+		t.Fatalf("unexpected listing after second variant: %+v err=%v", product, err)
+	}
+	gradeA, gradeB := product.Variants[0], product.Variants[1]
+
+	order := func(variantID uint, quantity float64) (*models.Order, error) {
+		return ctx.orderSvc.CreateOrder(ctx.buyerID, CreateOrderRequest{
+			ProductID: ctx.productID, VariantID: variantID, Quantity: quantity, DeliveryAddress: "Some address", PaymentMethod: "cod",
+		})
+	}
+	if _, err := order(0, 2); err == nil {
+		t.Fatalf("expected an order without a variant to be rejected")
+	}
+	if _, err := order(gradeA.ID, 1); err == nil {
+		t.Fatalf("expected the variant minimum order to apply")
+	}
+	if _, err := order(gradeA.ID, 7); err == nil {
+		t.Fatalf("expected variant stock to apply")
+	}
+	placed, err := order(gradeA.ID, 3)
+	if err != nil || placed.TotalPrice != 360 || placed.VariantSKU != "TOM-A-5" || placed.VariantLabel != "A / 5 kg crate" {
+		t.Fatalf("unexpected variant order: %+v err=%v", placed, err)
+	}
+	if variantStock(gradeA.ID) != 3 || variantStock(gradeB.ID) != 20 || listing().Quantity != 23 {
+		t.Fatalf("expected stock to come off the ordered variant only")
+	}
+
+	if err := ctx.cartSvc.AddToCart(ctx.buyerID, AddToCartRequest{ProductID: ctx.productID, Quantity: 5}); err == nil {
+		t.Fatalf("expected a cart line without a variant to be rejected")
+	}
+	if err := ctx.cartSvc.AddToCart(ctx.buyerID, AddToCartRequest{ProductID: ctx.productID, VariantID: gradeB.ID, Quantity: 5}); err != nil {
+		t.Fatalf("failed to add variant to cart: %v", err)
+	}
+	orders, err := ctx.cartSvc.Checkout(ctx.buyerID, "Cart address")
+	if err != nil || len(orders) != 1 || orders[0].TotalPrice != 400 || orders[0].VariantID == nil || *orders[0].VariantID != gradeB.ID {
+		t.Fatalf("unexpected checkout: %+v err=%v", orders, err)
+	}
+	if variantStock(gradeB.ID) != 15 || listing().Quantity != 18 {
+		t.Fatalf("expected checkout to reserve variant stock")
+	}
+
+	if _, err := ctx.orderSvc.RequestOrderAmendment(placed.ID, ctx.buyerID, RequestOrderAmendmentRequest{Quantity: 1}); err == nil {
+		t.Fatalf("expected an amendment below the variant minimum order to be rejected")
+	}
+	amendment, err := ctx.orderSvc.RequestOrderAmendment(placed.ID, ctx.buyerID, RequestOrderAmendmentRequest{Quantity: 4})
+	if err != nil {
+		t.Fatalf("failed to request amendment: %v", err)
+	}
+	if _, err := ctx.orderSvc.RespondToOrderAmendment(placed.ID, amendment.ID, ctx.farmerID, RespondOrderAmendmentRequest{Action: "approve"}); err != nil {
+		t.Fatalf("failed to approve amendment: %v", err)
+	}
+	if variantStock(gradeA.ID) != 2 || listing().Quantity != 17 {
+		t.Fatalf("expected the amendment to take variant stock")
+	}
+	if _, err := ctx.orderSvc.CancelOrder(placed.ID, ctx.buyerID, CancelOrderRequest{}); err != nil {
+		t.Fatalf("failed to cancel order: %v", err)
+	}
+	if variantStock(gradeA.ID) != 6 || listing().Quantity != 21 {
+		t.Fatalf("expected cancellation to restock the variant")
+	}
+
+	product, err = ctx.productSvc.DeactivateProductVariant(ctx.productID, gradeB.ID, ctx.farmerID)
+	if err != nil || product.Quantity != 6 || product.PricePerUnit != 120 {
+		t.Fatalf("unexpected listing after deactivation: %+v err=%v", product, err)
+	}
+	if _, err := order(gradeB.ID, 2); err == nil {
+		t.Fatalf("expected a deactivated variant to be rejected")
+	}
+	if _, err := ctx.productSvc.AdjustStock(ctx.productID, ctx.farmerID, StockAdjustmentRequest{Kind: "spoilage", Quantity: 5, VariantID: gradeB.ID, Note: "sacks got wet"}); err != nil {
+		t.Fatalf("failed to write off inactive variant stock: %v", err)
+	}
+	movements, err := ctx.productRepo.ListStockMovements(ctx.productID)
+	if err != nil || len(movements) == 0 {
+		t.Fatalf("failed to load stock movements: %v", err)
+	}
+	if writeOff := movements[0]; writeOff.Kind != "spoilage" || writeOff.VariantID == nil || *writeOff.VariantID != gradeB.ID || writeOff.Delta != 0 || writeOff.Quantity != 5 {
+		t.Fatalf("expected the inactive variant write-off to be recorded: %+v", writeOff)
+	}
+	if variantStock(gradeB.ID) != 10 || listing().Quantity != 6 {
+		t.Fatalf("expected the write-off to leave the listing's stock alone")
+	}
+	if _, err := ctx.productSvc.UpdateProductPrice(ctx.productID, ctx.farmerID, 50); err == nil {
+		t.Fatalf("expected listing prices to be managed per variant")
+	}
+	if _, err := ctx.productSvc.UpdatePriceTiers(ctx.productID, ctx.farmerID, tiered); err == nil {
+		t.Fatalf("expected price tiers to be rejected on a listing with variants")
+	}
+	category := models.TaxonomyNode{Kind: "category", Slug: "vegetables", Name: "Vegetables", Path: "/1/", IsActive: true}
+	if err := ctx.db.Create(&category).Error; err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	if _, err := ctx.productSvc.UpdateProduct(ctx.productID, ctx.farmerID, CreateProductRequest{CropName: "Tomato", CategoryID: category.ID, Unit: "kg"}); err != nil {
+		t.Fatalf("expected a listing priced by variants to stay editable: %v", err)
+	}
+}
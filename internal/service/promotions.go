@@ -0,0 +1,481 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PromotionRequest struct {
+	Name           string  `json:"name"`
+	Code           string  `json:"code"`
+	DiscountType   string  `json:"discount_type"`
+	DiscountValue  float64 `json:"discount_value"`
+	MaxDiscount    float64 `json:"max_discount"`
+	FundedBy       string  `json:"funded_by"`
+	FarmerID       *uint   `json:"farmer_id"`
+	CategoryID     uint    `json:"category_id"` // taxonomy category or crop
+	Category       string  `json:"category"`    // older clients: a category name or slug
+	ProductID      *uint   `json:"product_id"`
+	FirstOrderOnly bool    `json:"first_order_only"`
+	MinOrderValue  float64 `json:"min_order_value"`
+	UsageLimit     int     `json:"usage_limit"`
+	PerBuyerLimit  int     `json:"per_buyer_limit"`
+	StartsAt       string  `json:"starts_at"`
+	EndsAt         string  `json:"ends_at"`
+	IsActive       bool    `json:"is_active"`
+}
+
+type PromotionQuoteRequest struct {
+	ProductID  uint    `json:"product_id"`
+	VariantID  uint    `json:"variant_id"`
+	Quantity   float64 `json:"quantity"`
+	CouponCode string  `json:"coupon_code"`
+}
+
+// PromotionQuote is the discount a buyer would get on an order placed now.
+type PromotionQuote struct {
+	ListPrice float64           `json:"list_price"`
+	Discount  float64           `json:"discount"`
+	Total     float64           `json:"total"`
+	Promotion *models.Promotion `json:"promotion,omitempty"`
+	Currency  string            `json:"currency"`
+}
+
+// PromotionUsage is a promotion with how often it has been redeemed and what
+// it has cost so far. Cancelled orders do not count.
+type PromotionUsage struct {
+	Promotion   models.Promotion `json:"promotion"`
+	Redemptions int64            `json:"redemptions"`
+	Discounted  float64          `json:"discounted"`
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// promotionDiscount is what a promotion takes off an order's list price.
+func promotionDiscount(promotion models.Promotion, listPrice float64) float64 {
+	discount := promotion.DiscountValue
+	if promotion.DiscountType == "percent" {
+		discount = listPrice * promotion.DiscountValue / 100
+		if promotion.MaxDiscount > 0 && discount > promotion.MaxDiscount {
+			discount = promotion.MaxDiscount
+		}
+	}
+	if discount > listPrice {
+		discount = listPrice
+	}
+	return roundMoney(discount)
+}
+
+// promotionIneligibility explains why a promotion cannot apply to an order,
+// or returns "" when it can.
+func promotionIneligibility(tx *gorm.DB, promotion models.Promotion, order *models.Order, product models.Product, now time.Time) (string, error) {
+	switch {
+	case !promotion.IsActive:
+		return "coupon is not active", nil
+	case promotion.StartsAt != nil && now.Before(*promotion.StartsAt):
+		return "coupon is not valid yet", nil
+	case promotion.EndsAt != nil && !now.Before(*promotion.EndsAt):
+		return "coupon has expired", nil
+	case promotion.FarmerID != nil && *promotion.FarmerID != order.FarmerID:
+		return "coupon does not apply to this farmer's products", nil
+	case promotion.ProductID != nil && *promotion.ProductID != order.ProductID:
+		return "coupon does not apply to this product", nil
+	}
+	if promotion.CategoryID != nil || promotion.Category != "" {
+		placed, err := loadListingTaxonomy(tx, product)
+		if err != nil {
+			return "", err
+		}
+		if !placed.matches(promotion.CategoryID, promotion.Category) {
+			return "coupon does not apply to this category", nil
+		}
+	}
+	if promotion.MinOrderValue > 0 && order.TotalPrice < promotion.MinOrderValue {
+		return "order is below the coupon minimum of " + formatQuantity(promotion.MinOrderValue), nil
+	}
+
+	redeemed := func(buyerID uint) (int64, error) {
+		query := tx.Model(&models.Order{}).Where("promotion_id = ? AND status <> ?", promotion.ID, "cancelled")
+		if buyerID > 0 {
+			query = query.Where("buyer_id = ?", buyerID)
+		}
+		var count int64
+		err := query.Count(&count).Error
+		return count, err
+	}
+	if promotion.UsageLimit > 0 {
+		count, err := redeemed(0)
+		if err != nil {
+			return "", errors.New("failed to load promotion usage")
+		}
+		if count >= int64(promotion.UsageLimit) {
+			return "coupon has been fully redeemed", nil
+		}
+	}
+	if promotion.PerBuyerLimit > 0 {
+		count, err := redeemed(order.BuyerID)
+		if err != nil {
+			return "", errors.New("failed to load promotion usage")
+		}
+		if count >= int64(promotion.PerBuyerLimit) {
+			return "you have already used this coupon", nil
+		}
+	}
+	if promotion.FirstOrderOnly {
+		var previous int64
+		if err := tx.Model(&models.Order{}).
+			Where("buyer_id = ? AND status <> ?", order.BuyerID, "cancelled").
+			Count(&previous).Error; err != nil {
+			return "", errors.New("failed to load buyer orders")
+		}
+		if previous > 0 {
+			return "coupon is only valid on a first order", nil
+		}
+	}
+	return "", nil
+}
+
+// selectPromotion returns the promotion for a new order: the coupon when one
+// is entered, otherwise the automatic promotion with the biggest discount.
+// Promotions never stack. Candidates are ranked without locks; only the one
+// that is used is locked, and its limits are checked again under the lock.
+func selectPromotion(tx *gorm.DB, order *models.Order, product models.Product, code string, now time.Time) (*models.Promotion, error) {
+	var candidates []models.Promotion
+	query := tx.Where("is_active = ?", true)
+	if code != "" {
+		query = query.Where("code = ?", code)
+	} else {
+		query = query.Where("code = ? OR code IS NULL", "")
+	}
+	if err := query.Order("id ASC").Find(&candidates).Error; err != nil {
+		return nil, errors.New("failed to load promotions")
+	}
+	if code != "" && len(candidates) == 0 {
+		return nil, errors.New("invalid coupon code")
+	}
+
+	eligible := make([]models.Promotion, 0, len(candidates))
+	for _, candidate := range candidates {
+		reason, err := promotionIneligibility(tx, candidate, order, product, now)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			if code != "" {
+				return nil, errors.New(reason)
+			}
+			continue
+		}
+		if promotionDiscount(candidate, order.TotalPrice) > 0 {
+			eligible = append(eligible, candidate)
+		}
+	}
+	sort.SliceStable(eligible, func(i, j int) bool {
+		return promotionDiscount(eligible[i], order.TotalPrice) > promotionDiscount(eligible[j], order.TotalPrice)
+	})
+
+	for _, candidate := range eligible {
+		var locked models.Promotion
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", candidate.ID).First(&locked).Error; err != nil {
+			return nil, errors.New("failed to load promotions")
+		}
+		reason, err := promotionIneligibility(tx, locked, order, product, now)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			if code != "" {
+				return nil, errors.New(reason)
+			}
+			continue
+		}
+		return &locked, nil
+	}
+	return nil, nil
+}
+
+// applyPromotion takes the chosen promotion off a new order priced at its
+// list price and re-applies the fee terms to what is left.
+func applyPromotion(tx *gorm.DB, order *models.Order, product models.Product, code string, now time.Time) error {
+	promotion, err := selectPromotion(tx, order, product, normalizeCouponCode(code), now)
+	if err != nil || promotion == nil {
+		return err
+	}
+	discount := promotionDiscount(*promotion, order.TotalPrice)
+	if discount <= 0 {
+		return nil
+	}
+	order.PromotionID = &promotion.ID
+	order.PromotionCode = promotion.Code
+	order.DiscountAmount = discount
+	order.DiscountFundedBy = promotion.FundedBy
+	order.TotalPrice = roundMoney(order.TotalPrice - discount)
+	refreshPlatformFee(order)
+	return nil
+}
+
+func buildPromotion(req PromotionRequest, promotion *models.Promotion) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return errors.New("promotion name is required")
+	}
+	discountType := strings.ToLower(strings.TrimSpace(req.DiscountType))
+	switch discountType {
+	case "percent":
+		if req.DiscountValue <= 0 || req.DiscountValue > 100 {
+			return errors.New("percent discount must be between 0 and 100")
+		}
+	case "flat":
+		if req.DiscountValue <= 0 {
+			return errors.New("discount must be greater than 0")
+		}
+	default:
+		return errors.New("discount type must be percent or flat")
+	}
+	fundedBy := strings.ToLower(strings.TrimSpace(req.FundedBy))
+	if fundedBy == "" {
+		fundedBy = "platform"
+	}
+	if fundedBy != "platform" && fundedBy != "farmer" {
+		return errors.New("promotion must be funded by the platform or the farmer")
+	}
+	if fundedBy == "farmer" && req.FarmerID == nil {
+		return errors.New("a farmer-funded promotion must name the farmer")
+	}
+	if req.MaxDiscount < 0 || req.MinOrderValue < 0 || req.UsageLimit < 0 || req.PerBuyerLimit < 0 {
+		return errors.New("promotion limits cannot be negative")
+	}
+	startsAt, err := parseOptionalRFC3339(req.StartsAt)
+	if err != nil {
+		return err
+	}
+	endsAt, err := parseOptionalRFC3339(req.EndsAt)
+	if err != nil {
+		return err
+	}
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		return errors.New("promotion must end after it starts")
+	}
+
+	promotion.Name = utils.SanitizeString(name)
+	promotion.Code = normalizeCouponCode(req.Code)
+	promotion.DiscountType = discountType
+	promotion.DiscountValue = req.DiscountValue
+	promotion.MaxDiscount = req.MaxDiscount
+	promotion.FundedBy = fundedBy
+	promotion.FarmerID = req.FarmerID
+	promotion.ProductID = req.ProductID
+	promotion.FirstOrderOnly = req.FirstOrderOnly
+	promotion.MinOrderValue = req.MinOrderValue
+	promotion.UsageLimit = req.UsageLimit
+	promotion.PerBuyerLimit = req.PerBuyerLimit
+	promotion.StartsAt = startsAt
+	promotion.EndsAt = endsAt
+	promotion.IsActive = req.IsActive
+	return nil
+}
+
+func describePromotion(promotion models.Promotion) string {
+	note := promotion.Name + ": " + formatQuantity(promotion.DiscountValue)
+	if promotion.DiscountType == "percent" {
+		note += "%"
+	}
+	note += " off, " + promotion.FundedBy + "-funded"
+	if promotion.Code != "" {
+		note += ", code " + promotion.Code
+	}
+	if !promotion.IsActive {
+		note += " (inactive)"
+	}
+	return note
+}
+
+// savePromotion creates or updates a promotion. A non-zero farmerID limits the
+// change to that farmer's own farmer-funded promotions.
+func savePromotion(tx *gorm.DB, actorID, farmerID, promotionID uint, req PromotionRequest) (*models.Promotion, string, error) {
+	var promotion models.Promotion
+	action := "create_promotion"
+	if promotionID > 0 {
+		if err := tx.Where("id = ?", promotionID).First(&promotion).Error; err != nil {
+			return nil, "", errors.New("promotion not found")
+		}
+		if farmerID > 0 && (promotion.FarmerID == nil || *promotion.FarmerID != farmerID) {
+			return nil, "", errors.New("unauthorized: you can only edit your own promotions")
+		}
+		action = "update_promotion"
+	}
+	if farmerID > 0 {
+		req.FarmerID = &farmerID
+		req.FundedBy = "farmer"
+		if req.ProductID != nil {
+			var product models.Product
+			if err := tx.Where("id = ? AND farmer_id = ?", *req.ProductID, farmerID).First(&product).Error; err != nil {
+				return nil, "", errors.New("product not found")
+			}
+		}
+	}
+	if err := buildPromotion(req, &promotion); err != nil {
+		return nil, "", err
+	}
+	node, err := resolveRuleTaxonomy(tx, req.CategoryID, req.Category)
+	if err != nil {
+		return nil, "", err
+	}
+	promotion.CategoryID, promotion.Category = nil, ""
+	if node != nil {
+		promotion.CategoryID, promotion.Category = &node.ID, node.Slug
+	}
+	if promotion.Code != "" {
+		var clashes int64
+		if err := tx.Model(&models.Promotion{}).Where("code = ? AND id <> ?", promotion.Code, promotion.ID).Count(&clashes).Error; err != nil {
+			return nil, "", errors.New("failed to check coupon code")
+		}
+		if clashes > 0 {
+			return nil, "", errors.New("coupon code is already in use")
+		}
+	}
+	if promotion.ID == 0 {
+		promotion.CreatedBy = actorID
+	}
+	promotion.UpdatedBy = &actorID
+	if err := tx.Save(&promotion).Error; err != nil {
+		return nil, "", errors.New("failed to save promotion")
+	}
+	return &promotion, action, nil
+}
+
+func loadPromotionUsage(db *gorm.DB, promotions []models.Promotion) ([]PromotionUsage, error) {
+	items := make([]PromotionUsage, 0, len(promotions))
+	for _, promotion := range promotions {
+		var usage struct {
+			Count int64
+			Total float64
+		}
+		if err := db.Model(&models.Order{}).
+			Select("COUNT(*) AS count, COALESCE(SUM(discount_amount), 0) AS total").
+			Where("promotion_id = ? AND status <> ?", promotion.ID, "cancelled").
+			Scan(&usage).Error; err != nil {
+			return nil, errors.New("failed to load promotion usage")
+		}
+		items = append(items, PromotionUsage{Promotion: promotion, Redemptions: usage.Count, Discounted: roundMoney(usage.Total)})
+	}
+	return items, nil
+}
+
+func (s *AdminService) GetPromotions() ([]PromotionUsage, error) {
+	var promotions []models.Promotion
+	if err := s.orderRepo.GetDB().Order("id DESC").Find(&promotions).Error; err != nil {
+		return nil, errors.New("failed to load promotions")
+	}
+	return loadPromotionUsage(s.orderRepo.GetDB(), promotions)
+}
+
+func (s *AdminService) CreatePromotion(adminID uint, req PromotionRequest) (*models.Promotion, error) {
+	return s.savePromotion(adminID, 0, req)
+}
+
+// UpdatePromotion changes a promotion for future orders only; orders already
+// placed keep the discount they were given.
+func (s *AdminService) UpdatePromotion(adminID, promotionID uint, req PromotionRequest) (*models.Promotion, error) {
+	return s.savePromotion(adminID, promotionID, req)
+}
+
+func (s *AdminService) savePromotion(adminID, promotionID uint, req PromotionRequest) (*models.Promotion, error) {
+	var promotion *models.Promotion
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		saved, action, err := savePromotion(tx, adminID, 0, promotionID, req)
+		if err != nil {
+			return err
+		}
+		promotion = saved
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "promotion",
+			TargetID:   promotion.ID,
+			Action:     action,
+			Note:       describePromotion(*promotion),
+			CreatedAt:  time.Now().UTC(),
+		}).Error; err != nil {
+			return errors.New("failed to audit promotion")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+func (s *OrderService) GetFarmerPromotions(farmerID uint) ([]PromotionUsage, error) {
+	var promotions []models.Promotion
+	if err := s.orderRepo.GetDB().Where("farmer_id = ?", farmerID).Order("id DESC").Find(&promotions).Error; err != nil {
+		return nil, errors.New("failed to load promotions")
+	}
+	return loadPromotionUsage(s.orderRepo.GetDB(), promotions)
+}
+
+// SaveFarmerPromotion lets a farmer fund a discount on their own produce.
+func (s *OrderService) SaveFarmerPromotion(farmerID, promotionID uint, req PromotionRequest) (*models.Promotion, error) {
+	var promotion *models.Promotion
+	err := s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		saved, _, err := savePromotion(tx, farmerID, farmerID, promotionID, req)
+		promotion = saved
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+// QuotePromotion prices an order the buyer is about to place, with the
+// promotion it would get.
+func (s *OrderService) QuotePromotion(buyerID uint, req PromotionQuoteRequest) (*PromotionQuote, error) {
+	if req.Quantity <= 0 {
+		return nil, errors.New("quantity must be greater than 0")
+	}
+	db := s.orderRepo.GetDB()
+	var product models.Product
+	if err := db.Where("id = ?", req.ProductID).First(&product).Error; err != nil {
+		return nil, errors.New("product not found")
+	}
+	var tiers []models.ProductPriceTier
+	if err := db.Where("product_id = ?", product.ID).Order("min_quantity ASC").Find(&tiers).Error; err != nil {
+		return nil, errors.New("failed to load price tiers")
+	}
+	order := &models.Order{
+		ProductID:  product.ID,
+		BuyerID:    buyerID,
+		FarmerID:   product.FarmerID,
+		Quantity:   req.Quantity,
+		TotalPrice: roundMoney(req.Quantity * tierUnitPrice(product.PricePerUnit, tiers, req.Quantity)),
+	}
+	if req.VariantID != 0 {
+		var variant models.ProductVariant
+		if err := db.Where("id = ? AND product_id = ?", req.VariantID, product.ID).First(&variant).Error; err != nil {
+			return nil, errors.New("product variant not found")
+		}
+		applyOrderVariant(order, &variant)
+		order.TotalPrice = roundMoney(order.TotalPrice)
+	}
+	quote := &PromotionQuote{ListPrice: order.TotalPrice, Total: order.TotalPrice, Currency: "INR"}
+	promotion, err := selectPromotion(db, order, product, normalizeCouponCode(req.CouponCode), time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if promotion != nil {
+		quote.Promotion = promotion
+		quote.Discount = promotionDiscount(*promotion, order.TotalPrice)
+		quote.Total = roundMoney(order.TotalPrice - quote.Discount)
+	}
+	return quote, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestPromotionsDiscountOrdersInvoicesAndPayouts(t *testing.T) {
+	ctx := setupTestCtx(t)
+	adminSvc := newAdminServiceForTest(ctx)
+	placeOrder := func(quantity float64, coupon string) (*models.Order, error) {
+		return ctx.orderSvc.CreateOrder(ctx.buyerID, CreateOrderRequest{
+			ProductID:       ctx.productID,
+			Quantity:        quantity,
+			DeliveryAddress: "Some address",
+			PaymentMethod:   "cod",
+			CouponCode:      coupon,
+		})
+	}
+
+	if _, err := adminSvc.CreatePromotion(99, PromotionRequest{Name: "Welcome", DiscountType: "percent", DiscountValue: 10, FirstOrderOnly: true, IsActive: true}); err != nil {
+		t.Fatalf("failed to create automatic promotion: %v", err)
+	}
+	if _, err := ctx.orderSvc.SaveFarmerPromotion(ctx.farmerID, 0, PromotionRequest{Name: "Clear tomatoes", DiscountType: "percent", DiscountValue: 120, IsActive: true}); err == nil {
+		t.Fatalf("expected a discount over 100%% to be rejected")
+	}
+	coupon, err := ctx.orderSvc.SaveFarmerPromotion(ctx.farmerID, 0, PromotionRequest{
+		Name: "Clear tomatoes", Code: " tomato50 ", DiscountType: "flat", DiscountValue: 50,
+		FundedBy: "platform", MinOrderValue: 300, PerBuyerLimit: 1, IsActive: true,
+	})
+	if err != nil || coupon.Code != "TOMATO50" || coupon.FundedBy != "farmer" || coupon.FarmerID == nil || *coupon.FarmerID != ctx.farmerID {
+		t.Fatalf("unexpected farmer promotion: %+v err=%v", coupon, err)
+	}
+
+	quote, err := ctx.orderSvc.QuotePromotion(ctx.buyerID, PromotionQuoteRequest{ProductID: ctx.productID, Quantity: 2})
+	if err != nil || quote.Discount != 20 || quote.Total != 180 || quote.Promotion == nil || quote.Promotion.Name != "Welcome" {
+		t.Fatalf("unexpected quote: %+v err=%v", quote, err)
+	}
+
+	// The platform funds the first-order discount, so the farmer is paid as if
+	// the buyer had paid the list price.
+	first, err := placeOrder(2, "")
+	if err != nil || first.DiscountAmount != 20 || first.TotalPrice != 180 || first.DiscountFundedBy != "platform" || first.PlatformFee != 10 {
+		t.Fatalf("unexpected discounted order: %+v err=%v", first, err)
+	}
+	completeOrderForTest(t, ctx, first.ID)
+	invoice, err := ctx.orderSvc.GetFarmerInvoice(first.ID, ctx.farmerID)
+	if err != nil || invoice.DiscountAmount != 20 || invoice.PromotionSubsidy != 20 || invoice.PlatformFee != 10 || invoice.NetPayout != 190 {
+		t.Fatalf("unexpected invoice: %+v err=%v", invoice, err)
+	}
+	if invoice.TaxInvoice == nil || invoice.TaxInvoice.Discount != 20 || invoice.TaxInvoice.TotalAmount != 180 {
+		t.Fatalf("expected the tax invoice to carry the discount: %+v", invoice.TaxInvoice)
+	}
+
+	if _, err := placeOrder(2, "TOMATO50"); err == nil || !strings.Contains(err.Error(), "minimum") {
+		t.Fatalf("expected the coupon minimum to apply, got %v", err)
+	}
+	if _, err := placeOrder(4, "NOPE"); err == nil {
+		t.Fatalf("expected an unknown coupon to be rejected")
+	}
+	// The farmer funds their own coupon out of the sale.
+	second, err := placeOrder(4, "tomato50")
+	if err != nil || second.DiscountAmount != 50 || second.TotalPrice != 350 || second.DiscountFundedBy != "farmer" || second.PlatformFee != 17.5 {
+		t.Fatalf("unexpected coupon order: %+v err=%v", second, err)
+	}
+	if _, err := placeOrder(4, "TOMATO50"); err == nil {
+		t.Fatalf("expected the per-buyer limit to apply")
+	}
+	completeOrderForTest(t, ctx, second.ID)
+
+	summary, err := ctx.orderSvc.GetFarmerPayoutSummary(ctx.farmerID)
+	if err != nil || summary.FarmerDiscounts != 50 || summary.PromotionSubsidy != 20 || summary.PlatformFee != 27.5 || summary.NetPayout != 522.5 {
+		t.Fatalf("unexpected payout summary: %+v err=%v", summary, err)
+	}
+
+	promotions, err := adminSvc.GetPromotions()
+	if err != nil || len(promotions) != 2 {
+		t.Fatalf("unexpected promotions: %+v err=%v", promotions, err)
+	}
+	for _, item := range promotions {
+		if item.Redemptions != 1 {
+			t.Fatalf("expected one redemption per promotion, got %+v", item)
+		}
+	}
+
+	pdf, _, err := ctx.orderSvc.RenderOrderInvoicePDF(first.ID, ctx.buyerID)
+	if err != nil || len(pdf) == 0 {
+		t.Fatalf("failed to render discounted invoice: %v", err)
+	}
+}
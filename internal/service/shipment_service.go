@@ -0,0 +1,240 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+type ShipmentService struct {
+	shipmentRepo *repository.ShipmentRepository
+	orderRepo    *repository.OrderRepository
+	couriers     map[string]CourierAdapter
+}
+
+func NewShipmentService(shipmentRepo *repository.ShipmentRepository, orderRepo *repository.OrderRepository, couriers ...CourierAdapter) *ShipmentService {
+	registry := make(map[string]CourierAdapter, len(couriers))
+	for _, courier := range couriers {
+		registry[courier.Code()] = courier
+	}
+	return &ShipmentService{
+		shipmentRepo: shipmentRepo,
+		orderRepo:    orderRepo,
+		couriers:     registry,
+	}
+}
+
+type CreateShipmentRequest struct {
+	Carrier           string `json:"carrier"`
+	TrackingNumber    string `json:"tracking_number"`
+	EstimatedDelivery string `json:"estimated_delivery"`
+}
+
+type OrderTimelineItem struct {
+	Type       string `json:"type"` // status/tracking
+	Status     string `json:"status"`
+	FromStatus string `json:"from_status,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Category   string `json:"category,omitempty"`
+	Location   string `json:"location,omitempty"`
+	Note       string `json:"note"`
+	OccurredAt string `json:"occurred_at"`
+
+	occurredAt time.Time
+}
+
+func (s *ShipmentService) CreateShipment(orderID, farmerID uint, req CreateShipmentRequest) (*models.Shipment, error) {
+	carrier := strings.ToLower(strings.TrimSpace(req.Carrier))
+	if _, ok := s.couriers[carrier]; !ok {
+		return nil, errors.New("unsupported carrier")
+	}
+	trackingNumber := utils.SanitizeString(req.TrackingNumber)
+	if trackingNumber == "" {
+		return nil, errors.New("tracking number is required")
+	}
+	estimated, err := parseOptionalRFC3339(req.EstimatedDelivery)
+	if err != nil {
+		return nil, errors.New("invalid estimated delivery format")
+	}
+
+	order, err := s.orderRepo.GetByID(orderID)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	if order.FarmerID != farmerID {
+		return nil, errors.New("unauthorized: you can only ship your own orders")
+	}
+	if order.Status != "confirmed" && order.Status != "packed" && order.Status != "out_for_delivery" {
+		return nil, errors.New("shipments can only be created for confirmed, packed or dispatched orders")
+	}
+	if existing, err := s.shipmentRepo.GetByOrderID(orderID); err == nil && existing.Status != "returned" {
+		return nil, errors.New("order already has an active shipment")
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("failed to load shipment")
+	}
+	if existing, err := s.shipmentRepo.GetByCarrierAndTracking(carrier, trackingNumber); err == nil && existing.OrderID != orderID {
+		return nil, errors.New("tracking number is already in use")
+	}
+
+	now := time.Now().UTC()
+	shipment := &models.Shipment{
+		OrderID:           orderID,
+		Carrier:           carrier,
+		TrackingNumber:    trackingNumber,
+		Status:            "created",
+		EstimatedDelivery: estimated,
+		LastEventAt:       &now,
+		CreatedBy:         farmerID,
+	}
+	err = s.shipmentRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(shipment).Error; err != nil {
+			return errors.New("failed to create shipment")
+		}
+		if err := tx.Create(&models.ShipmentEvent{
+			ShipmentID:  shipment.ID,
+			OrderID:     orderID,
+			Status:      "created",
+			Description: "Shipment booked with " + carrier + " (" + trackingNumber + ")",
+			Source:      "farmer",
+			OccurredAt:  now,
+			CreatedAt:   now,
+		}).Error; err != nil {
+			return errors.New("failed to record shipment event")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.shipmentRepo.GetByOrderID(orderID)
+}
+
+func (s *ShipmentService) GetShipment(orderID, userID uint) (*models.Shipment, error) {
+	order, err := s.orderRepo.GetByID(orderID)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	if order.BuyerID != userID && order.FarmerID != userID {
+		return nil, errors.New("unauthorized access to order")
+	}
+	shipment, err := s.shipmentRepo.GetByOrderID(orderID)
+	if err != nil {
+		return nil, errors.New("no shipment found for this order")
+	}
+	return shipment, nil
+}
+
+// IngestWebhook verifies and applies a courier webhook. It returns the number
+// of tracking events recorded; events already seen are skipped so couriers can
+// safely retry deliveries.
+func (s *ShipmentService) IngestWebhook(carrier string, payload []byte, signature string) (int, error) {
+	adapter, ok := s.couriers[strings.ToLower(strings.TrimSpace(carrier))]
+	if !ok {
+		return 0, errors.New("unsupported carrier")
+	}
+	if err := adapter.VerifyWebhook(payload, signature); err != nil {
+		return 0, err
+	}
+	updates, err := adapter.ParseWebhook(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	for _, update := range updates {
+		if update.TrackingNumber == "" || !isAllowedShipmentStatus(update.Status) {
+			continue
+		}
+		shipment, err := s.shipmentRepo.GetByCarrierAndTracking(adapter.Code(), update.TrackingNumber)
+		if err != nil {
+			continue
+		}
+		if update.ExternalEventID != "" {
+			seen, err := s.shipmentRepo.HasEvent(shipment.ID, update.ExternalEventID)
+			if err != nil {
+				return recorded, errors.New("failed to check shipment events")
+			}
+			if seen {
+				continue
+			}
+		}
+
+		if err := s.shipmentRepo.CreateEvent(&models.ShipmentEvent{
+			ShipmentID:      shipment.ID,
+			OrderID:         shipment.OrderID,
+			Status:          update.Status,
+			Location:        update.Location,
+			Description:     update.Description,
+			ExternalEventID: update.ExternalEventID,
+			Source:          "webhook",
+			OccurredAt:      update.OccurredAt,
+			CreatedAt:       time.Now().UTC(),
+		}); err != nil {
+			return recorded, errors.New("failed to record shipment event")
+		}
+		recorded++
+
+		// Couriers may deliver events out of order; only move the shipment
+		// forward for the most recent one.
+		if shipment.LastEventAt == nil || !update.OccurredAt.Before(*shipment.LastEventAt) {
+			occurredAt := update.OccurredAt
+			shipment.Status = update.Status
+			shipment.LastEventAt = &occurredAt
+			if update.Location != "" {
+				shipment.LastLocation = update.Location
+			}
+			if err := s.shipmentRepo.Update(shipment); err != nil {
+				return recorded, errors.New("failed to update shipment")
+			}
+		}
+	}
+	return recorded, nil
+}
+
+// GetOrderTimeline merges order status logs and courier tracking events into a
+// single chronological feed.
+func (s *ShipmentService) GetOrderTimeline(orderID, userID uint) ([]OrderTimelineItem, error) {
+	order, err := s.orderRepo.GetByID(orderID)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	if order.BuyerID != userID && order.FarmerID != userID {
+		return nil, errors.New("unauthorized access to order")
+	}
+	events, err := s.shipmentRepo.GetEventsByOrder(orderID)
+	if err != nil {
+		return nil, errors.New("failed to load tracking events")
+	}
+
+	items := make([]OrderTimelineItem, 0, len(order.StatusLogs)+len(events))
+	for _, log := range order.StatusLogs {
+		items = append(items, OrderTimelineItem{
+			Type:       "status",
+			Status:     log.ToStatus,
+			FromStatus: log.FromStatus,
+			Reason:     log.Reason,
+			Category:   log.Category,
+			Note:       log.Note,
+			OccurredAt: log.CreatedAt.Format(time.RFC3339),
+			occurredAt: log.CreatedAt,
+		})
+	}
+	for _, event := range events {
+		items = append(items, OrderTimelineItem{
+			Type:       "tracking",
+			Status:     event.Status,
+			Location:   event.Location,
+			Note:       event.Description,
+			OccurredAt: event.OccurredAt.Format(time.RFC3339),
+			occurredAt: event.OccurredAt,
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].occurredAt.Before(items[j].occurredAt) })
+	return items, nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/repository"
+)
+
+func TestShipmentWebhookAppendsTrackingToTimeline(t *testing.T) {
+	ctx := setupTestCtx(t)
+	order := createOrderForTest(t, ctx)
+	if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(order.ID, ctx.farmerID, UpdateOrderStatusRequest{Status: "confirmed"}); err != nil {
+		t.Fatalf("failed to confirm order: %v", err)
+	}
+
+	courier := NewMockCourier("test-secret")
+	svc := NewShipmentService(repository.NewShipmentRepository(ctx.db), repository.NewOrderRepository(ctx.db), courier)
+
+	if _, err := svc.CreateShipment(order.ID, ctx.buyerID, CreateShipmentRequest{Carrier: "mock", TrackingNumber: "AWB1"}); err == nil {
+		t.Fatalf("expected buyer shipment creation to fail")
+	}
+	shipment, err := svc.CreateShipment(order.ID, ctx.farmerID, CreateShipmentRequest{Carrier: "mock", TrackingNumber: "AWB1"})
+	if err != nil {
+		t.Fatalf("failed to create shipment: %v", err)
+	}
+	if shipment.Status != "created" {
+		t.Fatalf("expected created shipment, got %s", shipment.Status)
+	}
+
+	payload := []byte(`{"events":[{"awb":"AWB1","status":"in_transit","location":"Madurai Hub","event_id":"evt-1","occurred_at":"2030-01-01T10:00:00Z"}]}`)
+	if _, err := svc.IngestWebhook("mock", payload, "bad-signature"); err == nil {
+		t.Fatalf("expected unsigned webhook to be rejected")
+	}
+	recorded, err := svc.IngestWebhook("mock", payload, courier.Sign(payload))
+	if err != nil {
+		t.Fatalf("failed to ingest webhook: %v", err)
+	}
+	if recorded != 1 {
+		t.Fatalf("expected 1 recorded event, got %d", recorded)
+	}
+	if recorded, _ := svc.IngestWebhook("mock", payload, courier.Sign(payload)); recorded != 0 {
+		t.Fatalf("expected duplicate event to be skipped, got %d", recorded)
+	}
+
+	updated, err := svc.GetShipment(order.ID, ctx.buyerID)
+	if err != nil {
+		t.Fatalf("failed to load shipment: %v", err)
+	}
+	if updated.Status != "in_transit" || updated.LastLocation != "Madurai Hub" {
+		t.Fatalf("unexpected shipment state: %s at %s", updated.Status, updated.LastLocation)
+	}
+
+	timeline, err := svc.GetOrderTimeline(order.ID, ctx.buyerID)
+	if err != nil {
+		t.Fatalf("failed to load timeline: %v", err)
+	}
+	statusItems, trackingItems := 0, 0
+	for _, item := range timeline {
+		switch item.Type {
+		case "status":
+			statusItems++
+		case "tracking":
+			trackingItems++
+		}
+	}
+	if statusItems != 2 || trackingItems != 2 {
+		t.Fatalf("expected 2 status and 2 tracking items, got %d and %d", statusItems, trackingItems)
+	}
+	if timeline[len(timeline)-1].Status != "in_transit" {
+		t.Fatalf("expected latest timeline item to be in_transit, got %s", timeline[len(timeline)-1].Status)
+	}
+}
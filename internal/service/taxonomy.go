@@ -0,0 +1,403 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TaxonomyNameInput struct {
+	Language string `json:"language"` // e.g. hi, ta, mr
+	Name     string `json:"name"`
+}
+
+type TaxonomyNodeRequest struct {
+	ParentID  uint   `json:"parent_id"` // 0 for a top-level category
+	Kind      string `json:"kind"`      // category/crop; fixed once created
+	Name      string `json:"name"`
+	Slug      string `json:"slug"` // generated from the name when empty; fixed once created
+	SortOrder int    `json:"sort_order"`
+	// ShelfLifeDays sets the default best-before of listings under this
+	// node; 0 inherits the parent's.
+	ShelfLifeDays int                 `json:"shelf_life_days"`
+	IsActive      bool                `json:"is_active"`
+	LocalNames    []TaxonomyNameInput `json:"local_names"`
+	Aliases       []string            `json:"aliases"`
+}
+
+var taxonomySlugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+func taxonomySlug(text string) string {
+	return strings.Trim(taxonomySlugPattern.ReplaceAllString(strings.ToLower(strings.TrimSpace(text)), "-"), "-")
+}
+
+// taxonomyRootID reads the top-level node id from a path like "/1/4/9/".
+func taxonomyRootID(path string) uint {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	id, _ := strconv.ParseUint(parts[0], 10, 32)
+	return uint(id)
+}
+
+// buildTaxonomyTree nests nodes under their parents. Nodes whose parent is
+// missing from the list, such as children of an inactive category, are left
+// out.
+func buildTaxonomyTree(nodes []models.TaxonomyNode) []models.TaxonomyNode {
+	children := make(map[uint][]models.TaxonomyNode)
+	roots := make([]models.TaxonomyNode, 0)
+	for _, node := range nodes {
+		if node.ParentID == nil {
+			roots = append(roots, node)
+		} else {
+			children[*node.ParentID] = append(children[*node.ParentID], node)
+		}
+	}
+	var attach func(items []models.TaxonomyNode) []models.TaxonomyNode
+	attach = func(items []models.TaxonomyNode) []models.TaxonomyNode {
+		for i := range items {
+			items[i].Children = attach(children[items[i].ID])
+		}
+		return items
+	}
+	return attach(roots)
+}
+
+// resolveProductTaxonomy works out a listing's taxonomy ids and top-level
+// category slug from the ids in the request or, for older clients, the
+// free-text category, which may name a category or a crop.
+func resolveProductTaxonomy(repo *repository.ProductRepository, categoryID, cropID uint, text string) (*uint, *uint, string, error) {
+	var node *models.TaxonomyNode
+	var err error
+	switch {
+	case cropID > 0:
+		node, err = repo.GetTaxonomyNode(cropID)
+		if err != nil || node.Kind != "crop" {
+			return nil, nil, "", errors.New("crop not found")
+		}
+	case categoryID > 0:
+		node, err = repo.GetTaxonomyNode(categoryID)
+		if err != nil || node.Kind != "category" {
+			return nil, nil, "", errors.New("category not found")
+		}
+	case strings.TrimSpace(text) != "":
+		node, err = repo.FindTaxonomyNode(text)
+		if err != nil {
+			return nil, nil, "", errors.New("unknown category: choose one from the crop taxonomy")
+		}
+	default:
+		return nil, nil, "", errors.New("category is required")
+	}
+	if !node.IsActive {
+		return nil, nil, "", errors.New("category is no longer available")
+	}
+
+	var category, crop *uint
+	if node.Kind == "crop" {
+		crop = &node.ID
+		category = node.ParentID
+		if categoryID > 0 {
+			parent, err := repo.GetTaxonomyNode(categoryID)
+			if err != nil || !strings.HasPrefix(node.Path, parent.Path) {
+				return nil, nil, "", errors.New("crop does not belong to this category")
+			}
+			category = &parent.ID
+		}
+	} else {
+		category = &node.ID
+	}
+	root := node
+	if rootID := taxonomyRootID(node.Path); rootID != node.ID {
+		if root, err = repo.GetTaxonomyNode(rootID); err != nil {
+			return nil, nil, "", errors.New("category not found")
+		}
+	}
+	return category, crop, root.Slug, nil
+}
+
+// resolveRuleTaxonomy validates the taxonomy node a promotion, fee rule or
+// tax rate is limited to: a category or crop id or, for older clients, text
+// naming one. Nothing given means the rule applies to every listing.
+func resolveRuleTaxonomy(tx *gorm.DB, nodeID uint, text string) (*models.TaxonomyNode, error) {
+	repo := repository.NewProductRepository(tx)
+	var node *models.TaxonomyNode
+	var err error
+	switch {
+	case nodeID > 0:
+		if node, err = repo.GetTaxonomyNode(nodeID); err != nil {
+			return nil, errors.New("category not found")
+		}
+	case strings.TrimSpace(text) != "":
+		if node, err = repo.FindTaxonomyNode(text); err != nil {
+			return nil, errors.New("unknown category: choose one from the crop taxonomy")
+		}
+	default:
+		return nil, nil
+	}
+	if !node.IsActive {
+		return nil, errors.New("category is no longer available")
+	}
+	return node, nil
+}
+
+// listingTaxonomy is where a listing sits in the taxonomy: the node ids on
+// its path, nearest first, and its top-level category slug.
+type listingTaxonomy struct {
+	nodeIDs  []uint
+	category string
+}
+
+func loadListingTaxonomy(tx *gorm.DB, product models.Product) (listingTaxonomy, error) {
+	placed := listingTaxonomy{category: strings.ToLower(strings.TrimSpace(product.Category))}
+	nodeID := product.CropID
+	if nodeID == nil {
+		nodeID = product.CategoryID
+	}
+	if nodeID == nil {
+		return placed, nil
+	}
+	var node models.TaxonomyNode
+	if err := tx.Select("path").Where("id = ?", *nodeID).First(&node).Error; err != nil {
+		return placed, errors.New("failed to load listing category")
+	}
+	parts := strings.Split(strings.Trim(node.Path, "/"), "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if id, err := strconv.ParseUint(parts[i], 10, 32); err == nil {
+			placed.nodeIDs = append(placed.nodeIDs, uint(id))
+		}
+	}
+	return placed, nil
+}
+
+// matches reports whether a rule limited to nodeID, or for rules saved
+// before the taxonomy to the category slug, covers the listing.
+func (t listingTaxonomy) matches(nodeID *uint, category string) bool {
+	if nodeID != nil {
+		for _, id := range t.nodeIDs {
+			if id == *nodeID {
+				return true
+			}
+		}
+		return false
+	}
+	return category == "" || strings.EqualFold(category, t.category)
+}
+
+// distance counts the steps from the listing up to nodeID; rules not set on
+// a taxonomy node come after every node on the path.
+func (t listingTaxonomy) distance(nodeID *uint) int {
+	if nodeID != nil {
+		for i, id := range t.nodeIDs {
+			if id == *nodeID {
+				return i
+			}
+		}
+	}
+	return len(t.nodeIDs)
+}
+
+// GetTaxonomy returns the active categories and crops as a tree.
+func (s *ProductService) GetTaxonomy() ([]models.TaxonomyNode, error) {
+	nodes, err := s.productRepo.ListTaxonomy(true)
+	if err != nil {
+		return nil, errors.New("failed to load taxonomy")
+	}
+	return buildTaxonomyTree(nodes), nil
+}
+
+// GetTaxonomy returns every category and crop, inactive ones included.
+func (s *AdminService) GetTaxonomy() ([]models.TaxonomyNode, error) {
+	nodes, err := s.productRepo.ListTaxonomy(false)
+	if err != nil {
+		return nil, errors.New("failed to load taxonomy")
+	}
+	return buildTaxonomyTree(nodes), nil
+}
+
+func (s *AdminService) CreateTaxonomyNode(adminID uint, req TaxonomyNodeRequest) (*models.TaxonomyNode, error) {
+	return s.saveTaxonomyNode(adminID, 0, req)
+}
+
+// UpdateTaxonomyNode renames, reorders, moves or deactivates a node. Moving
+// a node carries its subtree along; listings under it follow to the new
+// top-level category.
+func (s *AdminService) UpdateTaxonomyNode(adminID, nodeID uint, req TaxonomyNodeRequest) (*models.TaxonomyNode, error) {
+	return s.saveTaxonomyNode(adminID, nodeID, req)
+}
+
+func (s *AdminService) saveTaxonomyNode(adminID, nodeID uint, req TaxonomyNodeRequest) (*models.TaxonomyNode, error) {
+	name := strings.TrimSpace(utils.SanitizeString(req.Name))
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if req.ShelfLifeDays < 0 {
+		return nil, errors.New("shelf life cannot be negative")
+	}
+
+	var node models.TaxonomyNode
+	err := s.productRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		action := "create_taxonomy_node"
+		oldPath := ""
+		if nodeID > 0 {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", nodeID).First(&node).Error; err != nil {
+				return errors.New("taxonomy node not found")
+			}
+			action = "update_taxonomy_node"
+			oldPath = node.Path
+		} else {
+			node.Kind = strings.ToLower(strings.TrimSpace(req.Kind))
+			if node.Kind == "" {
+				node.Kind = "category"
+			}
+			if node.Kind != "category" && node.Kind != "crop" {
+				return errors.New("kind must be category or crop")
+			}
+			node.Slug = taxonomySlug(req.Slug)
+			if node.Slug == "" {
+				node.Slug = taxonomySlug(name)
+			}
+			if node.Slug == "" {
+				return errors.New("slug is required")
+			}
+			var clash int64
+			if err := tx.Model(&models.TaxonomyNode{}).Where("slug = ?", node.Slug).Count(&clash).Error; err != nil {
+				return errors.New("failed to check slug")
+			}
+			if clash > 0 {
+				return errors.New("slug is already in use")
+			}
+			node.CreatedBy = adminID
+		}
+
+		var parent *models.TaxonomyNode
+		if req.ParentID > 0 {
+			parent = &models.TaxonomyNode{}
+			if err := tx.Where("id = ?", req.ParentID).First(parent).Error; err != nil {
+				return errors.New("parent not found")
+			}
+			if parent.Kind != "category" {
+				return errors.New("only categories can have children")
+			}
+			if oldPath != "" && strings.HasPrefix(parent.Path, oldPath) {
+				return errors.New("a node cannot be moved under itself")
+			}
+		} else if node.Kind == "crop" {
+			return errors.New("crops must sit under a category")
+		}
+
+		node.ParentID = nil
+		node.Depth = 0
+		if parent != nil {
+			node.ParentID = &parent.ID
+			node.Depth = parent.Depth + 1
+		}
+		node.Name = name
+		node.SortOrder = req.SortOrder
+		node.ShelfLifeDays = req.ShelfLifeDays
+		node.IsActive = req.IsActive || nodeID == 0
+		node.UpdatedBy = adminID
+		if err := tx.Omit("Names").Save(&node).Error; err != nil {
+			return errors.New("failed to save taxonomy node")
+		}
+		node.Path = "/" + strconv.FormatUint(uint64(node.ID), 10) + "/"
+		if parent != nil {
+			node.Path = parent.Path + strconv.FormatUint(uint64(node.ID), 10) + "/"
+		}
+		if err := tx.Model(&node).Update("path", node.Path).Error; err != nil {
+			return errors.New("failed to save taxonomy node")
+		}
+
+		if oldPath != "" && oldPath != node.Path {
+			if err := moveTaxonomySubtree(tx, oldPath, node.Path, node.Depth-(strings.Count(oldPath, "/")-2)); err != nil {
+				return err
+			}
+		}
+		if err := replaceTaxonomyNames(tx, node.ID, req); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "taxonomy",
+			TargetID:   node.ID,
+			Action:     action,
+			Note:       node.Kind + " " + node.Slug + " at " + node.Path,
+			CreatedAt:  time.Now().UTC(),
+		}).Error; err != nil {
+			return errors.New("failed to audit taxonomy change")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.productRepo.GetTaxonomyNode(node.ID)
+}
+
+// moveTaxonomySubtree rewrites the paths and depths under a moved node and
+// points its listings at their new top-level category.
+func moveTaxonomySubtree(tx *gorm.DB, oldPath, newPath string, depthShift int) error {
+	var descendants []models.TaxonomyNode
+	if err := tx.Where("path LIKE ? AND path <> ?", oldPath+"%", oldPath).Find(&descendants).Error; err != nil {
+		return errors.New("failed to load taxonomy subtree")
+	}
+	for _, item := range descendants {
+		if err := tx.Model(&models.TaxonomyNode{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+			"path":  newPath + strings.TrimPrefix(item.Path, oldPath),
+			"depth": item.Depth + depthShift,
+		}).Error; err != nil {
+			return errors.New("failed to move taxonomy subtree")
+		}
+	}
+
+	var root models.TaxonomyNode
+	if err := tx.Where("id = ?", taxonomyRootID(newPath)).First(&root).Error; err != nil {
+		return errors.New("failed to load top-level category")
+	}
+	var subtree []uint
+	if err := tx.Model(&models.TaxonomyNode{}).Where("path LIKE ?", newPath+"%").Pluck("id", &subtree).Error; err != nil {
+		return errors.New("failed to load taxonomy subtree")
+	}
+	if err := tx.Model(&models.Product{}).
+		Where("category_id IN ? OR crop_id IN ?", subtree, subtree).
+		Update("category", root.Slug).Error; err != nil {
+		return errors.New("failed to update listings")
+	}
+	return nil
+}
+
+func replaceTaxonomyNames(tx *gorm.DB, nodeID uint, req TaxonomyNodeRequest) error {
+	if err := tx.Where("node_id = ?", nodeID).Delete(&models.TaxonomyName{}).Error; err != nil {
+		return errors.New("failed to update taxonomy names")
+	}
+	names := make([]models.TaxonomyName, 0, len(req.LocalNames)+len(req.Aliases))
+	for _, input := range req.LocalNames {
+		language := strings.ToLower(strings.TrimSpace(input.Language))
+		name := strings.TrimSpace(utils.SanitizeString(input.Name))
+		if name == "" {
+			continue
+		}
+		if language == "" {
+			return errors.New("local names need a language")
+		}
+		names = append(names, models.TaxonomyName{NodeID: nodeID, Kind: "local", Language: language, Name: name})
+	}
+	for _, alias := range req.Aliases {
+		if alias = strings.TrimSpace(utils.SanitizeString(alias)); alias != "" {
+			names = append(names, models.TaxonomyName{NodeID: nodeID, Kind: "alias", Name: alias})
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	if err := tx.Create(&names).Error; err != nil {
+		return errors.New("failed to update taxonomy names")
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestCategoryRulesFollowTheListingTaxonomyPath(t *testing.T) {
+	ctx := setupTestCtx(t)
+	adminSvc := newAdminServiceForTest(ctx)
+	vegetables, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{Name: "Vegetables"})
+	if err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	leafy, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{ParentID: vegetables.ID, Name: "Leafy Greens"})
+	if err != nil {
+		t.Fatalf("failed to create subcategory: %v", err)
+	}
+	spinach, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{ParentID: leafy.ID, Kind: "crop", Name: "Spinach"})
+	if err != nil {
+		t.Fatalf("failed to create crop: %v", err)
+	}
+	fruits, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{Name: "Fruits"})
+	if err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	if err := ctx.db.Model(&models.Product{}).Where("id = ?", ctx.productID).Updates(map[string]interface{}{"category": "vegetables", "category_id": leafy.ID, "crop_id": spinach.ID}).Error; err != nil {
+		t.Fatalf("failed to place the listing: %v", err)
+	}
+
+	if _, err := adminSvc.CreateFeeRule(99, FeeRuleRequest{Name: "Fresh stuff", Category: "fresh stuff", RatePercent: 2, IsActive: true}); err == nil {
+		t.Fatalf("expected a fee rule on an unknown category to be rejected")
+	}
+	if _, err := adminSvc.CreateFeeRule(99, FeeRuleRequest{Name: "Missing", CategoryID: 999, RatePercent: 2, IsActive: true}); err == nil {
+		t.Fatalf("expected a fee rule on a missing category to be rejected")
+	}
+	// The rule nearest the listing wins over a newer one further up.
+	if _, err := adminSvc.CreateFeeRule(99, FeeRuleRequest{Name: "Leafy", CategoryID: leafy.ID, RatePercent: 3, IsActive: true}); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	rule, err := adminSvc.CreateFeeRule(99, FeeRuleRequest{Name: "Vegetables", Category: "Vegetables", RatePercent: 4, IsActive: true})
+	if err != nil || rule.CategoryID == nil || *rule.CategoryID != vegetables.ID || rule.Category != "vegetables" {
+		t.Fatalf("expected the category text to resolve to its node: %+v err=%v", rule, err)
+	}
+	if _, err := adminSvc.CreateFeeRule(99, FeeRuleRequest{Name: "Fruits", CategoryID: fruits.ID, RatePercent: 1, Priority: 5, IsActive: true}); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	if order := createOrderForTest(t, ctx); order.PlatformFeePercent != 3 || order.PlatformFee != 6 {
+		t.Fatalf("expected the leafy greens rate, got %+v", order)
+	}
+
+	if _, err := adminSvc.CreatePromotion(99, PromotionRequest{Name: "Fresh stuff", DiscountType: "flat", DiscountValue: 10, Category: "fresh stuff", IsActive: true}); err == nil {
+		t.Fatalf("expected a promotion on an unknown category to be rejected")
+	}
+	promotion, err := adminSvc.CreatePromotion(99, PromotionRequest{Name: "Vegetables", DiscountType: "flat", DiscountValue: 5, Category: "Vegetables", IsActive: true})
+	if err != nil || promotion.CategoryID == nil || *promotion.CategoryID != vegetables.ID || promotion.Category != "vegetables" {
+		t.Fatalf("expected the category text to resolve to its node: %+v err=%v", promotion, err)
+	}
+	if _, err := adminSvc.CreatePromotion(99, PromotionRequest{Name: "Fruit week", Code: "FRUIT", DiscountType: "flat", DiscountValue: 30, CategoryID: fruits.ID, IsActive: true}); err != nil {
+		t.Fatalf("failed to create promotion: %v", err)
+	}
+	if _, err := adminSvc.CreatePromotion(99, PromotionRequest{Name: "Greens", DiscountType: "flat", DiscountValue: 20, CategoryID: leafy.ID, IsActive: true}); err != nil {
+		t.Fatalf("failed to create promotion: %v", err)
+	}
+	quote, err := ctx.orderSvc.QuotePromotion(ctx.buyerID, PromotionQuoteRequest{ProductID: ctx.productID, Quantity: 2})
+	if err != nil || quote.Promotion == nil || quote.Promotion.Name != "Greens" || quote.Discount != 20 {
+		t.Fatalf("expected the subcategory promotion to apply to the crop: %+v err=%v", quote, err)
+	}
+	if _, err := ctx.orderSvc.QuotePromotion(ctx.buyerID, PromotionQuoteRequest{ProductID: ctx.productID, Quantity: 2, CouponCode: "FRUIT"}); err == nil || !strings.Contains(err.Error(), "category") {
+		t.Fatalf("expected the fruit coupon not to apply, got %v", err)
+	}
+
+	if _, err := adminSvc.UpdateTaxRate(99, UpdateTaxRateRequest{Category: "vegetables", IsExempt: true}); err != nil {
+		t.Fatalf("failed to update tax rate: %v", err)
+	}
+	if _, err := adminSvc.UpdateTaxRate(99, UpdateTaxRateRequest{CategoryID: leafy.ID, RatePercent: 12}); err != nil {
+		t.Fatalf("failed to update tax rate: %v", err)
+	}
+	product, err := ctx.productRepo.GetByID(ctx.productID)
+	if err != nil {
+		t.Fatalf("failed to load product: %v", err)
+	}
+	if rate := effectiveTaxRate(ctx.db, *product); rate.RatePercent != 12 || rate.Category != "leafy-greens" {
+		t.Fatalf("expected the leafy greens tax rate, got %+v", rate)
+	}
+}
+
+func TestCropTaxonomyHierarchyFiltersAndAliases(t *testing.T) {
+	ctx := setupTestCtx(t)
+	adminSvc := newAdminServiceForTest(ctx)
+
+	vegetables, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{Name: "Vegetables"})
+	if err != nil || vegetables.Slug != "vegetables" || vegetables.Path != "/1/" || !vegetables.IsActive {
+		t.Fatalf("unexpected category: %+v err=%v", vegetables, err)
+	}
+	leafy, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{ParentID: vegetables.ID, Name: "Leafy Greens"})
+	if err != nil || leafy.Depth != 1 {
+		t.Fatalf("unexpected subcategory: %+v err=%v", leafy, err)
+	}
+	spinach, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{
+		ParentID: leafy.ID, Kind: "crop", Name: "Spinach",
+		LocalNames: []TaxonomyNameInput{{Language: "hi", Name: "पालक"}},
+		Aliases:    []string{"palak"},
+	})
+	if err != nil || spinach.Path != vegetables.Path+"2/3/" || len(spinach.Names) != 2 {
+		t.Fatalf("unexpected crop: %+v err=%v", spinach, err)
+	}
+	if _, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{Kind: "crop", Name: "Orphan"}); err == nil {
+		t.Fatalf("expected a crop without a category to be rejected")
+	}
+	if _, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{ParentID: spinach.ID, Name: "Baby spinach"}); err == nil {
+		t.Fatalf("expected a crop to refuse children")
+	}
+	if _, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{Name: "vegetables"}); err == nil {
+		t.Fatalf("expected a duplicate slug to be rejected")
+	}
+
+	req := CreateProductRequest{CropName: "Tomato", Quantity: 10, Unit: "kg", PricePerUnit: 100, Category: "fresh stuff"}
+	if _, err := ctx.productSvc.UpdateProduct(ctx.productID, ctx.farmerID, req); err == nil {
+		t.Fatalf("expected an unknown free-text category to be rejected")
+	}
+	req.Category = "पालक"
+	product, err := ctx.productSvc.UpdateProduct(ctx.productID, ctx.farmerID, req)
+	if err != nil || product.CropID == nil || *product.CropID != spinach.ID || *product.CategoryID != leafy.ID || product.Category != "vegetables" {
+		t.Fatalf("expected a local name to resolve to the crop: %+v err=%v", product, err)
+	}
+	// Edits send a listing back to review; approve it again.
+	ctx.db.Model(&models.Product{}).Where("id = ?", ctx.productID).Update("status", "active")
+
+	for _, filter := range []map[string]interface{}{
+		{"category_id": vegetables.ID},
+		{"category_id": leafy.ID},
+		{"category": "Vegetables"},
+	} {
+		products, total, _, err := ctx.productSvc.GetAllProducts(filter, 1, 20)
+		if err != nil || total != 1 || products[0].ID != ctx.productID {
+			t.Fatalf("expected %v to include subcategories: total=%d err=%v", filter, total, err)
+		}
+	}
+	if products, _, _, err := ctx.productSvc.GetAllProducts(map[string]interface{}{"category": "fruits"}, 1, 20); err != nil || len(products) != 0 {
+		t.Fatalf("expected an unknown category filter to match nothing: %d err=%v", len(products), err)
+	}
+	if found, err := ctx.productSvc.SearchProducts("palak", 10); err != nil || len(found) != 1 {
+		t.Fatalf("expected search to match the crop alias: %d err=%v", len(found), err)
+	}
+
+	greens, err := adminSvc.CreateTaxonomyNode(1, TaxonomyNodeRequest{Name: "Greens"})
+	if err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	if _, err := adminSvc.UpdateTaxonomyNode(1, vegetables.ID, TaxonomyNodeRequest{ParentID: spinach.ID, Name: "Vegetables", IsActive: true}); err == nil {
+		t.Fatalf("expected a move under a crop to be rejected")
+	}
+	if _, err := adminSvc.UpdateTaxonomyNode(1, vegetables.ID, TaxonomyNodeRequest{ParentID: leafy.ID, Name: "Vegetables", IsActive: true}); err == nil {
+		t.Fatalf("expected a move under its own subtree to be rejected")
+	}
+	if _, err := adminSvc.UpdateTaxonomyNode(1, leafy.ID, TaxonomyNodeRequest{ParentID: greens.ID, Name: "Leafy Greens", IsActive: true}); err != nil {
+		t.Fatalf("failed to move subcategory: %v", err)
+	}
+	moved, err := ctx.productRepo.GetTaxonomyNode(spinach.ID)
+	if err != nil || moved.Path != greens.Path+"2/3/" || moved.Depth != 2 {
+		t.Fatalf("expected the crop to move with its subcategory: %+v err=%v", moved, err)
+	}
+	if product, _ := ctx.productRepo.GetByID(ctx.productID); product.Category != "greens" {
+		t.Fatalf("expected the listing to follow the move, got %q", product.Category)
+	}
+
+	if _, err := adminSvc.UpdateTaxonomyNode(1, greens.ID, TaxonomyNodeRequest{Name: "Greens"}); err != nil {
+		t.Fatalf("failed to deactivate category: %v", err)
+	}
+	tree, err := ctx.productSvc.GetTaxonomy()
+	if err != nil || len(tree) != 1 || tree[0].ID != vegetables.ID || len(tree[0].Children) != 0 {
+		t.Fatalf("expected only active nodes in the public tree: %+v err=%v", tree, err)
+	}
+	var audits int64
+	ctx.db.Model(&models.AdminAuditLog{}).Where("target_type = ?", "taxonomy").Count(&audits)
+	if audits != 6 {
+		t.Fatalf("expected each taxonomy change to be audited, got %d", audits)
+	}
+}
@@ -46,8 +46,30 @@ func (s *TrustScoreService) CalculateTrustScore(farmerID uint) (float64, string,
 	// Normalize rating (0-5 to 0-1)
 	normalizedRating := profile.RatingAverage / 5.0
 
-	// Calculate trust score: (Average Rating × 0.6) + (Completion Rate × 0.4)
-	trustScore := (normalizedRating * 0.6) + (completionRate * 0.4)
+	disputesLost, err := s.orderRepo.CountDisputesLostByFarmer(farmerID)
+	if err != nil {
+		return 0, "", err
+	}
+	farmerCancellations, err := s.orderRepo.CountFarmerCancellations(farmerID)
+	if err != nil {
+		return 0, "", err
+	}
+	var disputeRate, cancellationRate float64
+	if totalOrders > 0 {
+		disputeRate = float64(disputesLost) / float64(totalOrders)
+		// A single cancellation is forgiven; repeated ones are penalised.
+		if farmerCancellations > 1 {
+			cancellationRate = float64(farmerCancellations-1) / float64(totalOrders)
+		}
+	}
+
+	// Calculate trust score: (Average Rating × 0.6) + (Completion Rate × 0.4),
+	// less up to 0.3 for disputes settled against the farmer and up to 0.3 for
+	// repeated farmer-side cancellations.
+	trustScore := (normalizedRating * 0.6) + (completionRate * 0.4) - (disputeRate * 0.3) - (cancellationRate * 0.3)
+	if trustScore < 0 {
+		trustScore = 0
+	}
 
 	// Determine badge
 	var badge string
@@ -76,6 +98,12 @@ func (s *TrustScoreService) UpdateTrustScore(farmerID uint) error {
 	profile.TrustScore = trustScore
 	profile.Badge = badge
 	profile.CompletedOrders = len(s.getCompletedOrdersCount(farmerID))
+	if lost, err := s.orderRepo.CountDisputesLostByFarmer(farmerID); err == nil {
+		profile.DisputesLost = int(lost)
+	}
+	if cancelled, err := s.orderRepo.CountFarmerCancellations(farmerID); err == nil {
+		profile.CancelledOrders = int(cancelled)
+	}
 
 	return s.userRepo.UpdateFarmerProfile(profile)
 }
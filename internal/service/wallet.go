@@ -0,0 +1,248 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/f2b-portal/backend/internal/models"
+	"github.com/f2b-portal/backend/internal/repository"
+	"github.com/f2b-portal/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BuyerWalletStatement is a buyer's store credit: the balance and every
+// posting that moved it.
+type BuyerWalletStatement struct {
+	BuyerID  uint                 `json:"buyer_id"`
+	Credited float64              `json:"credited"`
+	Spent    float64              `json:"spent"`
+	Balance  float64              `json:"balance"`
+	Entries  []models.LedgerEntry `json:"entries"`
+	Currency string               `json:"currency"`
+}
+
+type WalletCreditRequest struct {
+	BuyerID uint    `json:"buyer_id"`
+	Amount  float64 `json:"amount"`
+	Reason  string  `json:"reason"` // goodwill/cancellation_fee_reversal
+	OrderID *uint   `json:"order_id"`
+	Note    string  `json:"note"`
+}
+
+// amountDue is what the buyer pays outside the wallet, less anything refunded
+// before they paid.
+func amountDue(order *models.Order) float64 {
+	return roundMoney(order.TotalPrice - order.WalletAmount - order.WaivedAmount)
+}
+
+func walletBalance(tx *gorm.DB, buyerID uint) (float64, error) {
+	var balance float64
+	if err := tx.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(credit - debit), 0)").
+		Where("account = ? AND user_id = ?", ledgerBuyerWallet, buyerID).
+		Scan(&balance).Error; err != nil {
+		return 0, errors.New("failed to read wallet")
+	}
+	return roundMoney(balance), nil
+}
+
+// reserveWalletPayment applies up to requested from the buyer's wallet to a
+// new order. The "wallet" method pays the whole order; an order the wallet
+// covers completely is treated as paid by wallet whatever method was chosen.
+func reserveWalletPayment(tx *gorm.DB, order *models.Order, requested float64) error {
+	total := roundMoney(order.TotalPrice)
+	if normalizePaymentMethod(order.PaymentMethod) == "wallet" {
+		requested = total
+	}
+	requested = roundMoney(requested)
+	if requested < 0 {
+		return errors.New("wallet amount cannot be negative")
+	}
+	if requested == 0 {
+		return nil
+	}
+	if requested > total {
+		return errors.New("wallet amount exceeds the order total")
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", order.BuyerID).First(&models.User{}).Error; err != nil {
+		return errors.New("buyer not found")
+	}
+	balance, err := walletBalance(tx, order.BuyerID)
+	if err != nil {
+		return err
+	}
+	if requested > balance {
+		return errors.New("insufficient wallet balance")
+	}
+
+	order.WalletAmount = requested
+	if requested == total {
+		now := time.Now().UTC()
+		order.PaymentMethod = "wallet"
+		order.PaymentStatus = "paid"
+		order.PaidAt = &now
+	}
+	return nil
+}
+
+// postWalletPayment moves the wallet part of a new order into escrow.
+func postWalletPayment(tx *gorm.DB, order *models.Order) error {
+	if order.WalletAmount <= 0 {
+		return nil
+	}
+	return postLedgerJournal(tx, "wallet_payment", &order.ID, &order.BuyerID, "Paid from wallet",
+		ledgerLine{account: ledgerBuyerWallet, userID: &order.BuyerID, amount: order.WalletAmount},
+		ledgerLine{account: ledgerEscrow, amount: -order.WalletAmount},
+	)
+}
+
+// postGoodwillCredit gives the buyer store credit at the platform's expense.
+func postGoodwillCredit(tx *gorm.DB, buyerID uint, orderID *uint, actorID uint, amount float64, memo string) error {
+	return postLedgerJournal(tx, "wallet_credit", orderID, &actorID, memo,
+		ledgerLine{account: ledgerPlatformFee, amount: amount},
+		ledgerLine{account: ledgerBuyerWallet, userID: &buyerID, amount: -amount},
+	)
+}
+
+// postCancellationFeeReversal hands back up to the cancellation fee still
+// held on an order. A fee the buyer never paid is written off first; the
+// rest is credited to the wallet. It returns the amount reversed.
+func postCancellationFeeReversal(tx *gorm.DB, order *models.Order, actorID uint, amount float64, memo string) (float64, error) {
+	var kept float64
+	if err := tx.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(credit - debit), 0)").
+		Where("order_id = ? AND kind = ? AND account IN ?", order.ID, "cancellation", []string{ledgerPlatformFee, ledgerFarmerPayable}).
+		Scan(&kept).Error; err != nil {
+		return 0, errors.New("failed to read ledger")
+	}
+	kept = roundMoney(kept)
+	if kept <= 0 {
+		return 0, errors.New("no cancellation fee is left to reverse")
+	}
+	if amount == 0 {
+		amount = kept
+	}
+	if amount > kept {
+		return 0, errors.New("credit exceeds the cancellation fee kept on this order")
+	}
+	owed, err := ledgerBalance(tx, order.ID, ledgerBuyerReceivable)
+	if err != nil {
+		return 0, errors.New("failed to read ledger")
+	}
+	toReceivable := roundMoney(math.Min(amount, math.Max(owed, 0)))
+	fee, share := splitPlatformFee(order, amount)
+	if err := postLedgerJournal(tx, "cancellation", &order.ID, &actorID, memo,
+		ledgerLine{account: ledgerPlatformFee, amount: fee},
+		ledgerLine{account: ledgerFarmerPayable, userID: &order.FarmerID, amount: share},
+		ledgerLine{account: ledgerBuyerReceivable, userID: &order.BuyerID, amount: -toReceivable},
+		ledgerLine{account: ledgerBuyerWallet, userID: &order.BuyerID, amount: -(amount - toReceivable)},
+	); err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
+
+func buildBuyerWalletStatement(repo *repository.OrderRepository, buyerID uint) (*BuyerWalletStatement, error) {
+	entries, err := repo.GetLedgerEntriesByUser(ledgerBuyerWallet, buyerID)
+	if err != nil {
+		return nil, errors.New("failed to read wallet")
+	}
+	statement := &BuyerWalletStatement{BuyerID: buyerID, Entries: entries, Currency: "INR"}
+	for _, entry := range entries {
+		statement.Credited += entry.Credit
+		statement.Spent += entry.Debit
+	}
+	statement.Credited = roundMoney(statement.Credited)
+	statement.Spent = roundMoney(statement.Spent)
+	statement.Balance = roundMoney(statement.Credited - statement.Spent)
+	return statement, nil
+}
+
+func (s *OrderService) GetBuyerWallet(buyerID uint) (*BuyerWalletStatement, error) {
+	return buildBuyerWalletStatement(s.orderRepo, buyerID)
+}
+
+func (s *AdminService) GetBuyerWallet(buyerID uint) (*BuyerWalletStatement, error) {
+	buyer, err := s.userRepo.GetByID(buyerID)
+	if err != nil || buyer.UserType != "buyer" {
+		return nil, errors.New("buyer not found")
+	}
+	return buildBuyerWalletStatement(s.orderRepo, buyerID)
+}
+
+// IssueWalletCredit credits a buyer's wallet as goodwill or by reversing a
+// cancellation fee. Every credit needs a note, which is kept in the audit log.
+func (s *AdminService) IssueWalletCredit(adminID uint, req WalletCreditRequest) (*BuyerWalletStatement, error) {
+	note := utils.SanitizeString(strings.TrimSpace(req.Note))
+	if note == "" {
+		return nil, errors.New("a note is required for wallet credits")
+	}
+	reason := strings.ToLower(strings.TrimSpace(req.Reason))
+	if reason == "" {
+		reason = "goodwill"
+	}
+	if reason != "goodwill" && reason != "cancellation_fee_reversal" {
+		return nil, errors.New("invalid wallet credit reason")
+	}
+	amount := roundMoney(req.Amount)
+	if amount < 0 || (amount == 0 && reason == "goodwill") {
+		return nil, errors.New("credit amount must be greater than 0")
+	}
+	buyer, err := s.userRepo.GetByID(req.BuyerID)
+	if err != nil || buyer.UserType != "buyer" {
+		return nil, errors.New("buyer not found")
+	}
+
+	err = s.orderRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var order *models.Order
+		if req.OrderID != nil {
+			order = &models.Order{}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", *req.OrderID).First(order).Error; err != nil {
+				return errors.New("order not found")
+			}
+			if order.BuyerID != req.BuyerID {
+				return errors.New("order does not belong to this buyer")
+			}
+		}
+
+		memo := "Goodwill credit: " + note
+		if reason == "cancellation_fee_reversal" {
+			if order == nil || order.Status != "cancelled" {
+				return errors.New("a cancelled order is required to reverse its fee")
+			}
+			memo = "Cancellation fee reversed: " + note
+			reversed, err := postCancellationFeeReversal(tx, order, adminID, amount, memo)
+			if err != nil {
+				return err
+			}
+			amount = reversed
+		} else {
+			var orderID *uint
+			if order != nil {
+				orderID = &order.ID
+			}
+			if err := postGoodwillCredit(tx, req.BuyerID, orderID, adminID, amount, memo); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&models.AdminAuditLog{
+			AdminID:    adminID,
+			TargetType: "user",
+			TargetID:   req.BuyerID,
+			Action:     "wallet_" + reason,
+			Note:       formatQuantity(amount) + ": " + note,
+			CreatedAt:  time.Now().UTC(),
+		}).Error; err != nil {
+			return errors.New("failed to audit wallet credit")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buildBuyerWalletStatement(s.orderRepo, req.BuyerID)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/f2b-portal/backend/internal/models"
+)
+
+func TestBuyerWalletCreditsPaymentsAndRefunds(t *testing.T) {
+	ctx := setupTestCtx(t)
+	adminSvc := newAdminServiceForTest(ctx)
+	balance := func(want float64) {
+		t.Helper()
+		wallet, err := ctx.orderSvc.GetBuyerWallet(ctx.buyerID)
+		if err != nil || wallet.Balance != want {
+			t.Fatalf("expected wallet balance %v, got %+v err=%v", want, wallet, err)
+		}
+	}
+	placeOrder := func(method string, walletAmount float64) (*models.Order, error) {
+		return ctx.orderSvc.CreateOrder(ctx.buyerID, CreateOrderRequest{
+			ProductID:       ctx.productID,
+			Quantity:        2,
+			DeliveryAddress: "Some address",
+			PaymentMethod:   method,
+			WalletAmount:    walletAmount,
+		})
+	}
+
+	if _, err := adminSvc.IssueWalletCredit(99, WalletCreditRequest{BuyerID: ctx.buyerID, Amount: 300}); err == nil {
+		t.Fatalf("expected a credit without a note to be rejected")
+	}
+	if _, err := adminSvc.IssueWalletCredit(99, WalletCreditRequest{BuyerID: ctx.buyerID, Amount: 300, Note: "late delivery apology"}); err != nil {
+		t.Fatalf("failed to issue goodwill credit: %v", err)
+	}
+	var audits int64
+	ctx.db.Model(&models.AdminAuditLog{}).Where("action = ? AND target_id = ?", "wallet_goodwill", ctx.buyerID).Count(&audits)
+	if audits != 1 {
+		t.Fatalf("expected the credit to be audited, got %d entries", audits)
+	}
+	balance(300)
+
+	// Part wallet, rest cash on delivery.
+	split, err := placeOrder("cod", 50)
+	if err != nil || split.WalletAmount != 50 || split.PaymentMethod != "cod" || split.PaymentStatus != "pending" {
+		t.Fatalf("unexpected split payment order: %+v err=%v", split, err)
+	}
+	balance(250)
+
+	full, err := placeOrder("wallet", 0)
+	if err != nil || full.WalletAmount != 200 || full.PaymentStatus != "paid" || full.PaidAt == nil {
+		t.Fatalf("unexpected wallet order: %+v err=%v", full, err)
+	}
+	balance(50)
+	if _, err := placeOrder("wallet", 0); err == nil {
+		t.Fatalf("expected an order above the wallet balance to be rejected")
+	}
+	if _, err := placeOrder("cod", 60); err == nil {
+		t.Fatalf("expected a wallet share above the balance to be rejected")
+	}
+
+	// Cancelling a wallet-paid order puts the money back in the wallet.
+	if _, err := ctx.orderSvc.CancelOrder(full.ID, ctx.buyerID, CancelOrderRequest{}); err != nil {
+		t.Fatalf("failed to cancel wallet order: %v", err)
+	}
+	balance(250)
+
+	// The packed-stage fee is kept until an admin reverses it.
+	packed, err := placeOrder("wallet", 0)
+	if err != nil {
+		t.Fatalf("failed to place wallet order: %v", err)
+	}
+	for _, step := range []string{"confirmed", "packed"} {
+		if _, err := ctx.orderSvc.UpdateOrderStatusWithDetails(packed.ID, ctx.farmerID, UpdateOrderStatusRequest{Status: step}); err != nil {
+			t.Fatalf("failed to move order to %s: %v", step, err)
+		}
+	}
+	if cancelled, err := ctx.orderSvc.CancelOrder(packed.ID, ctx.buyerID, CancelOrderRequest{}); err != nil || cancelled.CancellationFee != 20 {
+		t.Fatalf("unexpected packed cancellation: %+v err=%v", cancelled, err)
+	}
+	balance(230)
+	if _, err := adminSvc.IssueWalletCredit(99, WalletCreditRequest{BuyerID: ctx.buyerID, Reason: "cancellation_fee_reversal", OrderID: &split.ID, Note: "fee waived"}); err == nil {
+		t.Fatalf("expected a fee reversal on an open order to be rejected")
+	}
+	wallet, err := adminSvc.IssueWalletCredit(99, WalletCreditRequest{BuyerID: ctx.buyerID, Reason: "cancellation_fee_reversal", OrderID: &packed.ID, Note: "fee waived"})
+	if err != nil || wallet.Balance != 250 || wallet.Spent != 450 {
+		t.Fatalf("unexpected wallet after fee reversal: %+v err=%v", wallet, err)
+	}
+	if _, err := adminSvc.IssueWalletCredit(99, WalletCreditRequest{BuyerID: ctx.buyerID, Reason: "cancellation_fee_reversal", OrderID: &packed.ID, Note: "again"}); err == nil {
+		t.Fatalf("expected a second reversal to be rejected")
+	}
+
+	payout, err := ctx.orderSvc.GetFarmerPayoutSummary(ctx.farmerID)
+	if err != nil || payout.CancellationFees != 0 {
+		t.Fatalf("expected the reversed fee to leave the farmer's cancellation fees: %+v err=%v", payout, err)
+	}
+}
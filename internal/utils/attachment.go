@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	MaxAttachments        = 3
+	AttachmentsSubdir     = "attachments"
+	pdfSignature          = "%PDF-"
+	attachmentContentPDF  = "application/pdf"
+	attachmentContentJPEG = "image/jpeg"
+	attachmentContentPNG  = "image/png"
+)
+
+// ValidateAttachmentFile accepts the same images as product uploads plus PDF
+// documents, under the same size limit.
+func ValidateAttachmentFile(file *multipart.FileHeader) error {
+	if file.Size > MaxFileSize {
+		return fmt.Errorf("file size exceeds 5MB limit")
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" && ext != ".pdf" {
+		return fmt.Errorf("only jpg, jpeg, png, and pdf files are allowed")
+	}
+	return nil
+}
+
+// attachmentFilename returns a random name so attachment URLs cannot be
+// guessed from the time they were uploaded.
+func attachmentFilename(ext string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to name file: %w", err)
+	}
+	return hex.EncodeToString(buf) + ext, nil
+}
+
+// SaveAttachment stores a message attachment under the attachments directory
+// and returns its upload URL and content type. The directory is not served
+// publicly; see PublicUploads. Images are re-encoded and thumbnailed like
+// other uploads; PDFs are checked for a PDF header and stored as-is.
+func SaveAttachment(file *multipart.FileHeader) (string, string, error) {
+	if err := ValidateAttachmentFile(file); err != nil {
+		return "", "", err
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	name, err := attachmentFilename(ext)
+	if err != nil {
+		return "", "", err
+	}
+	dir := filepath.Join(UploadsDir, AttachmentsSubdir)
+	if ext != ".pdf" {
+		url, err := saveImageAs(file, dir, func(string) string { return name })
+		if err != nil {
+			return "", "", err
+		}
+		if ext == ".png" {
+			return url, attachmentContentPNG, nil
+		}
+		return url, attachmentContentJPEG, nil
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	header := make([]byte, len(pdfSignature))
+	if _, err := io.ReadFull(src, header); err != nil || !bytes.Equal(header, []byte(pdfSignature)) {
+		return "", "", fmt.Errorf("file is not a valid pdf")
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to save file: %w", err)
+	}
+	filePath := filepath.Join(dir, name)
+	if err := CopyFile(src, filePath); err != nil {
+		return "", "", fmt.Errorf("failed to save file: %w", err)
+	}
+	return "/" + filepath.ToSlash(filePath), attachmentContentPDF, nil
+}
+
+// IsAttachmentURL reports whether url points at a message attachment.
+func IsAttachmentURL(url string) bool {
+	if !IsUploadURL(url) {
+		return false
+	}
+	clean := filepath.ToSlash(filepath.Clean(strings.TrimPrefix(url, "/")))
+	return strings.HasPrefix(clean, UploadsDir+"/"+AttachmentsSubdir+"/")
+}
+
+// UploadFilePath returns where the file behind an upload URL is stored.
+func UploadFilePath(url string) (string, bool) {
+	if !IsUploadURL(url) {
+		return "", false
+	}
+	return filepath.Clean(filepath.FromSlash(strings.TrimPrefix(url, "/"))), true
+}
+
+// publicUploads serves UploadsDir to anyone, except message attachments,
+// which only the parties to an order may download. Directories are not listed.
+type publicUploads struct {
+	root http.FileSystem
+}
+
+// PublicUploads returns the file system behind the public /uploads route.
+func PublicUploads() http.FileSystem {
+	return publicUploads{root: http.Dir(UploadsDir)}
+}
+
+func (u publicUploads) Open(name string) (http.File, error) {
+	clean := path.Clean("/" + name)
+	if clean == "/"+AttachmentsSubdir || strings.HasPrefix(clean, "/"+AttachmentsSubdir+"/") {
+		return nil, os.ErrNotExist
+	}
+	file, err := u.root.Open(clean)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := file.Stat(); err != nil || info.IsDir() {
+		file.Close()
+		return nil, os.ErrNotExist
+	}
+	return file, nil
+}
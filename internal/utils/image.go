@@ -8,6 +8,7 @@ import (
 	"io"
 	"mime/multipart"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -21,6 +22,8 @@ const (
 	ThumbnailSize  = 200
 	ResizedWidth   = 800
 	UploadsDir     = "uploads"
+	// ProductImagesSubdir holds the images farmers upload for their listings.
+	ProductImagesSubdir = "products"
 )
 
 func ValidateImageFile(file *multipart.FileHeader) error {
@@ -39,6 +42,24 @@ func ValidateImageFile(file *multipart.FileHeader) error {
 }
 
 func SaveImage(file *multipart.FileHeader) (string, error) {
+	return saveImageIn(file, UploadsDir)
+}
+
+// SaveProductImage saves a listing image under the product image directory,
+// the only place gallery and image_url uploads are accepted from.
+func SaveProductImage(file *multipart.FileHeader) (string, error) {
+	return saveImageIn(file, filepath.Join(UploadsDir, ProductImagesSubdir))
+}
+
+func saveImageIn(file *multipart.FileHeader, dir string) (string, error) {
+	return saveImageAs(file, dir, func(format string) string {
+		return generateFilename(file.Filename, format)
+	})
+}
+
+// saveImageAs saves a resized image and its thumbnail in dir under the name
+// filename returns for the decoded format.
+func saveImageAs(file *multipart.FileHeader, dir string, filename func(format string) string) (string, error) {
 	// Validate file
 	if err := ValidateImageFile(file); err != nil {
 		return "", err
@@ -58,8 +79,8 @@ func SaveImage(file *multipart.FileHeader) (string, error) {
 	}
 
 	// Generate unique filename
-	filename := generateFilename(file.Filename, format)
-	filePath := filepath.Join(UploadsDir, filename)
+	name := filename(format)
+	filePath := filepath.Join(dir, name)
 
 	// Resize image to max width 800px
 	resized := imaging.Resize(img, ResizedWidth, 0, imaging.Lanczos)
@@ -71,16 +92,16 @@ func SaveImage(file *multipart.FileHeader) (string, error) {
 
 	// Generate thumbnail
 	thumbnail := imaging.Thumbnail(img, ThumbnailSize, ThumbnailSize, imaging.Lanczos)
-	thumbPath := filepath.Join(UploadsDir, "thumb_"+filename)
+	thumbPath := filepath.Join(dir, "thumb_"+name)
 	if err := saveImageFile(thumbnail, thumbPath, format); err != nil {
 		// Log error but don't fail
 	}
 
-	return "/" + filePath, nil
+	return "/" + filepath.ToSlash(filePath), nil
 }
 
 func generateFilename(originalName, format string) string {
-	timestamp := time.Now().Unix()
+	timestamp := time.Now().UnixNano()
 	ext := strings.ToLower(filepath.Ext(originalName))
 	if ext == "" {
 		if format == "jpeg" {
@@ -123,8 +144,11 @@ func SaveMultipleImages(files []*multipart.FileHeader) ([]string, error) {
 
 	var urls []string
 	for _, file := range files {
-		url, err := SaveImage(file)
+		url, err := SaveProductImage(file)
 		if err != nil {
+			for _, saved := range urls {
+				_ = DeleteImage(saved)
+			}
 			return nil, err
 		}
 		urls = append(urls, url)
@@ -169,6 +193,33 @@ func DeleteImage(url string) error {
 	return nil
 }
 
+// ThumbnailURL returns the URL of the thumbnail SaveImage writes next to an
+// uploaded image.
+func ThumbnailURL(url string) string {
+	if url == "" {
+		return ""
+	}
+	idx := strings.LastIndex(url, "/")
+	return url[:idx+1] + "thumb_" + url[idx+1:]
+}
+
+// IsUploadURL reports whether url points at a file saved under UploadsDir.
+func IsUploadURL(url string) bool {
+	clean := filepath.ToSlash(filepath.Clean(strings.TrimPrefix(url, "/")))
+	return strings.HasPrefix(clean, UploadsDir+"/") && !strings.Contains(clean, "..")
+}
+
+// IsProductImageURL reports whether url points at an image SaveProductImage
+// wrote, rather than a thumbnail or a file uploaded for another purpose.
+func IsProductImageURL(url string) bool {
+	if !IsUploadURL(url) {
+		return false
+	}
+	clean := filepath.ToSlash(filepath.Clean(strings.TrimPrefix(url, "/")))
+	dir, name := path.Split(clean)
+	return dir == UploadsDir+"/"+ProductImagesSubdir+"/" && !strings.HasPrefix(name, "thumb_")
+}
+
 func CopyFile(src multipart.File, dstPath string) error {
 	dst, err := os.Create(dstPath)
 	if err != nil {
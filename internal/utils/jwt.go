@@ -8,14 +8,33 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const (
+	// StreamTicketScope marks a token that may only open an event stream.
+	StreamTicketScope = "order_events"
+	// StreamTicketTTL is how long a stream ticket can be used to connect.
+	StreamTicketTTL = time.Minute
+)
+
 type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Email    string `json:"email"`
 	UserType string `json:"user_type"`
+	Scope    string `json:"scope,omitempty"` // empty for session tokens
 	jwt.RegisteredClaims
 }
 
 func GenerateToken(userID uint, email, userType string) (string, error) {
+	return signToken(userID, email, userType, "", 24*time.Hour)
+}
+
+// GenerateStreamTicket issues a token that only opens an event stream.
+// EventSource cannot send headers, so the ticket travels in the URL where it
+// may be logged; it expires within a minute and is refused everywhere else.
+func GenerateStreamTicket(userID uint, email, userType string) (string, error) {
+	return signToken(userID, email, userType, StreamTicketScope, StreamTicketTTL)
+}
+
+func signToken(userID uint, email, userType, scope string, ttl time.Duration) (string, error) {
 	if config.AppConfig == nil || config.AppConfig.JWTSecret == "" {
 		return "", errors.New("server configuration not loaded")
 	}
@@ -23,8 +42,9 @@ func GenerateToken(userID uint, email, userType string) (string, error) {
 		UserID:   userID,
 		Email:    email,
 		UserType: userType,
+		Scope:    scope,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "f2b-portal",
 		},
@@ -25,6 +25,9 @@ type Config struct {
 	AdminEmail    string
 	AdminPhone    string
 	AdminPassword string
+
+	CourierWebhookSecret string
+	PaymentWebhookSecret string
 }
 
 var AppConfig *Config
@@ -51,6 +54,9 @@ func LoadConfig() (*Config, error) {
 		AdminEmail:    getEnv("ADMIN_EMAIL", ""),
 		AdminPhone:    getEnv("ADMIN_PHONE", ""),
 		AdminPassword: getEnv("ADMIN_PASSWORD", ""),
+
+		CourierWebhookSecret: getEnv("COURIER_WEBHOOK_SECRET", ""),
+		PaymentWebhookSecret: getEnv("PAYMENT_WEBHOOK_SECRET", ""),
 	}
 
 	AppConfig = config
@@ -35,6 +35,13 @@ func MigrateDB(db *gorm.DB) error {
 		&models.FarmerProfile{},
 		&models.Product{},
 		&models.ProductPriceHistory{},
+		&models.ProductPriceTier{},
+		&models.ProductImage{},
+		&models.Upload{},
+		&models.ProductVariant{},
+		&models.StockMovement{},
+		&models.TaxonomyNode{},
+		&models.TaxonomyName{},
 		&models.CartItem{},
 		&models.Address{},
 		&models.Favorite{},
@@ -44,8 +51,26 @@ func MigrateDB(db *gorm.DB) error {
 		&models.AdminAuditLog{},
 		&models.OrderStatusLog{},
 		&models.OrderMessage{},
+		&models.OrderMessageAttachment{},
+		&models.OrderMessageRead{},
 		&models.DisputeEvidence{},
 		&models.Review{},
+		&models.Shipment{},
+		&models.ShipmentEvent{},
+		&models.OrderAmendment{},
+		&models.CancellationPolicy{},
+		&models.PaymentEvent{},
+		&models.LedgerEntry{},
+		&models.FeeRule{},
+		&models.TaxRate{},
+		&models.TaxInvoice{},
+		&models.InvoiceSequence{},
+		&models.PayoutAccount{},
+		&models.SettlementBatch{},
+		&models.FarmerPayout{},
+		&models.CODCollection{},
+		&models.BuyerCreditAccount{},
+		&models.Promotion{},
 	)
 
 	// Keep startup resilient even if AutoMigrate fails on legacy/inconsistent schemas.
@@ -60,14 +85,29 @@ func MigrateDB(db *gorm.DB) error {
 		`ALTER TABLE users ADD COLUMN IF NOT EXISTS verification_note TEXT`,
 		`ALTER TABLE users ADD COLUMN IF NOT EXISTS verified_by BIGINT`,
 		`ALTER TABLE users ADD COLUMN IF NOT EXISTS verified_at TIMESTAMPTZ`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS gstin TEXT`,
+		`CREATE INDEX IF NOT EXISTS idx_users_gstin ON users(gstin)`,
 		`ALTER TABLE products ADD COLUMN IF NOT EXISTS category TEXT`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS hsn_code TEXT`,
 		`ALTER TABLE products ADD COLUMN IF NOT EXISTS moderation_note TEXT`,
 		`ALTER TABLE products ADD COLUMN IF NOT EXISTS reviewed_by BIGINT`,
 		`ALTER TABLE products ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ`,
 		`ALTER TABLE products ADD COLUMN IF NOT EXISTS is_bulk_available BOOLEAN DEFAULT FALSE`,
 		`ALTER TABLE products ADD COLUMN IF NOT EXISTS minimum_bulk_quantity DOUBLE PRECISION DEFAULT 0`,
 		`ALTER TABLE products ADD COLUMN IF NOT EXISTS supports_harvest_request BOOLEAN DEFAULT TRUE`,
+		`ALTER TABLE product_price_histories ADD COLUMN IF NOT EXISTS change_type TEXT DEFAULT 'base_price'`,
+		`ALTER TABLE product_price_histories ADD COLUMN IF NOT EXISTS min_quantity DOUBLE PRECISION DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_product_price_tiers_product_id ON product_price_tiers(product_id)`,
 		`ALTER TABLE products ADD COLUMN IF NOT EXISTS harvest_lead_days INTEGER DEFAULT 0`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS category_id BIGINT`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS crop_id BIGINT`,
+		`CREATE INDEX IF NOT EXISTS idx_products_category_id ON products(category_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_products_crop_id ON products(crop_id)`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS harvested_at TIMESTAMPTZ`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS best_before TIMESTAMPTZ`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS expired_at TIMESTAMPTZ`,
+		`CREATE INDEX IF NOT EXISTS idx_products_best_before ON products(best_before)`,
+		`ALTER TABLE taxonomy_nodes ADD COLUMN IF NOT EXISTS shelf_life_days INTEGER DEFAULT 0`,
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS delivery_date TIMESTAMPTZ`,
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS delivery_slot TEXT`,
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancellation_type TEXT`,
@@ -77,11 +117,60 @@ func MigrateDB(db *gorm.DB) error {
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS payment_method TEXT DEFAULT 'cod'`,
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS payment_reference TEXT`,
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS payment_status TEXT DEFAULT 'pending'`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS payment_provider TEXT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS payment_intent_id TEXT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS paid_at TIMESTAMPTZ`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS wallet_amount DOUBLE PRECISION DEFAULT 0`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS waived_amount DOUBLE PRECISION DEFAULT 0`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS credit_due_at TIMESTAMPTZ`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS credit_reminder_at TIMESTAMPTZ`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_credit_due_at ON orders(credit_due_at)`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS promotion_id BIGINT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS promotion_code TEXT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount_amount DOUBLE PRECISION DEFAULT 0`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount_funded_by TEXT`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_promotion_id ON orders(promotion_id)`,
+		`ALTER TABLE promotions ADD COLUMN IF NOT EXISTS category_id BIGINT`,
+		`ALTER TABLE fee_rules ADD COLUMN IF NOT EXISTS category_id BIGINT`,
+		`ALTER TABLE tax_rates ADD COLUMN IF NOT EXISTS category_id BIGINT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS variant_id BIGINT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS unit TEXT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS variant_sku TEXT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS variant_label TEXT`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_variant_id ON orders(variant_id)`,
+		`ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS variant_id BIGINT`,
+		`CREATE INDEX IF NOT EXISTS idx_cart_items_variant_id ON cart_items(variant_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_payment_intent_id ON orders(payment_intent_id)`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS fee_rule_id BIGINT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS platform_fee_percent DOUBLE PRECISION`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS platform_fee_min DOUBLE PRECISION DEFAULT 0`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS platform_fee_max DOUBLE PRECISION DEFAULT 0`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS platform_fee DOUBLE PRECISION DEFAULT 0`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS farmer_payout_id BIGINT`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_farmer_payout_id ON orders(farmer_payout_id)`,
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ`,
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS preferred_date TIMESTAMPTZ`,
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS source_request_id BIGINT`,
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispute_status TEXT DEFAULT 'none'`,
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispute_note TEXT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispute_category TEXT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispute_remedy TEXT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispute_claim_amount DOUBLE PRECISION DEFAULT 0`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispute_opened_by BIGINT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispute_opened_at TIMESTAMPTZ`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispute_response_due_at TIMESTAMPTZ`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispute_escalated_by BIGINT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispute_escalated_at TIMESTAMPTZ`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispute_decision TEXT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispute_decided_by BIGINT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispute_decided_at TIMESTAMPTZ`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS refund_amount DOUBLE PRECISION DEFAULT 0`,
+		`ALTER TABLE farmer_profiles ADD COLUMN IF NOT EXISTS disputes_lost INTEGER DEFAULT 0`,
+		`ALTER TABLE farmer_profiles ADD COLUMN IF NOT EXISTS cancelled_orders INTEGER DEFAULT 0`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancelled_by BIGINT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancelled_by_role TEXT`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancellation_fee DOUBLE PRECISION DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_cancelled_by_role ON orders(cancelled_by_role)`,
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS admin_review_status TEXT DEFAULT 'open'`,
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS admin_review_note TEXT`,
 		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS admin_reviewed_by BIGINT`,
@@ -103,10 +192,26 @@ func MigrateDB(db *gorm.DB) error {
 			updated_at TIMESTAMPTZ,
 			deleted_at TIMESTAMPTZ
 		)`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS counter_quantity DOUBLE PRECISION`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS counter_harvest_date TIMESTAMPTZ`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS counter_price_per_unit DOUBLE PRECISION`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS counter_note TEXT`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS countered_at TIMESTAMPTZ`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS counter_expires_at TIMESTAMPTZ`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS buyer_response_note TEXT`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS agreed_quantity DOUBLE PRECISION`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS agreed_harvest_date TIMESTAMPTZ`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS agreed_price_per_unit DOUBLE PRECISION`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS response_deadline TIMESTAMPTZ`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMPTZ`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS expired_at TIMESTAMPTZ`,
+		`ALTER TABLE harvest_requests ADD COLUMN IF NOT EXISTS converted_at TIMESTAMPTZ`,
 		`CREATE INDEX IF NOT EXISTS idx_harvest_requests_product_id ON harvest_requests(product_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_harvest_requests_buyer_id ON harvest_requests(buyer_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_harvest_requests_farmer_id ON harvest_requests(farmer_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_harvest_requests_status ON harvest_requests(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_harvest_requests_response_deadline ON harvest_requests(response_deadline)`,
+		`CREATE INDEX IF NOT EXISTS idx_harvest_requests_counter_expires_at ON harvest_requests(counter_expires_at)`,
 		`CREATE TABLE IF NOT EXISTS addresses (
 			id BIGSERIAL PRIMARY KEY,
 			user_id BIGINT NOT NULL,
@@ -188,6 +293,8 @@ func MigrateDB(db *gorm.DB) error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_dispute_evidences_order_id ON dispute_evidences(order_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_dispute_evidences_uploaded_by ON dispute_evidences(uploaded_by)`,
+		`CREATE INDEX IF NOT EXISTS idx_shipments_carrier_tracking ON shipments(carrier, tracking_number)`,
+		`CREATE INDEX IF NOT EXISTS idx_shipment_events_shipment_external ON shipment_events(shipment_id, external_event_id)`,
 	}
 	for _, q := range essentialSchemaFixes {
 		if execErr := db.Exec(q).Error; execErr != nil {
@@ -203,6 +310,29 @@ func MigrateDB(db *gorm.DB) error {
 		`UPDATE orders SET payment_method = 'cod' WHERE payment_method IS NULL OR payment_method = ''`,
 		`UPDATE orders SET payment_status = CASE WHEN payment_method = 'cod' THEN 'pending' ELSE 'initiated' END WHERE payment_status IS NULL OR payment_status = ''`,
 		`UPDATE orders SET expires_at = created_at + INTERVAL '30 minutes' WHERE expires_at IS NULL AND status = 'pending'`,
+		`UPDATE harvest_requests SET response_deadline = created_at + INTERVAL '48 hours' WHERE response_deadline IS NULL AND status = 'pending'`,
+		`UPDATE harvest_requests SET counter_expires_at = COALESCE(countered_at, updated_at) + INTERVAL '48 hours' WHERE counter_expires_at IS NULL AND status = 'countered'`,
+		`UPDATE orders SET platform_fee_percent = 5, platform_fee = ROUND((total_price * 0.05)::numeric, 2) WHERE platform_fee_percent IS NULL`,
+		`INSERT INTO product_images (product_id, url, thumbnail_url, alt_text, position, is_primary, created_at, updated_at)
+			SELECT p.id, p.image_url,
+				CASE WHEN p.image_url LIKE '/uploads/%' THEN regexp_replace(p.image_url, '([^/]+)$', 'thumb_\1') ELSE p.image_url END,
+				p.crop_name, 0, TRUE, NOW(), NOW()
+			FROM products p
+			WHERE p.image_url IS NOT NULL AND p.image_url <> ''
+			AND NOT EXISTS (SELECT 1 FROM product_images i WHERE i.product_id = p.id)`,
+		// Only uploads have a thumb_ file next to them; external images are their own thumbnail.
+		`UPDATE product_images SET thumbnail_url = url WHERE url NOT LIKE '/uploads/%' AND thumbnail_url IS DISTINCT FROM url`,
+		// Files uploaded before uploads were recorded belong to the farmer of the
+		// oldest listing showing them.
+		`INSERT INTO uploads (url, uploader_id, created_at)
+			SELECT DISTINCT ON (u.url) u.url, u.farmer_id, NOW()
+			FROM (
+				SELECT p.image_url AS url, p.farmer_id, p.created_at FROM products p WHERE p.image_url LIKE '/uploads/%'
+				UNION ALL
+				SELECT i.url, p.farmer_id, i.created_at FROM product_images i JOIN products p ON p.id = i.product_id WHERE i.url LIKE '/uploads/%'
+			) u
+			ORDER BY u.url, u.created_at
+			ON CONFLICT (url) DO NOTHING`,
 		`UPDATE orders SET admin_review_status = CASE WHEN dispute_status IN ('resolved', 'rejected') THEN 'closed' ELSE 'open' END WHERE admin_review_status IS NULL OR admin_review_status = ''`,
 	}
 	for _, q := range stateBackfills {
@@ -225,6 +355,87 @@ func MigrateDB(db *gorm.DB) error {
 		}
 	}
 
+	// Seed the crop taxonomy with the built-in categories, turn every other
+	// free-text category into a top-level category, and point listings at it.
+	taxonomyMigrations := []string{
+		`INSERT INTO taxonomy_nodes (kind, slug, name, path, depth, sort_order, is_active, created_at, updated_at)
+			VALUES ('category', 'vegetables', 'Vegetables', '', 0, 1, TRUE, NOW(), NOW()),
+				('category', 'fruits', 'Fruits', '', 0, 2, TRUE, NOW(), NOW()),
+				('category', 'grains', 'Grains', '', 0, 3, TRUE, NOW(), NOW()),
+				('category', 'dairy', 'Dairy', '', 0, 4, TRUE, NOW(), NOW()),
+				('category', 'honey', 'Honey', '', 0, 5, TRUE, NOW(), NOW())
+			ON CONFLICT (slug) DO NOTHING`,
+		`UPDATE products SET category = TRIM(BOTH '-' FROM regexp_replace(LOWER(TRIM(category)), '[^a-z0-9]+', '-', 'g'))
+			WHERE category_id IS NULL AND category IS NOT NULL AND category <> ''`,
+		`INSERT INTO taxonomy_nodes (kind, slug, name, path, depth, sort_order, is_active, created_at, updated_at)
+			SELECT DISTINCT 'category', category, INITCAP(REPLACE(category, '-', ' ')), '', 0, 100, TRUE, NOW(), NOW()
+			FROM products WHERE category_id IS NULL AND category IS NOT NULL AND category <> ''
+			ON CONFLICT (slug) DO NOTHING`,
+		`UPDATE taxonomy_nodes SET path = '/' || id || '/' WHERE parent_id IS NULL AND (path IS NULL OR path = '')`,
+		`UPDATE taxonomy_nodes SET shelf_life_days = CASE slug
+			WHEN 'vegetables' THEN 7 WHEN 'fruits' THEN 10 WHEN 'grains' THEN 180 WHEN 'dairy' THEN 3 WHEN 'honey' THEN 365 END
+			WHERE slug IN ('vegetables', 'fruits', 'grains', 'dairy', 'honey') AND (shelf_life_days IS NULL OR shelf_life_days = 0)`,
+		`UPDATE products SET category_id = t.id FROM taxonomy_nodes t
+			WHERE products.category_id IS NULL AND t.slug = products.category AND t.kind = 'category'`,
+		// Promotions, fee rules and tax rates limited to a category by its
+		// text point at the taxonomy node with that slug.
+		`UPDATE promotions SET category_id = t.id FROM taxonomy_nodes t
+			WHERE promotions.category_id IS NULL AND promotions.category <> '' AND t.slug = promotions.category`,
+		`UPDATE fee_rules SET category_id = t.id FROM taxonomy_nodes t
+			WHERE fee_rules.category_id IS NULL AND fee_rules.category <> '' AND t.slug = fee_rules.category`,
+		`UPDATE tax_rates SET category_id = t.id FROM taxonomy_nodes t
+			WHERE tax_rates.category_id IS NULL AND tax_rates.category <> '' AND t.slug = tax_rates.category`,
+	}
+	for _, q := range taxonomyMigrations {
+		if execErr := db.Exec(q).Error; execErr != nil {
+			log.Printf("taxonomy migration failed: %v", execErr)
+		}
+	}
+
+	// Rewrite free-text units such as "Kgs" or "qtl" to their registry code.
+	for _, unit := range models.Units() {
+		names := append([]string{unit.Code}, unit.Aliases...)
+		if execErr := db.Exec(`UPDATE products SET unit = ? WHERE LOWER(TRIM(unit)) IN ? AND unit <> ?`, unit.Code, names, unit.Code).Error; execErr != nil {
+			log.Printf("unit migration failed for %s: %v", unit.Code, execErr)
+		}
+	}
+
+	// Date listings created before harvest dates existed from when they were
+	// listed, and give them a best-before one crop shelf life (the nearest
+	// ancestor that sets one, else the 7 day default) from now. Counting from
+	// the listing date would expire most older listings on the first sweep,
+	// before their farmers had a chance to set real dates.
+	produceDateBackfills := []string{
+		`UPDATE products SET harvested_at = created_at WHERE harvested_at IS NULL`,
+		`UPDATE products p SET best_before = NOW() + make_interval(days => COALESCE((
+			SELECT a.shelf_life_days FROM taxonomy_nodes n
+			JOIN taxonomy_nodes a ON n.path LIKE a.path || '%'
+			WHERE n.id = COALESCE(p.crop_id, p.category_id) AND a.shelf_life_days > 0
+			ORDER BY a.depth DESC LIMIT 1), 7))
+			WHERE p.best_before IS NULL`,
+	}
+	for _, q := range produceDateBackfills {
+		if execErr := db.Exec(q).Error; execErr != nil {
+			log.Printf("produce date backfill failed: %v", execErr)
+		}
+	}
+
+	// Orders placed before they recorded their unit take the listing's,
+	// which is the best record of it left.
+	if execErr := db.Exec(`UPDATE orders SET unit = p.unit FROM products p
+		WHERE orders.product_id = p.id AND (orders.unit IS NULL OR orders.unit = '')`).Error; execErr != nil {
+		log.Printf("order unit backfill failed: %v", execErr)
+	}
+
+	// Open the inventory ledger of listings created before it existed with
+	// their stock on hand, so their movements add up to their quantity.
+	if execErr := db.Exec(`INSERT INTO stock_movements (product_id, kind, quantity, delta, balance_after, reference, note, created_at)
+		SELECT id, 'opening', quantity, quantity, quantity, 'migration', 'Stock on hand when the inventory ledger started', NOW()
+		FROM products p
+		WHERE NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.id)`).Error; execErr != nil {
+		log.Printf("stock ledger backfill failed: %v", execErr)
+	}
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }